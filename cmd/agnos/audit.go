@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const auditUsage = "usage: agnos audit verify"
+
+// runAudit implements the audit subcommand.
+func runAudit(args []string) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return errors.New(auditUsage)
+	}
+
+	checked, err := newService().AuditService.Verify()
+	if err != nil {
+		return fmt.Errorf("%w after %d valid entries", err, checked)
+	}
+	fmt.Printf("Verified %d audit log entries\n", checked)
+	return nil
+}
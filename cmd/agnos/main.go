@@ -1,66 +1,97 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
 
-	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-
-	"github.com/Markikie/agnos/internal/agnos/entity"
-	"github.com/Markikie/agnos/internal/agnos/handler"
-	"github.com/Markikie/agnos/internal/agnos/repository"
-	"github.com/Markikie/agnos/internal/agnos/router"
+	"github.com/Markikie/agnos/internal/agnos/app"
 	"github.com/Markikie/agnos/internal/agnos/service"
 )
 
+const usage = `usage: agnos [command]
+
+Commands:
+  serve                                   run the HTTP API, workers and HL7 listener (default)
+  migrate up | down [steps] | status      manage the database schema
+  staff create-admin --hospital HOSPITAL --username USERNAME [--password PASSWORD]
+                                          create an admin; the password is read from stdin if omitted
+  staff reset-password --hospital HOSPITAL --username USERNAME [--password PASSWORD]
+                                          set a staff member's password
+  patient import --hospital HOSPITAL [--format csv|ndjson] [--resume JOB_ID] FILE
+                                          import hospital records from FILE, or stdin for -;
+                                          --resume continues a stopped import of the same FILE
+  patient export --hospital HOSPITAL [--output FILE]
+                                          export the hospital's patients as NDJSON
+  patient verify-export FILE              check the signature of a patient's export bundle
+  retention run [--dry-run]               apply the hospitals' retention policies once;
+                                          --dry-run reports what would be purged
+  audit verify                            check the audit log hash chain
+`
+
 func main() {
-	// Database connection
-	dsn := "host=localhost user=agnos password=password dbname=agnos port=5432 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	command, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
 	}
 
-	// Auto migrate
-	err = db.AutoMigrate(&entity.Staff{}, &entity.Patient{})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch command {
+	case "serve":
+		err = app.NewApp().Run(ctx)
+	case "migrate":
+		err = runMigrate(ctx, app.ConnectDB(), args)
+	case "staff":
+		err = runStaff(args)
+	case "patient":
+		err = runPatient(ctx, args)
+	case "retention":
+		err = runRetention(ctx, args)
+	case "audit":
+		err = runAudit(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		slog.Error(command+" failed", "error", err)
+		os.Exit(1)
 	}
+}
 
-	// Initialize repositories
-	staffRepo := repository.NewStaffRepository(db)
-	patientRepo := repository.NewPatientRepository(db)
-
-	// Initialize services
-	staffService := service.NewStaffService(staffRepo)
-	patientService := service.NewPatientService(patientRepo)
-
-	// Initialize handlers
-	staffHandler := handler.NewStaffHandler(staffService)
-	patientHandler := handler.NewPatientHandler(patientService)
-
-	// Initialize Gin
-	app := gin.Default()
-
-	// Health check endpoint
-	app.GET("/", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Agnos Hospital Middleware API",
-			"status":  "healthy",
-		})
-	})
-
-	// Setup routes
-	router.NewStaffRouter(app, staffHandler)
-	router.NewPatientRouter(app, patientHandler)
-
-	// Start server
-	log.Println("Starting server on :8081...")
-	err = app.Run(":8081")
+// newService connects to the database and builds the service layer the HTTP
+// API uses, so commands apply the same validation rules.
+func newService() *app.Service {
+	config := app.NewConfig()
+	return app.NewService(config, app.NewRepository(config))
+}
 
+// audit records an operator command in the audit log, with status 0 when it
+// succeeded and 1 when it failed. Failing to record is logged, not fatal.
+func audit(services *app.Service, hospital, action string, err error) {
+	status := 0
 	if err != nil {
-		log.Fatal("Failed to start server:", err)
+		status = 1
+	}
+	record := service.AuditRecord{Actor: cliActor(), Hospital: hospital, Action: action, Status: status}
+	if auditErr := services.AuditService.Record(record); auditErr != nil {
+		slog.Error("audit failed", "action", action, "error", auditErr)
+	}
+}
+
+// cliActor names the operator running the command.
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
 	}
+	return "cli:unknown"
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+const patientUsage = "usage: agnos patient import --hospital HOSPITAL [--format csv|ndjson] [--resume JOB_ID] FILE | export --hospital HOSPITAL [--output FILE] | verify-export FILE"
+
+// runPatient implements the patient subcommand.
+func runPatient(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(patientUsage)
+	}
+	switch args[0] {
+	case "import":
+		return importPatients(ctx, args[1:])
+	case "export":
+		return exportPatients(ctx, args[1:])
+	case "verify-export":
+		return verifyExport(args[1:])
+	default:
+		return errors.New(patientUsage)
+	}
+}
+
+func importPatients(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("patient import", flag.ContinueOnError)
+	hospital := flags.String("hospital", "", "hospital the records come from")
+	format := flags.String("format", "", "csv or ndjson; taken from the file extension when omitted")
+	resume := flags.String("resume", "", "ID of a stopped import job to continue with the same FILE")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *hospital == "" || flags.NArg() != 1 {
+		return errors.New(patientUsage)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = importFormat(path)
+	}
+	in := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	services := newService()
+	var report *service.ImportReport
+	var err error
+	if *resume != "" {
+		report, err = services.ImportService.ResumeImport(ctx, *resume, *hospital, in)
+	} else {
+		report, err = services.ImportService.ImportPatients(ctx, service.ImportRequest{
+			Hospital:  *hospital,
+			Format:    *format,
+			StartedBy: cliActor(),
+		}, in)
+	}
+	audit(services, *hospital, "patient import", err)
+	if report == nil {
+		return err
+	}
+
+	job := report.Job
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", rowErr.Line, rowErr.Kind, rowErr.Error)
+	}
+	if len(report.Errors) < job.Failed+job.Duplicates {
+		fmt.Fprintf(os.Stderr, "... and %d more\n", job.Failed+job.Duplicates-len(report.Errors))
+	}
+	fmt.Printf("Import %s %s: created %d, updated %d, duplicates %d, failed %d\n",
+		job.ID, job.Status, job.Created, job.Updated, job.Duplicates, job.Failed)
+	if err != nil {
+		return fmt.Errorf("%w; continue with: agnos patient import --hospital %s --resume %s %s", err, *hospital, job.ID, path)
+	}
+	if job.Failed > 0 {
+		return fmt.Errorf("%d records failed validation", job.Failed)
+	}
+	return nil
+}
+
+// importFormat guesses the format of path from its extension.
+func importFormat(path string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case "jsonl":
+		return service.ImportFormatNDJSON
+	default:
+		return ext
+	}
+}
+
+func exportPatients(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("patient export", flag.ContinueOnError)
+	hospital := flags.String("hospital", "", "hospital whose patients are exported")
+	output := flags.String("output", "-", "file to write, or - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *hospital == "" || flags.NArg() > 0 {
+		return errors.New(patientUsage)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	// Each batch of patients is audited as it is exported.
+	exported, err := newService().ExportService.ExportPatients(ctx, service.ExportRequest{
+		Hospital: *hospital,
+		Audit:    service.AuditRecord{Actor: cliActor(), Hospital: *hospital, Action: "patient export"},
+	}, out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d patients\n", exported)
+	return nil
+}
+
+// verifyExport checks the signature of a patient's data portability bundle
+// as downloaded from GET /patient/:id/export.
+func verifyExport(args []string) error {
+	if len(args) != 1 {
+		return errors.New(patientUsage)
+	}
+	bundle, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	if err := service.VerifyPatientBundle(bundle, agnos.Env.ExportSigningKey); err != nil {
+		return err
+	}
+	fmt.Println("Bundle signature is valid")
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+const retentionUsage = "usage: agnos retention run [--dry-run]"
+
+// runRetention implements the retention subcommand.
+func runRetention(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "run" {
+		return errors.New(retentionUsage)
+	}
+
+	flags := flag.NewFlagSet("retention run", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be purged without purging it")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New(retentionUsage)
+	}
+
+	report, err := newService().RetentionService.ApplyRetention(ctx, *dryRun)
+	if report != nil {
+		if report.DryRun {
+			fmt.Println("Dry run: nothing was purged")
+		}
+		if len(report.Policies) == 0 {
+			fmt.Println("No retention policies are configured")
+		}
+		for _, policy := range report.Policies {
+			fmt.Printf("%s %s (idle %s): %d links removed, %d patients erased\n",
+				policy.Hospital, policy.Source, policy.MaxIdle, policy.LinksRemoved, policy.PatientsErased)
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+)
+
+const staffUsage = "usage: agnos staff create-admin | reset-password --hospital HOSPITAL --username USERNAME [--password PASSWORD]"
+
+// runStaff implements the staff subcommand.
+func runStaff(args []string) error {
+	if len(args) == 0 || (args[0] != "create-admin" && args[0] != "reset-password") {
+		return errors.New(staffUsage)
+	}
+
+	flags := flag.NewFlagSet("staff "+args[0], flag.ContinueOnError)
+	hospital := flags.String("hospital", "", "hospital the staff member belongs to")
+	username := flags.String("username", "", "staff username")
+	password := flags.String("password", "", "new password; read from stdin when omitted")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *hospital == "" || *username == "" || flags.NArg() > 0 {
+		return errors.New(staffUsage)
+	}
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		var err error
+		if *password, err = readPassword(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	services := newService()
+	var err error
+	if args[0] == "create-admin" {
+		var staff *entity.Staff
+		if staff, err = services.StaffService.CreateAdmin(*username, *password, *hospital); err == nil {
+			fmt.Printf("Created admin %s (%s) at %s\n", staff.Username, staff.ID, staff.Hospital)
+		}
+	} else if err = services.StaffService.ResetPassword(*username, *hospital, *password); err == nil {
+		fmt.Printf("Reset the password of %s at %s\n", *username, *hospital)
+	}
+	audit(services, *hospital, "staff "+args[0]+" "+*username, err)
+	return err
+}
+
+// readPassword reads the first line of r, so that a password need not appear
+// in the process list or shell history.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,9 @@
+package request
+
+import "encoding/json"
+
+type JobRequest struct {
+	Kind string `json:"kind" binding:"required"`
+	// Payload is the job's input, specific to its kind.
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
@@ -1,12 +1,23 @@
 package request
 
 type PatientSearchRequest struct {
-	NationalID   string `json:"national_id,omitempty"`
-	PassportID   string `json:"passport_id,omitempty"`
-	FirstName    string `json:"first_name,omitempty"`
-	MiddleName   string `json:"middle_name,omitempty"`
-	LastName     string `json:"last_name,omitempty"`
-	DateOfBirth  string `json:"date_of_birth,omitempty"`
-	PhoneNumber  string `json:"phone_number,omitempty"`
-	Email        string `json:"email,omitempty"`
+	NationalID string `json:"national_id,omitempty"`
+	PassportID string `json:"passport_id,omitempty"`
+	// PassportCountry is the ISO 3166-1 alpha-3 code of the passport's
+	// issuing country, used to validate PassportID.
+	PassportCountry string `json:"passport_country,omitempty"`
+	FirstName       string `json:"first_name,omitempty"`
+	MiddleName      string `json:"middle_name,omitempty"`
+	LastName        string `json:"last_name,omitempty"`
+	DateOfBirth     string `json:"date_of_birth,omitempty"`
+	// DateOfBirthFrom and DateOfBirthTo bound the date of birth, inclusive.
+	// Partial dates cover their whole year or month.
+	DateOfBirthFrom string `json:"date_of_birth_from,omitempty"`
+	DateOfBirthTo   string `json:"date_of_birth_to,omitempty"`
+	BirthYear       int    `json:"birth_year,omitempty"`
+	// AgeMin and AgeMax bound the age in whole years today, inclusive.
+	AgeMin      *int   `json:"age_min,omitempty"`
+	AgeMax      *int   `json:"age_max,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Email       string `json:"email,omitempty"`
 }
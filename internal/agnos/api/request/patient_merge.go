@@ -0,0 +1,10 @@
+package request
+
+type PatientMergeRequest struct {
+	SurvivorID string `json:"survivor_id" binding:"required"`
+	RetiredID  string `json:"retired_id" binding:"required"`
+	// Prefer maps a column to "survivor" or "retired" to override the default
+	// survivorship, which keeps the survivor's non-empty values.
+	Prefer map[string]string `json:"prefer,omitempty"`
+	Reason string            `json:"reason,omitempty"`
+}
@@ -0,0 +1,33 @@
+package request
+
+type WebhookRequest struct {
+	Version string         `json:"version"`
+	Events  []WebhookEvent `json:"events"`
+}
+
+type WebhookEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt string          `json:"occurred_at,omitempty"`
+	Patient    WebhookPatient  `json:"patient"`
+	Merged     *WebhookPatient `json:"merged,omitempty"`
+}
+
+type WebhookPatient struct {
+	FirstNameTH  string `json:"first_name_th"`
+	MiddleNameTH string `json:"middle_name_th"`
+	LastNameTH   string `json:"last_name_th"`
+	FirstNameEN  string `json:"first_name_en"`
+	MiddleNameEN string `json:"middle_name_en"`
+	LastNameEN   string `json:"last_name_en"`
+	DateOfBirth  string `json:"date_of_birth"`
+	PatientHN    string `json:"patient_hn"`
+	NationalID   string `json:"national_id"`
+	PassportID   string `json:"passport_id"`
+	// PassportCountry is the ISO 3166-1 alpha-3 code of the passport's issuing
+	// country, used to validate PassportID.
+	PassportCountry string `json:"passport_country,omitempty"`
+	PhoneNumber     string `json:"phone_number"`
+	Email           string `json:"email"`
+	Gender          string `json:"gender"`
+}
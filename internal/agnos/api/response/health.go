@@ -0,0 +1,18 @@
+package response
+
+import "time"
+
+type HealthResponse struct {
+	Status     string                       `json:"status"`
+	Components map[string]ComponentResponse `json:"components,omitempty"`
+}
+
+type ComponentResponse struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	// Hospital APIs only.
+	ConsecutiveFailures *int       `json:"consecutive_failures,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+}
@@ -1,8 +1,15 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Search struct {
+	// ID is how clients address a patient found by a search in the
+	// /patient/:id routes.
+	ID           uuid.UUID `json:"id"`
 	FirstNameTH  string    `json:"first_name_th"`
 	MiddleNameTH string    `json:"middle_name_th"`
 	LastNameTH   string    `json:"last_name_th"`
@@ -16,4 +23,33 @@ type Search struct {
 	PhoneNumber  string    `json:"phone_number"`
 	Email        string    `json:"email"`
 	Gender       string    `json:"gender"`
+	// Stale is true when any hospital copy has not been synced recently.
+	Stale     bool              `json:"stale"`
+	Hospitals []PatientHospital `json:"hospitals"`
+}
+
+type PatientHospital struct {
+	Hospital     string     `json:"hospital"`
+	PatientHN    string     `json:"patient_hn"`
+	Source       string     `json:"source"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	Stale        bool       `json:"stale"`
+}
+
+type SearchSource struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+type PatientSearchResponse struct {
+	Patients []Search       `json:"patients"`
+	Count    int            `json:"count"`
+	Sources  []SearchSource `json:"sources"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+type PatientRefreshResponse struct {
+	Patient Search   `json:"patient"`
+	Changed []string `json:"changed"`
 }
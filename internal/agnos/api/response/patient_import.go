@@ -0,0 +1,33 @@
+package response
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+type ImportJobResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Hospital string    `json:"hospital"`
+	Format   string    `json:"format"`
+	Status   string    `json:"status"`
+	// JobID is the background job that runs the import, or last ran it.
+	JobID      *uuid.UUID `json:"job_id,omitempty"`
+	Checkpoint int        `json:"checkpoint"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Duplicates int        `json:"duplicates"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	StartedBy  string     `json:"started_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Errors are the job's first row errors in line order.
+	Errors []ImportRowError `json:"errors"`
+}
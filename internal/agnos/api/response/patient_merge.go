@@ -0,0 +1,42 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PatientMerge struct {
+	ID            uuid.UUID       `json:"id"`
+	SurvivorID    uuid.UUID       `json:"survivor_id"`
+	RetiredID     uuid.UUID       `json:"retired_id"`
+	RetiredRecord json.RawMessage `json:"retired_record"`
+	Survivorship  json.RawMessage `json:"survivorship"`
+	MergedBy      string          `json:"merged_by"`
+	Reason        string          `json:"reason,omitempty"`
+	MergedAt      time.Time       `json:"merged_at"`
+}
+
+type PatientMergeResponse struct {
+	Patient Search       `json:"patient"`
+	Merge   PatientMerge `json:"merge"`
+}
+
+type PatientMergeHistoryResponse struct {
+	Merges []PatientMerge `json:"merges"`
+}
+
+type DuplicateCandidate struct {
+	ID         uuid.UUID `json:"id"`
+	Score      float64   `json:"score"`
+	Reasons    []string  `json:"reasons"`
+	Status     string    `json:"status"`
+	DetectedAt time.Time `json:"detected_at"`
+	Patients   []Search  `json:"patients"`
+}
+
+type DuplicateListResponse struct {
+	Candidates []DuplicateCandidate `json:"candidates"`
+	Count      int                  `json:"count"`
+}
@@ -0,0 +1,20 @@
+package response
+
+// Problem is an error response, an RFC 7807 problem details object with the
+// extension members code, request_id and errors.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is machine-readable and stable; Type is derived from it.
+	Code      string         `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []ProblemField `json:"errors,omitempty"`
+}
+
+type ProblemField struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -1,5 +1,12 @@
 package response
 
+import "github.com/google/uuid"
+
+type CreateStaffResponse struct {
+	Message string    `json:"message"`
+	StaffID uuid.UUID `json:"staff_id"`
+}
+
 type LoginStaffResponse struct {
 	AccessToken string `json:"access_token"`
 }
@@ -0,0 +1,12 @@
+package response
+
+type WebhookEventResult struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	PatientID string `json:"patient_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type WebhookResponse struct {
+	Results []WebhookEventResult `json:"results"`
+}
@@ -1,33 +1,201 @@
 package app
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
 
 	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/hl7"
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/tracing"
+	"github.com/Markikie/agnos/internal/agnos/worker"
 	"github.com/caarlos0/env/v11"
 	"github.com/gin-gonic/gin"
 )
 
 func init() {
 	if err := env.Parse(&agnos.Env); err != nil {
-		log.Fatal(err)
+		fatal("parse environment", err)
 	}
+	slog.SetDefault(logging.New(os.Stderr, agnos.Env.LogLevel))
+}
+
+// fatal logs an error the service cannot start with and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 type App struct {
-	Config *Config
+	Config          *Config
+	Engine          *gin.Engine
+	SyncWorker      *worker.SyncWorker
+	DuplicateWorker *worker.DuplicateWorker
+	RetentionWorker *worker.RetentionWorker
+	JobWorker       *worker.JobWorker
+	// HL7Server is nil when no MLLP listen address is configured.
+	HL7Server *hl7.Server
+	// ShutdownTracing flushes the spans not exported yet.
+	ShutdownTracing func(context.Context) error
 }
 
 func NewApp() *App {
+	if agnos.Env.ExportSigningKey == "" {
+		fatal("check environment", service.ErrExportSigningKeyUnset)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), agnos.Env.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		fatal("set up tracing", err)
+	}
 	config := NewConfig()
 	ginEngine := gin.New()
 
 	NewMiddleware(ginEngine)
 	repository := NewRepository(config)
-	service := NewService(repository)
+	service := NewService(config, repository)
 	handler := NewHandler(service)
-	NewRouter(ginEngine, handler)
+	NewRouter(ginEngine, handler, service)
 	return &App{
-		Config: config,
+		Config:          config,
+		Engine:          ginEngine,
+		SyncWorker:      NewSyncWorker(config, service),
+		DuplicateWorker: NewDuplicateWorker(service),
+		RetentionWorker: NewRetentionWorker(service),
+		JobWorker:       NewJobWorker(service),
+		HL7Server:       NewHL7Server(handler),
+		ShutdownTracing: shutdownTracing,
+	}
+}
+
+// Run serves HTTP on agnos.Env.Port, alongside the background workers and the
+// HL7 listener, until ctx is cancelled or the server fails. It then stops
+// accepting requests and gives in-flight requests, workers and HL7 messages up
+// to agnos.Env.ShutdownTimeout to finish before closing the database pool.
+func (a *App) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    ":" + agnos.Env.Port,
+		Handler: a.Engine,
+	}
+
+	// Background work outlives ctx so it can be stopped after the server.
+	backgroundCtx, stopBackground := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopBackground()
+
+	serveErrs := make(chan error, 2)
+	var background sync.WaitGroup
+	background.Add(4)
+	go func() {
+		defer background.Done()
+		a.SyncWorker.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.DuplicateWorker.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.RetentionWorker.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.JobWorker.Run(backgroundCtx)
+	}()
+	if a.HL7Server != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			slog.Info("starting HL7 MLLP listener", "addr", a.HL7Server.Addr)
+			if err := a.HL7Server.ListenAndServe(backgroundCtx); err != nil {
+				serveErrs <- fmt.Errorf("hl7 listener: %w", err)
+			}
+		}()
+	}
+
+	go func() {
+		slog.Info("starting server", "addr", server.Addr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErrs <- err
+		}
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err = <-serveErrs:
+		slog.Error("shutting down after error", "error", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), agnos.Env.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		err = errors.Join(err, fmt.Errorf("drain requests: %w", shutdownErr))
+	}
+
+	stopBackground()
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		err = errors.Join(err, errors.New("background work did not stop in time"))
+	}
+
+	if db, dbErr := a.Config.DB.DB(); dbErr != nil {
+		err = errors.Join(err, dbErr)
+	} else if dbErr := db.Close(); dbErr != nil {
+		err = errors.Join(err, fmt.Errorf("close database: %w", dbErr))
+	}
+	if tracingErr := a.ShutdownTracing(shutdownCtx); tracingErr != nil {
+		err = errors.Join(err, fmt.Errorf("flush spans: %w", tracingErr))
+	}
+	return err
+}
+
+func NewSyncWorker(config *Config, service *Service) *worker.SyncWorker {
+	return worker.NewSyncWorker(
+		service.JobService,
+		config.Hospitals,
+		agnos.Env.Sync.Interval,
+	)
+}
+
+func NewDuplicateWorker(service *Service) *worker.DuplicateWorker {
+	return worker.NewDuplicateWorker(
+		service.MergeService,
+		agnos.Env.Dedup.Interval,
+		agnos.Env.Dedup.BatchSize,
+	)
+}
+
+func NewRetentionWorker(service *Service) *worker.RetentionWorker {
+	return worker.NewRetentionWorker(
+		service.JobService,
+		agnos.Env.Retention.Interval,
+		agnos.Env.Retention.DryRun,
+	)
+}
+
+func NewJobWorker(service *Service) *worker.JobWorker {
+	return worker.NewJobWorker(
+		service.JobService,
+		agnos.Env.Jobs.Workers,
+		agnos.Env.Jobs.PollInterval,
+	)
+}
+
+func NewHL7Server(handler *Handler) *hl7.Server {
+	if agnos.Env.HL7.Addr == "" {
+		return nil
 	}
+	return hl7.NewServer(agnos.Env.HL7.Addr, &handler.HL7Handler, agnos.Env.HL7.IdleTimeout)
 }
@@ -1,10 +1,14 @@
 package app
 
 import (
+	"context"
 	"fmt"
-	"log"
 
 	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/Markikie/agnos/internal/agnos/migration"
+	"github.com/Markikie/agnos/internal/agnos/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -12,17 +16,22 @@ import (
 )
 
 type Config struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Hospitals *hospital.Registry
 }
 
 func NewConfig() *Config {
+	db := ConnectDB()
+	CheckSchema(db)
+	RegisterDBMetrics(db)
 	return &Config{
-		DB: ConnectDB(),
+		DB:        db,
+		Hospitals: NewHospitalRegistry(),
 	}
 }
 
 func ConnectDB() *gorm.DB {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Shanghai",
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
 		agnos.Env.Database.Host,
 		agnos.Env.Database.Port,
 		agnos.Env.Database.User,
@@ -34,7 +43,41 @@ func ConnectDB() *gorm.DB {
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
-		log.Fatal(err)
+		fatal("connect database", err)
+	}
+	if err := dbClient.Use(tracing.GORMPlugin{}); err != nil {
+		fatal("trace database", err)
 	}
 	return dbClient
 }
+
+// CheckSchema refuses to start on a database schema older than this binary;
+// migrations are applied with agnos migrate up.
+func CheckSchema(db *gorm.DB) {
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		fatal("open migrations", err)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		fatal("check schema", err)
+	}
+}
+
+// RegisterDBMetrics exports the stats of the database connection pool.
+func RegisterDBMetrics(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = metrics.RegisterDB(sqlDB)
+	}
+	if err != nil {
+		fatal("register database metrics", err)
+	}
+}
+
+func NewHospitalRegistry() *hospital.Registry {
+	registry, err := hospital.LoadRegistry(agnos.Env.HospitalsConfig)
+	if err != nil {
+		fatal("load hospitals config", err)
+	}
+	return registry
+}
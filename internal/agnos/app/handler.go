@@ -1,15 +1,28 @@
 package app
 
-import "github.com/Markikie/agnos/internal/agnos/handler"
+import (
+	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/handler"
+)
 
 type Handler struct {
-	StaffHandler   handler.StaffHandler
-	PatientHandler handler.PatientHandler
+	StaffHandler       handler.StaffHandler
+	PatientHandler     handler.PatientHandler
+	IntegrationHandler handler.IntegrationHandler
+	FHIRHandler        handler.FHIRHandler
+	HL7Handler         handler.HL7Handler
+	JobHandler         handler.JobHandler
+	HealthHandler      handler.HealthHandler
 }
 
 func NewHandler(service *Service) *Handler {
 	return &Handler{
-		StaffHandler:   handler.NewStaffHandler(service.StaffService),
-		PatientHandler: handler.NewPatientHandler(service.PatientService),
+		StaffHandler:       handler.NewStaffHandler(service.StaffService, agnos.Env.JWTSecret),
+		PatientHandler:     handler.NewPatientHandler(service.PatientService, service.MergeService, service.ImportService, service.ExportService, service.RetentionService),
+		IntegrationHandler: handler.NewIntegrationHandler(service.IntegrationService),
+		FHIRHandler:        handler.NewFHIRHandler(service.PatientService),
+		HL7Handler:         handler.NewHL7Handler(service.IntegrationService),
+		JobHandler:         handler.NewJobHandler(service.JobService),
+		HealthHandler:      handler.NewHealthHandler(service.HealthService),
 	}
 }
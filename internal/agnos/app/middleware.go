@@ -1,10 +1,18 @@
 package app
 
 import (
+	"github.com/Markikie/agnos/internal/agnos/middleware"
 	middlewareConfig "github.com/Markikie/agnos/internal/agnos/middleware/config"
 	"github.com/gin-gonic/gin"
 )
 
 func NewMiddleware(ginEngine *gin.Engine) {
-	ginEngine.Use(middlewareConfig.Logger())
+	ginEngine.Use(
+		middlewareConfig.RequestID(),
+		middlewareConfig.Tracing(),
+		middlewareConfig.Logger(),
+		middlewareConfig.Metrics(),
+		middleware.Errors(),
+		middlewareConfig.Recovery(),
+	)
 }
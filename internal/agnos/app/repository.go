@@ -3,13 +3,29 @@ package app
 import "github.com/Markikie/agnos/internal/agnos/repository"
 
 type Repository struct {
-	PatientRepository repository.PatientRepository
-	StaffRepository   repository.StaffRepository
+	PatientRepository      repository.PatientRepository
+	StaffRepository        repository.StaffRepository
+	WebhookEventRepository repository.WebhookEventRepository
+	PatientMergeRepository repository.PatientMergeRepository
+	QuarantineRepository   repository.QuarantineRepository
+	AuditRepository        repository.AuditRepository
+	ImportJobRepository    repository.ImportJobRepository
+	RetentionRepository    repository.RetentionRepository
+	JobRepository          repository.JobRepository
+	HealthRepository       repository.HealthRepository
 }
 
 func NewRepository(config *Config) *Repository {
 	return &Repository{
-		PatientRepository: repository.NewPatientRepository(config.DB),
-		StaffRepository:   repository.NewStaffRepository(config.DB),
+		PatientRepository:      repository.NewPatientRepository(config.DB),
+		StaffRepository:        repository.NewStaffRepository(config.DB),
+		WebhookEventRepository: repository.NewWebhookEventRepository(config.DB),
+		PatientMergeRepository: repository.NewPatientMergeRepository(config.DB),
+		QuarantineRepository:   repository.NewQuarantineRepository(config.DB),
+		AuditRepository:        repository.NewAuditRepository(config.DB),
+		ImportJobRepository:    repository.NewImportJobRepository(config.DB),
+		RetentionRepository:    repository.NewRetentionRepository(config.DB),
+		JobRepository:          repository.NewJobRepository(config.DB),
+		HealthRepository:       repository.NewHealthRepository(config.DB),
 	}
 }
@@ -1,10 +1,44 @@
 package app
 
 import (
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/openapi"
 	"github.com/Markikie/agnos/internal/agnos/router"
+	"github.com/Markikie/agnos/internal/agnos/service"
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(ginEngine *gin.Engine, handler *Handler) {
+var errRouteNotFound = service.NewError(service.ErrNotFound, "route_not_found", "no route matches the request")
+
+func NewRouter(ginEngine *gin.Engine, handler *Handler, service *Service) {
+	// Health is reported by /healthz and /readyz, see NewHealthRouter.
+	ginEngine.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Agnos Hospital Middleware API",
+		})
+	})
+
+	// Prometheus metrics, scraped from inside the deployment; nginx does not
+	// expose them.
+	ginEngine.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// The API description, generated from the request and response types,
+	// and a page rendering it.
+	ginEngine.GET("/openapi.json", gin.WrapH(openapi.Handler()))
+	ginEngine.GET("/docs", gin.WrapH(openapi.DocsHandler()))
+
 	router.NewStaffRouter(ginEngine, handler.StaffHandler)
+	router.NewPatientRouter(ginEngine, handler.PatientHandler, agnos.Env.JWTSecret, service.AuditService)
+	router.NewIntegrationRouter(ginEngine, handler.IntegrationHandler)
+	router.NewFHIRRouter(ginEngine, handler.FHIRHandler, agnos.Env.JWTSecret, service.AuditService)
+	router.NewJobRouter(ginEngine, handler.JobHandler, agnos.Env.JWTSecret)
+	router.NewHealthRouter(ginEngine, handler.HealthHandler)
+
+	ginEngine.NoRoute(func(c *gin.Context) {
+		middleware.AbortWithProblem(c, errRouteNotFound)
+	})
 }
@@ -1,17 +1,89 @@
 package app
 
 import (
+	"github.com/Markikie/agnos/internal/agnos"
+	"github.com/Markikie/agnos/internal/agnos/migration"
 	"github.com/Markikie/agnos/internal/agnos/service"
 )
 
 type Service struct {
-	PatientService service.PatientService
-	StaffService   service.StaffService
+	PatientService     service.PatientService
+	StaffService       service.StaffService
+	IntegrationService service.IntegrationService
+	MergeService       service.MergeService
+	AuditService       service.AuditService
+	ImportService      service.ImportService
+	ExportService      service.ExportService
+	RetentionService   service.RetentionService
+	JobService         service.JobService
+	HealthService      service.HealthService
 }
 
-func NewService(repository *Repository) *Service {
+func NewService(config *Config, repository *Repository) *Service {
+	auditService := service.NewAuditService(repository.AuditRepository)
+	patientService := service.NewPatientService(
+		repository.PatientRepository,
+		repository.PatientMergeRepository,
+		repository.QuarantineRepository,
+		config.Hospitals,
+		agnos.Env.Sync.StaleAfter,
+	)
+	retentionService := service.NewRetentionService(
+		repository.RetentionRepository,
+		patientService,
+		auditService,
+		config.Hospitals,
+	)
+	jobService := service.NewJobService(
+		repository.JobRepository,
+		agnos.Env.Jobs.Lease,
+		agnos.Env.Jobs.MaxAttempts,
+		agnos.Env.Jobs.RetryBackoff,
+		agnos.Env.Jobs.OutputTTL,
+	)
+	importService := service.NewImportService(repository.ImportJobRepository, jobService, config.Hospitals, agnos.Env.Import.BatchSize)
+	exportService := service.NewExportService(
+		repository.PatientRepository,
+		repository.PatientMergeRepository,
+		repository.AuditRepository,
+		auditService,
+		jobService,
+		config.Hospitals,
+		agnos.Env.ExportSigningKey,
+	)
+	jobService.Register(service.JobKindRetention, service.NewRetentionJob(retentionService, jobService))
+	jobService.Register(service.JobKindSync, service.NewSyncJob(patientService, config.Hospitals, agnos.Env.Sync.BatchSize))
+	jobService.Register(service.JobKindImport, service.NewImportJob(importService, jobService))
+	jobService.Register(service.JobKindExport, service.NewExportJob(exportService, jobService))
+	migrator, err := migration.NewMigrator(config.DB)
+	if err != nil {
+		fatal("open migrations", err)
+	}
 	return &Service{
-		PatientService: service.NewPatientService(repository.PatientRepository),
+		PatientService: patientService,
 		StaffService:   service.NewStaffService(repository.StaffRepository),
+		IntegrationService: service.NewIntegrationService(
+			repository.PatientRepository,
+			repository.PatientMergeRepository,
+			repository.WebhookEventRepository,
+			repository.QuarantineRepository,
+			config.Hospitals,
+		),
+		MergeService: service.NewMergeService(
+			repository.PatientRepository,
+			repository.PatientMergeRepository,
+			agnos.Env.Dedup.Threshold,
+		),
+		AuditService:     auditService,
+		ImportService:    importService,
+		ExportService:    exportService,
+		RetentionService: retentionService,
+		JobService:       jobService,
+		HealthService: service.NewHealthService(
+			repository.HealthRepository,
+			migrator,
+			config.Hospitals,
+			agnos.Env.Health.Timeout,
+		),
 	}
 }
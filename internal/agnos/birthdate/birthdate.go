@@ -0,0 +1,156 @@
+// Package birthdate parses dates of birth as Thai hospital systems write
+// them: in Buddhist Era or Common Era years, in several layouts, and possibly
+// only to the year or month.
+package birthdate
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuddhistEraOffset is the difference between Buddhist Era and Common Era
+// years: 2533 BE is 1990 CE.
+const BuddhistEraOffset = 543
+
+// buddhistEraFrom is the smallest year read as Buddhist Era. No living
+// patient was born in 2400 CE or later, nor before 1857 CE (2400 BE).
+const buddhistEraFrom = 2400
+
+var (
+	ErrFormat  = errors.New("is not a date in a supported format")
+	ErrFuture  = errors.New("is in the future")
+	ErrPartial = errors.New("must be a full date")
+)
+
+// Precision is how much of a date of birth is known.
+type Precision int
+
+const (
+	Year Precision = iota + 1
+	Month
+	Day
+)
+
+// Date is a possibly partial date of birth.
+type Date struct {
+	// Time is midnight UTC of the first day the date covers.
+	Time      time.Time
+	Precision Precision
+}
+
+// Range returns the first and last day the date covers: the whole year or
+// month of a partial date, or the day itself.
+func (d Date) Range() (time.Time, time.Time) {
+	switch d.Precision {
+	case Year:
+		return d.Time, d.Time.AddDate(1, 0, -1)
+	case Month:
+		return d.Time, d.Time.AddDate(0, 1, -1)
+	default:
+		return d.Time, d.Time
+	}
+}
+
+// DefaultLayouts are the layouts, in time.Parse notation, accepted when none
+// are configured: ISO 8601, day-first as written in Thailand, HL7's compact
+// form and their partial variants.
+var DefaultLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"20060102",
+	"2006-01",
+	"01/2006",
+	"2006",
+}
+
+// Parser parses dates of birth in a fixed list of layouts, the first matching
+// layout winning. A nil Parser uses DefaultLayouts.
+type Parser struct {
+	layouts []string
+}
+
+func NewParser(layouts []string) *Parser {
+	if len(layouts) == 0 {
+		layouts = DefaultLayouts
+	}
+	return &Parser{layouts: layouts}
+}
+
+var digitRun = regexp.MustCompile(`[0-9]+`)
+
+// Parse parses value in the first layout that matches it. Buddhist Era years
+// are converted to Common Era, and dates after today are rejected.
+func (p *Parser) Parse(value string) (Date, error) {
+	layouts := DefaultLayouts
+	if p != nil {
+		layouts = p.layouts
+	}
+
+	value = toCommonEra(strings.TrimSpace(value))
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		date := Date{Time: t, Precision: layoutPrecision(layout)}
+		if date.Time.After(time.Now()) {
+			return Date{}, ErrFuture
+		}
+		return date, nil
+	}
+	return Date{}, ErrFormat
+}
+
+// ParseFull is Parse for values that must be a full date.
+func (p *Parser) ParseFull(value string) (time.Time, error) {
+	date, err := p.Parse(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date.Precision != Day {
+		return time.Time{}, ErrPartial
+	}
+	return date.Time, nil
+}
+
+// Parse parses value with the default layouts.
+func Parse(value string) (Date, error) {
+	var p *Parser
+	return p.Parse(value)
+}
+
+// toCommonEra rewrites Buddhist Era years in value, whether written alone or
+// leading a compact YYYYMMDD date. It is applied before parsing because the
+// eras' leap years differ: 29/02/2535 BE (1992) exists, but 2535 CE is not a
+// leap year.
+func toCommonEra(value string) string {
+	return digitRun.ReplaceAllStringFunc(value, func(digits string) string {
+		if len(digits) != 4 && len(digits) != 8 {
+			return digits
+		}
+		year, _ := strconv.Atoi(digits[:4])
+		if year < buddhistEraFrom {
+			return digits
+		}
+		return strconv.Itoa(year-BuddhistEraOffset) + digits[4:]
+	})
+}
+
+// layoutPrecision tells from the elements of layout which parts of a date it
+// carries.
+func layoutPrecision(layout string) Precision {
+	rest := strings.ReplaceAll(layout, "2006", "")
+	switch {
+	case strings.Contains(rest, "2"):
+		return Day
+	case strings.Contains(rest, "1") || strings.Contains(rest, "Jan"):
+		return Month
+	default:
+		return Year
+	}
+}
@@ -0,0 +1,73 @@
+package birthdate
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value     string
+		want      time.Time
+		precision Precision
+		err       error
+	}{
+		{value: "1990-01-31", want: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "31/01/1990", want: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "31/01/2533", want: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "2533-01-31", want: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "25330131", want: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "5/3/1990", want: time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "29/02/2535", want: time.Date(1992, 2, 29, 0, 0, 0, 0, time.UTC), precision: Day},
+		{value: "1990-03", want: time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC), precision: Month},
+		{value: "03/2533", want: time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC), precision: Month},
+		{value: "2533", want: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), precision: Year},
+		{value: "1990/31/01", err: ErrFormat},
+		{value: "31/02/1990", err: ErrFormat},
+		{value: strconv.Itoa(time.Now().Year() + 1), err: ErrFuture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			date, err := Parse(tt.value)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, date.Time)
+			assert.Equal(t, tt.precision, date.Precision)
+		})
+	}
+}
+
+func TestParser_ConfiguredLayouts(t *testing.T) {
+	parser := NewParser([]string{"01/02/2006"})
+
+	date, err := parser.ParseFull("01/31/2533")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), date)
+
+	_, err = parser.ParseFull("1990-01-31")
+	assert.Equal(t, ErrFormat, err)
+}
+
+func TestParser_ParseFullRejectsPartialDates(t *testing.T) {
+	_, err := NewParser(nil).ParseFull("1990-01")
+	assert.Equal(t, ErrPartial, err)
+}
+
+func TestDate_Range(t *testing.T) {
+	year, _ := Parse("1992")
+	from, to := year.Range()
+	assert.Equal(t, time.Date(1992, 1, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(1992, 12, 31, 0, 0, 0, 0, time.UTC), to)
+
+	month, _ := Parse("1992-02")
+	from, to = month.Range()
+	assert.Equal(t, time.Date(1992, 2, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(1992, 2, 29, 0, 0, 0, 0, time.UTC), to)
+
+	day, _ := Parse("1992-02-10")
+	from, to = day.Range()
+	assert.Equal(t, from, to)
+}
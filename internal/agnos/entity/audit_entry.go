@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditActorRetention is the actor of patients purged by retention policies.
+const AuditActorRetention = "system:retention"
+
+// AuditEntry records an access to patient data. Entries form a hash chain:
+// Hash covers the entry's fields and the previous entry's Hash, so editing or
+// deleting an entry breaks every later link. Entries hold no patient PII, only
+// IDs, so erasing a patient leaves the chain intact.
+type AuditEntry struct {
+	Seq        int64     `gorm:"column:seq;primaryKey;autoIncrement"`
+	OccurredAt time.Time `gorm:"column:occurred_at;not null"`
+	// Actor is "staff:<id>", "cli:<user>" for operator commands, or
+	// AuditActorRetention.
+	Actor    string `gorm:"column:actor;not null"`
+	Hospital string `gorm:"column:hospital;not null"`
+	// Action is the HTTP method and route, or the CLI command.
+	Action string `gorm:"column:action;not null"`
+	// PatientID is the patient accessed; nil when none was.
+	PatientID *uuid.UUID `gorm:"column:patient_id;type:uuid;index"`
+	// Status is the HTTP response status, or the exit status of a CLI command
+	// or background job.
+	Status   int    `gorm:"column:status;not null"`
+	PrevHash string `gorm:"column:prev_hash;not null"`
+	Hash     string `gorm:"column:hash;not null"`
+}
+
+func (e *AuditEntry) TableName() string {
+	return "tbl_audit_log"
+}
+
+// ComputeHash returns the hash chaining the entry to PrevHash. OccurredAt is
+// hashed at microsecond precision, as stored.
+func (e *AuditEntry) ComputeHash() string {
+	patientID := ""
+	if e.PatientID != nil {
+		patientID = e.PatientID.String()
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\n%s\n%s\n%s\n%s\n%s\n%d",
+		e.PrevHash,
+		e.OccurredAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		e.Actor,
+		e.Hospital,
+		e.Action,
+		patientID,
+		e.Status,
+	))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DuplicateStatusPending   = "pending"
+	DuplicateStatusUnlikely  = "unlikely"
+	DuplicateStatusMerged    = "merged"
+	DuplicateStatusDismissed = "dismissed"
+)
+
+// DuplicateCandidate is a pair of patients scored as possibly being the same
+// person. Each pair is stored once, with PatientAID < PatientBID. There is no
+// foreign key to tbl_patients so merged pairs remain as history after the
+// retired patient is deleted.
+type DuplicateCandidate struct {
+	ID         uuid.UUID `gorm:"column:id;type:uuid;primaryKey"`
+	PatientAID uuid.UUID `gorm:"column:patient_a_id;type:uuid;not null;uniqueIndex:idx_duplicate_candidates_pair"`
+	PatientBID uuid.UUID `gorm:"column:patient_b_id;type:uuid;not null;uniqueIndex:idx_duplicate_candidates_pair"`
+	Score      float64   `gorm:"column:score;not null"`
+	// Reasons lists the matching signals, comma separated.
+	Reasons    string     `gorm:"column:reasons"`
+	Status     string     `gorm:"column:status;not null;index"`
+	DetectedAt time.Time  `gorm:"column:detected_at;not null"`
+	ReviewedBy string     `gorm:"column:reviewed_by"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at"`
+
+	// PatientA and PatientB are loaded by the repository and not persisted.
+	PatientA *Patient `gorm:"-"`
+	PatientB *Patient `gorm:"-"`
+}
+
+func (e *DuplicateCandidate) TableName() string {
+	return "tbl_duplicate_candidates"
+}
+
+func (e *DuplicateCandidate) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// ImportJobQueued jobs wait for the background job that runs them.
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	// ImportJobFailed jobs stopped early, on an error or cancellation, and can
+	// be resumed with the same input.
+	ImportJobFailed = "failed"
+)
+
+// ImportJob tracks a bulk import of hospital records. Records are committed in
+// batches together with Checkpoint, so a job that stopped can be resumed from
+// the first line it had not committed.
+type ImportJob struct {
+	ID       uuid.UUID `gorm:"column:id;type:uuid;primaryKey"`
+	Hospital string    `gorm:"column:hospital;not null"`
+	// Format is "csv" or "ndjson".
+	Format string `gorm:"column:format;not null"`
+	Status string `gorm:"column:status;not null"`
+	// JobID is the background job that runs the import, or last ran it; nil
+	// for imports run by the CLI.
+	JobID *uuid.UUID `gorm:"column:job_id;type:uuid"`
+	// Checkpoint is the last input line committed; 0 before the first batch.
+	Checkpoint int `gorm:"column:checkpoint;not null"`
+	Created    int `gorm:"column:created;not null"`
+	Updated    int `gorm:"column:updated;not null"`
+	// Duplicates counts records repeating the identifiers of an earlier record
+	// in the input; they are skipped.
+	Duplicates int `gorm:"column:duplicates;not null"`
+	Failed     int `gorm:"column:failed;not null"`
+	// Error is why a failed job stopped.
+	Error string `gorm:"column:error"`
+	// StartedBy is "staff:<id>" or "cli:<user>".
+	StartedBy string    `gorm:"column:started_by;not null"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (e *ImportJob) TableName() string {
+	return "tbl_import_jobs"
+}
+
+func (e *ImportJob) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+
+const (
+	ImportErrorInvalid   = "invalid"
+	ImportErrorDuplicate = "duplicate"
+)
+
+// ImportJobError is a record of an import job that was not applied.
+type ImportJobError struct {
+	JobID uuid.UUID `gorm:"column:job_id;type:uuid;primaryKey"`
+	// Line is the 1-based input line the record starts on.
+	Line int `gorm:"column:line;primaryKey"`
+	// Kind is ImportErrorInvalid or ImportErrorDuplicate.
+	Kind  string `gorm:"column:kind;not null"`
+	Error string `gorm:"column:error;not null"`
+}
+
+func (e *ImportJobError) TableName() string {
+	return "tbl_import_job_errors"
+}
@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	// JobSucceeded, JobFailed and JobCancelled are final.
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a unit of background work in the Postgres job queue. Workers claim
+// due jobs with SELECT ... FOR UPDATE SKIP LOCKED and hold them for a lease
+// they renew while running; a job whose lease expires is claimed again.
+type Job struct {
+	ID uuid.UUID `gorm:"column:id;type:uuid;primaryKey"`
+	// Kind names the handler that runs the job.
+	Kind string `gorm:"column:kind;not null"`
+	// Hospital is the hospital of the staff member who enqueued the job, or
+	// the one a scheduled job works on; staff of other hospitals cannot see
+	// it. Scheduled jobs spanning hospitals have none.
+	Hospital string `gorm:"column:hospital;not null"`
+	// Payload is the job's input as JSON.
+	Payload string `gorm:"column:payload;type:jsonb;not null"`
+	Status  string `gorm:"column:status;not null"`
+	// Attempts counts the runs started so far, including the current one.
+	Attempts    int `gorm:"column:attempts;not null"`
+	MaxAttempts int `gorm:"column:max_attempts;not null"`
+	// RunAt is when the job is due; retries are pushed back from it.
+	RunAt time.Time `gorm:"column:run_at;not null"`
+	// LockedUntil is when the lease of a running job expires.
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+	// CancelRequested asks the worker running the job to stop it.
+	CancelRequested bool `gorm:"column:cancel_requested;not null"`
+	// Result is the output of a succeeded job as JSON.
+	Result *string `gorm:"column:result;type:jsonb"`
+	// Error is why the last attempt failed.
+	Error string `gorm:"column:error"`
+	// CreatedBy is "staff:<id>", "cli:<user>" or "worker:<name>" for jobs
+	// scheduled by a worker.
+	CreatedBy  string     `gorm:"column:created_by;not null"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+}
+
+func (e *Job) TableName() string {
+	return "tbl_jobs"
+}
+
+func (e *Job) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+
+// Finished reports whether the job is in a final status.
+func (e *Job) Finished() bool {
+	return e.Status == JobSucceeded || e.Status == JobFailed || e.Status == JobCancelled
+}
+
+const (
+	// JobFileInput is the file uploaded with a job for its handler to read.
+	JobFileInput = "input"
+	// JobFileOutput is the file a job produced, for its creator to download.
+	JobFileOutput = "output"
+)
+
+// JobFile is a file a job reads or produces, too large for its payload or
+// result. The input is deleted when the job finishes; the output when it
+// expires.
+type JobFile struct {
+	JobID uuid.UUID `gorm:"column:job_id;type:uuid;primaryKey"`
+	// Name is JobFileInput or JobFileOutput.
+	Name string `gorm:"column:name;primaryKey"`
+	// Filename is what a downloaded output is saved as.
+	Filename    string     `gorm:"column:filename"`
+	ContentType string     `gorm:"column:content_type"`
+	Data        []byte     `gorm:"column:data;not null"`
+	ExpiresAt   *time.Time `gorm:"column:expires_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+}
+
+func (e *JobFile) TableName() string {
+	return "tbl_job_files"
+}
@@ -3,6 +3,7 @@ package entity
 import (
 	"time"
 
+	"github.com/Markikie/agnos/internal/agnos/validation"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -15,19 +16,45 @@ type Patient struct {
 	FirstNameEN  string    `gorm:"column:first_name_en"`
 	MiddleNameEN string    `gorm:"column:middle_name_en"`
 	LastNameEN   string    `gorm:"column:last_name_en"`
-	DateOfBirth  time.Time `gorm:"column:date_of_birth"`
+	DateOfBirth  time.Time `gorm:"column:date_of_birth;type:date;index"`
 	PatientHN    string    `gorm:"column:patient_hn"`
-	NationalID   string    `gorm:"column:national_id;unique"`
-	PassportID   string    `gorm:"column:passport_id;unique"`
+	NationalID   string    `gorm:"column:national_id;uniqueIndex:uni_tbl_patients_national_id,where:national_id <> ''"`
+	PassportID   string    `gorm:"column:passport_id;uniqueIndex:uni_tbl_patients_passport_id,where:passport_id <> ''"`
 	PhoneNumber  string    `gorm:"column:phone_number"`
 	Email        string    `gorm:"column:email"`
 	Gender       string    `gorm:"column:gender"`
+
+	// PhoneNormalized and EmailNormalized are the canonical forms phone
+	// numbers and emails are matched by, kept in step by BeforeSave.
+	PhoneNormalized string `gorm:"column:phone_normalized;index"`
+	EmailNormalized string `gorm:"column:email_normalized;index"`
+
+	// ErasedAt is set when the patient was pseudonymized on request: its PII
+	// is blanked and only the ID remains, so audit entries still resolve.
+	ErasedAt *time.Time `gorm:"column:erased_at"`
+	// ErasedHospital is the hospital whose admin pseudonymized the patient.
+	// Erasure drops the hospital links, so only it may delete the patient.
+	ErasedHospital string `gorm:"column:erased_hospital"`
+
+	Hospitals []PatientHospital `gorm:"foreignKey:PatientID"`
 }
 
 func (e *Patient) TableName() string {
 	return "tbl_patients"
 }
 
+func (e *Patient) BeforeSave(tx *gorm.DB) (err error) {
+	e.NormalizeContacts()
+	return
+}
+
+// NormalizeContacts sets PhoneNormalized and EmailNormalized from PhoneNumber
+// and Email.
+func (e *Patient) NormalizeContacts() {
+	e.PhoneNormalized = validation.CanonicalPhone(e.PhoneNumber)
+	e.EmailNormalized = validation.CanonicalEmail(e.Email)
+}
+
 func (e *Patient) BeforeCreate(tx *gorm.DB) (err error) {
 	e.ID = uuid.New()
 	return
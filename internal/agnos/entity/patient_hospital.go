@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PatientSourceAPI     = "api"
+	PatientSourceWebhook = "webhook"
+	PatientSourceImport  = "import"
+)
+
+// PatientHospital associates a patient with a hospital that holds their record,
+// and tracks when the local copy was last synchronized from that hospital.
+type PatientHospital struct {
+	PatientID    uuid.UUID  `gorm:"column:patient_id;type:uuid;primaryKey"`
+	Hospital     string     `gorm:"column:hospital;primaryKey"`
+	PatientHN    string     `gorm:"column:patient_hn"`
+	Source       string     `gorm:"column:source;not null"`
+	LastSyncedAt *time.Time `gorm:"column:last_synced_at"`
+	// LastAttemptAt is updated on every sync attempt, successful or not, so
+	// failing records do not starve the rest of the sync queue.
+	LastAttemptAt *time.Time `gorm:"column:last_attempt_at;index"`
+	SyncError     string     `gorm:"column:sync_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at"`
+
+	// Stale is computed by the service layer from LastSyncedAt and is not persisted.
+	Stale bool `gorm:"-"`
+}
+
+func (e *PatientHospital) TableName() string {
+	return "tbl_patient_hospitals"
+}
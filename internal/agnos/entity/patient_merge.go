@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PatientMerge records a patient retired by merging it into a survivor. The
+// retired row is deleted, so its columns are kept here as a snapshot together
+// with where each surviving value came from. Lookups of RetiredID are
+// redirected to SurvivorID.
+type PatientMerge struct {
+	ID         uuid.UUID `gorm:"column:id;type:uuid;primaryKey"`
+	SurvivorID uuid.UUID `gorm:"column:survivor_id;type:uuid;not null;index"`
+	RetiredID  uuid.UUID `gorm:"column:retired_id;type:uuid;not null;uniqueIndex"`
+	// RetiredRecord is the retired patient as JSON.
+	RetiredRecord string `gorm:"column:retired_record;type:jsonb;not null"`
+	// Survivorship maps each merged column to "survivor" or "retired", as JSON.
+	Survivorship string `gorm:"column:survivorship;type:jsonb;not null"`
+	// MergedBy is "staff:<id>" or "hospital:<name>" for merges pushed by a hospital.
+	MergedBy string    `gorm:"column:merged_by;not null"`
+	Reason   string    `gorm:"column:reason"`
+	MergedAt time.Time `gorm:"column:merged_at;not null"`
+}
+
+func (e *PatientMerge) TableName() string {
+	return "tbl_patient_merges"
+}
+
+func (e *PatientMerge) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
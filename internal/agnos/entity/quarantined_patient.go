@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// QuarantinedPatient holds a hospital record that failed validation at ingest,
+// kept for review instead of being applied. A record arriving again under the
+// same reference replaces the earlier copy.
+type QuarantinedPatient struct {
+	Hospital string `gorm:"column:hospital;primaryKey"`
+	// Source is how the record arrived, PatientSourceAPI or PatientSourceWebhook.
+	Source string `gorm:"column:source;primaryKey"`
+	// Reference is the identifier the record was fetched by, or the event ID
+	// it was pushed in.
+	Reference  string    `gorm:"column:reference;primaryKey"`
+	Record     string    `gorm:"column:record;type:jsonb;not null"`
+	Errors     string    `gorm:"column:errors;type:jsonb;not null"`
+	ReceivedAt time.Time `gorm:"column:received_at;not null"`
+}
+
+func (e *QuarantinedPatient) TableName() string {
+	return "tbl_quarantined_patients"
+}
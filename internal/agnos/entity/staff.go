@@ -7,11 +7,17 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	StaffRoleStaff = "staff"
+	StaffRoleAdmin = "admin"
+)
+
 type Staff struct {
 	ID        uuid.UUID `gorm:"column:id;type:uuid;primaryKey"`
 	Username  string    `gorm:"column:username;unique;not null"`
 	Password  string    `gorm:"column:password;not null"`
 	Hospital  string    `gorm:"column:hospital;not null"`
+	Role      string    `gorm:"column:role;not null;default:staff"`
 	CreatedAt time.Time `gorm:"column:created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at"`
 }
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEvent records an inbound hospital event that has been applied, so
+// redelivered events can be recognised and skipped.
+type WebhookEvent struct {
+	Hospital   string     `gorm:"column:hospital;primaryKey"`
+	EventID    string     `gorm:"column:event_id;primaryKey"`
+	Type       string     `gorm:"column:type;not null"`
+	PatientID  *uuid.UUID `gorm:"column:patient_id;type:uuid"`
+	ReceivedAt time.Time  `gorm:"column:received_at;not null"`
+}
+
+func (e *WebhookEvent) TableName() string {
+	return "tbl_webhook_events"
+}
@@ -1,12 +1,82 @@
 package agnos
 
+import (
+	"log/slog"
+	"time"
+)
+
 var Env struct {
-	Port     string `env:"PORT" envDefault:"8080"`
-	Database struct {
-		Host     string `env:"DB_HOST" envDefault:"localhost"`
-		Port     string `env:"DB_PORT" envDefault:"5432"`
-		User     string `env:"DB_USER" envDefault:"agnos"`
-		Password string `env:"DB_PASSWORD" envDefault:"password"`
-		DBName   string `env:"DB_NAME" envDefault:"agnos"`
+	Port string `env:"PORT" envDefault:"8080"`
+	// LogLevel is the lowest level logged: debug, info, warn or error.
+	LogLevel slog.Level `env:"LOG_LEVEL" envDefault:"info"`
+	// ShutdownTimeout bounds how long in-flight requests and background
+	// work are given to finish on shutdown.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	// JWTSecret signs and verifies staff tokens.
+	JWTSecret string `env:"JWT_SECRET" envDefault:"your-secret-key"`
+	// ExportSigningKey signs patient data portability bundles. It has no
+	// default: a published key would make the signatures worthless, so the
+	// server does not start without one.
+	ExportSigningKey string `env:"EXPORT_SIGNING_KEY"`
+	Database         struct {
+		Host     string `env:"HOST" envDefault:"localhost"`
+		Port     string `env:"PORT" envDefault:"5432"`
+		User     string `env:"USER" envDefault:"agnos"`
+		Password string `env:"PASSWORD" envDefault:"password"`
+		DBName   string `env:"NAME" envDefault:"agnos"`
 	} `envPrefix:"DB_"`
+	// Tracing selects where spans are exported: none, stdout or otlp, the
+	// latter configured by the standard OTEL_EXPORTER_OTLP_* variables.
+	Tracing struct {
+		Exporter string `env:"EXPORTER" envDefault:"none"`
+	} `envPrefix:"TRACING_"`
+	Health struct {
+		// Timeout bounds the checks of a readiness probe.
+		Timeout time.Duration `env:"TIMEOUT" envDefault:"2s"`
+	} `envPrefix:"HEALTH_"`
+	// HospitalsConfig is the path to a JSON file describing per-hospital API
+	// adapters; when empty only hospital-a is configured with defaults.
+	HospitalsConfig string `env:"HOSPITALS_CONFIG"`
+	Sync            struct {
+		Interval   time.Duration `env:"INTERVAL" envDefault:"15m"`
+		StaleAfter time.Duration `env:"STALE_AFTER" envDefault:"24h"`
+		BatchSize  int           `env:"BATCH_SIZE" envDefault:"100"`
+	} `envPrefix:"SYNC_"`
+	Dedup struct {
+		Interval  time.Duration `env:"INTERVAL" envDefault:"1h"`
+		BatchSize int           `env:"BATCH_SIZE" envDefault:"500"`
+		// Threshold is the score from which a pair is queued for review.
+		Threshold float64 `env:"THRESHOLD" envDefault:"0.75"`
+	} `envPrefix:"DEDUP_"`
+	Import struct {
+		// BatchSize is how many imported records are committed per
+		// transaction, and so how much a resumed import may redo.
+		BatchSize int `env:"BATCH_SIZE" envDefault:"500"`
+	} `envPrefix:"IMPORT_"`
+	Retention struct {
+		Interval time.Duration `env:"INTERVAL" envDefault:"24h"`
+		// DryRun only logs what the hospitals' retention policies would
+		// purge.
+		DryRun bool `env:"DRY_RUN"`
+	} `envPrefix:"RETENTION_"`
+	// Jobs configures the workers running queued background jobs.
+	Jobs struct {
+		Workers      int           `env:"WORKERS" envDefault:"4"`
+		PollInterval time.Duration `env:"POLL_INTERVAL" envDefault:"1s"`
+		// Lease is how long a worker holds a job without renewing it before
+		// another worker may take it over.
+		Lease        time.Duration `env:"LEASE" envDefault:"1m"`
+		MaxAttempts  int           `env:"MAX_ATTEMPTS" envDefault:"5"`
+		RetryBackoff time.Duration `env:"RETRY_BACKOFF" envDefault:"10s"`
+		// OutputTTL is how long the output of a job, such as an export, can
+		// be downloaded. Expired outputs are deleted by the next retention
+		// job.
+		OutputTTL time.Duration `env:"OUTPUT_TTL" envDefault:"1h"`
+	} `envPrefix:"JOB_"`
+	// HL7 configures the MLLP listener for HL7 v2 ADT feeds; it is disabled
+	// when Addr is empty.
+	HL7 struct {
+		Addr        string        `env:"ADDR"`
+		IdleTimeout time.Duration `env:"IDLE_TIMEOUT"`
+	} `envPrefix:"HL7_"`
 }
@@ -0,0 +1,268 @@
+package fhir
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Markikie/agnos/internal/agnos/birthdate"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+)
+
+const (
+	// SystemNationalID is the Thai citizen ID system used by TH Core.
+	SystemNationalID = "https://terms.sil-th.org/id/th-cid"
+	SystemPassport   = "http://hl7.org/fhir/sid/passport"
+	// SystemHNPrefix is followed by the hospital name to form a per-hospital HN system.
+	SystemHNPrefix = "urn:agnos:hospital:"
+
+	SystemIdentifierType = "http://terminology.hl7.org/CodeSystem/v2-0203"
+	SystemLanguage       = "http://hl7.org/fhir/StructureDefinition/language"
+
+	IdentifierTypeNationalID = "NI"
+	IdentifierTypePassport   = "PPN"
+	IdentifierTypeHN         = "MR"
+)
+
+// HNSystem returns the identifier system for hospital numbers issued by hospital.
+func HNSystem(hospital string) string {
+	return SystemHNPrefix + hospital + ":hn"
+}
+
+// HospitalFromHNSystem extracts the hospital name from an HN system, if system is one.
+func HospitalFromHNSystem(system string) (string, bool) {
+	if !strings.HasPrefix(system, SystemHNPrefix) || !strings.HasSuffix(system, ":hn") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(system, SystemHNPrefix), ":hn"), true
+}
+
+func identifier(system, typeCode, display, value string) Identifier {
+	return Identifier{
+		Use: "official",
+		Type: &CodeableConcept{
+			Coding: []Coding{{System: SystemIdentifierType, Code: typeCode, Display: display}},
+		},
+		System: system,
+		Value:  value,
+	}
+}
+
+func humanName(language, first, middle, last string) (HumanName, bool) {
+	var given []string
+	for _, part := range []string{first, middle} {
+		if part != "" {
+			given = append(given, part)
+		}
+	}
+	if len(given) == 0 && last == "" {
+		return HumanName{}, false
+	}
+
+	text := strings.Join(append(append([]string{}, given...), last), " ")
+	return HumanName{
+		Extension: []Extension{{URL: SystemLanguage, ValueCode: language}},
+		Use:       "official",
+		Text:      strings.TrimSpace(text),
+		Family:    last,
+		Given:     given,
+	}, true
+}
+
+// NewPatient maps a patient onto a FHIR Patient resource with Thai and
+// English names and national ID, passport and per-hospital HN identifiers.
+func NewPatient(patient *entity.Patient) Patient {
+	resource := Patient{
+		ResourceType: "Patient",
+		ID:           patient.ID.String(),
+		Gender:       Gender(patient.Gender),
+	}
+
+	if patient.NationalID != "" {
+		resource.Identifier = append(resource.Identifier, identifier(SystemNationalID, IdentifierTypeNationalID, "National unique individual identifier", patient.NationalID))
+	}
+	if patient.PassportID != "" {
+		resource.Identifier = append(resource.Identifier, identifier(SystemPassport, IdentifierTypePassport, "Passport number", patient.PassportID))
+	}
+	for _, link := range patient.Hospitals {
+		if link.PatientHN != "" {
+			resource.Identifier = append(resource.Identifier, identifier(HNSystem(link.Hospital), IdentifierTypeHN, "Medical record number", link.PatientHN))
+		}
+	}
+
+	if name, ok := humanName("th", patient.FirstNameTH, patient.MiddleNameTH, patient.LastNameTH); ok {
+		resource.Name = append(resource.Name, name)
+	}
+	if name, ok := humanName("en", patient.FirstNameEN, patient.MiddleNameEN, patient.LastNameEN); ok {
+		resource.Name = append(resource.Name, name)
+	}
+
+	if patient.PhoneNumber != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "phone", Value: patient.PhoneNumber, Use: "mobile"})
+	}
+	if patient.Email != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "email", Value: patient.Email})
+	}
+
+	if !patient.DateOfBirth.IsZero() {
+		resource.BirthDate = patient.DateOfBirth.Format("2006-01-02")
+	}
+
+	return resource
+}
+
+// Gender maps the stored M/F codes onto FHIR administrative gender.
+func Gender(code string) string {
+	switch strings.ToUpper(code) {
+	case "M":
+		return "male"
+	case "F":
+		return "female"
+	case "":
+		return "unknown"
+	default:
+		return "other"
+	}
+}
+
+// GenderCode is the inverse of Gender; it returns "" for genders not stored as codes.
+func GenderCode(gender string) string {
+	switch gender {
+	case "male":
+		return "M"
+	case "female":
+		return "F"
+	default:
+		return ""
+	}
+}
+
+// IdentifierSystems names the identifier systems a FHIR server uses. An empty
+// HN system falls back to identifiers typed MR.
+type IdentifierSystems struct {
+	NationalID string
+	Passport   string
+	HN         string
+}
+
+// birthDates parses FHIR dates, which are always ISO 8601 though some Thai
+// servers write the year in the Buddhist Era.
+var birthDates = birthdate.NewParser([]string{"2006-01-02", "2006-01", "2006"})
+
+// ToEntity maps a Patient received from a hospital FHIR server onto a patient.
+// Like the bespoke JSON feed, the resource must carry a national ID or passport
+// and a full birth date.
+func (p *Patient) ToEntity(systems IdentifierSystems) (*entity.Patient, error) {
+	patient := &entity.Patient{Gender: GenderCode(p.Gender)}
+
+	for _, id := range p.Identifier {
+		if id.Value == "" || id.Use == "old" {
+			continue
+		}
+		switch {
+		case id.System == systems.NationalID:
+			patient.NationalID = id.Value
+		case id.System == systems.Passport:
+			patient.PassportID = id.Value
+		case systems.HN != "" && id.System == systems.HN:
+			patient.PatientHN = id.Value
+		case systems.HN == "" && id.hasType(IdentifierTypeHN) && patient.PatientHN == "":
+			patient.PatientHN = id.Value
+		}
+	}
+	if patient.NationalID == "" && patient.PassportID == "" {
+		return nil, errors.New("resource has neither national ID nor passport identifier")
+	}
+
+	if p.BirthDate == "" {
+		return nil, errors.New("resource has no birthDate")
+	}
+	dob, err := birthDates.ParseFull(p.BirthDate)
+	if err != nil {
+		return nil, fmt.Errorf("birthDate %q %w", p.BirthDate, err)
+	}
+	patient.DateOfBirth = dob
+
+	if name, ok := p.nameIn("th"); ok {
+		patient.FirstNameTH, patient.MiddleNameTH, patient.LastNameTH = name.parts()
+	}
+	if name, ok := p.nameIn("en"); ok {
+		patient.FirstNameEN, patient.MiddleNameEN, patient.LastNameEN = name.parts()
+	}
+
+	for _, telecom := range p.Telecom {
+		switch {
+		case telecom.System == "phone" && (patient.PhoneNumber == "" || telecom.Use == "mobile"):
+			patient.PhoneNumber = telecom.Value
+		case telecom.System == "email" && patient.Email == "":
+			patient.Email = telecom.Value
+		}
+	}
+
+	return patient, nil
+}
+
+func (id Identifier) hasType(code string) bool {
+	if id.Type == nil {
+		return false
+	}
+	for _, coding := range id.Type.Coding {
+		if coding.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// nameIn picks the name for language, preferring official over usual names.
+// Names without a language extension are classified by script.
+func (p *Patient) nameIn(language string) (HumanName, bool) {
+	var found HumanName
+	ok := false
+	for _, name := range p.Name {
+		if name.Use == "old" || name.language() != language {
+			continue
+		}
+		if !ok || (name.Use == "official" && found.Use != "official") {
+			found, ok = name, true
+		}
+	}
+	return found, ok
+}
+
+func (n HumanName) language() string {
+	for _, extension := range n.Extension {
+		if extension.URL == SystemLanguage {
+			return strings.ToLower(strings.SplitN(extension.ValueCode, "-", 2)[0])
+		}
+	}
+	for _, r := range n.Text + n.Family + strings.Join(n.Given, "") {
+		if unicode.Is(unicode.Thai, r) {
+			return "th"
+		}
+	}
+	return "en"
+}
+
+// parts splits the name into first, middle and last. A name given only as
+// text is split on spaces.
+func (n HumanName) parts() (first, middle, last string) {
+	given, family := n.Given, n.Family
+	if len(given) == 0 && family == "" {
+		fields := strings.Fields(n.Text)
+		if len(fields) == 0 {
+			return "", "", ""
+		}
+		if len(fields) > 1 {
+			family = fields[len(fields)-1]
+			fields = fields[:len(fields)-1]
+		}
+		given = fields
+	}
+	if len(given) > 0 {
+		first = given[0]
+		middle = strings.Join(given[1:], " ")
+	}
+	return first, middle, family
+}
@@ -0,0 +1,183 @@
+package fhir
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/birthdate"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+)
+
+func TestNewPatient(t *testing.T) {
+	patient := &entity.Patient{
+		ID:          uuid.New(),
+		FirstNameTH: "สมชาย",
+		LastNameTH:  "ใจดี",
+		FirstNameEN: "Somchai",
+		LastNameEN:  "Jaidee",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		NationalID:  "1234567890121",
+		PassportID:  "AA1234567",
+		PhoneNumber: "0812345678",
+		Email:       "somchai@example.com",
+		Gender:      "M",
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-a", PatientHN: "HN001234"}},
+	}
+
+	resource := NewPatient(patient)
+
+	assert.Equal(t, "Patient", resource.ResourceType)
+	assert.Equal(t, patient.ID.String(), resource.ID)
+	assert.Equal(t, "male", resource.Gender)
+	assert.Equal(t, "1990-01-01", resource.BirthDate)
+
+	require.Len(t, resource.Identifier, 3)
+	assert.Equal(t, SystemNationalID, resource.Identifier[0].System)
+	assert.Equal(t, IdentifierTypeNationalID, resource.Identifier[0].Type.Coding[0].Code)
+	assert.Equal(t, SystemPassport, resource.Identifier[1].System)
+	assert.Equal(t, "urn:agnos:hospital:hospital-a:hn", resource.Identifier[2].System)
+	assert.Equal(t, "HN001234", resource.Identifier[2].Value)
+
+	require.Len(t, resource.Name, 2)
+	assert.Equal(t, "th", resource.Name[0].Extension[0].ValueCode)
+	assert.Equal(t, "ใจดี", resource.Name[0].Family)
+	assert.Equal(t, []string{"สมชาย"}, resource.Name[0].Given)
+	assert.Equal(t, "en", resource.Name[1].Extension[0].ValueCode)
+	assert.Equal(t, "Somchai Jaidee", resource.Name[1].Text)
+
+	require.Len(t, resource.Telecom, 2)
+	assert.Equal(t, "phone", resource.Telecom[0].System)
+	assert.Equal(t, "email", resource.Telecom[1].System)
+}
+
+func TestNewPatient_OmitsEmptyFields(t *testing.T) {
+	resource := NewPatient(&entity.Patient{ID: uuid.New(), FirstNameEN: "John"})
+
+	assert.Empty(t, resource.Identifier)
+	assert.Empty(t, resource.Telecom)
+	assert.Empty(t, resource.BirthDate)
+	assert.Equal(t, "unknown", resource.Gender)
+	require.Len(t, resource.Name, 1)
+	assert.Equal(t, "en", resource.Name[0].Extension[0].ValueCode)
+}
+
+func TestHospitalFromHNSystem(t *testing.T) {
+	hospital, ok := HospitalFromHNSystem(HNSystem("hospital-b"))
+	assert.True(t, ok)
+	assert.Equal(t, "hospital-b", hospital)
+
+	_, ok = HospitalFromHNSystem(SystemNationalID)
+	assert.False(t, ok)
+}
+
+func TestParseDateParam(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		value string
+		from  string
+		to    string
+	}{
+		{"1990-05-17", "1990-05-17", "1990-05-17"},
+		{"eq1990", "1990-01-01", "1990-12-31"},
+		{"1990-02", "1990-02-01", "1990-02-28"},
+		{"ge1990-05", "1990-05-01", ""},
+		{"gt1990-05-17", "1990-05-18", ""},
+		{"le1990", "", "1990-12-31"},
+		{"lt1990-05-17", "", "1990-05-16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			dates, err := ParseDateParam(tt.value)
+			require.NoError(t, err)
+			if tt.from == "" {
+				assert.Nil(t, dates.From)
+			} else {
+				require.NotNil(t, dates.From)
+				assert.Equal(t, day(tt.from), *dates.From)
+			}
+			if tt.to == "" {
+				assert.Nil(t, dates.To)
+			} else {
+				require.NotNil(t, dates.To)
+				assert.Equal(t, day(tt.to), *dates.To)
+			}
+		})
+	}
+}
+
+func TestParseDateParam_Invalid(t *testing.T) {
+	for _, value := range []string{"", "ne1990", "1990-13", "17/05/1990"} {
+		_, err := ParseDateParam(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSplitToken(t *testing.T) {
+	system, code, hasSystem := SplitToken(SystemPassport + "|AA1234567")
+	assert.True(t, hasSystem)
+	assert.Equal(t, SystemPassport, system)
+	assert.Equal(t, "AA1234567", code)
+
+	_, code, hasSystem = SplitToken("1234567890121")
+	assert.False(t, hasSystem)
+	assert.Equal(t, "1234567890121", code)
+}
+
+func TestPatient_ToEntity_RoundTrip(t *testing.T) {
+	patient := &entity.Patient{
+		ID:          uuid.New(),
+		FirstNameTH: "สมชาย",
+		LastNameTH:  "ใจดี",
+		FirstNameEN: "Somchai",
+		LastNameEN:  "Jaidee",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		NationalID:  "1234567890121",
+		PhoneNumber: "0812345678",
+		Email:       "somchai@example.com",
+		Gender:      "M",
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-a", PatientHN: "HN001234"}},
+	}
+
+	resource := NewPatient(patient)
+	got, err := resource.ToEntity(IdentifierSystems{NationalID: SystemNationalID, Passport: SystemPassport, HN: HNSystem("hospital-a")})
+	require.NoError(t, err)
+
+	assert.Equal(t, patient.NationalID, got.NationalID)
+	assert.Equal(t, "HN001234", got.PatientHN)
+	assert.Equal(t, patient.FirstNameTH, got.FirstNameTH)
+	assert.Equal(t, patient.LastNameTH, got.LastNameTH)
+	assert.Equal(t, patient.FirstNameEN, got.FirstNameEN)
+	assert.Equal(t, patient.LastNameEN, got.LastNameEN)
+	assert.Equal(t, patient.DateOfBirth, got.DateOfBirth)
+	assert.Equal(t, patient.PhoneNumber, got.PhoneNumber)
+	assert.Equal(t, patient.Email, got.Email)
+	assert.Equal(t, patient.Gender, got.Gender)
+}
+
+func TestPatient_ToEntity_RequiresIdentifierAndBirthDate(t *testing.T) {
+	systems := IdentifierSystems{NationalID: SystemNationalID, Passport: SystemPassport}
+
+	_, err := (&Patient{BirthDate: "1990-01-01"}).ToEntity(systems)
+	assert.Error(t, err)
+
+	_, err = (&Patient{Identifier: []Identifier{{System: SystemPassport, Value: "AA1234567"}}, BirthDate: "1990"}).ToEntity(systems)
+	assert.ErrorIs(t, err, birthdate.ErrPartial)
+}
+
+func TestPatient_ToEntity_BuddhistEraBirthDate(t *testing.T) {
+	resource := &Patient{Identifier: []Identifier{{System: SystemPassport, Value: "AA1234567"}}, BirthDate: "2535-02-29"}
+
+	got, err := resource.ToEntity(IdentifierSystems{NationalID: SystemNationalID, Passport: SystemPassport})
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1992, 2, 29, 0, 0, 0, 0, time.UTC), got.DateOfBirth)
+}
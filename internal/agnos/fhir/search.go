@@ -0,0 +1,69 @@
+package fhir
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateRange is an inclusive range of calendar days; nil bounds are open.
+type DateRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// ParseDateParam parses a FHIR date search parameter such as "1990",
+// "ge1990-05" or "lt1990-05-17" into an inclusive day range. Partial dates
+// cover the whole year or month.
+func ParseDateParam(value string) (DateRange, error) {
+	prefix := "eq"
+	if len(value) > 2 && value[0] >= 'a' && value[0] <= 'z' {
+		prefix, value = value[:2], value[2:]
+	}
+
+	start, end, err := parsePartialDate(value)
+	if err != nil {
+		return DateRange{}, err
+	}
+
+	dayBefore := start.AddDate(0, 0, -1)
+	dayAfter := end.AddDate(0, 0, 1)
+	switch prefix {
+	case "eq":
+		return DateRange{From: &start, To: &end}, nil
+	case "ge":
+		return DateRange{From: &start}, nil
+	case "gt", "sa":
+		return DateRange{From: &dayAfter}, nil
+	case "le":
+		return DateRange{To: &end}, nil
+	case "lt", "eb":
+		return DateRange{To: &dayBefore}, nil
+	default:
+		return DateRange{}, fmt.Errorf("unsupported date prefix %q", prefix)
+	}
+}
+
+// parsePartialDate returns the first and last day covered by YYYY, YYYY-MM or YYYY-MM-DD.
+func parsePartialDate(value string) (time.Time, time.Time, error) {
+	switch strings.Count(value, "-") {
+	case 0:
+		start, err := time.Parse("2006", value)
+		return start, start.AddDate(1, 0, -1), err
+	case 1:
+		start, err := time.Parse("2006-01", value)
+		return start, start.AddDate(0, 1, -1), err
+	default:
+		start, err := time.Parse("2006-01-02", value)
+		return start, start, err
+	}
+}
+
+// SplitToken splits a token parameter of the form [system|]code.
+func SplitToken(value string) (system, code string, hasSystem bool) {
+	system, code, hasSystem = strings.Cut(value, "|")
+	if !hasSystem {
+		return "", value, false
+	}
+	return system, code, true
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	// errNoStaff is a request that reached a handler needing the staff member
+	// without going through the auth middleware.
+	errNoStaff = service.NewError(service.ErrUnauthorized, "staff_not_authenticated", "staff not authenticated")
+
+	errMalformedBody = service.NewError(service.ErrValidation, "malformed_body", "request body is not valid JSON")
+)
+
+// bindJSON decodes the request body into obj, a pointer to a request struct,
+// and checks its binding tags. On failure the request is answered with the
+// fields at fault and false is returned.
+func bindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var errs validation.Errors
+	var fieldErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &fieldErrs):
+		for _, fieldErr := range fieldErrs {
+			errs.Add(jsonName(obj, fieldErr), bindingError(fieldErr))
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		errs.Add(typeErr.Field, errors.New("must be a "+typeErr.Value))
+	default:
+		// Syntax errors quote the body, so they are not shown.
+		middleware.AbortWithProblem(c, errMalformedBody)
+		return false
+	}
+	middleware.AbortWithProblem(c, service.NewValidationError("invalid request body", errs))
+	return false
+}
+
+// jsonName names the field of fieldErr as in the API.
+func jsonName(obj any, fieldErr validator.FieldError) string {
+	field, ok := reflect.TypeOf(obj).Elem().FieldByName(fieldErr.StructField())
+	if !ok {
+		return fieldErr.Field()
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return fieldErr.Field()
+}
+
+func bindingError(fieldErr validator.FieldError) error {
+	if fieldErr.Tag() == "required" {
+		return errors.New("is required")
+	}
+	return errors.New("must satisfy " + fieldErr.Tag())
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/fhir"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+type FHIRHandler struct {
+	patientService service.PatientService
+}
+
+func NewFHIRHandler(
+	patientService service.PatientService,
+) FHIRHandler {
+	return FHIRHandler{
+		patientService: patientService,
+	}
+}
+
+// ReadPatient returns a patient linked to the staff member's hospital. Other
+// hospitals' patients are not found, as with the REST API.
+func (h *FHIRHandler) ReadPatient(c *gin.Context) {
+	patient, err := h.patientService.GetHospitalPatient(c.Param("id"), c.GetString("hospital"))
+	switch {
+	case errors.Is(err, service.ErrPatientNotFound):
+		renderFHIR(c, http.StatusNotFound, fhir.NewOperationOutcome("error", "not-found", "Patient/"+c.Param("id")+" not found"))
+		return
+	case errors.Is(err, service.ErrPatientErased):
+		renderFHIR(c, http.StatusGone, fhir.NewOperationOutcome("error", "deleted", "Patient/"+c.Param("id")+" has been erased"))
+		return
+	case err != nil:
+		renderFHIRError(c, err)
+		return
+	}
+
+	auditPatients(c, patient)
+	renderFHIR(c, http.StatusOK, fhir.NewPatient(patient))
+}
+
+func (h *FHIRHandler) SearchPatients(c *gin.Context) {
+	staffHospital, exists := c.Get("hospital")
+	if !exists {
+		renderFHIR(c, http.StatusUnauthorized, fhir.NewOperationOutcome("error", "login", "Staff hospital not found"))
+		return
+	}
+
+	filters, matchable, err := fhirSearchFilters(c)
+	if err != nil {
+		renderFHIR(c, http.StatusBadRequest, fhir.NewOperationOutcome("error", "invalid", err.Error()))
+		return
+	}
+	if !matchable {
+		renderFHIR(c, http.StatusOK, newSearchBundle(c, nil, nil))
+		return
+	}
+
+	result, err := h.patientService.SearchPatients(c.Request.Context(), filters, staffHospital.(string))
+	if err != nil {
+		renderFHIRError(c, err)
+		return
+	}
+	if len(result.Patients) == 0 && result.UpstreamFailed() {
+		outcome := fhir.NewOperationOutcome("error", "transient", "hospital API unavailable")
+		for _, warning := range result.Warnings {
+			outcome.Issue = append(outcome.Issue, fhir.Issue{Severity: "warning", Code: "transient", Diagnostics: warning})
+		}
+		renderFHIR(c, http.StatusBadGateway, outcome)
+		return
+	}
+
+	auditPatients(c, result.Patients...)
+	renderFHIR(c, http.StatusOK, newSearchBundle(c, result.Patients, result.Warnings))
+}
+
+// fhirSearchFilters translates FHIR Patient search parameters into repository
+// filters. matchable is false when a parameter can never match, e.g. an
+// identifier in a system this server does not issue.
+func fhirSearchFilters(c *gin.Context) (map[string]interface{}, bool, error) {
+	filters := make(map[string]interface{})
+
+	if value := c.Query("identifier"); value != "" {
+		system, code, hasSystem := fhir.SplitToken(value)
+		switch {
+		case !hasSystem || system == "":
+			filters["identifier"] = code
+		case system == fhir.SystemNationalID:
+			filters["national_id"] = code
+		case system == fhir.SystemPassport:
+			filters["passport_id"] = code
+		default:
+			hospital, ok := fhir.HospitalFromHNSystem(system)
+			if !ok {
+				return nil, false, nil
+			}
+			filters["patient_hn"] = code
+			filters["hn_hospital"] = hospital
+		}
+	}
+	if value := c.Query("name"); value != "" {
+		filters["name"] = value
+	}
+	if value := c.Query("birthdate"); value != "" {
+		dates, err := fhir.ParseDateParam(value)
+		if err != nil {
+			return nil, false, errors.New("invalid birthdate: " + err.Error())
+		}
+		if dates.From != nil {
+			filters["date_of_birth_from"] = *dates.From
+		}
+		if dates.To != nil {
+			filters["date_of_birth_to"] = *dates.To
+		}
+	}
+	if value := c.Query("telecom"); value != "" {
+		_, code, _ := fhir.SplitToken(value)
+		filters["telecom"] = code
+	}
+	if value := c.Query("gender"); value != "" {
+		code := fhir.GenderCode(value)
+		if code == "" {
+			return nil, false, errors.New("gender must be male or female")
+		}
+		filters["gender"] = code
+	}
+
+	return filters, true, nil
+}
+
+func newSearchBundle(c *gin.Context, patients []*entity.Patient, warnings []string) fhir.Bundle {
+	baseURL := fhirBaseURL(c)
+	total := len(patients)
+	bundle := fhir.Bundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Total:        &total,
+		Link:         []fhir.BundleLink{{Relation: "self", URL: baseURL + c.Request.URL.RequestURI()}},
+		Entry:        make([]fhir.BundleEntry, 0, len(patients)+1),
+	}
+
+	for _, patient := range patients {
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{
+			FullURL:  baseURL + "/fhir/Patient/" + patient.ID.String(),
+			Resource: fhir.NewPatient(patient),
+			Search:   &fhir.BundleSearch{Mode: "match"},
+		})
+	}
+	if len(warnings) > 0 {
+		outcome := fhir.OperationOutcome{ResourceType: "OperationOutcome"}
+		for _, warning := range warnings {
+			outcome.Issue = append(outcome.Issue, fhir.Issue{Severity: "warning", Code: "incomplete", Diagnostics: warning})
+		}
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{
+			Resource: outcome,
+			Search:   &fhir.BundleSearch{Mode: "outcome"},
+		})
+	}
+
+	return bundle
+}
+
+// fhirBaseURL reconstructs the public base URL, honouring the scheme set by nginx.
+func fhirBaseURL(c *gin.Context) string {
+	scheme := c.GetHeader("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func renderFHIR(c *gin.Context, status int, resource any) {
+	c.Header("Content-Type", fhir.ContentType)
+	c.JSON(status, resource)
+}
+
+// renderFHIRError answers with the OperationOutcome for err, mapped as for
+// problems: the detail of an error the service does not know is only logged.
+func renderFHIRError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	problem := middleware.NewProblem(c, err)
+	renderFHIR(c, problem.Status, fhir.NewOperationOutcome("error", "exception", problem.Detail))
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/fhir"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+func TestFHIRHandler_ReadPatient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	patient := &entity.Patient{ID: uuid.New(), FirstNameEN: "Somchai", LastNameEN: "Jaidee", Gender: "M"}
+	mockService.On("GetHospitalPatient", patient.ID.String(), "hospital-a").Return(patient, nil)
+
+	c, w := newTestContext("GET", "/fhir/Patient/"+patient.ID.String(), "", "")
+	c.Params = gin.Params{{Key: "id", Value: patient.ID.String()}}
+
+	handler.ReadPatient(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), fhir.ContentType)
+
+	var resource fhir.Patient
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resource))
+	assert.Equal(t, "Patient", resource.ResourceType)
+	assert.Equal(t, patient.ID.String(), resource.ID)
+	assert.Equal(t, "male", resource.Gender)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestFHIRHandler_ReadPatient_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	mockService.On("GetHospitalPatient", "missing", "hospital-a").Return(nil, service.ErrPatientNotFound)
+
+	c, w := newTestContext("GET", "/fhir/Patient/missing", "", "")
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+
+	handler.ReadPatient(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var outcome fhir.OperationOutcome
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, "OperationOutcome", outcome.ResourceType)
+	assert.Equal(t, "not-found", outcome.Issue[0].Code)
+}
+
+// patientByIDRepository serves one patient to GetByID; other methods are
+// not expected to be called.
+type patientByIDRepository struct {
+	repository.PatientRepository
+	patient *entity.Patient
+}
+
+func (r patientByIDRepository) GetByID(id string) (*entity.Patient, error) {
+	return r.patient, nil
+}
+
+func TestFHIRHandler_ReadPatient_OtherHospital(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// A patient linked only to hospital-b, read by hospital-a staff.
+	patient := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-b"}}}
+	handler := FHIRHandler{patientService: service.NewPatientService(patientByIDRepository{patient: patient}, nil, nil, nil, time.Hour)}
+
+	c, w := newTestContext("GET", "/fhir/Patient/"+patient.ID.String(), "", "")
+	c.Params = gin.Params{{Key: "id", Value: patient.ID.String()}}
+
+	handler.ReadPatient(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, c.Value(middleware.AuditPatientsKey))
+}
+
+func TestFHIRHandler_SearchPatients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	patient := &entity.Patient{ID: uuid.New(), NationalID: "1234567890121", Gender: "F"}
+	expectedFilters := map[string]interface{}{
+		"national_id":        "1234567890121",
+		"name":               "jaidee",
+		"date_of_birth_from": time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		"date_of_birth_to":   time.Date(1990, 12, 31, 0, 0, 0, 0, time.UTC),
+		"gender":             "F",
+	}
+	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: []*entity.Patient{patient},
+		Warnings: []string{"hospital-a: patient could not be cached locally"},
+	}, nil)
+
+	query := url.Values{
+		"identifier": {fhir.SystemNationalID + "|1234567890121"},
+		"name":       {"jaidee"},
+		"birthdate":  {"1990"},
+		"gender":     {"female"},
+	}
+	c, w := newTestContext("GET", "/fhir/Patient?"+query.Encode(), "", "")
+	c.Request.Host = "api.example.com"
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Type         string `json:"type"`
+		Total        int    `json:"total"`
+		Entry        []struct {
+			FullURL  string                 `json:"fullUrl"`
+			Resource map[string]interface{} `json:"resource"`
+			Search   fhir.BundleSearch      `json:"search"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, "Bundle", bundle.ResourceType)
+	assert.Equal(t, "searchset", bundle.Type)
+	assert.Equal(t, 1, bundle.Total)
+	require.Len(t, bundle.Entry, 2)
+	assert.Equal(t, "http://api.example.com/fhir/Patient/"+patient.ID.String(), bundle.Entry[0].FullURL)
+	assert.Equal(t, "match", bundle.Entry[0].Search.Mode)
+	assert.Equal(t, "OperationOutcome", bundle.Entry[1].Resource["resourceType"])
+	assert.Equal(t, "outcome", bundle.Entry[1].Search.Mode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestFHIRHandler_SearchPatients_HNSystem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	// The HN is looked up at the hospital its system names only.
+	mockService.On("SearchPatients", map[string]interface{}{"patient_hn": "123", "hn_hospital": "hospital-b"}, "hospital-a").
+		Return(&service.PatientSearchResult{}, nil)
+
+	c, w := newTestContext("GET", "/fhir/Patient?identifier="+url.QueryEscape(fhir.HNSystem("hospital-b")+"|123"), "", "")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestFHIRHandler_SearchPatients_UnknownIdentifierSystem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	c, w := newTestContext("GET", "/fhir/Patient?identifier=urn:other|123", "", "")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bundle fhir.Bundle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, 0, *bundle.Total)
+	mockService.AssertNotCalled(t, "SearchPatients")
+}
+
+func TestFHIRHandler_SearchPatients_InvalidParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, query := range []string{"birthdate=17/05/1990", "gender=x"} {
+		t.Run(query, func(t *testing.T) {
+			mockService := new(MockPatientService)
+			handler := FHIRHandler{patientService: mockService}
+
+			c, w := newTestContext("GET", "/fhir/Patient?"+query, "", "")
+
+			handler.SearchPatients(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var outcome fhir.OperationOutcome
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+			assert.Equal(t, "invalid", outcome.Issue[0].Code)
+		})
+	}
+}
+
+func TestFHIRHandler_SearchPatients_UpstreamUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	mockService.On("SearchPatients", map[string]interface{}{"national_id": "1234567890121"}, "hospital-a").Return(&service.PatientSearchResult{
+		Sources: []service.SearchSource{
+			{Name: service.LocalSource, Status: service.SourceStatusNotFound},
+			{Name: "hospital-a", Status: service.SourceStatusUnavailable},
+		},
+		Warnings: []string{"hospital-a: hospital API unavailable"},
+	}, nil)
+
+	c, w := newTestContext("GET", "/fhir/Patient?identifier="+url.QueryEscape(fhir.SystemNationalID+"|1234567890121"), "", "")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	var outcome fhir.OperationOutcome
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, "transient", outcome.Issue[0].Code)
+}
+
+func TestFHIRHandler_SearchPatients_Error(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := FHIRHandler{patientService: mockService}
+
+	mockService.On("SearchPatients", map[string]interface{}{"telecom": "0812345678"}, "hospital-a").Return(nil, errors.New("db down"))
+
+	c, w := newTestContext("GET", "/fhir/Patient?telecom=phone|0812345678", "", "")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var outcome fhir.OperationOutcome
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, "exception", outcome.Issue[0].Code)
+	assert.NotContains(t, outcome.Issue[0].Diagnostics, "db down")
+	assert.Len(t, c.Errors, 1)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService service.HealthService
+}
+
+func NewHealthHandler(
+	healthService service.HealthService,
+) HealthHandler {
+	return HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Liveness answers liveness probes: 200 as long as the process serves.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	respondHealth(c, h.healthService.Liveness())
+}
+
+// Readiness answers readiness probes: 503 while a critical component is
+// down, so no traffic is routed to the instance, and 200 otherwise, including
+// when degraded.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	respondHealth(c, h.healthService.Readiness(c.Request.Context()))
+}
+
+func respondHealth(c *gin.Context, report *service.HealthReport) {
+	resp := response.HealthResponse{Status: string(report.Status)}
+	if len(report.Components) > 0 {
+		resp.Components = make(map[string]response.ComponentResponse, len(report.Components))
+	}
+	for name, component := range report.Components {
+		componentResp := response.ComponentResponse{
+			Status:   string(component.Status),
+			Critical: component.Critical,
+			Error:    component.Error,
+		}
+		if upstream := component.Upstream; upstream != nil {
+			componentResp.ConsecutiveFailures = &upstream.ConsecutiveFailures
+			componentResp.LastSuccessAt = upstream.LastSuccessAt
+			componentResp.LastFailureAt = upstream.LastFailureAt
+		}
+		resp.Components[name] = componentResp
+	}
+
+	status := http.StatusOK
+	if report.Status == service.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+	// Probes must see the current state, not a cached one.
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, resp)
+}
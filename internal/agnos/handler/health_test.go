@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockHealthService is a mock implementation of HealthService
+type MockHealthService struct {
+	mock.Mock
+}
+
+func (m *MockHealthService) Liveness() *service.HealthReport {
+	args := m.Called()
+	return args.Get(0).(*service.HealthReport)
+}
+
+func (m *MockHealthService) Readiness(ctx context.Context) *service.HealthReport {
+	args := m.Called()
+	return args.Get(0).(*service.HealthReport)
+}
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	healthService := new(MockHealthService)
+	handler := NewHealthHandler(healthService)
+	healthService.On("Liveness").Return(&service.HealthReport{Status: service.HealthUp})
+
+	c, w := newTestContext(http.MethodGet, "/healthz", "", "")
+	handler.Liveness(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up"}`, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	failedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		report   *service.HealthReport
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "degraded",
+			report: &service.HealthReport{Status: service.HealthDegraded, Components: map[string]service.ComponentHealth{
+				service.HealthComponentDatabase: {Status: service.HealthUp, Critical: true},
+				"hospital:hospital-a": {Status: service.HealthDown, Upstream: &hospital.Upstream{
+					ConsecutiveFailures: 5,
+					LastFailureAt:       &failedAt,
+				}},
+			}},
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"degraded","components":{
+				"database":{"status":"up","critical":true},
+				"hospital:hospital-a":{"status":"down","critical":false,"consecutive_failures":5,"last_failure_at":"2024-05-01T10:00:00Z"}
+			}}`,
+		},
+		{
+			name: "down",
+			report: &service.HealthReport{Status: service.HealthDown, Components: map[string]service.ComponentHealth{
+				service.HealthComponentDatabase: {Status: service.HealthDown, Critical: true, Error: "check failed"},
+			}},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"down","components":{"database":{"status":"down","critical":true,"error":"check failed"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthService := new(MockHealthService)
+			handler := NewHealthHandler(healthService)
+			healthService.On("Readiness").Return(tt.report)
+
+			c, w := newTestContext(http.MethodGet, "/readyz", "", "")
+			handler.Readiness(c)
+
+			require.Equal(t, tt.wantCode, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Markikie/agnos/internal/agnos/hl7"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// hl7EventIDPrefix keeps HL7 control IDs apart from webhook event IDs of the
+// same hospital in the shared dedup table.
+const hl7EventIDPrefix = "hl7:"
+
+// HL7Handler applies HL7 v2 ADT messages received over MLLP. Messages go
+// through the same idempotent pipeline as webhook events, keyed by the
+// MSH-10 control ID, so a resent message is acknowledged without being
+// applied again.
+type HL7Handler struct {
+	integrationService service.IntegrationService
+}
+
+func NewHL7Handler(
+	integrationService service.IntegrationService,
+) HL7Handler {
+	return HL7Handler{
+		integrationService: integrationService,
+	}
+}
+
+func (h *HL7Handler) HandleMessage(ctx context.Context, msg *hl7.Message) *hl7.Message {
+	code, trigger := msg.Type()
+	if code != "ADT" {
+		return hl7.NewACK(msg, hl7.AckReject, fmt.Sprintf("unsupported message type %s^%s", code, trigger))
+	}
+
+	hospitalName, err := h.integrationService.HL7Hospital(msg.Header().Get(4, 1), hl7.PeerFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, service.ErrHL7PeerNotAllowed) {
+			slog.WarnContext(ctx, "hl7 message from unexpected peer", "error", err)
+		}
+		return hl7.NewACK(msg, hl7.AckReject, err.Error())
+	}
+
+	controlID := msg.ControlID()
+	if controlID == "" {
+		return hl7.NewACK(msg, hl7.AckError, "MSH-10 message control ID is required")
+	}
+	pid := msg.Segment("PID")
+	if pid == nil {
+		return hl7.NewACK(msg, hl7.AckError, "PID segment is required")
+	}
+
+	event := service.WebhookEvent{
+		ID:      hl7EventIDPrefix + controlID,
+		Patient: hl7.PatientFromPID(pid),
+	}
+	switch trigger {
+	case hl7.EventRegister:
+		event.Type = service.WebhookEventPatientCreated
+	case hl7.EventUpdate:
+		event.Type = service.WebhookEventPatientUpdated
+	case hl7.EventMerge:
+		mrg := msg.Segment("MRG")
+		if mrg == nil {
+			return hl7.NewACK(msg, hl7.AckError, "MRG segment is required for A40")
+		}
+		retired := hl7.RetiredFromMRG(mrg)
+		event.Type = service.WebhookEventPatientMerged
+		event.Merged = &retired
+	default:
+		return hl7.NewACK(msg, hl7.AckReject, fmt.Sprintf("unsupported trigger event %s", trigger))
+	}
+
+	result := h.integrationService.ApplyWebhookEvents(hospitalName, []service.WebhookEvent{event})[0]
+	switch result.Status {
+	case service.WebhookEventApplied, service.WebhookEventDuplicate:
+		return hl7.NewACK(msg, hl7.AckAccept, "")
+	case service.WebhookEventRejected, service.WebhookEventQuarantined:
+		return hl7.NewACK(msg, hl7.AckError, result.Error)
+	default:
+		slog.ErrorContext(ctx, "hl7 message failed", "hospital", hospitalName, "control_id", controlID, "error", result.Error)
+		return hl7.NewACK(msg, hl7.AckReject, result.Error)
+	}
+}
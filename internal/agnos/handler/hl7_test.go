@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/hl7"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+const (
+	hl7A04 = "MSH|^~\\&|HIS|HOSPA|AGNOS|AGNOS|20250101080000||ADT^A04^ADT_A01|MSG0001|P|2.5\r" +
+		"PID|1||1234567890121^^^MOI^NI~HN001234^^^HOSPA^MR||Jaidee^Somchai||19900101|M\r"
+	hl7A40 = "MSH|^~\\&|HIS|HOSPA|AGNOS|AGNOS|20250101080000||ADT^A40^ADT_A39|MSG0002|P|2.5\r" +
+		"PID|1||1234567890121^^^MOI^NI||Jaidee^Somchai||19900101|M\r" +
+		"MRG|AA1234567^^^THA^PPN\r"
+)
+
+var loopback = netip.MustParseAddr("127.0.0.1")
+
+// sendHL7 sends payload to handler through a real MLLP listener and client.
+func sendHL7(t *testing.T, handler *HL7Handler, payload string) *hl7.Segment {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hl7.NewServer("", handler, 0).Serve(ctx, listener)
+
+	client, err := hl7.Dial(context.Background(), listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	ack, err := client.Send(context.Background(), []byte(payload))
+	require.NoError(t, err)
+	return ack.Segment("MSA")
+}
+
+func TestHL7Handler_Register(t *testing.T) {
+	mockService := new(MockIntegrationService)
+	handler := HL7Handler{integrationService: mockService}
+
+	mockService.On("HL7Hospital", "HOSPA", loopback).Return("hospital-a", nil)
+	mockService.On("ApplyWebhookEvents", "hospital-a", mock.MatchedBy(func(events []service.WebhookEvent) bool {
+		return len(events) == 1 &&
+			events[0].ID == "hl7:MSG0001" &&
+			events[0].Type == service.WebhookEventPatientCreated &&
+			events[0].Patient.NationalID == "1234567890121" &&
+			events[0].Patient.PatientHN == "HN001234" &&
+			events[0].Patient.DateOfBirth == "1990-01-01"
+	})).Return([]service.WebhookEventResult{{ID: "hl7:MSG0001", Status: service.WebhookEventApplied}})
+
+	msa := sendHL7(t, &handler, hl7A04)
+
+	assert.Equal(t, hl7.AckAccept, msa.Get(1, 1))
+	assert.Equal(t, "MSG0001", msa.Get(2, 1))
+	mockService.AssertExpectations(t)
+}
+
+func TestHL7Handler_Merge(t *testing.T) {
+	mockService := new(MockIntegrationService)
+	handler := HL7Handler{integrationService: mockService}
+
+	mockService.On("HL7Hospital", "HOSPA", loopback).Return("hospital-a", nil)
+	mockService.On("ApplyWebhookEvents", "hospital-a", mock.MatchedBy(func(events []service.WebhookEvent) bool {
+		return events[0].Type == service.WebhookEventPatientMerged &&
+			events[0].Merged != nil && events[0].Merged.PassportID == "AA1234567"
+	})).Return([]service.WebhookEventResult{{ID: "hl7:MSG0002", Status: service.WebhookEventDuplicate}})
+
+	msa := sendHL7(t, &handler, hl7A40)
+
+	assert.Equal(t, hl7.AckAccept, msa.Get(1, 1))
+	mockService.AssertExpectations(t)
+}
+
+func TestHL7Handler_Negative(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		facility error
+		result   *service.WebhookEventResult
+		wantCode string
+	}{
+		{
+			name:     "unknown facility",
+			payload:  hl7A04,
+			facility: service.ErrHL7NotConfigured,
+			wantCode: hl7.AckReject,
+		},
+		{
+			name:     "peer not allowed",
+			payload:  hl7A04,
+			facility: service.ErrHL7PeerNotAllowed,
+			wantCode: hl7.AckReject,
+		},
+		{
+			name:     "unsupported message type",
+			payload:  "MSH|^~\\&|HIS|HOSPA|||20250101||ORU^R01|MSG9|P|2.5\r",
+			wantCode: hl7.AckReject,
+		},
+		{
+			name:     "unsupported trigger",
+			payload:  "MSH|^~\\&|HIS|HOSPA|||20250101||ADT^A03|MSG9|P|2.5\rPID|1\r",
+			wantCode: hl7.AckReject,
+		},
+		{
+			name:     "merge without MRG",
+			payload:  "MSH|^~\\&|HIS|HOSPA|||20250101||ADT^A40|MSG9|P|2.5\rPID|1\r",
+			wantCode: hl7.AckError,
+		},
+		{
+			name:     "invalid patient",
+			payload:  hl7A04,
+			result:   &service.WebhookEventResult{Status: service.WebhookEventRejected, Error: "record has neither national_id nor passport_id"},
+			wantCode: hl7.AckError,
+		},
+		{
+			name:     "storage failure",
+			payload:  hl7A04,
+			result:   &service.WebhookEventResult{Status: service.WebhookEventFailed, Error: "connection refused"},
+			wantCode: hl7.AckReject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockIntegrationService)
+			handler := HL7Handler{integrationService: mockService}
+
+			if tt.facility != nil {
+				mockService.On("HL7Hospital", "HOSPA", loopback).Return("", tt.facility)
+			} else {
+				mockService.On("HL7Hospital", "HOSPA", loopback).Return("hospital-a", nil).Maybe()
+			}
+			if tt.result != nil {
+				mockService.On("ApplyWebhookEvents", "hospital-a", mock.Anything).Return([]service.WebhookEventResult{*tt.result})
+			}
+
+			msa := sendHL7(t, &handler, tt.payload)
+
+			assert.Equal(t, tt.wantCode, msa.Get(1, 1))
+			assert.NotEmpty(t, msa.Get(3, 1))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+var _ hl7.Handler = (*HL7Handler)(nil)
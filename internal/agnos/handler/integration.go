@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxWebhookBodyBytes = 1 << 20
+	maxWebhookEvents    = 500
+)
+
+var (
+	errUnreadableBody = service.NewError(service.ErrValidation, "unreadable_body", "request body could not be read")
+	errBodyTooLarge   = service.NewError(service.ErrTooLarge, "body_too_large", "request body too large")
+)
+
+type IntegrationHandler struct {
+	integrationService service.IntegrationService
+}
+
+func NewIntegrationHandler(
+	integrationService service.IntegrationService,
+) IntegrationHandler {
+	return IntegrationHandler{
+		integrationService: integrationService,
+	}
+}
+
+func (h *IntegrationHandler) Webhook(c *gin.Context) {
+	hospitalName := c.Param("hospital")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodyBytes+1))
+	if err != nil {
+		middleware.AbortWithProblem(c, errUnreadableBody)
+		return
+	}
+	if len(body) > maxWebhookBodyBytes {
+		middleware.AbortWithProblem(c, errBodyTooLarge)
+		return
+	}
+
+	// The signature covers the raw body, so verify before decoding it.
+	err = h.integrationService.VerifyWebhook(
+		hospitalName,
+		c.Request.Method,
+		c.Request.URL.RequestURI(),
+		c.GetHeader(hospital.SignatureTimestampHeader),
+		c.GetHeader(hospital.SignatureHeader),
+		body,
+	)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	var req request.WebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		middleware.AbortWithProblem(c, errMalformedBody)
+		return
+	}
+	var errs validation.Errors
+	if req.Version != service.WebhookSchemaVersion {
+		errs.Add("version", fmt.Errorf("unsupported schema version %q", req.Version))
+	}
+	if len(req.Events) > maxWebhookEvents {
+		errs.Add("events", fmt.Errorf("at most %d events per request", maxWebhookEvents))
+	}
+	if len(errs) > 0 {
+		middleware.AbortWithProblem(c, service.NewValidationError("invalid webhook request", errs))
+		return
+	}
+
+	events := make([]service.WebhookEvent, 0, len(req.Events))
+	for _, event := range req.Events {
+		serviceEvent := service.WebhookEvent{
+			ID:      event.ID,
+			Type:    event.Type,
+			Patient: hospital.JSONPatient(event.Patient),
+		}
+		if event.Merged != nil {
+			merged := hospital.JSONPatient(*event.Merged)
+			serviceEvent.Merged = &merged
+		}
+		events = append(events, serviceEvent)
+	}
+
+	results := h.integrationService.ApplyWebhookEvents(hospitalName, events)
+
+	resp := response.WebhookResponse{
+		Results: make([]response.WebhookEventResult, 0, len(results)),
+	}
+	for _, result := range results {
+		resp.Results = append(resp.Results, response.WebhookEventResult{
+			ID:        result.ID,
+			Status:    string(result.Status),
+			PatientID: result.PatientID,
+			Error:     result.Error,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
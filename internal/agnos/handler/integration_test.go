@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockIntegrationService is a mock implementation of IntegrationService
+type MockIntegrationService struct {
+	mock.Mock
+}
+
+func (m *MockIntegrationService) VerifyWebhook(hospitalName, method, requestURI, timestamp, signature string, body []byte) error {
+	args := m.Called(hospitalName, method, requestURI, timestamp, signature, body)
+	return args.Error(0)
+}
+
+func (m *MockIntegrationService) ApplyWebhookEvents(hospitalName string, events []service.WebhookEvent) []service.WebhookEventResult {
+	args := m.Called(hospitalName, events)
+	return args.Get(0).([]service.WebhookEventResult)
+}
+
+func (m *MockIntegrationService) HL7Hospital(sendingFacility string, peer netip.Addr) (string, error) {
+	args := m.Called(sendingFacility, peer)
+	return args.String(0), args.Error(1)
+}
+
+func TestIntegrationHandler_Webhook_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIntegrationService)
+	handler := IntegrationHandler{
+		integrationService: mockService,
+	}
+
+	body := []byte(`{"version":"1","events":[{"id":"evt-1","type":"patient.updated","patient":{"national_id":"1234567890121","date_of_birth":"1990-01-01"}}]}`)
+	mockService.On("VerifyWebhook", "hospital-a", "POST", "/integrations/hospital-a/webhook", "1700000000", "good", body).Return(nil)
+	mockService.On("ApplyWebhookEvents", "hospital-a", []service.WebhookEvent{
+		{ID: "evt-1", Type: "patient.updated", Patient: hospital.JSONPatient{NationalID: "1234567890121", DateOfBirth: "1990-01-01"}},
+	}).Return([]service.WebhookEventResult{{ID: "evt-1", Status: service.WebhookEventApplied, PatientID: "p-1"}})
+
+	c, w := newTestContext("POST", "/integrations/hospital-a/webhook", "application/json", string(body))
+	c.Request.Header.Set(hospital.SignatureTimestampHeader, "1700000000")
+	c.Request.Header.Set(hospital.SignatureHeader, "good")
+	c.Params = gin.Params{{Key: "hospital", Value: "hospital-a"}}
+	handler.Webhook(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string][]map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "applied", response["results"][0]["status"])
+	assert.Equal(t, "p-1", response["results"][0]["patient_id"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestIntegrationHandler_Webhook_InvalidSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIntegrationService)
+	handler := IntegrationHandler{
+		integrationService: mockService,
+	}
+
+	body := []byte(`{"version":"1","events":[]}`)
+	mockService.On("VerifyWebhook", "hospital-a", "POST", "/integrations/hospital-a/webhook", "1700000000", "bad", body).Return(hospital.ErrSignatureInvalid)
+
+	c, w := newTestContext("POST", "/integrations/hospital-a/webhook", "application/json", string(body))
+	c.Request.Header.Set(hospital.SignatureTimestampHeader, "1700000000")
+	c.Request.Header.Set(hospital.SignatureHeader, "bad")
+	c.Params = gin.Params{{Key: "hospital", Value: "hospital-a"}}
+	handler.Webhook(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertNotCalled(t, "ApplyWebhookEvents", mock.Anything, mock.Anything)
+}
+
+func TestIntegrationHandler_Webhook_UnsupportedVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIntegrationService)
+	handler := IntegrationHandler{
+		integrationService: mockService,
+	}
+
+	body := []byte(`{"version":"2","events":[]}`)
+	mockService.On("VerifyWebhook", "hospital-a", "POST", "/integrations/hospital-a/webhook", "1700000000", "good", body).Return(nil)
+
+	c, w := newTestContext("POST", "/integrations/hospital-a/webhook", "application/json", string(body))
+	c.Request.Header.Set(hospital.SignatureTimestampHeader, "1700000000")
+	c.Request.Header.Set(hospital.SignatureHeader, "good")
+	c.Params = gin.Params{{Key: "hospital", Value: "hospital-a"}}
+	handler.Webhook(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "ApplyWebhookEvents", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobService service.JobService
+}
+
+func NewJobHandler(
+	jobService service.JobService,
+) JobHandler {
+	return JobHandler{
+		jobService: jobService,
+	}
+}
+
+// CreateJob enqueues a background job for the staff member's hospital.
+func (h *JobHandler) CreateJob(c *gin.Context) {
+	var req request.JobRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var payload any
+	if len(req.Payload) > 0 {
+		payload = req.Payload
+	}
+	job, err := h.jobService.Enqueue(service.JobRequest{
+		Kind:      req.Kind,
+		Hospital:  c.GetString("hospital"),
+		CreatedBy: "staff:" + c.GetString("staff_id"),
+		Payload:   payload,
+	})
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.Header("Location", "/jobs/"+job.ID.String())
+	c.JSON(http.StatusAccepted, newJobResponse(job))
+}
+
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, err := h.jobService.GetJob(c.Param("id"), c.GetString("hospital"))
+	respondJob(c, job, err)
+}
+
+// CancelJob cancels a queued job, or asks a running one to stop; the job is
+// returned with cancel_requested set until its worker stops it.
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	job, err := h.jobService.CancelJob(c.Param("id"), c.GetString("hospital"))
+	respondJob(c, job, err)
+}
+
+// GetJobOutput downloads the output of a job, such as an export's records.
+// Only the staff member who enqueued the job may download it.
+func (h *JobHandler) GetJobOutput(c *gin.Context) {
+	file, err := h.jobService.GetOutput(c.Param("id"), c.GetString("hospital"), "staff:"+c.GetString("staff_id"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+file.Filename+`"`)
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+func respondJob(c *gin.Context, job *entity.Job, err error) {
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newJobResponse(job))
+}
+
+func newJobResponse(job *entity.Job) response.JobResponse {
+	resp := response.JobResponse{
+		ID:              job.ID,
+		Kind:            job.Kind,
+		Status:          job.Status,
+		Payload:         json.RawMessage(job.Payload),
+		Attempts:        job.Attempts,
+		MaxAttempts:     job.MaxAttempts,
+		RunAt:           job.RunAt,
+		CancelRequested: job.CancelRequested,
+		Error:           job.Error,
+		CreatedBy:       job.CreatedBy,
+		CreatedAt:       job.CreatedAt,
+		UpdatedAt:       job.UpdatedAt,
+		FinishedAt:      job.FinishedAt,
+	}
+	if job.Result != nil {
+		resp.Result = json.RawMessage(*job.Result)
+	}
+	return resp
+}
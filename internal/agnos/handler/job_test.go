@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockJobService is a mock implementation of JobService
+type MockJobService struct {
+	mock.Mock
+}
+
+func (m *MockJobService) Register(kind string, handler service.JobHandler) {
+	m.Called(kind)
+}
+
+func (m *MockJobService) Enqueue(req service.JobRequest) (*entity.Job, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockJobService) GetJob(id, hospitalName string) (*entity.Job, error) {
+	args := m.Called(id, hospitalName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockJobService) CancelJob(id, hospitalName string) (*entity.Job, error) {
+	args := m.Called(id, hospitalName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockJobService) RunNext(ctx context.Context) (bool, error) {
+	args := m.Called()
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockJobService) Input(job *entity.Job) ([]byte, error) {
+	args := m.Called(job)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockJobService) SaveOutput(job *entity.Job, filename, contentType string, data []byte) error {
+	args := m.Called(job, filename, contentType, data)
+	return args.Error(0)
+}
+
+func (m *MockJobService) GetOutput(id, hospitalName, createdBy string) (*entity.JobFile, error) {
+	args := m.Called(id, hospitalName, createdBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.JobFile), args.Error(1)
+}
+
+func (m *MockJobService) PurgeOutputs() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestJobHandler_CreateJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jobService := new(MockJobService)
+	handler := NewJobHandler(jobService)
+
+	job := &entity.Job{ID: uuid.New(), Kind: service.JobKindRetention, Status: entity.JobQueued, Payload: `{"dry_run":true}`}
+	jobService.On("Enqueue", mock.MatchedBy(func(req service.JobRequest) bool {
+		payload, ok := req.Payload.(json.RawMessage)
+		return req.Kind == service.JobKindRetention && req.Hospital == "hospital-a" && req.CreatedBy == "staff:1" &&
+			ok && string(payload) == `{"dry_run":true}`
+	})).Return(job, nil).Once()
+	jobService.On("Enqueue", mock.MatchedBy(func(req service.JobRequest) bool {
+		return req.Kind == "unknown"
+	})).Return(nil, service.ErrJobKind).Once()
+
+	c, w := newTestContext("POST", "/jobs", "application/json", `{"kind":"retention","payload":{"dry_run":true}}`)
+	handler.CreateJob(c)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/jobs/"+job.ID.String(), w.Header().Get("Location"))
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "queued", resp["status"])
+	assert.Equal(t, map[string]interface{}{"dry_run": true}, resp["payload"])
+
+	c, w = newTestContext("POST", "/jobs", "application/json", `{"kind":"unknown"}`)
+	handler.CreateJob(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	c, w = newTestContext("POST", "/jobs", "application/json", `{}`)
+	handler.CreateJob(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []response.ProblemField{{Field: "kind", Message: "is required"}}, problem.Errors)
+
+	c, w = newTestContext("POST", "/jobs", "application/json", `{"kind":`)
+	handler.CreateJob(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "malformed_body", problem.Code)
+	jobService.AssertExpectations(t)
+}
+
+func TestJobHandler_GetJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jobService := new(MockJobService)
+	handler := NewJobHandler(jobService)
+
+	result := `{"dry_run":false,"policies":[]}`
+	job := &entity.Job{ID: uuid.New(), Kind: service.JobKindRetention, Status: entity.JobSucceeded, Payload: "{}", Result: &result}
+	missing := uuid.New().String()
+	jobService.On("GetJob", job.ID.String(), "hospital-a").Return(job, nil)
+	jobService.On("GetJob", missing, "hospital-a").Return(nil, service.ErrJobNotFound)
+
+	c, w := newTestContext("GET", "/jobs/"+job.ID.String(), "", "")
+	c.Params = gin.Params{{Key: "id", Value: job.ID.String()}}
+	handler.GetJob(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "succeeded", resp["status"])
+	assert.Equal(t, false, resp["result"].(map[string]interface{})["dry_run"])
+
+	c, w = newTestContext("GET", "/jobs/"+missing, "", "")
+	c.Params = gin.Params{{Key: "id", Value: missing}}
+	handler.GetJob(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestJobHandler_CancelJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jobService := new(MockJobService)
+	handler := NewJobHandler(jobService)
+
+	running := &entity.Job{ID: uuid.New(), Status: entity.JobRunning, Payload: "{}", CancelRequested: true}
+	finished := uuid.New().String()
+	jobService.On("CancelJob", running.ID.String(), "hospital-a").Return(running, nil)
+	jobService.On("CancelJob", finished, "hospital-a").Return(nil, service.ErrJobFinished)
+
+	c, w := newTestContext("POST", "/jobs/"+running.ID.String()+"/cancel", "", "")
+	c.Params = gin.Params{{Key: "id", Value: running.ID.String()}}
+	handler.CancelJob(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cancel_requested":true`)
+
+	c, w = newTestContext("POST", "/jobs/"+finished+"/cancel", "", "")
+	c.Params = gin.Params{{Key: "id", Value: finished}}
+	handler.CancelJob(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestJobHandler_GetJobOutput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jobService := new(MockJobService)
+	handler := NewJobHandler(jobService)
+
+	id, expired := uuid.New().String(), uuid.New().String()
+	output := &entity.JobFile{Name: entity.JobFileOutput, Filename: "hospital-a-patients.ndjson", ContentType: "application/x-ndjson", Data: []byte("{}\n")}
+	jobService.On("GetOutput", id, "hospital-a", "staff:1").Return(output, nil)
+	jobService.On("GetOutput", expired, "hospital-a", "staff:1").Return(nil, service.ErrJobOutputExpired)
+
+	c, w := newTestContext("GET", "/jobs/"+id+"/output", "", "")
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler.GetJobOutput(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="hospital-a-patients.ndjson"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "{}\n", w.Body.String())
+
+	c, w = newTestContext("GET", "/jobs/"+expired+"/output", "", "")
+	c.Params = gin.Params{{Key: "id", Value: expired}}
+	handler.GetJobOutput(c)
+	assert.Equal(t, http.StatusGone, w.Code)
+}
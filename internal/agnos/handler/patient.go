@@ -1,37 +1,74 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/birthdate"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
 	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+const (
+	defaultDuplicateLimit = 50
+	maxDuplicateLimit     = 500
+
+	maxAge = 150
 )
 
 type PatientHandler struct {
-	patientService service.PatientService
+	patientService   service.PatientService
+	mergeService     service.MergeService
+	importService    service.ImportService
+	exportService    service.ExportService
+	retentionService service.RetentionService
 }
 
 func NewPatientHandler(
 	patientService service.PatientService,
+	mergeService service.MergeService,
+	importService service.ImportService,
+	exportService service.ExportService,
+	retentionService service.RetentionService,
 ) PatientHandler {
 	return PatientHandler{
-		patientService: patientService,
+		patientService:   patientService,
+		mergeService:     mergeService,
+		importService:    importService,
+		exportService:    exportService,
+		retentionService: retentionService,
 	}
 }
 
 func (h *PatientHandler) SearchPatients(c *gin.Context) {
 	var req request.PatientSearchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Get staff info from context (set by auth middleware)
 	staffHospital, exists := c.Get("hospital")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Staff hospital not found"})
+		middleware.AbortWithProblem(c, errNoStaff)
+		return
+	}
+
+	req.NationalID = validation.NormalizeNationalID(req.NationalID)
+	errs := validation.Patient(req.NationalID, req.PassportID, req.PassportCountry, req.PhoneNumber, req.Email)
+	dobFilters, dobErrs := dateOfBirthFilters(req)
+	errs = append(errs, dobErrs...)
+	if len(errs) > 0 {
+		middleware.AbortWithProblem(c, service.NewValidationError("invalid search request", errs))
 		return
 	}
 
@@ -52,12 +89,7 @@ func (h *PatientHandler) SearchPatients(c *gin.Context) {
 	if req.LastName != "" {
 		filters["last_name"] = req.LastName
 	}
-	if req.DateOfBirth != "" {
-		// Parse date
-		if dob, err := time.Parse("2006-01-02", req.DateOfBirth); err == nil {
-			filters["date_of_birth"] = dob
-		}
-	}
+	maps.Copy(filters, dobFilters)
 	if req.PhoneNumber != "" {
 		filters["phone_number"] = req.PhoneNumber
 	}
@@ -65,14 +97,227 @@ func (h *PatientHandler) SearchPatients(c *gin.Context) {
 		filters["email"] = req.Email
 	}
 
-	patients, err := h.patientService.SearchPatients(filters, staffHospital.(string))
+	result, err := h.patientService.SearchPatients(c.Request.Context(), filters, staffHospital.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"patients": patients,
-		"count":    len(patients),
+	auditPatients(c, result.Patients...)
+	c.JSON(searchStatus(result), newPatientSearchResponse(result))
+}
+
+// dateOfBirthFilters turns the date of birth and age criteria of req into
+// search filters. A year or year-month date of birth searches the whole period
+// it covers; it and date_of_birth_from/to are intersected into one range.
+func dateOfBirthFilters(req request.PatientSearchRequest) (map[string]interface{}, validation.Errors) {
+	filters := make(map[string]interface{})
+	var errs validation.Errors
+
+	// A zero bound is open.
+	var from, to time.Time
+	narrow := func(lower, upper time.Time) {
+		if !lower.IsZero() && (from.IsZero() || lower.After(from)) {
+			from = lower
+		}
+		if !upper.IsZero() && (to.IsZero() || upper.Before(to)) {
+			to = upper
+		}
+	}
+
+	if req.DateOfBirth != "" {
+		dob, err := birthdate.Parse(req.DateOfBirth)
+		switch {
+		case err != nil:
+			errs.Add("date_of_birth", err)
+		case dob.Precision == birthdate.Day:
+			filters["date_of_birth"] = dob.Time
+		default:
+			narrow(dob.Range())
+		}
+	}
+
+	var rangeFrom, rangeTo time.Time
+	if req.DateOfBirthFrom != "" {
+		dob, err := birthdate.Parse(req.DateOfBirthFrom)
+		if err != nil {
+			errs.Add("date_of_birth_from", err)
+		} else {
+			rangeFrom, _ = dob.Range()
+		}
+	}
+	if req.DateOfBirthTo != "" {
+		dob, err := birthdate.Parse(req.DateOfBirthTo)
+		if err != nil {
+			errs.Add("date_of_birth_to", err)
+		} else {
+			_, rangeTo = dob.Range()
+		}
+	}
+	if !rangeFrom.IsZero() && !rangeTo.IsZero() && rangeFrom.After(rangeTo) {
+		errs.Add("date_of_birth_from", errors.New("must not be after date_of_birth_to"))
+	}
+	narrow(rangeFrom, rangeTo)
+	if !from.IsZero() {
+		filters["date_of_birth_from"] = from
+	}
+	if !to.IsZero() {
+		filters["date_of_birth_to"] = to
+	}
+
+	if req.BirthYear != 0 {
+		year, err := birthdate.Parse(strconv.Itoa(req.BirthYear))
+		if err != nil {
+			errs.Add("birth_year", err)
+		} else {
+			filters["birth_year"] = year.Time.Year()
+		}
+	}
+
+	ages := []struct {
+		field string
+		age   *int
+	}{{"age_min", req.AgeMin}, {"age_max", req.AgeMax}}
+	for _, a := range ages {
+		if a.age == nil {
+			continue
+		}
+		if *a.age < 0 || *a.age > maxAge {
+			errs.Add(a.field, fmt.Errorf("must be between 0 and %d", maxAge))
+			continue
+		}
+		filters[a.field] = *a.age
+	}
+	if req.AgeMin != nil && req.AgeMax != nil && *req.AgeMin > *req.AgeMax {
+		errs.Add("age_min", errors.New("must not be greater than age_max"))
+	}
+
+	return filters, errs
+}
+
+// GetPatient returns a patient linked to the staff member's hospital. The ID
+// of a patient retired by a merge is answered with a permanent redirect to
+// the survivor, carrying the survivor in the body for clients that do not
+// follow redirects.
+func (h *PatientHandler) GetPatient(c *gin.Context) {
+	id := c.Param("id")
+	patient, err := h.patientService.GetHospitalPatient(id, c.GetString("hospital"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatients(c, patient)
+	if patient.ID.String() != id {
+		c.Header("Location", "/patient/"+patient.ID.String())
+		c.JSON(http.StatusPermanentRedirect, newPatientResponse(patient))
+		return
+	}
+	c.JSON(http.StatusOK, newPatientResponse(patient))
+}
+
+func (h *PatientHandler) RefreshPatient(c *gin.Context) {
+	staffHospital, exists := c.Get("hospital")
+	if !exists {
+		middleware.AbortWithProblem(c, errNoStaff)
+		return
+	}
+
+	result, err := h.patientService.RefreshPatient(c.Request.Context(), c.Param("id"), staffHospital.(string))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatients(c, result.Patient)
+	changed := result.Changed
+	if changed == nil {
+		changed = []string{}
+	}
+	c.JSON(http.StatusOK, response.PatientRefreshResponse{
+		Patient: newPatientResponse(result.Patient),
+		Changed: changed,
 	})
 }
+
+// searchStatus maps the outcome of a search onto an HTTP status: 502 when the
+// hospital API was needed but failed, 206 when an upstream patient is returned
+// but could not be cached locally.
+func searchStatus(result *service.PatientSearchResult) int {
+	switch {
+	case len(result.Patients) == 0 && result.UpstreamFailed():
+		return http.StatusBadGateway
+	case result.CacheFailed():
+		return http.StatusPartialContent
+	default:
+		return http.StatusOK
+	}
+}
+
+func newPatientSearchResponse(result *service.PatientSearchResult) response.PatientSearchResponse {
+	resp := response.PatientSearchResponse{
+		Patients: make([]response.Search, 0, len(result.Patients)),
+		Count:    len(result.Patients),
+		Sources:  make([]response.SearchSource, 0, len(result.Sources)),
+		Warnings: result.Warnings,
+	}
+	for _, patient := range result.Patients {
+		resp.Patients = append(resp.Patients, newPatientResponse(patient))
+	}
+	for _, source := range result.Sources {
+		resp.Sources = append(resp.Sources, response.SearchSource{
+			Name:   source.Name,
+			Status: string(source.Status),
+			Count:  source.Count,
+		})
+	}
+	return resp
+}
+
+func newPatientResponse(patient *entity.Patient) response.Search {
+	resp := response.Search{
+		ID:           patient.ID,
+		FirstNameTH:  patient.FirstNameTH,
+		MiddleNameTH: patient.MiddleNameTH,
+		LastNameTH:   patient.LastNameTH,
+		FirstNameEN:  patient.FirstNameEN,
+		MiddleNameEN: patient.MiddleNameEN,
+		LastNameEN:   patient.LastNameEN,
+		DateOfBirth:  patient.DateOfBirth,
+		PatientHN:    patient.PatientHN,
+		NationalID:   patient.NationalID,
+		PassportID:   patient.PassportID,
+		PhoneNumber:  patient.PhoneNumber,
+		Email:        patient.Email,
+		Gender:       patient.Gender,
+		Hospitals:    make([]response.PatientHospital, 0, len(patient.Hospitals)),
+	}
+	for _, link := range patient.Hospitals {
+		resp.Stale = resp.Stale || link.Stale
+		resp.Hospitals = append(resp.Hospitals, response.PatientHospital{
+			Hospital:     link.Hospital,
+			PatientHN:    link.PatientHN,
+			Source:       link.Source,
+			LastSyncedAt: link.LastSyncedAt,
+			Stale:        link.Stale,
+		})
+	}
+	return resp
+}
+
+// auditPatients reports the patients a request accessed to the audit
+// middleware.
+func auditPatients(c *gin.Context, patients ...*entity.Patient) {
+	ids := make([]uuid.UUID, 0, len(patients))
+	for _, patient := range patients {
+		if patient != nil {
+			ids = append(ids, patient.ID)
+		}
+	}
+	auditPatientIDs(c, ids...)
+}
+
+func auditPatientIDs(c *gin.Context, ids ...uuid.UUID) {
+	accessed, _ := c.Value(middleware.AuditPatientsKey).([]uuid.UUID)
+	c.Set(middleware.AuditPatientsKey, append(accessed, ids...))
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ErasePatient erases a patient's PII on their request under the PDPA. The
+// mode query parameter picks service.EraseModeDelete, the default, or
+// service.EraseModePseudonymize. Only patients linked to the admin's
+// hospital, or pseudonymized by it, are erased. The erasure itself is
+// audited under the patient's ID.
+func (h *PatientHandler) ErasePatient(c *gin.Context) {
+	mode := c.DefaultQuery("mode", service.EraseModeDelete)
+	id, err := h.retentionService.ErasePatient(c.Param("id"), c.GetString("hospital"), mode)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatientIDs(c, id)
+	c.Status(http.StatusNoContent)
+}
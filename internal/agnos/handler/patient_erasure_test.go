@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockRetentionService is a mock implementation of RetentionService
+type MockRetentionService struct {
+	mock.Mock
+}
+
+func (m *MockRetentionService) ErasePatient(id, hospital, mode string) (uuid.UUID, error) {
+	args := m.Called(id, hospital, mode)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockRetentionService) ApplyRetention(ctx context.Context, dryRun bool) (*service.RetentionReport, error) {
+	args := m.Called(dryRun)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.RetentionReport), args.Error(1)
+}
+
+func TestPatientHandler_ErasePatient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	retentionService := new(MockRetentionService)
+	handler := PatientHandler{retentionService: retentionService}
+
+	id := uuid.New()
+	erased := uuid.New().String()
+	retentionService.On("ErasePatient", id.String(), "hospital-a", service.EraseModeDelete).Return(id, nil).Once()
+	retentionService.On("ErasePatient", id.String(), "hospital-a", service.EraseModePseudonymize).Return(id, nil).Once()
+	retentionService.On("ErasePatient", id.String(), "hospital-a", "shred").Return(uuid.Nil, service.ErrEraseMode).Once()
+	retentionService.On("ErasePatient", erased, "hospital-a", service.EraseModePseudonymize).Return(uuid.Nil, service.ErrPatientErased).Once()
+
+	tests := []struct {
+		id     string
+		query  string
+		status int
+	}{
+		{id.String(), "", http.StatusNoContent},
+		{id.String(), "?mode=pseudonymize", http.StatusNoContent},
+		{id.String(), "?mode=shred", http.StatusBadRequest},
+		{erased, "?mode=pseudonymize", http.StatusGone},
+	}
+	for _, tt := range tests {
+		c, w := newTestContext("GET", "/patient/"+tt.id+"/erase"+tt.query, "", "")
+		c.Request.Method = http.MethodDelete
+		c.Params = gin.Params{{Key: "id", Value: tt.id}}
+		c.Set("hospital", "hospital-a")
+		handler.ErasePatient(c)
+		c.Writer.WriteHeaderNow()
+
+		assert.Equal(t, tt.status, w.Code, tt.query)
+		if tt.status == http.StatusNoContent {
+			assert.Equal(t, []uuid.UUID{id}, c.Value(middleware.AuditPatientsKey))
+		} else {
+			assert.Nil(t, c.Value(middleware.AuditPatientsKey))
+		}
+	}
+	retentionService.AssertExpectations(t)
+}
+
+func TestPatientHandler_GetPatient_Erased(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	patientService := new(MockPatientService)
+	handler := PatientHandler{patientService: patientService}
+
+	id := uuid.New().String()
+	patientService.On("GetHospitalPatient", id, "hospital-a").Return(nil, service.ErrPatientErased)
+
+	c, w := newTestContext("GET", "/patient/"+id, "", "")
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler.GetPatient(c)
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPatient returns the patient's signed data portability bundle, for
+// requests made by the patient under the PDPA. The bundle holds every
+// hospital's records, so only admins of a hospital the patient is linked to
+// may export it.
+func (h *PatientHandler) ExportPatient(c *gin.Context) {
+	patient, err := h.patientService.GetHospitalPatient(c.Param("id"), c.GetString("hospital"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	bundle, err := h.exportService.ExportPatient(patient, "staff:"+c.GetString("staff_id"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatients(c, patient)
+	c.Header("Content-Disposition", `attachment; filename="patient-`+patient.ID.String()+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", bundle)
+}
+
+// ExportPatients queues an export of the patients linked to the staff
+// member's hospital as NDJSON hospital records, run by a background job. The
+// staff member downloads them from the job's output once it succeeded.
+func (h *PatientHandler) ExportPatients(c *gin.Context) {
+	job, err := h.exportService.QueueExport(c.GetString("hospital"), "staff:"+c.GetString("staff_id"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	c.Header("Location", "/jobs/"+job.ID.String())
+	c.JSON(http.StatusAccepted, newJobResponse(job))
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockExportService is a mock implementation of ExportService
+type MockExportService struct {
+	mock.Mock
+}
+
+func (m *MockExportService) ExportPatients(ctx context.Context, req service.ExportRequest, w io.Writer) (int, error) {
+	args := m.Called(req)
+	if records, ok := args.Get(0).(string); ok {
+		io.WriteString(w, records)
+	}
+	return args.Int(1), args.Error(2)
+}
+
+func (m *MockExportService) QueueExport(hospitalName, createdBy string) (*entity.Job, error) {
+	args := m.Called(hospitalName, createdBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockExportService) ExportPatient(patient *entity.Patient, generatedBy string) ([]byte, error) {
+	args := m.Called(patient, generatedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func TestPatientHandler_ExportPatient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	patientService := new(MockPatientService)
+	exportService := new(MockExportService)
+	handler := PatientHandler{
+		patientService: patientService,
+		exportService:  exportService,
+	}
+
+	patient := &entity.Patient{ID: uuid.New()}
+	missing := uuid.New().String()
+	patientService.On("GetHospitalPatient", patient.ID.String(), "hospital-a").Return(patient, nil)
+	patientService.On("GetHospitalPatient", missing, "hospital-a").Return(nil, service.ErrPatientNotFound)
+	exportService.On("ExportPatient", patient, "staff:1").Return([]byte("PK"), nil)
+
+	c, w := newTestContext("GET", "/patient/"+patient.ID.String()+"/export", "", "")
+	c.Params = gin.Params{{Key: "id", Value: patient.ID.String()}}
+	handler.ExportPatient(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "patient-"+patient.ID.String()+".zip")
+	assert.Equal(t, []uuid.UUID{patient.ID}, c.Value(middleware.AuditPatientsKey))
+
+	c, w = newTestContext("GET", "/patient/"+missing+"/export", "", "")
+	c.Params = gin.Params{{Key: "id", Value: missing}}
+	handler.ExportPatient(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	exportService.AssertNumberOfCalls(t, "ExportPatient", 1)
+}
+
+func TestPatientHandler_ExportPatients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	job := &entity.Job{ID: uuid.New(), Kind: service.JobKindExport, Status: entity.JobQueued, Payload: "{}", CreatedBy: "staff:1"}
+	tests := []struct {
+		name   string
+		job    *entity.Job
+		err    error
+		status int
+	}{
+		{name: "queued", job: job, status: http.StatusAccepted},
+		{name: "unsupported hospital", err: service.ErrUnsupportedHospital, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exportService := new(MockExportService)
+			handler := PatientHandler{
+				exportService: exportService,
+			}
+			exportService.On("QueueExport", "hospital-a", "staff:1").Return(tt.job, tt.err)
+
+			c, w := newTestContext("GET", "/patient/export", "", "")
+			handler.ExportPatients(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.job != nil {
+				assert.Equal(t, "/jobs/"+tt.job.ID.String(), w.Header().Get("Location"))
+				assert.Contains(t, w.Body.String(), `"kind":"export"`)
+			}
+		})
+	}
+}
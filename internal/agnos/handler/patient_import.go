@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBodyBytes bounds an uploaded import, which is stored with the job
+// running it until the job finishes.
+const maxImportBodyBytes = 64 << 20
+
+// importFormats maps request content types to import formats.
+var importFormats = map[string]string{
+	"text/csv":             service.ImportFormatCSV,
+	"application/x-ndjson": service.ImportFormatNDJSON,
+	"application/jsonl":    service.ImportFormatNDJSON,
+}
+
+// ImportPatients queues an import of the hospital records in the request body
+// into the staff member's hospital, run by a background job; the import job
+// returned reports its progress. The format is taken from the format query
+// parameter or the Content-Type.
+func (h *PatientHandler) ImportPatients(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		format = importFormats[mediaType]
+	}
+	input, ok := readImport(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.importService.QueueImport(service.ImportRequest{
+		Hospital:  c.GetString("hospital"),
+		Format:    format,
+		StartedBy: "staff:" + c.GetString("staff_id"),
+	}, input)
+	respondImport(c, http.StatusAccepted, report, err)
+}
+
+// ResumeImport queues a stopped import job again with the same body.
+func (h *PatientHandler) ResumeImport(c *gin.Context) {
+	input, ok := readImport(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.importService.QueueResume(c.Param("job_id"), c.GetString("hospital"), "staff:"+c.GetString("staff_id"), input)
+	respondImport(c, http.StatusAccepted, report, err)
+}
+
+func (h *PatientHandler) GetImportJob(c *gin.Context) {
+	report, err := h.importService.GetImportJob(c.Param("job_id"), c.GetString("hospital"))
+	respondImport(c, http.StatusOK, report, err)
+}
+
+// readImport reads the uploaded import, aborting with a problem when it
+// cannot be read or is too large.
+func readImport(c *gin.Context) ([]byte, bool) {
+	input, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportBodyBytes+1))
+	if err != nil {
+		middleware.AbortWithProblem(c, errUnreadableBody)
+		return nil, false
+	}
+	if len(input) > maxImportBodyBytes {
+		middleware.AbortWithProblem(c, errBodyTooLarge)
+		return nil, false
+	}
+	return input, true
+}
+
+func respondImport(c *gin.Context, status int, report *service.ImportReport, err error) {
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+	if status == http.StatusAccepted {
+		c.Header("Location", "/patient/import/"+report.Job.ID.String())
+	}
+	c.JSON(status, newImportJobResponse(report))
+}
+
+func newImportJobResponse(report *service.ImportReport) response.ImportJobResponse {
+	job := report.Job
+	resp := response.ImportJobResponse{
+		ID:         job.ID,
+		Hospital:   job.Hospital,
+		Format:     job.Format,
+		Status:     job.Status,
+		JobID:      job.JobID,
+		Checkpoint: job.Checkpoint,
+		Created:    job.Created,
+		Updated:    job.Updated,
+		Duplicates: job.Duplicates,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		StartedBy:  job.StartedBy,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		Errors:     make([]response.ImportRowError, 0, len(report.Errors)),
+	}
+	for _, rowErr := range report.Errors {
+		resp.Errors = append(resp.Errors, response.ImportRowError{Line: rowErr.Line, Kind: rowErr.Kind, Error: rowErr.Error})
+	}
+	return resp
+}
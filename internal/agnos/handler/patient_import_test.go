@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// MockImportService is a mock implementation of ImportService
+type MockImportService struct {
+	mock.Mock
+}
+
+func (m *MockImportService) ImportPatients(ctx context.Context, req service.ImportRequest, r io.Reader) (*service.ImportReport, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func (m *MockImportService) QueueImport(req service.ImportRequest, input []byte) (*service.ImportReport, error) {
+	args := m.Called(req, string(input))
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func (m *MockImportService) QueueResume(jobID, hospitalName, startedBy string, input []byte) (*service.ImportReport, error) {
+	args := m.Called(jobID, hospitalName, startedBy, string(input))
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func (m *MockImportService) ResumeImport(ctx context.Context, jobID, hospitalName string, r io.Reader) (*service.ImportReport, error) {
+	args := m.Called(jobID, hospitalName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func (m *MockImportService) GetImportJob(jobID, hospitalName string) (*service.ImportReport, error) {
+	args := m.Called(jobID, hospitalName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ImportReport), args.Error(1)
+}
+
+func TestPatientHandler_ImportPatients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockImportService)
+	handler := PatientHandler{
+		importService: mockService,
+	}
+
+	input := "national_id\n1234567890121\n1234567890123\n"
+	jobID := uuid.New()
+	job := &entity.ImportJob{ID: uuid.New(), Hospital: "hospital-a", Format: service.ImportFormatCSV, Status: entity.ImportJobQueued, JobID: &jobID}
+	mockService.On("QueueImport", service.ImportRequest{
+		Hospital:  "hospital-a",
+		Format:    service.ImportFormatCSV,
+		StartedBy: "staff:1",
+	}, input).Return(&service.ImportReport{Job: job}, nil)
+
+	c, w := newTestContext("POST", "/patient/import", "text/csv; charset=utf-8", input)
+	handler.ImportPatients(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/patient/import/"+job.ID.String(), w.Header().Get("Location"))
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, job.ID.String(), response["id"])
+	assert.Equal(t, "queued", response["status"])
+	assert.Equal(t, jobID.String(), response["job_id"])
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_ImportPatients_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "unknown format", err: service.ErrImportFormat, status: http.StatusBadRequest},
+		{name: "unsupported hospital", err: service.ErrUnsupportedHospital, status: http.StatusBadRequest},
+		{name: "enqueue fails", err: errors.New("connection reset"), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockImportService)
+			handler := PatientHandler{
+				importService: mockService,
+			}
+			mockService.On("QueueImport", mock.Anything, "").Return(nil, tt.err)
+
+			c, w := newTestContext("POST", "/patient/import?format=xml", "application/xml", "")
+			handler.ImportPatients(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Empty(t, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestPatientHandler_ResumeImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockImportService)
+	handler := PatientHandler{
+		importService: mockService,
+	}
+	jobID := uuid.New().String()
+	mockService.On("QueueResume", jobID, "hospital-a", "staff:1", "{}\n").Return(nil, service.ErrImportJobCompleted)
+	mockService.On("GetImportJob", jobID, "hospital-a").Return(nil, service.ErrImportJobNotFound)
+
+	c, w := newTestContext("POST", "/patient/import/"+jobID+"/resume", "application/x-ndjson", "{}\n")
+	c.Params = gin.Params{{Key: "job_id", Value: jobID}}
+	handler.ResumeImport(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	c, w = newTestContext("GET", "/patient/import/"+jobID, "", "")
+	c.Params = gin.Params{{Key: "job_id", Value: jobID}}
+	handler.GetImportJob(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *PatientHandler) MergePatients(c *gin.Context) {
+	var req request.PatientMergeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	staffID, exists := c.Get("staff_id")
+	if !exists {
+		middleware.AbortWithProblem(c, errNoStaff)
+		return
+	}
+
+	prefer := make(map[string]service.MergeSide, len(req.Prefer))
+	for column, side := range req.Prefer {
+		prefer[column] = service.MergeSide(side)
+	}
+
+	result, err := h.mergeService.MergePatients(service.MergeRequest{
+		SurvivorID: req.SurvivorID,
+		RetiredID:  req.RetiredID,
+		Prefer:     prefer,
+		Reason:     req.Reason,
+		MergedBy:   "staff:" + staffID.(string),
+		Hospital:   c.GetString("hospital"),
+	})
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatients(c, result.Patient)
+	auditPatientIDs(c, result.Merge.RetiredID)
+	c.JSON(http.StatusOK, response.PatientMergeResponse{
+		Patient: newPatientResponse(result.Patient),
+		Merge:   newPatientMergeResponse(result.Merge),
+	})
+}
+
+// ListMerges returns the merges into a patient linked to the staff member's
+// hospital. The records of the retired patients are theirs, so their access
+// is audited along with the survivor's.
+func (h *PatientHandler) ListMerges(c *gin.Context) {
+	patient, err := h.patientService.GetHospitalPatient(c.Param("id"), c.GetString("hospital"))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	merges, err := h.mergeService.ListMerges(patient.ID.String())
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	auditPatients(c, patient)
+	resp := response.PatientMergeHistoryResponse{Merges: make([]response.PatientMerge, 0, len(merges))}
+	for _, merge := range merges {
+		resp.Merges = append(resp.Merges, newPatientMergeResponse(merge))
+		auditPatientIDs(c, merge.RetiredID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListDuplicates returns the duplicate review queue of the staff member's
+// hospital.
+func (h *PatientHandler) ListDuplicates(c *gin.Context) {
+	var errs validation.Errors
+	limit := defaultDuplicateLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxDuplicateLimit {
+			errs.Add("limit", fmt.Errorf("must be between 1 and %d", maxDuplicateLimit))
+		}
+		limit = parsed
+	}
+	var minScore float64
+	if value := c.Query("min_score"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			errs.Add("min_score", errors.New("must be between 0 and 1"))
+		}
+		minScore = parsed
+	}
+	if len(errs) > 0 {
+		middleware.AbortWithProblem(c, service.NewValidationError("invalid query", errs))
+		return
+	}
+
+	candidates, err := h.mergeService.ListDuplicates(c.GetString("hospital"), minScore, limit)
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+
+	resp := response.DuplicateListResponse{
+		Candidates: make([]response.DuplicateCandidate, 0, len(candidates)),
+		Count:      len(candidates),
+	}
+	for _, candidate := range candidates {
+		resp.Candidates = append(resp.Candidates, newDuplicateCandidateResponse(candidate))
+		auditPatients(c, candidate.PatientA, candidate.PatientB)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *PatientHandler) DismissDuplicate(c *gin.Context) {
+	staffID, exists := c.Get("staff_id")
+	if !exists {
+		middleware.AbortWithProblem(c, errNoStaff)
+		return
+	}
+
+	err := h.mergeService.DismissDuplicate(c.Param("id"), c.GetString("hospital"), "staff:"+staffID.(string))
+	if err != nil {
+		middleware.AbortWithProblem(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func newPatientMergeResponse(merge *entity.PatientMerge) response.PatientMerge {
+	return response.PatientMerge{
+		ID:            merge.ID,
+		SurvivorID:    merge.SurvivorID,
+		RetiredID:     merge.RetiredID,
+		RetiredRecord: json.RawMessage(merge.RetiredRecord),
+		Survivorship:  json.RawMessage(merge.Survivorship),
+		MergedBy:      merge.MergedBy,
+		Reason:        merge.Reason,
+		MergedAt:      merge.MergedAt,
+	}
+}
+
+func newDuplicateCandidateResponse(candidate *entity.DuplicateCandidate) response.DuplicateCandidate {
+	reasons := []string{}
+	if candidate.Reasons != "" {
+		reasons = strings.Split(candidate.Reasons, ",")
+	}
+	return response.DuplicateCandidate{
+		ID:         candidate.ID,
+		Score:      candidate.Score,
+		Reasons:    reasons,
+		Status:     candidate.Status,
+		DetectedAt: candidate.DetectedAt,
+		Patients:   []response.Search{newPatientResponse(candidate.PatientA), newPatientResponse(candidate.PatientB)},
+	}
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/google/uuid"
+)
+
+// MockMergeService is a mock implementation of MergeService
+type MockMergeService struct {
+	mock.Mock
+}
+
+func (m *MockMergeService) MergePatients(req service.MergeRequest) (*service.MergeResult, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.MergeResult), args.Error(1)
+}
+
+func (m *MockMergeService) ListMerges(survivorID string) ([]*entity.PatientMerge, error) {
+	args := m.Called(survivorID)
+	return args.Get(0).([]*entity.PatientMerge), args.Error(1)
+}
+
+func (m *MockMergeService) DetectDuplicates(ctx context.Context, batchSize int) (int, int, error) {
+	args := m.Called(ctx, batchSize)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+func (m *MockMergeService) ListDuplicates(hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error) {
+	args := m.Called(hospital, minScore, limit)
+	return args.Get(0).([]*entity.DuplicateCandidate), args.Error(1)
+}
+
+func (m *MockMergeService) DismissDuplicate(id, hospital, reviewedBy string) error {
+	args := m.Called(id, hospital, reviewedBy)
+	return args.Error(0)
+}
+
+func TestPatientHandler_MergePatients_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockMergeService)
+	handler := PatientHandler{
+		mergeService: mockService,
+	}
+
+	survivor := &entity.Patient{ID: uuid.New(), FirstNameEN: "Somchai"}
+	retiredID := uuid.New()
+	mockService.On("MergePatients", service.MergeRequest{
+		SurvivorID: survivor.ID.String(),
+		RetiredID:  retiredID.String(),
+		Prefer:     map[string]service.MergeSide{"phone_number": service.MergeRetired},
+		Reason:     "registered twice",
+		MergedBy:   "staff:1",
+		Hospital:   "hospital-a",
+	}).Return(&service.MergeResult{
+		Patient: survivor,
+		Merge: &entity.PatientMerge{
+			ID:            uuid.New(),
+			SurvivorID:    survivor.ID,
+			RetiredID:     retiredID,
+			RetiredRecord: `{"first_name_en":"Somchay"}`,
+			Survivorship:  `{"phone_number":"retired"}`,
+			MergedBy:      "staff:1",
+			MergedAt:      time.Now(),
+		},
+	}, nil)
+
+	body, _ := json.Marshal(request.PatientMergeRequest{
+		SurvivorID: survivor.ID.String(),
+		RetiredID:  retiredID.String(),
+		Prefer:     map[string]string{"phone_number": "retired"},
+		Reason:     "registered twice",
+	})
+	c, w := newTestContext("POST", "/patient/merge", "application/json", string(body))
+	handler.MergePatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	merge := response["merge"].(map[string]interface{})
+	assert.Equal(t, "Somchay", merge["retired_record"].(map[string]interface{})["first_name_en"])
+	assert.Equal(t, survivor.ID.String(), response["patient"].(map[string]interface{})["id"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_MergePatients_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "not found", err: service.ErrPatientNotFound, code: http.StatusNotFound},
+		{name: "same patient", err: service.ErrMergeSamePatient, code: http.StatusBadRequest},
+		{name: "invalid field", err: service.ErrInvalidMergeField, code: http.StatusBadRequest},
+		{name: "conflict", err: service.ErrMergeConflict, code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockMergeService)
+			handler := PatientHandler{
+				mergeService: mockService,
+			}
+			mockService.On("MergePatients", mock.Anything).Return(nil, tt.err)
+
+			body, _ := json.Marshal(request.PatientMergeRequest{SurvivorID: uuid.New().String(), RetiredID: uuid.New().String()})
+			c, w := newTestContext("POST", "/patient/merge", "application/json", string(body))
+			handler.MergePatients(c)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestPatientHandler_ListMerges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	patientService := new(MockPatientService)
+	mergeService := new(MockMergeService)
+	handler := PatientHandler{
+		patientService: patientService,
+		mergeService:   mergeService,
+	}
+
+	survivor := &entity.Patient{ID: uuid.New()}
+	retiredIDs := []uuid.UUID{uuid.New(), uuid.New()}
+	patientService.On("GetHospitalPatient", survivor.ID.String(), "hospital-a").Return(survivor, nil)
+	mergeService.On("ListMerges", survivor.ID.String()).Return([]*entity.PatientMerge{
+		{ID: uuid.New(), SurvivorID: survivor.ID, RetiredID: retiredIDs[0], RetiredRecord: `{}`, Survivorship: `{}`},
+		{ID: uuid.New(), SurvivorID: survivor.ID, RetiredID: retiredIDs[1], RetiredRecord: `{}`, Survivorship: `{}`},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/patient/"+survivor.ID.String()+"/merges", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: survivor.ID.String()}}
+	c.Set("hospital", "hospital-a")
+
+	handler.ListMerges(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uuid.UUID{survivor.ID, retiredIDs[0], retiredIDs[1]}, c.Value(middleware.AuditPatientsKey))
+	mergeService.AssertExpectations(t)
+}
+
+func TestPatientHandler_ListDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockMergeService)
+	handler := PatientHandler{
+		mergeService: mockService,
+	}
+
+	mockService.On("ListDuplicates", "hospital-a", 0.9, 10).Return([]*entity.DuplicateCandidate{{
+		ID:       uuid.New(),
+		Score:    0.95,
+		Reasons:  "name:1.00,date_of_birth",
+		Status:   entity.DuplicateStatusPending,
+		PatientA: &entity.Patient{ID: uuid.New()},
+		PatientB: &entity.Patient{ID: uuid.New()},
+	}}, nil)
+
+	req, _ := http.NewRequest("GET", "/patient/duplicates?min_score=0.9&limit=10", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.ListDuplicates(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(1), response["count"])
+	candidate := response["candidates"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{"name:1.00", "date_of_birth"}, candidate["reasons"])
+	assert.Len(t, candidate["patients"], 2)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_ListDuplicates_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := PatientHandler{
+		mergeService: new(MockMergeService),
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "min_score=2"} {
+		req, _ := http.NewRequest("GET", "/patient/duplicates?"+query, nil)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		handler.ListDuplicates(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestPatientHandler_DismissDuplicate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "dismissed", code: http.StatusNoContent},
+		{name: "not found", err: service.ErrDuplicateNotFound, code: http.StatusNotFound},
+		{name: "already reviewed", err: service.ErrDuplicateAlreadyClosed, code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockMergeService)
+			handler := PatientHandler{
+				mergeService: mockService,
+			}
+			id := uuid.New().String()
+			mockService.On("DismissDuplicate", id, "hospital-a", "staff:1").Return(tt.err)
+
+			req, _ := http.NewRequest("POST", "/patient/duplicates/"+id+"/dismiss", nil)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: id}}
+			c.Set("staff_id", "1")
+			c.Set("hospital", "hospital-a")
+
+			handler.DismissDuplicate(c)
+			c.Writer.WriteHeaderNow()
+
+			assert.Equal(t, tt.code, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatientHandler_GetPatient_RedirectsRetiredID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	retiredID := uuid.New().String()
+	survivor := &entity.Patient{ID: uuid.New()}
+	mockService.On("GetHospitalPatient", retiredID, "hospital-a").Return(survivor, nil)
+
+	req, _ := http.NewRequest("GET", "/patient/"+retiredID, nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: retiredID}}
+	c.Set("hospital", "hospital-a")
+
+	handler.GetPatient(c)
+
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "/patient/"+survivor.ID.String(), w.Header().Get("Location"))
+	mockService.AssertExpectations(t)
+}
@@ -2,9 +2,11 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,7 +15,10 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
 	"github.com/google/uuid"
 )
 
@@ -22,16 +27,81 @@ type MockPatientService struct {
 	mock.Mock
 }
 
-func (m *MockPatientService) SearchPatients(filters map[string]interface{}, staffHospital string) ([]*entity.Patient, error) {
+func (m *MockPatientService) SearchPatients(ctx context.Context, filters map[string]interface{}, staffHospital string) (*service.PatientSearchResult, error) {
 	args := m.Called(filters, staffHospital)
-	return args.Get(0).([]*entity.Patient), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.PatientSearchResult), args.Error(1)
 }
 
-func (m *MockPatientService) GetPatientFromHospitalAPI(id, hospital string) (*entity.Patient, error) {
+func (m *MockPatientService) GetPatientFromHospitalAPI(ctx context.Context, id, hospital string) (*entity.Patient, error) {
 	args := m.Called(id, hospital)
 	return args.Get(0).(*entity.Patient), args.Error(1)
 }
 
+func (m *MockPatientService) GetPatient(id string) (*entity.Patient, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) ResolvePatient(id string) (*entity.Patient, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) GetHospitalPatient(id, hospital string) (*entity.Patient, error) {
+	args := m.Called(id, hospital)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) RefreshPatient(ctx context.Context, id, staffHospital string) (*service.SyncResult, error) {
+	args := m.Called(id, staffHospital)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.SyncResult), args.Error(1)
+}
+
+func (m *MockPatientService) ListStalePatients(hospital string, limit int) ([]*entity.Patient, error) {
+	args := m.Called(hospital, limit)
+	return args.Get(0).([]*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) SyncPatient(ctx context.Context, patient *entity.Patient, hospital string) (*service.SyncResult, error) {
+	args := m.Called(ctx, patient, hospital)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.SyncResult), args.Error(1)
+}
+
+// newTestContext returns the context of a request as the auth middleware
+// leaves it for staff member 1 of hospital-a. Content-Type is set unless
+// contentType is empty.
+func newTestContext(method, target, contentType, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("staff_id", "1")
+	c.Set("hospital", "hospital-a")
+	return c, w
+}
+
 func TestPatientHandler_SearchPatients_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -47,19 +117,22 @@ func TestPatientHandler_SearchPatients_Success(t *testing.T) {
 			LastNameTH:  "ใจดี",
 			FirstNameEN: "Somchai",
 			LastNameEN:  "Jaidee",
-			NationalID:  "1234567890123",
+			NationalID:  "1234567890121",
 			Gender:      "M",
 		},
 	}
 
 	expectedFilters := map[string]interface{}{
-		"national_id": "1234567890123",
+		"national_id": "1234567890121",
 	}
 
-	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(patients, nil)
+	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: patients,
+		Sources:  []service.SearchSource{{Name: service.LocalSource, Status: service.SourceStatusFound, Count: 1}},
+	}, nil)
 
 	reqBody := request.PatientSearchRequest{
-		NationalID: "1234567890123",
+		NationalID: "1234567890121",
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -93,7 +166,7 @@ func TestPatientHandler_SearchPatients_NoAuth(t *testing.T) {
 	}
 
 	reqBody := request.PatientSearchRequest{
-		NationalID: "1234567890123",
+		NationalID: "1234567890121",
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -127,7 +200,10 @@ func TestPatientHandler_SearchPatients_MultipleFilters(t *testing.T) {
 		"date_of_birth":  time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
 
-	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(patients, nil)
+	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: patients,
+		Sources:  []service.SearchSource{{Name: service.LocalSource, Status: service.SourceStatusNotFound}},
+	}, nil)
 
 	reqBody := request.PatientSearchRequest{
 		FirstName:    "John",
@@ -176,3 +252,305 @@ func TestPatientHandler_SearchPatients_InvalidJSON(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestPatientHandler_SearchPatients_InvalidFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	jsonBody, _ := json.Marshal(request.PatientSearchRequest{
+		NationalID:  "1234567890123",
+		PhoneNumber: "12345",
+		Email:       "somchai@",
+		DateOfBirth: "1990-13-01",
+	})
+	req, _ := http.NewRequest("POST", "/patient/search", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem response.Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, "validation_failed", problem.Code)
+	var fields []string
+	for _, field := range problem.Errors {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{"national_id", "phone_number", "email", "date_of_birth"}, fields)
+	mockService.AssertNotCalled(t, "SearchPatients", mock.Anything, mock.Anything)
+}
+
+func TestPatientHandler_SearchPatients_NormalizesNationalID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	mockService.On("SearchPatients", map[string]interface{}{"national_id": "1234567890121"}, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: []*entity.Patient{},
+		Sources:  []service.SearchSource{{Name: service.LocalSource, Status: service.SourceStatusNotFound}},
+	}, nil)
+
+	jsonBody, _ := json.Marshal(request.PatientSearchRequest{NationalID: "1-2345-67890-12-1"})
+	req, _ := http.NewRequest("POST", "/patient/search", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_SearchPatients_BuddhistEraPartialDateOfBirth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	// 03/2533 BE is March 1990.
+	mockService.On("SearchPatients", map[string]interface{}{
+		"last_name":          "Jaidee",
+		"date_of_birth_from": time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC),
+		"date_of_birth_to":   time.Date(1990, 3, 31, 0, 0, 0, 0, time.UTC),
+	}, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: []*entity.Patient{},
+		Sources:  []service.SearchSource{{Name: service.LocalSource, Status: service.SourceStatusNotFound}},
+	}, nil)
+
+	jsonBody, _ := json.Marshal(request.PatientSearchRequest{LastName: "Jaidee", DateOfBirth: "03/2533"})
+	req, _ := http.NewRequest("POST", "/patient/search", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDateOfBirthFilters(t *testing.T) {
+	age := func(n int) *int { return &n }
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		req    request.PatientSearchRequest
+		want   map[string]interface{}
+		fields []string
+	}{
+		{
+			name: "range of partial dates",
+			req:  request.PatientSearchRequest{DateOfBirthFrom: "1985", DateOfBirthTo: "03/2533"},
+			want: map[string]interface{}{"date_of_birth_from": date(1985, 1, 1), "date_of_birth_to": date(1990, 3, 31)},
+		},
+		{
+			name: "partial date of birth narrows range",
+			req:  request.PatientSearchRequest{DateOfBirth: "1990-06", DateOfBirthFrom: "1990-06-15"},
+			want: map[string]interface{}{"date_of_birth_from": date(1990, 6, 15), "date_of_birth_to": date(1990, 6, 30)},
+		},
+		{
+			name: "Buddhist Era birth year and age band",
+			req:  request.PatientSearchRequest{BirthYear: 2528, AgeMin: age(0), AgeMax: age(60)},
+			want: map[string]interface{}{"birth_year": 1985, "age_min": 0, "age_max": 60},
+		},
+		{
+			name:   "invalid",
+			req:    request.PatientSearchRequest{DateOfBirthFrom: "1990", DateOfBirthTo: "1989", BirthYear: 85, AgeMin: age(70), AgeMax: age(200)},
+			want:   map[string]interface{}{"date_of_birth_from": date(1990, 1, 1), "date_of_birth_to": date(1989, 12, 31), "age_min": 70},
+			fields: []string{"date_of_birth_from", "birth_year", "age_max"},
+		},
+		{
+			name:   "age band reversed",
+			req:    request.PatientSearchRequest{AgeMin: age(60), AgeMax: age(50)},
+			want:   map[string]interface{}{"age_min": 60, "age_max": 50},
+			fields: []string{"age_min"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, errs := dateOfBirthFilters(tt.req)
+
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+			assert.Equal(t, tt.want, filters)
+		})
+	}
+}
+
+func TestPatientHandler_SearchPatients_UpstreamUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	expectedFilters := map[string]interface{}{
+		"national_id": "1234567890121",
+	}
+
+	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: []*entity.Patient{},
+		Sources: []service.SearchSource{
+			{Name: service.LocalSource, Status: service.SourceStatusNotFound},
+			{Name: "hospital-a", Status: service.SourceStatusUnavailable},
+		},
+		Warnings: []string{"hospital-a: hospital API unavailable"},
+	}, nil)
+
+	jsonBody, _ := json.Marshal(request.PatientSearchRequest{NationalID: "1234567890121"})
+	req, _ := http.NewRequest("POST", "/patient/search", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(0), response["count"])
+	assert.Len(t, response["sources"], 2)
+	assert.Len(t, response["warnings"], 1)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_SearchPatients_CacheFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	expectedFilters := map[string]interface{}{
+		"passport_id": "AA1234567",
+	}
+
+	mockService.On("SearchPatients", expectedFilters, "hospital-a").Return(&service.PatientSearchResult{
+		Patients: []*entity.Patient{{PassportID: "AA1234567"}},
+		Sources: []service.SearchSource{
+			{Name: service.LocalSource, Status: service.SourceStatusNotFound},
+			{Name: "hospital-a", Status: service.SourceStatusCacheFailed, Count: 1},
+		},
+		Warnings: []string{"hospital-a: patient could not be cached locally"},
+	}, nil)
+
+	jsonBody, _ := json.Marshal(request.PatientSearchRequest{PassportID: "AA1234567"})
+	req, _ := http.NewRequest("POST", "/patient/search", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("hospital", "hospital-a")
+
+	handler.SearchPatients(c)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(1), response["count"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_RefreshPatient_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	syncedAt := time.Now()
+	patient := &entity.Patient{
+		ID:          uuid.New(),
+		PhoneNumber: "0899999999",
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-a", Source: entity.PatientSourceAPI, LastSyncedAt: &syncedAt}},
+	}
+	mockService.On("RefreshPatient", patient.ID.String(), "hospital-a").Return(&service.SyncResult{
+		Patient: patient,
+		Changed: []string{"phone_number"},
+	}, nil)
+
+	req, _ := http.NewRequest("POST", "/patient/"+patient.ID.String()+"/refresh", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: patient.ID.String()}}
+	c.Set("hospital", "hospital-a")
+
+	handler.RefreshPatient(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []interface{}{"phone_number"}, response["changed"])
+	assert.Equal(t, false, response["patient"].(map[string]interface{})["stale"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_RefreshPatient_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockPatientService)
+	handler := PatientHandler{
+		patientService: mockService,
+	}
+
+	id := uuid.New().String()
+	mockService.On("RefreshPatient", id, "hospital-a").Return(nil, service.ErrPatientNotFound)
+
+	req, _ := http.NewRequest("POST", "/patient/"+id+"/refresh", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("hospital", "hospital-a")
+
+	handler.RefreshPatient(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/Markikie/agnos/internal/agnos/api/request"
 	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
 	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
 )
 
 type StaffHandler struct {
@@ -20,60 +22,62 @@ type Claims struct {
 	StaffID  string `json:"staff_id"`
 	Username string `json:"username"`
 	Hospital string `json:"hospital"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
 func NewStaffHandler(
 	staffService service.StaffService,
+	jwtSecret string,
 ) StaffHandler {
 	return StaffHandler{
 		staffService: staffService,
-		jwtSecret:    "your-secret-key", // In production, use environment variable
+		jwtSecret:    jwtSecret,
 	}
 }
 
 func (h *StaffHandler) CreateStaff(c *gin.Context) {
 	var req request.StaffRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Validate required fields
 	if req.Username == "" || req.Password == "" || req.Hospital == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username, password, and hospital are required"})
+		middleware.AbortWithProblem(c, service.ErrStaffFieldsRequired)
 		return
 	}
 
+	// Only an existing username is a conflict; any other failure is ours.
 	staff, err := h.staffService.CreateStaff(req.Username, req.Password, req.Hospital)
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Staff created successfully",
-		"staff_id": staff.ID,
+	c.JSON(http.StatusCreated, response.CreateStaffResponse{
+		Message: "Staff created successfully",
+		StaffID: staff.ID,
 	})
 }
 
 func (h *StaffHandler) Login(c *gin.Context) {
 	var req request.LoginStaffRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Get hospital from query parameter
 	hospital := c.Query("hospital")
 	if hospital == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "hospital parameter is required"})
+		middleware.AbortWithProblem(c, service.NewValidationError("hospital parameter is required",
+			validation.Errors{{Field: "hospital", Message: "is required"}}))
 		return
 	}
 
 	staff, err := h.staffService.Login(req.Username, req.Password, hospital)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
@@ -82,6 +86,7 @@ func (h *StaffHandler) Login(c *gin.Context) {
 		StaffID:  staff.ID.String(),
 		Username: staff.Username,
 		Hospital: staff.Hospital,
+		Role:     staff.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -91,7 +96,7 @@ func (h *StaffHandler) Login(c *gin.Context) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(h.jwtSecret))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		middleware.AbortWithProblem(c, err)
 		return
 	}
 
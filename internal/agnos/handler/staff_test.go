@@ -3,6 +3,7 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,7 +13,9 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/service"
 	"github.com/google/uuid"
 )
 
@@ -26,6 +29,16 @@ func (m *MockStaffService) CreateStaff(username, password, hospital string) (*en
 	return args.Get(0).(*entity.Staff), args.Error(1)
 }
 
+func (m *MockStaffService) CreateAdmin(username, password, hospital string) (*entity.Staff, error) {
+	args := m.Called(username, password, hospital)
+	return args.Get(0).(*entity.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) ResetPassword(username, hospital, password string) error {
+	args := m.Called(username, hospital, password)
+	return args.Error(0)
+}
+
 func (m *MockStaffService) Login(username, password, hospital string) (*entity.Staff, error) {
 	args := m.Called(username, password, hospital)
 	if args.Get(0) == nil {
@@ -104,6 +117,43 @@ func TestStaffHandler_CreateStaff_ValidationError(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestStaffHandler_CreateStaff_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "exists", err: service.ErrStaffExists, status: http.StatusConflict, code: "staff_exists"},
+		{name: "database down", err: errors.New("dial tcp 10.0.0.5:5432: connection refused"), status: http.StatusInternalServerError, code: "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockStaffService)
+			handler := StaffHandler{staffService: mockService}
+			mockService.On("CreateStaff", "testuser", "password123", "hospital-a").Return((*entity.Staff)(nil), tt.err)
+
+			jsonBody, _ := json.Marshal(request.StaffRequest{Username: "testuser", Password: "password123", Hospital: "hospital-a"})
+			req, _ := http.NewRequest("POST", "/staff/create", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+
+			handler.CreateStaff(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			var problem response.Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.code, problem.Code)
+			assert.NotContains(t, w.Body.String(), "10.0.0.5")
+		})
+	}
+}
+
 func TestStaffHandler_Login_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -154,7 +204,7 @@ func TestStaffHandler_Login_InvalidCredentials(t *testing.T) {
 		jwtSecret:    "test-secret",
 	}
 
-	mockService.On("Login", "testuser", "wrongpassword", "hospital-a").Return(nil, assert.AnError)
+	mockService.On("Login", "testuser", "wrongpassword", "hospital-a").Return(nil, service.ErrInvalidCredentials)
 
 	reqBody := request.LoginStaffRequest{
 		Username: "testuser",
@@ -0,0 +1,83 @@
+package hl7
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Acknowledgment codes for MSA-1 in original acknowledgment mode.
+const (
+	// AckAccept: the message was processed.
+	AckAccept = "AA"
+	// AckError: the message content is invalid; resending it unchanged will fail again.
+	AckError = "AE"
+	// AckReject: the message could not be processed now, or is not one this
+	// receiver handles.
+	AckReject = "AR"
+)
+
+const (
+	ackSendingApplication = "AGNOS"
+	ackSendingFacility    = "AGNOS"
+	ackVersion            = "2.5"
+)
+
+// NewACK builds the acknowledgment for original, which may be nil when the
+// received message could not be parsed.
+func NewACK(original *Message, code, text string) *Message {
+	enc := DefaultEncoding
+	var receivingApp, receivingFacility, event, controlID, processingID, version string
+	if original != nil {
+		enc = original.Encoding
+		header := original.Header()
+		receivingApp = header.Field(3)
+		receivingFacility = header.Field(4)
+		_, event = original.Type()
+		controlID = original.ControlID()
+		processingID = header.Field(11)
+		version = header.Field(12)
+	}
+	if processingID == "" {
+		processingID = "P"
+	}
+	if version == "" {
+		version = ackVersion
+	}
+
+	messageType := "ACK"
+	if event != "" {
+		messageType = strings.Join([]string{"ACK", event, "ACK"}, string(enc.Component))
+	}
+
+	msh := []string{
+		"MSH", string(enc.Field),
+		string([]byte{enc.Component, enc.Repetition, enc.Escape, enc.Subcomponent}),
+		ackSendingApplication,
+		ackSendingFacility,
+		receivingApp,
+		receivingFacility,
+		time.Now().Format("20060102150405"),
+		"",
+		messageType,
+		newControlID(),
+		processingID,
+		version,
+	}
+	msa := []string{"MSA", code, enc.EscapeText(controlID), enc.EscapeText(text)}
+
+	return &Message{
+		Encoding: enc,
+		Segments: []*Segment{
+			{Name: "MSH", fields: msh, enc: enc},
+			{Name: "MSA", fields: msa, enc: enc},
+		},
+	}
+}
+
+func newControlID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
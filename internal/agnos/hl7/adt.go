@@ -0,0 +1,166 @@
+package hl7
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+)
+
+// ADT trigger events handled by ingestion.
+const (
+	EventRegister = "A04"
+	EventUpdate   = "A08"
+	EventMerge    = "A40"
+)
+
+// Identifier type codes (HL7 table 0203) recognised in PID-3 and MRG-1.
+var (
+	nationalIDTypes = []string{"NI", "NNTHA", "CZ"}
+	passportTypes   = []string{"PPN"}
+	hnTypes         = []string{"MR", "PI"}
+)
+
+// PatientFromPID maps a PID segment onto the hospital record shape shared
+// with the JSON API and webhooks. The date of birth is reformatted from
+// YYYYMMDD to YYYY-MM-DD, leaving a Buddhist Era year for the hospital's
+// date parser to convert; anything shorter is passed through for validation
+// to reject.
+func PatientFromPID(pid *Segment) hospital.JSONPatient {
+	patient := identifiers(pid, pid.Repetitions(3))
+
+	if th, ok := name(pid, "th"); ok {
+		patient.FirstNameTH, patient.MiddleNameTH, patient.LastNameTH = th[0], th[1], th[2]
+	}
+	if en, ok := name(pid, "en"); ok {
+		patient.FirstNameEN, patient.MiddleNameEN, patient.LastNameEN = en[0], en[1], en[2]
+	}
+
+	dob := pid.Get(7, 1)
+	if len(dob) >= 8 {
+		dob = dob[0:4] + "-" + dob[4:6] + "-" + dob[6:8]
+	}
+	patient.DateOfBirth = dob
+
+	switch gender := strings.ToUpper(pid.Get(8, 1)); gender {
+	case "M", "F":
+		patient.Gender = gender
+	}
+
+	patient.PhoneNumber, patient.Email = telecom(pid, append(pid.Repetitions(13), pid.Repetitions(14)...))
+	return patient
+}
+
+// RetiredFromMRG returns the identifiers of the record retired by an A40,
+// taken from MRG-1.
+func RetiredFromMRG(mrg *Segment) hospital.JSONPatient {
+	return identifiers(mrg, mrg.Repetitions(1))
+}
+
+// identifiers classifies CX repetitions by identifier type code (CX-5).
+// Untyped 13-digit identifiers are taken to be Thai national IDs.
+func identifiers(segment *Segment, repetitions []string) hospital.JSONPatient {
+	var patient hospital.JSONPatient
+	for _, repetition := range repetitions {
+		value := segment.Component(repetition, 1)
+		if value == "" {
+			continue
+		}
+		typeCode := strings.ToUpper(segment.Component(repetition, 5))
+		switch {
+		case contains(nationalIDTypes, typeCode), typeCode == "" && isNationalIDShape(value):
+			patient.NationalID = value
+		case contains(passportTypes, typeCode):
+			patient.PassportID = value
+		case contains(hnTypes, typeCode):
+			if patient.PatientHN == "" {
+				patient.PatientHN = value
+			}
+		}
+	}
+	return patient
+}
+
+// name returns first, middle and last name in language from the PID-5 XPN
+// repetitions, preferring the legal name (type L). v2 has no language on
+// names, so it is inferred from the script.
+func name(pid *Segment, language string) ([3]string, bool) {
+	var found [3]string
+	ok, legal := false, false
+	for _, repetition := range pid.Repetitions(5) {
+		parts := [3]string{
+			pid.Component(repetition, 2),
+			pid.Component(repetition, 3),
+			pid.Subcomponent(repetition, 1, 1),
+		}
+		if parts == [3]string{} || scriptLanguage(strings.Join(parts[:], "")) != language {
+			continue
+		}
+		isLegal := strings.EqualFold(pid.Component(repetition, 7), "L")
+		if !ok || (isLegal && !legal) {
+			found, ok, legal = parts, true, isLegal
+		}
+	}
+	return found, ok
+}
+
+// telecom picks a phone number, preferring mobiles, and an email address from
+// XTN repetitions.
+func telecom(pid *Segment, repetitions []string) (phone, email string) {
+	mobile := false
+	for _, repetition := range repetitions {
+		use := strings.ToUpper(pid.Component(repetition, 2))
+		equipment := strings.ToUpper(pid.Component(repetition, 3))
+
+		if use == "NET" || equipment == "INTERNET" || pid.Component(repetition, 4) != "" {
+			if email == "" {
+				email = pid.Component(repetition, 4)
+				if email == "" {
+					email = pid.Component(repetition, 1)
+				}
+			}
+			continue
+		}
+
+		number := pid.Component(repetition, 12)
+		if number == "" {
+			number = pid.Component(repetition, 1)
+		}
+		if number == "" && pid.Component(repetition, 7) != "" {
+			number = pid.Component(repetition, 6) + pid.Component(repetition, 7)
+			if country := strings.TrimPrefix(pid.Component(repetition, 5), "+"); country != "" {
+				number = "+" + country + number
+			}
+		}
+		if number == "" {
+			continue
+		}
+		isMobile := equipment == "CP"
+		if phone == "" || (isMobile && !mobile) {
+			phone, mobile = number, isMobile
+		}
+	}
+	return phone, email
+}
+
+func scriptLanguage(text string) string {
+	for _, r := range text {
+		if unicode.Is(unicode.Thai, r) {
+			return "th"
+		}
+	}
+	return "en"
+}
+
+func isNationalIDShape(value string) bool {
+	return len(value) == 13 && strings.Trim(value, "0123456789") == ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package hl7
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encoding holds the delimiters declared in MSH-1 and MSH-2.
+type Encoding struct {
+	Field        byte
+	Component    byte
+	Repetition   byte
+	Escape       byte
+	Subcomponent byte
+}
+
+var DefaultEncoding = Encoding{Field: '|', Component: '^', Repetition: '~', Escape: '\\', Subcomponent: '&'}
+
+// Message is a parsed HL7 v2 message. Only what ADT ingestion needs is
+// modelled: segments, fields, repetitions and components.
+type Message struct {
+	Segments []*Segment
+	Encoding Encoding
+}
+
+// Segment holds the raw fields of one segment. For MSH, Field(1) is the field
+// separator itself so that field numbers match the standard.
+type Segment struct {
+	Name   string
+	fields []string
+	enc    Encoding
+}
+
+// Parse parses a message whose segments are separated by CR, tolerating LF
+// and CRLF as sent by some interface engines.
+func Parse(data []byte) (*Message, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	text = strings.Trim(text, "\r")
+
+	if !strings.HasPrefix(text, "MSH") || len(text) < 8 {
+		return nil, errors.New("message does not start with an MSH segment")
+	}
+	enc := Encoding{
+		Field:        text[3],
+		Component:    text[4],
+		Repetition:   text[5],
+		Escape:       text[6],
+		Subcomponent: text[7],
+	}
+
+	msg := &Message{Encoding: enc}
+	for _, line := range strings.Split(text, "\r") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, string(enc.Field))
+		if len(fields[0]) != 3 {
+			return nil, fmt.Errorf("invalid segment name %q", fields[0])
+		}
+		if fields[0] == "MSH" {
+			fields = append([]string{"MSH", string(enc.Field)}, fields[1:]...)
+		}
+		msg.Segments = append(msg.Segments, &Segment{Name: fields[0], fields: fields, enc: enc})
+	}
+	return msg, nil
+}
+
+// Segment returns the first segment with the given name, or nil.
+func (m *Message) Segment(name string) *Segment {
+	for _, segment := range m.Segments {
+		if segment.Name == name {
+			return segment
+		}
+	}
+	return nil
+}
+
+// Header returns the MSH segment.
+func (m *Message) Header() *Segment {
+	return m.Segment("MSH")
+}
+
+// Type returns the message code and trigger event from MSH-9, e.g. ADT and A04.
+func (m *Message) Type() (code, event string) {
+	header := m.Header()
+	return header.Get(9, 1), header.Get(9, 2)
+}
+
+// ControlID returns MSH-10.
+func (m *Message) ControlID() string {
+	return m.Header().Get(10, 1)
+}
+
+// Bytes encodes the message with CR segment terminators.
+func (m *Message) Bytes() []byte {
+	var b strings.Builder
+	for _, segment := range m.Segments {
+		fields := segment.fields
+		if segment.Name == "MSH" {
+			fields = append([]string{"MSH"}, fields[2:]...)
+		}
+		b.WriteString(strings.Join(fields, string(m.Encoding.Field)))
+		b.WriteByte('\r')
+	}
+	return []byte(b.String())
+}
+
+// Field returns the raw, still escaped, value of field n (1-based).
+func (s *Segment) Field(n int) string {
+	if s == nil || n <= 0 || n >= len(s.fields) {
+		return ""
+	}
+	return s.fields[n]
+}
+
+// Repetitions returns the raw repetitions of field n.
+func (s *Segment) Repetitions(n int) []string {
+	field := s.Field(n)
+	if field == "" {
+		return nil
+	}
+	if s.Name == "MSH" && n <= 2 {
+		return []string{field}
+	}
+	return strings.Split(field, string(s.enc.Repetition))
+}
+
+// Get returns component c (1-based) of the first repetition of field n, unescaped.
+func (s *Segment) Get(n, c int) string {
+	repetitions := s.Repetitions(n)
+	if len(repetitions) == 0 {
+		return ""
+	}
+	return s.Component(repetitions[0], c)
+}
+
+// Component returns component c (1-based) of a raw field repetition, unescaped.
+func (s *Segment) Component(repetition string, c int) string {
+	components := strings.Split(repetition, string(s.enc.Component))
+	if c <= 0 || c > len(components) {
+		return ""
+	}
+	return s.enc.UnescapeText(components[c-1])
+}
+
+// Subcomponent returns subcomponent sc of component c of a raw field
+// repetition, unescaped.
+func (s *Segment) Subcomponent(repetition string, c, sc int) string {
+	components := strings.Split(repetition, string(s.enc.Component))
+	if c <= 0 || c > len(components) {
+		return ""
+	}
+	subcomponents := strings.Split(components[c-1], string(s.enc.Subcomponent))
+	if sc <= 0 || sc > len(subcomponents) {
+		return ""
+	}
+	return s.enc.UnescapeText(subcomponents[sc-1])
+}
+
+// UnescapeText resolves the standard delimiter escapes (\F\, \S\, \T\, \R\, \E\).
+// Other escape sequences, such as formatting commands, are dropped.
+func (e Encoding) UnescapeText(value string) string {
+	esc := string(e.Escape)
+	if !strings.Contains(value, esc) {
+		return value
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(value, esc)
+		if start < 0 {
+			b.WriteString(value)
+			return b.String()
+		}
+		end := strings.Index(value[start+1:], esc)
+		if end < 0 {
+			b.WriteString(value)
+			return b.String()
+		}
+		b.WriteString(value[:start])
+		switch value[start+1 : start+1+end] {
+		case "F":
+			b.WriteByte(e.Field)
+		case "S":
+			b.WriteByte(e.Component)
+		case "T":
+			b.WriteByte(e.Subcomponent)
+		case "R":
+			b.WriteByte(e.Repetition)
+		case "E":
+			b.WriteByte(e.Escape)
+		}
+		value = value[start+end+2:]
+	}
+}
+
+// EscapeText is the inverse of UnescapeText.
+func (e Encoding) EscapeText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case e.Escape:
+			b.WriteString(string(e.Escape) + "E" + string(e.Escape))
+		case e.Field:
+			b.WriteString(string(e.Escape) + "F" + string(e.Escape))
+		case e.Component:
+			b.WriteString(string(e.Escape) + "S" + string(e.Escape))
+		case e.Subcomponent:
+			b.WriteString(string(e.Escape) + "T" + string(e.Escape))
+		case e.Repetition:
+			b.WriteString(string(e.Escape) + "R" + string(e.Escape))
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package hl7
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adtA04 = "MSH|^~\\&|HIS|HOSPA|AGNOS|AGNOS|20250101080000||ADT^A04^ADT_A01|MSG0001|P|2.5\r" +
+	"EVN|A04|20250101080000\r" +
+	"PID|1||1234567890121^^^MOI^NI~HN001234^^^HOSPA^MR~AA1234567^^^THA^PPN||ใจดี^สมชาย^^^^^L~Jaidee^Somchai^K^^^^L||19900101|M|||||021234567^PRN^PH~^NET^Internet^somchai@example.com~^PRN^CP^^66^81^2345678\r"
+
+func TestParse(t *testing.T) {
+	msg, err := Parse([]byte(adtA04))
+	require.NoError(t, err)
+
+	require.Len(t, msg.Segments, 3)
+	code, event := msg.Type()
+	assert.Equal(t, "ADT", code)
+	assert.Equal(t, "A04", event)
+	assert.Equal(t, "MSG0001", msg.ControlID())
+	assert.Equal(t, "|", msg.Header().Field(1))
+	assert.Equal(t, "^~\\&", msg.Header().Field(2))
+	assert.Equal(t, "HOSPA", msg.Header().Get(4, 1))
+
+	pid := msg.Segment("PID")
+	require.NotNil(t, pid)
+	assert.Len(t, pid.Repetitions(3), 3)
+	assert.Equal(t, "HN001234", pid.Component(pid.Repetitions(3)[1], 1))
+	assert.Nil(t, msg.Segment("MRG"))
+	assert.Equal(t, "", pid.Get(99, 1))
+}
+
+func TestParse_LineEndings(t *testing.T) {
+	msg, err := Parse([]byte(strings.ReplaceAll(adtA04, "\r", "\r\n")))
+	require.NoError(t, err)
+	assert.Len(t, msg.Segments, 3)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse([]byte("PID|1||123\r"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte("MSH|^~\\&|HIS\rPIDX|1\r"))
+	assert.Error(t, err)
+}
+
+func TestEncoding_Escape(t *testing.T) {
+	raw := `A|B^C&D~E\F`
+	escaped := DefaultEncoding.EscapeText(raw)
+	assert.Equal(t, `A\F\B\S\C\T\D\R\E\E\F`, escaped)
+	assert.Equal(t, raw, DefaultEncoding.UnescapeText(escaped))
+	assert.Equal(t, "line1line2", DefaultEncoding.UnescapeText(`line1\.br\line2`))
+}
+
+func TestMessage_BytesRoundTrip(t *testing.T) {
+	msg, err := Parse([]byte(adtA04))
+	require.NoError(t, err)
+	assert.Equal(t, adtA04, string(msg.Bytes()))
+}
+
+func TestPatientFromPID(t *testing.T) {
+	msg, err := Parse([]byte(adtA04))
+	require.NoError(t, err)
+
+	patient := PatientFromPID(msg.Segment("PID"))
+
+	assert.Equal(t, "1234567890121", patient.NationalID)
+	assert.Equal(t, "AA1234567", patient.PassportID)
+	assert.Equal(t, "HN001234", patient.PatientHN)
+	assert.Equal(t, "สมชาย", patient.FirstNameTH)
+	assert.Equal(t, "ใจดี", patient.LastNameTH)
+	assert.Equal(t, "Somchai", patient.FirstNameEN)
+	assert.Equal(t, "K", patient.MiddleNameEN)
+	assert.Equal(t, "Jaidee", patient.LastNameEN)
+	assert.Equal(t, "1990-01-01", patient.DateOfBirth)
+	assert.Equal(t, "M", patient.Gender)
+	assert.Equal(t, "+66812345678", patient.PhoneNumber)
+	assert.Equal(t, "somchai@example.com", patient.Email)
+
+	_, err = patient.ToEntity(nil)
+	assert.NoError(t, err)
+}
+
+func TestRetiredFromMRG(t *testing.T) {
+	msg, err := Parse([]byte("MSH|^~\\&|HIS|HOSPA|||20250101||ADT^A40|MSG2|P|2.5\rMRG|AA1234567^^^THA^PPN~HN9^^^HOSPA^MR\r"))
+	require.NoError(t, err)
+
+	retired := RetiredFromMRG(msg.Segment("MRG"))
+	assert.Equal(t, "AA1234567", retired.PassportID)
+	assert.Equal(t, "HN9", retired.PatientHN)
+	assert.Empty(t, retired.NationalID)
+}
+
+func TestNewACK(t *testing.T) {
+	msg, err := Parse([]byte(adtA04))
+	require.NoError(t, err)
+
+	ack, err := Parse(NewACK(msg, AckError, "bad|data").Bytes())
+	require.NoError(t, err)
+
+	code, event := ack.Type()
+	assert.Equal(t, "ACK", code)
+	assert.Equal(t, "A04", event)
+	assert.Equal(t, "HIS", ack.Header().Get(5, 1))
+	assert.Equal(t, "HOSPA", ack.Header().Get(6, 1))
+	assert.NotEmpty(t, ack.ControlID())
+
+	msa := ack.Segment("MSA")
+	assert.Equal(t, AckError, msa.Get(1, 1))
+	assert.Equal(t, "MSG0001", msa.Get(2, 1))
+	assert.Equal(t, "bad|data", msa.Get(3, 1))
+}
+
+func TestNewACK_Unparseable(t *testing.T) {
+	ack, err := Parse(NewACK(nil, AckReject, "garbage").Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, AckReject, ack.Segment("MSA").Get(1, 1))
+	assert.Equal(t, "", ack.Segment("MSA").Get(2, 1))
+}
@@ -0,0 +1,294 @@
+package hl7
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// MLLP frames each message as <VT> message <FS><CR>.
+const (
+	startBlock = 0x0b
+	endBlock   = 0x1c
+	carriageCR = 0x0d
+
+	// maxMessageSize bounds a single framed message.
+	maxMessageSize = 1 << 20
+)
+
+var ErrMessageTooLarge = errors.New("hl7: message exceeds maximum size")
+
+// ReadMessage reads one MLLP frame and returns its payload. Bytes before the
+// start block are discarded. It reads through bufio.Reader.ReadSlice, a buffer
+// at a time, so a frame that never ends fails with ErrMessageTooLarge once it
+// exceeds maxMessageSize rather than being buffered whole.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	for {
+		_, err := r.ReadSlice(startBlock)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+
+	var payload []byte
+	for {
+		chunk, err := r.ReadSlice(endBlock)
+		if len(payload)+len(chunk) > maxMessageSize {
+			return nil, ErrMessageTooLarge
+		}
+		payload = append(payload, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		next, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if next == carriageCR {
+			return payload[:len(payload)-1], nil
+		}
+		// An FS inside the payload; keep reading.
+		payload = append(payload, next)
+	}
+}
+
+// WriteMessage writes payload as one MLLP frame.
+func WriteMessage(w io.Writer, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, payload...)
+	frame = append(frame, endBlock, carriageCR)
+	_, err := w.Write(frame)
+	return err
+}
+
+// Handler processes one message and returns its acknowledgment. The context
+// carries the address of the sender, see PeerFromContext.
+type Handler interface {
+	HandleMessage(ctx context.Context, msg *Message) *Message
+}
+
+type peerKey struct{}
+
+// NewPeerContext returns a copy of ctx carrying the address of the peer a
+// message was read from.
+func NewPeerContext(ctx context.Context, peer netip.Addr) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// PeerFromContext returns the address of the peer that sent the message being
+// handled; it is invalid when the connection's address is not an IP address.
+func PeerFromContext(ctx context.Context) netip.Addr {
+	peer, _ := ctx.Value(peerKey{}).(netip.Addr)
+	return peer
+}
+
+func peerAddr(addr net.Addr) netip.Addr {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.AddrPort().Addr().Unmap()
+	}
+	return netip.Addr{}
+}
+
+// Server accepts MLLP connections and acknowledges every message it reads.
+type Server struct {
+	Addr    string
+	Handler Handler
+	// IdleTimeout closes connections that send nothing for this long; zero
+	// keeps them open, as interface engines usually hold one connection.
+	IdleTimeout time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+func NewServer(addr string, handler Handler, idleTimeout time.Duration) *Server {
+	return &Server{
+		Addr:        addr,
+		Handler:     handler,
+		IdleTimeout: idleTimeout,
+	}
+}
+
+// ListenAndServe listens on s.Addr and serves until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener until ctx is cancelled. On shutdown it
+// stops accepting, lets messages being processed finish and be acknowledged,
+// then closes every connection before returning.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		s.mu.Lock()
+		for conn := range s.conns {
+			// Unblocks idle reads; a message in flight is still acknowledged.
+			conn.SetReadDeadline(time.Now())
+		}
+		s.mu.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			shuttingDown := parent.Err() != nil
+			cancel()
+			wg.Wait()
+			if shuttingDown {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// ListenerAddr returns the address the server is listening on once Serve has started.
+func (s *Server) ListenerAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	peerCtx := NewPeerContext(ctx, peerAddr(conn.RemoteAddr()))
+	reader := bufio.NewReader(conn)
+	for {
+		if !s.armRead(ctx, conn) {
+			return
+		}
+
+		payload, err := ReadMessage(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !isTimeout(err) {
+				slog.Warn("hl7 read failed", "remote_addr", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+
+		var ack *Message
+		msg, err := Parse(payload)
+		if err != nil {
+			ack = NewACK(nil, AckReject, err.Error())
+		} else {
+			// Processing is detached from ctx so shutdown does not abort a
+			// message the sender will otherwise never see acknowledged.
+			ack = s.Handler.HandleMessage(context.WithoutCancel(peerCtx), msg)
+		}
+
+		if err := WriteMessage(conn, ack.Bytes()); err != nil {
+			slog.Warn("hl7 write ack failed", "remote_addr", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+	}
+}
+
+// armRead sets the idle deadline for the next read unless the server is
+// shutting down. It holds the lock so it cannot undo the deadline Serve sets
+// on shutdown.
+func (s *Server) armRead(ctx context.Context, conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	if s.IdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+	}
+	return true
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Client sends messages over one MLLP connection and waits for each
+// acknowledgment. It is not safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Send writes payload and returns the parsed acknowledgment.
+func (c *Client) Send(ctx context.Context, payload []byte) (*Message, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	if err := WriteMessage(c.conn, payload); err != nil {
+		return nil, err
+	}
+	reply, err := ReadMessage(c.reader)
+	if err != nil {
+		return nil, fmt.Errorf("read ack: %w", err)
+	}
+	return Parse(reply)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
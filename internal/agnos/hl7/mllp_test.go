@@ -0,0 +1,164 @@
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handlerFunc func(ctx context.Context, msg *Message) *Message
+
+func (f handlerFunc) HandleMessage(ctx context.Context, msg *Message) *Message {
+	return f(ctx, msg)
+}
+
+func startServer(t *testing.T, handler Handler) (string, context.CancelFunc, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	server := NewServer("", handler, 0)
+	go func() {
+		done <- server.Serve(ctx, listener)
+	}()
+	t.Cleanup(cancel)
+	return listener.Addr().String(), cancel, done
+}
+
+func TestReadWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("noise")
+	require.NoError(t, WriteMessage(&buf, []byte("MSH|one\r")))
+	require.NoError(t, WriteMessage(&buf, []byte("MSH|two\x1cstill two\r")))
+
+	reader := bufio.NewReader(&buf)
+	first, err := ReadMessage(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "MSH|one\r", string(first))
+
+	second, err := ReadMessage(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "MSH|two\x1cstill two\r", string(second))
+}
+
+// endless yields the same byte forever, as a peer that never ends its frame.
+type endless byte
+
+func (b endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestReadMessage_TooLarge(t *testing.T) {
+	reader := bufio.NewReader(io.MultiReader(bytes.NewReader([]byte{startBlock}), endless('A')))
+
+	_, err := ReadMessage(reader)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+}
+
+func TestServer_AcknowledgesMessages(t *testing.T) {
+	var received []string
+	var peers []netip.Addr
+	var mu sync.Mutex
+	addr, _, _ := startServer(t, handlerFunc(func(ctx context.Context, msg *Message) *Message {
+		mu.Lock()
+		received = append(received, msg.ControlID())
+		peers = append(peers, PeerFromContext(ctx))
+		mu.Unlock()
+		return NewACK(msg, AckAccept, "")
+	}))
+
+	client, err := Dial(context.Background(), addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	for _, controlID := range []string{"MSG1", "MSG2"} {
+		ack, err := client.Send(context.Background(), []byte("MSH|^~\\&|HIS|HOSPA|||20250101||ADT^A08|"+controlID+"|P|2.5\rPID|1\r"))
+		require.NoError(t, err)
+		assert.Equal(t, AckAccept, ack.Segment("MSA").Get(1, 1))
+		assert.Equal(t, controlID, ack.Segment("MSA").Get(2, 1))
+	}
+
+	mu.Lock()
+	assert.Equal(t, []string{"MSG1", "MSG2"}, received)
+	loopback := netip.MustParseAddr("127.0.0.1")
+	assert.Equal(t, []netip.Addr{loopback, loopback}, peers)
+	mu.Unlock()
+}
+
+func TestServer_RejectsUnparseableMessage(t *testing.T) {
+	addr, _, _ := startServer(t, handlerFunc(func(ctx context.Context, msg *Message) *Message {
+		t.Error("handler called for unparseable message")
+		return nil
+	}))
+
+	client, err := Dial(context.Background(), addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ack, err := client.Send(context.Background(), []byte("not hl7"))
+	require.NoError(t, err)
+	assert.Equal(t, AckReject, ack.Segment("MSA").Get(1, 1))
+}
+
+func TestServer_ShutdownFinishesInFlightMessage(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	addr, cancel, done := startServer(t, handlerFunc(func(ctx context.Context, msg *Message) *Message {
+		close(started)
+		<-release
+		return NewACK(msg, AckAccept, "")
+	}))
+
+	busy, err := Dial(context.Background(), addr)
+	require.NoError(t, err)
+	defer busy.Close()
+	idle, err := Dial(context.Background(), addr)
+	require.NoError(t, err)
+	defer idle.Close()
+
+	acked := make(chan *Message, 1)
+	go func() {
+		ack, err := busy.Send(context.Background(), []byte("MSH|^~\\&|HIS|HOSPA|||20250101||ADT^A04|MSG1|P|2.5\r"))
+		assert.NoError(t, err)
+		acked <- ack
+	}()
+	<-started
+
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("server stopped before the in-flight message was acknowledged")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case ack := <-acked:
+		require.NotNil(t, ack)
+		assert.Equal(t, AckAccept, ack.Segment("MSA").Get(1, 1))
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight message was not acknowledged")
+	}
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop")
+	}
+
+	_, err = Dial(context.Background(), addr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package hospital
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/Markikie/agnos/internal/agnos/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// NewHTTPClient builds the outbound client for a hospital: TLS settings are
+// applied to the transport, then requests are signed and authenticated, and
+// finally measured and traced. Signing wraps auth, so it runs before the auth
+// headers are set: the signature covers the method, request URI, timestamp
+// and body only, as Sign describes.
+func NewHTTPClient(config Config) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	var roundTripper http.RoundTripper = transport
+	switch config.Auth.Type {
+	case AuthNone, "":
+	case AuthAPIKey:
+		if config.Auth.APIKey == "" {
+			return nil, errors.New("api_key auth requires api_key")
+		}
+		header := config.Auth.APIKeyHeader
+		if header == "" {
+			header = "X-API-Key"
+		}
+		roundTripper = &headerTransport{base: roundTripper, header: header, value: config.Auth.APIKey}
+	case AuthOAuth2:
+		if config.Auth.TokenURL == "" || config.Auth.ClientID == "" {
+			return nil, errors.New("oauth2 auth requires token_url and client_id")
+		}
+		// The token endpoint shares the TLS settings but not the auth or signing layers.
+		tokens := newTokenSource(config.Auth, &http.Client{Transport: transport, Timeout: defaultTimeout})
+		roundTripper = &bearerTransport{base: roundTripper, tokens: tokens}
+	default:
+		return nil, fmt.Errorf("unknown auth type: %s", config.Auth.Type)
+	}
+
+	if config.Signing.Secret != "" {
+		roundTripper = &signingTransport{base: roundTripper, keyID: config.Signing.KeyID, secret: []byte(config.Signing.Secret)}
+	}
+	roundTripper = &metricsTransport{base: roundTripper, hospital: config.Name}
+	roundTripper = &tracingTransport{base: roundTripper, hospital: config.Name}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   timeout,
+	}, nil
+}
+
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// metricsTransport observes the latency of each call, up to its response
+// headers, and counts the calls that failed or met a server error. Outcomes
+// are also recorded for the hospital's Upstream.
+type metricsTransport struct {
+	base     http.RoundTripper
+	hospital string
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	metrics.HospitalRequestDuration.WithLabelValues(t.hospital).Observe(time.Since(start).Seconds())
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	if failed {
+		metrics.HospitalRequestErrors.WithLabelValues(t.hospital).Inc()
+	}
+	recordUpstream(t.hospital, failed)
+	return resp, err
+}
+
+// tracingTransport records a client span for each call and passes its trace
+// context on in the traceparent header. The URL is left out of the span, as
+// it may hold the identifier looked up.
+type tracingTransport struct {
+	base     http.RoundTripper
+	hospital string
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Tracer().Start(req.Context(), "hospital "+t.hospital,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("hospital", t.hospital),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		// Transport errors quote the URL.
+		span.SetStatus(codes.Error, logging.Scrub(err.Error()))
+	case resp.StatusCode >= http.StatusInternalServerError:
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	default:
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	return resp, err
+}
+
+type headerTransport struct {
+	base   http.RoundTripper
+	header string
+	value  string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.value)
+	return t.base.RoundTrip(req)
+}
+
+type bearerTransport struct {
+	base   http.RoundTripper
+	tokens *tokenSource
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,448 @@
+package hospital
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/Markikie/agnos/internal/agnos/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+const patientJSON = `{"national_id":"1234567890121","first_name_en":"Somchai","last_name_en":"Jaidee","date_of_birth":"1990-01-01"}`
+
+type testPKI struct {
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+	dir    string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "agnos test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return &testPKI{caCert: cert, caKey: key, caFile: caFile, dir: dir}
+}
+
+// issue signs a leaf certificate and writes it with its key to the PKI directory.
+func (p *testPKI) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) (tls.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.caCert, &key.PublicKey, p.caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certFile := filepath.Join(p.dir, name+".pem")
+	keyFile := filepath.Join(p.dir, name+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return pair, certFile, keyFile
+}
+
+func TestJSONAdapter_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert, _, _ := pki.issue(t, "server", 2, x509.ExtKeyUsageServerAuth)
+	_, clientCertFile, clientKeyFile := pki.issue(t, "client", 3, x509.ExtKeyUsageClientAuth)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(pki.caCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/patient/search/1234567890121", r.URL.Path)
+		assert.Equal(t, "client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Write([]byte(patientJSON))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{
+		Name:    "hospital-a",
+		BaseURL: server.URL,
+		TLS:     TLSConfig{CAFile: pki.caFile, CertFile: clientCertFile, KeyFile: clientKeyFile},
+	})
+	require.NoError(t, err)
+
+	patient, err := adapter.FetchPatient(context.Background(), "1234567890121")
+	require.NoError(t, err)
+	assert.Equal(t, "Somchai", patient.FirstNameEN)
+
+	// Without a client certificate the handshake is rejected.
+	adapter, err = NewAdapter(Config{Name: "hospital-a", BaseURL: server.URL, TLS: TLSConfig{CAFile: pki.caFile}})
+	require.NoError(t, err)
+	_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestJSONAdapter_APIKey(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hospital-Key") != "secret-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(patientJSON))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	adapter, err := NewAdapter(Config{
+		Name:    "hospital-a",
+		BaseURL: server.URL,
+		TLS:     TLSConfig{CAFile: caFile},
+		Auth:    AuthConfig{Type: AuthAPIKey, APIKey: "secret-key", APIKeyHeader: "X-Hospital-Key"},
+	})
+	require.NoError(t, err)
+
+	_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+	assert.NoError(t, err)
+}
+
+func TestJSONAdapter_OAuth2ClientCredentialsCachesToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		clientID, clientSecret, _ := r.BasicAuth()
+		assert.Equal(t, "agnos", clientID)
+		assert.Equal(t, "s3cret", clientSecret)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "patient.read", r.PostForm.Get("scope"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token-1","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/patient/search/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(patientJSON))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{
+		Name:    "hospital-a",
+		BaseURL: server.URL,
+		Auth: AuthConfig{
+			Type:         AuthOAuth2,
+			TokenURL:     server.URL + "/oauth/token",
+			ClientID:     "agnos",
+			ClientSecret: "s3cret",
+			Scopes:       []string{"patient.read"},
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), tokenRequests.Load())
+}
+
+func TestTokenSource_Lifetime(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		lifetime time.Duration
+	}{
+		{name: "stated", response: `{"access_token":"token-1","expires_in":3600}`, lifetime: time.Hour - tokenExpiryMargin},
+		{name: "absent expires_in", response: `{"access_token":"token-1"}`, lifetime: defaultTokenTTL - tokenExpiryMargin},
+		{name: "shorter than the margin", response: `{"access_token":"token-1","expires_in":10}`, lifetime: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokenRequests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tokenRequests.Add(1)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			source := newTokenSource(AuthConfig{TokenURL: server.URL}, server.Client())
+			start := time.Now()
+			for i := 0; i < 2; i++ {
+				token, err := source.Token(context.Background())
+				require.NoError(t, err)
+				assert.Equal(t, "token-1", token)
+			}
+
+			assert.Equal(t, int32(1), tokenRequests.Load())
+			assert.WithinDuration(t, start.Add(tt.lifetime), source.expires, time.Second)
+		})
+	}
+}
+
+func TestJSONAdapter_HMACSigning(t *testing.T) {
+	secret := []byte("signing-secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := Sign(secret, r.Method, r.URL.RequestURI(), r.Header.Get(SignatureTimestampHeader), body)
+		if r.Header.Get(SignatureHeader) != expected || r.Header.Get(SignatureKeyIDHeader) != "agnos-1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(patientJSON))
+	}))
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{
+		Name:    "hospital-a",
+		BaseURL: server.URL,
+		Signing: SigningConfig{KeyID: "agnos-1", Secret: string(secret)},
+	})
+	require.NoError(t, err)
+
+	_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+	assert.NoError(t, err)
+}
+
+func TestJSONAdapter_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "not found", status: http.StatusNotFound, wantErr: ErrPatientNotFound},
+		{name: "server error", status: http.StatusBadGateway, wantErr: ErrUnavailable},
+		{name: "malformed body", status: http.StatusOK, body: `{"national_id":`, wantErr: ErrInvalidResponse},
+		{name: "missing identifiers", status: http.StatusOK, body: `{"date_of_birth":"1990-01-01"}`, wantErr: ErrInvalidResponse},
+		{name: "invalid national id", status: http.StatusOK, body: `{"national_id":"123456789012","date_of_birth":"1990-01-01"}`, wantErr: ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			adapter, err := NewAdapter(Config{Name: "hospital-a", BaseURL: server.URL})
+			require.NoError(t, err)
+
+			_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+}
+
+func TestJSONAdapter_Metrics(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(patientJSON))
+	}))
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{Name: "hospital-metrics", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+	require.NoError(t, err)
+	_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+	require.ErrorIs(t, err, ErrUnavailable)
+
+	var duration dto.Metric
+	require.NoError(t, metrics.HospitalRequestDuration.WithLabelValues("hospital-metrics").(prometheus.Metric).Write(&duration))
+	assert.Equal(t, uint64(2), duration.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.HospitalRequestErrors.WithLabelValues("hospital-metrics")))
+}
+
+func TestJSONAdapter_Tracing(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(patientJSON))
+	}))
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{Name: "hospital-a", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "request")
+	_, err = adapter.FetchPatient(ctx, "1234567890121")
+	require.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	call := spans[0]
+	assert.Equal(t, "hospital hospital-a", call.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), call.Parent.SpanID())
+	assert.Equal(t, fmt.Sprintf("00-%s-%s-01", call.SpanContext.TraceID(), call.SpanContext.SpanID()), traceparent)
+	for _, attr := range call.Attributes {
+		assert.NotContains(t, attr.Value.Emit(), "1234567890121")
+	}
+}
+
+func TestJSONAdapter_InvalidRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"passport_id":"AA1234567","passport_country":"USA","phone_number":"12345","date_of_birth":"1990-01-01"}`))
+	}))
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{Name: "hospital-a", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	_, err = adapter.FetchPatient(context.Background(), "AA1234567")
+
+	var invalid *InvalidRecordError
+	require.True(t, errors.As(err, &invalid), "got %v", err)
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	assert.Equal(t, "AA1234567", invalid.Patient.PassportID)
+	assert.Equal(t, validation.Errors{
+		{Field: "passport_id", Message: validation.ErrPassportFormat.Error()},
+		{Field: "phone_number", Message: validation.ErrPhoneFormat.Error()},
+	}, invalid.Errors)
+}
+
+func TestJSONPatient_ToEntity_FormattedNationalID(t *testing.T) {
+	record := JSONPatient{NationalID: "1-2345-67890-12-1", DateOfBirth: "1990-01-01"}
+	patient, err := record.ToEntity(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890121", patient.NationalID)
+
+	record = JSONPatient{NationalID: "1 2345 67890 12 2", DateOfBirth: "1990-01-01"}
+	_, err = record.ToEntity(nil)
+	var invalid *InvalidRecordError
+	require.True(t, errors.As(err, &invalid), "got %v", err)
+	assert.Equal(t, validation.Errors{{Field: "national_id", Message: validation.ErrNationalIDChecksum.Error()}}, invalid.Errors)
+
+	record = JSONPatient{NationalID: " - ", DateOfBirth: "1990-01-01"}
+	_, err = record.ToEntity(nil)
+	assert.EqualError(t, err, "record has neither national_id nor passport_id")
+}
+
+func TestJSONAdapter_DateFormats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"national_id":"1234567890121","date_of_birth":"31.01.2533"}`))
+	}))
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{Name: "hospital-a", BaseURL: server.URL, DateFormats: []string{"02.01.2006"}})
+	require.NoError(t, err)
+
+	patient, err := adapter.FetchPatient(context.Background(), "1234567890121")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), patient.DateOfBirth)
+
+	_, err = NewRegistry([]Config{{Name: "hospital-a", BaseURL: server.URL, DateFormats: []string{"02.01"}}})
+	assert.Error(t, err)
+}
+
+func TestRegistry_UnknownInvalidRecordsPolicy(t *testing.T) {
+	_, err := NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example", InvalidRecords: "ignore"}})
+	assert.Error(t, err)
+}
+
+func TestRegistry_RetentionPolicies(t *testing.T) {
+	_, err := NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		Retention: []RetentionPolicy{{Source: entity.PatientSourceAPI, MaxIdle: Duration(24 * time.Hour)}}}})
+	assert.NoError(t, err)
+
+	_, err = NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		Retention: []RetentionPolicy{{Source: "cache", MaxIdle: Duration(24 * time.Hour)}}}})
+	assert.Error(t, err)
+
+	_, err = NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		Retention: []RetentionPolicy{{Source: entity.PatientSourceAPI}}}})
+	assert.Error(t, err)
+}
+
+func TestRegistry_HL7AllowedPeers(t *testing.T) {
+	_, err := NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		HL7: HL7Config{SendingFacility: "HOSPA", AllowedPeers: []string{"10.0.0.0/24", "192.0.2.7"}}}})
+	assert.NoError(t, err)
+
+	_, err = NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		HL7: HL7Config{SendingFacility: "HOSPA"}}})
+	assert.Error(t, err)
+
+	_, err = NewRegistry([]Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example",
+		HL7: HL7Config{SendingFacility: "HOSPA", AllowedPeers: []string{"his.hospital-a.example"}}}})
+	assert.Error(t, err)
+}
+
+func TestRegistry_UnsupportedHospital(t *testing.T) {
+	registry, err := NewRegistry(DefaultConfigs())
+	require.NoError(t, err)
+
+	_, err = registry.Adapter("hospital-a")
+	assert.NoError(t, err)
+	_, err = registry.Adapter("hospital-z")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
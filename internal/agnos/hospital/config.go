@@ -0,0 +1,170 @@
+package hospital
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+)
+
+const (
+	TypeJSON = "json"
+	TypeFHIR = "fhir"
+
+	AuthNone   = "none"
+	AuthAPIKey = "api_key"
+	AuthOAuth2 = "oauth2"
+
+	InvalidRecordsReject     = "reject"
+	InvalidRecordsQuarantine = "quarantine"
+)
+
+// Config describes how to reach one hospital's patient API.
+type Config struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	BaseURL string        `json:"base_url"`
+	Timeout Duration      `json:"timeout"`
+	TLS     TLSConfig     `json:"tls"`
+	Auth    AuthConfig    `json:"auth"`
+	Signing SigningConfig `json:"signing"`
+	Webhook WebhookConfig `json:"webhook"`
+	FHIR    FHIRConfig    `json:"fhir"`
+	HL7     HL7Config     `json:"hl7"`
+	// RateLimit caps background sync calls to the hospital, in requests per second.
+	RateLimit float64 `json:"rate_limit"`
+	// InvalidRecords is what happens to records from the hospital that fail
+	// validation: InvalidRecordsReject (the default) drops them, while
+	// InvalidRecordsQuarantine also keeps them for review. Neither applies them.
+	InvalidRecords string `json:"invalid_records"`
+	// DateFormats are the time.Parse layouts the hospital writes dates of
+	// birth in, tried in order after ISO 8601. Empty means
+	// birthdate.DefaultLayouts. Buddhist Era years are accepted in any layout.
+	DateFormats []string `json:"date_formats"`
+	// Retention lists how long records from the hospital are kept, per source.
+	Retention []RetentionPolicy `json:"retention"`
+}
+
+// RetentionPolicy purges the hospital's links of one source to patients not
+// accessed for MaxIdle. A patient left without links is erased.
+type RetentionPolicy struct {
+	// Source is entity.PatientSourceAPI, PatientSourceWebhook or
+	// PatientSourceImport.
+	Source  string   `json:"source"`
+	MaxIdle Duration `json:"max_idle"`
+}
+
+type TLSConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile     string `json:"ca_file"`
+	CertFile   string `json:"cert_file"`
+	KeyFile    string `json:"key_file"`
+	ServerName string `json:"server_name"`
+}
+
+type AuthConfig struct {
+	Type         string   `json:"type"`
+	APIKey       string   `json:"api_key"`
+	APIKeyHeader string   `json:"api_key_header"`
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// SigningConfig enables HMAC-SHA256 signing of outbound requests when Secret is set.
+type SigningConfig struct {
+	KeyID  string `json:"key_id"`
+	Secret string `json:"secret"`
+}
+
+// FHIRConfig overrides the identifier systems of a hospital whose Type is
+// TypeFHIR. Empty systems default to those in package fhir; an empty HN
+// system matches identifiers typed MR.
+type FHIRConfig struct {
+	NationalIDSystem string `json:"national_id_system"`
+	PassportSystem   string `json:"passport_system"`
+	HNSystem         string `json:"hn_system"`
+}
+
+// HL7Config identifies the hospital on the HL7 v2 MLLP listener. MLLP has no
+// authentication of its own, so a message is only attributed to the hospital
+// when it arrives from one of AllowedPeers.
+type HL7Config struct {
+	// SendingFacility is the MSH-4 value the hospital's ADT feed sends.
+	SendingFacility string `json:"sending_facility"`
+	// AllowedPeers are the IP addresses or CIDR prefixes the hospital's
+	// interface engine connects from. A feed needs at least one.
+	AllowedPeers []string `json:"allowed_peers"`
+}
+
+// Allows reports whether peer is one of AllowedPeers. Peers are validated by
+// NewRegistry, so unparseable ones never match.
+func (c HL7Config) Allows(peer netip.Addr) bool {
+	peer = peer.Unmap()
+	for _, allowed := range c.AllowedPeers {
+		if prefix, err := netip.ParsePrefix(allowed); err == nil && prefix.Contains(peer) {
+			return true
+		}
+		if addr, err := netip.ParseAddr(allowed); err == nil && addr.Unmap() == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookConfig enables inbound pushes from the hospital, signed with Secret
+// the same way outbound requests are signed, see Sign.
+type WebhookConfig struct {
+	Secret string `json:"secret"`
+	// Tolerance is the maximum clock skew accepted on the signature timestamp.
+	Tolerance Duration `json:"tolerance"`
+}
+
+// Duration is a time.Duration that reads as a Go duration string in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func DefaultConfigs() []Config {
+	return []Config{
+		{
+			Name:      "hospital-a",
+			Type:      TypeJSON,
+			BaseURL:   "https://hospital-a.api.co.th",
+			RateLimit: 1,
+		},
+	}
+}
+
+// LoadConfigs reads hospital configurations from a JSON file. Environment
+// variables referenced as ${VAR} are expanded so secrets can stay out of the
+// file. An empty path yields DefaultConfigs.
+func LoadConfigs(path string) ([]Config, error) {
+	if path == "" {
+		return DefaultConfigs(), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+	if err := json.Unmarshal([]byte(os.ExpandEnv(string(b))), &configs); err != nil {
+		return nil, fmt.Errorf("parse hospital config %s: %w", path, err)
+	}
+	return configs, nil
+}
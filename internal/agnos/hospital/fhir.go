@@ -0,0 +1,155 @@
+package hospital
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/fhir"
+)
+
+// fhirAdapter looks patients up on a FHIR R4 server with
+// GET {base_url}/Patient?identifier={system}|{id}.
+type fhirAdapter struct {
+	baseURL string
+	systems fhir.IdentifierSystems
+	client  *http.Client
+}
+
+func newFHIRAdapter(baseURL string, config FHIRConfig, client *http.Client) Adapter {
+	systems := fhir.IdentifierSystems{
+		NationalID: config.NationalIDSystem,
+		Passport:   config.PassportSystem,
+		HN:         config.HNSystem,
+	}
+	if systems.NationalID == "" {
+		systems.NationalID = fhir.SystemNationalID
+	}
+	if systems.Passport == "" {
+		systems.Passport = fhir.SystemPassport
+	}
+
+	return &fhirAdapter{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		systems: systems,
+		client:  client,
+	}
+}
+
+// searchBundle is a Bundle whose entries are decoded lazily, since a search
+// may also return OperationOutcome and included resources.
+type searchBundle struct {
+	ResourceType string `json:"resourceType"`
+	Entry        []struct {
+		Resource json.RawMessage    `json:"resource"`
+		Search   *fhir.BundleSearch `json:"search"`
+	} `json:"entry"`
+}
+
+// FetchPatient searches by national ID when id looks like one, then by
+// passport. A search matching several distinct patients is treated as invalid
+// data rather than guessing.
+func (a *fhirAdapter) FetchPatient(ctx context.Context, id string) (*entity.Patient, error) {
+	for _, system := range a.identifierSystems(id) {
+		matches, err := a.search(ctx, system, id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			patient, err := matches[0].ToEntity(a.systems)
+			if err == nil {
+				err = ValidatePatient(patient, "")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+			}
+			return patient, nil
+		default:
+			return nil, fmt.Errorf("%w: %d patients match identifier", ErrInvalidResponse, len(matches))
+		}
+	}
+	return nil, ErrPatientNotFound
+}
+
+func (a *fhirAdapter) identifierSystems(id string) []string {
+	if len(id) == 13 && strings.Trim(id, "0123456789") == "" {
+		return []string{a.systems.NationalID, a.systems.Passport}
+	}
+	return []string{a.systems.Passport}
+}
+
+// search returns the distinct active patients carrying identifier system|id.
+func (a *fhirAdapter) search(ctx context.Context, system, id string) ([]*fhir.Patient, error) {
+	query := url.Values{"identifier": {system + "|" + id}}
+	apiURL := a.baseURL + "/Patient?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", fhir.ContentType)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	// A search never 404s for no matches; a 404 means the endpoint is wrong.
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var bundle searchBundle
+	if err := json.NewDecoder(resp.Body).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if bundle.ResourceType != "Bundle" {
+		return nil, fmt.Errorf("%w: expected Bundle, got %q", ErrInvalidResponse, bundle.ResourceType)
+	}
+
+	var matches []*fhir.Patient
+	seen := make(map[string]bool)
+	for _, entry := range bundle.Entry {
+		if entry.Search != nil && entry.Search.Mode != "" && entry.Search.Mode != "match" {
+			continue
+		}
+
+		var patient fhir.Patient
+		if err := json.Unmarshal(entry.Resource, &patient); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		if patient.ResourceType != "Patient" || (patient.Active != nil && !*patient.Active) {
+			continue
+		}
+		if !hasIdentifier(&patient, system, id) {
+			continue
+		}
+		if patient.ID != "" {
+			if seen[patient.ID] {
+				continue
+			}
+			seen[patient.ID] = true
+		}
+		matches = append(matches, &patient)
+	}
+	return matches, nil
+}
+
+// hasIdentifier guards against servers that match identifiers loosely.
+func hasIdentifier(patient *fhir.Patient, system, value string) bool {
+	for _, id := range patient.Identifier {
+		if id.System == system && id.Value == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package hospital
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/fhir"
+)
+
+const fhirPatientJSON = `{
+	"resourceType": "Patient",
+	"id": "p1",
+	"identifier": [
+		{"system": "https://terms.sil-th.org/id/th-cid", "value": "1234567890121"},
+		{"type": {"coding": [{"code": "MR"}]}, "system": "urn:hospital-c:hn", "value": "HN-77"}
+	],
+	"name": [
+		{"use": "official", "family": "ใจดี", "given": ["สมชาย"]},
+		{"extension": [{"url": "http://hl7.org/fhir/StructureDefinition/language", "valueCode": "en"}], "use": "official", "family": "Jaidee", "given": ["Somchai", "K."]}
+	],
+	"telecom": [{"system": "phone", "value": "021234567", "use": "home"}, {"system": "phone", "value": "0812345678", "use": "mobile"}],
+	"gender": "male",
+	"birthDate": "1990-01-01"
+}`
+
+func fhirSearchServer(t *testing.T, bodies map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/fhir/Patient", r.URL.Path)
+		assert.Equal(t, fhir.ContentType, r.Header.Get("Accept"))
+
+		body, ok := bodies[r.URL.Query().Get("identifier")]
+		if !ok {
+			body = `{"resourceType":"Bundle","type":"searchset","total":0}`
+		}
+		w.Header().Set("Content-Type", fhir.ContentType)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestFHIRAdapter_FetchPatientByNationalID(t *testing.T) {
+	server := fhirSearchServer(t, map[string]string{
+		fhir.SystemNationalID + "|1234567890121": `{"resourceType":"Bundle","type":"searchset","entry":[
+			{"resource":` + fhirPatientJSON + `,"search":{"mode":"match"}},
+			{"resource":{"resourceType":"Organization","id":"o1"},"search":{"mode":"include"}}
+		]}`,
+	})
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{Name: "hospital-c", Type: TypeFHIR, BaseURL: server.URL + "/fhir"})
+	require.NoError(t, err)
+
+	patient, err := adapter.FetchPatient(context.Background(), "1234567890121")
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890121", patient.NationalID)
+	assert.Equal(t, "HN-77", patient.PatientHN)
+	assert.Equal(t, "สมชาย", patient.FirstNameTH)
+	assert.Equal(t, "ใจดี", patient.LastNameTH)
+	assert.Equal(t, "Somchai", patient.FirstNameEN)
+	assert.Equal(t, "K.", patient.MiddleNameEN)
+	assert.Equal(t, "Jaidee", patient.LastNameEN)
+	assert.Equal(t, "0812345678", patient.PhoneNumber)
+	assert.Equal(t, "M", patient.Gender)
+	assert.Equal(t, "1990-01-01", patient.DateOfBirth.Format("2006-01-02"))
+}
+
+func TestFHIRAdapter_FetchPatientByPassportWithConfiguredSystem(t *testing.T) {
+	const passportSystem = "urn:oid:2.16.764.1.1.2"
+	server := fhirSearchServer(t, map[string]string{
+		passportSystem + "|AA1234567": `{"resourceType":"Bundle","type":"searchset","entry":[{"resource":{
+			"resourceType":"Patient","id":"p2",
+			"identifier":[{"system":"` + passportSystem + `","value":"AA1234567"}],
+			"name":[{"text":"John Doe"}],
+			"birthDate":"1985-06-15"
+		}}]}`,
+	})
+	defer server.Close()
+
+	adapter, err := NewAdapter(Config{
+		Name:    "hospital-c",
+		Type:    TypeFHIR,
+		BaseURL: server.URL + "/fhir",
+		FHIR:    FHIRConfig{PassportSystem: passportSystem},
+	})
+	require.NoError(t, err)
+
+	patient, err := adapter.FetchPatient(context.Background(), "AA1234567")
+	require.NoError(t, err)
+	assert.Equal(t, "AA1234567", patient.PassportID)
+	assert.Equal(t, "John", patient.FirstNameEN)
+	assert.Equal(t, "Doe", patient.LastNameEN)
+}
+
+func TestFHIRAdapter_MultipleMatches(t *testing.T) {
+	duplicate := `{"resourceType":"Patient","id":"p9","identifier":[{"system":"https://terms.sil-th.org/id/th-cid","value":"1234567890121"}],"birthDate":"1990-01-01"}`
+	inactive := `{"resourceType":"Patient","id":"p8","active":false,"identifier":[{"system":"https://terms.sil-th.org/id/th-cid","value":"1234567890121"}],"birthDate":"1990-01-01"}`
+
+	tests := []struct {
+		name    string
+		entries string
+		wantErr error
+	}{
+		{name: "same resource twice", entries: `{"resource":` + fhirPatientJSON + `},{"resource":` + fhirPatientJSON + `}`},
+		{name: "inactive record ignored", entries: `{"resource":` + fhirPatientJSON + `},{"resource":` + inactive + `}`},
+		{name: "distinct patients", entries: `{"resource":` + fhirPatientJSON + `},{"resource":` + duplicate + `}`, wantErr: ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fhirSearchServer(t, map[string]string{
+				fhir.SystemNationalID + "|1234567890121": `{"resourceType":"Bundle","type":"searchset","entry":[` + tt.entries + `]}`,
+			})
+			defer server.Close()
+
+			adapter, err := NewAdapter(Config{Name: "hospital-c", Type: TypeFHIR, BaseURL: server.URL + "/fhir"})
+			require.NoError(t, err)
+
+			patient, err := adapter.FetchPatient(context.Background(), "1234567890121")
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "1234567890121", patient.NationalID)
+		})
+	}
+}
+
+func TestFHIRAdapter_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "no matches", status: http.StatusOK, body: `{"resourceType":"Bundle","type":"searchset","total":0}`, wantErr: ErrPatientNotFound},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: ErrUnavailable},
+		{name: "not a bundle", status: http.StatusOK, body: `{"resourceType":"OperationOutcome"}`, wantErr: ErrInvalidResponse},
+		{name: "missing birth date", status: http.StatusOK, body: `{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"Patient","identifier":[{"system":"https://terms.sil-th.org/id/th-cid","value":"1234567890121"}]}}]}`, wantErr: ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			adapter, err := NewAdapter(Config{Name: "hospital-c", Type: TypeFHIR, BaseURL: server.URL})
+			require.NoError(t, err)
+
+			_, err = adapter.FetchPatient(context.Background(), "1234567890121")
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+}
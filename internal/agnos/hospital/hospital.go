@@ -0,0 +1,162 @@
+package hospital
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/birthdate"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+var (
+	ErrUnsupported     = errors.New("unsupported hospital")
+	ErrPatientNotFound = errors.New("patient not found in hospital API")
+	ErrUnavailable     = errors.New("hospital API unavailable")
+	ErrInvalidResponse = errors.New("hospital API returned invalid data")
+)
+
+// InvalidRecordError reports a hospital record that failed validation.
+// Patient is the record as mapped, kept so it can be quarantined for review.
+type InvalidRecordError struct {
+	Patient *entity.Patient
+	Errors  validation.Errors
+}
+
+func (e *InvalidRecordError) Error() string {
+	return "invalid patient record: " + e.Errors.Error()
+}
+
+// ValidatePatient checks the identifiers and contact details of a patient
+// mapped from a hospital record, see validation.Patient.
+func ValidatePatient(patient *entity.Patient, passportCountry string) error {
+	errs := validation.Patient(patient.NationalID, patient.PassportID, passportCountry, patient.PhoneNumber, patient.Email)
+	if len(errs) > 0 {
+		return &InvalidRecordError{Patient: patient, Errors: errs}
+	}
+	return nil
+}
+
+// Adapter fetches patients from one hospital's API.
+type Adapter interface {
+	FetchPatient(ctx context.Context, id string) (*entity.Patient, error)
+}
+
+type Registry struct {
+	adapters    map[string]Adapter
+	configs     map[string]Config
+	dateParsers map[string]*birthdate.Parser
+	names       []string
+}
+
+func NewRegistry(configs []Config) (*Registry, error) {
+	registry := &Registry{
+		adapters:    make(map[string]Adapter, len(configs)),
+		configs:     make(map[string]Config, len(configs)),
+		dateParsers: make(map[string]*birthdate.Parser, len(configs)),
+	}
+	for _, config := range configs {
+		switch config.InvalidRecords {
+		case "", InvalidRecordsReject, InvalidRecordsQuarantine:
+		default:
+			return nil, fmt.Errorf("hospital %s: unknown invalid_records policy: %s", config.Name, config.InvalidRecords)
+		}
+		for _, policy := range config.Retention {
+			switch policy.Source {
+			case entity.PatientSourceAPI, entity.PatientSourceWebhook, entity.PatientSourceImport:
+			default:
+				return nil, fmt.Errorf("hospital %s: unknown retention source: %s", config.Name, policy.Source)
+			}
+			if policy.MaxIdle <= 0 {
+				return nil, fmt.Errorf("hospital %s: retention of %s records needs a positive max_idle", config.Name, policy.Source)
+			}
+		}
+		if config.HL7.SendingFacility != "" && len(config.HL7.AllowedPeers) == 0 {
+			return nil, fmt.Errorf("hospital %s: hl7 feed needs allowed_peers", config.Name)
+		}
+		for _, peer := range config.HL7.AllowedPeers {
+			if _, err := netip.ParsePrefix(peer); err == nil {
+				continue
+			}
+			if _, err := netip.ParseAddr(peer); err != nil {
+				return nil, fmt.Errorf("hospital %s: hl7 peer %q is neither an IP address nor a CIDR prefix", config.Name, peer)
+			}
+		}
+		for _, layout := range config.DateFormats {
+			if !strings.Contains(layout, "2006") {
+				return nil, fmt.Errorf("hospital %s: date format %q has no year", config.Name, layout)
+			}
+		}
+		adapter, err := NewAdapter(config)
+		if err != nil {
+			return nil, fmt.Errorf("hospital %s: %w", config.Name, err)
+		}
+		registry.adapters[config.Name] = adapter
+		registry.configs[config.Name] = config
+		registry.dateParsers[config.Name] = DateParser(config)
+		registry.names = append(registry.names, config.Name)
+	}
+	return registry, nil
+}
+
+// LoadRegistry builds a Registry from the JSON file at path, see LoadConfigs.
+func LoadRegistry(path string) (*Registry, error) {
+	configs, err := LoadConfigs(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(configs)
+}
+
+func (r *Registry) Adapter(hospital string) (Adapter, error) {
+	adapter, ok := r.adapters[hospital]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, hospital)
+	}
+	return adapter, nil
+}
+
+// Names lists the configured hospitals in configuration order.
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+func (r *Registry) Config(hospital string) (Config, bool) {
+	config, ok := r.configs[hospital]
+	return config, ok
+}
+
+// DateParser returns the parser for dates of birth pushed by hospital, whether
+// through webhooks or HL7. Unknown hospitals get the default layouts.
+func (r *Registry) DateParser(hospital string) *birthdate.Parser {
+	return r.dateParsers[hospital]
+}
+
+// DateParser builds the parser for the dates of birth a hospital sends, see
+// Config.DateFormats. ISO 8601 is always accepted since webhooks and HL7
+// ingestion produce it.
+func DateParser(config Config) *birthdate.Parser {
+	if len(config.DateFormats) == 0 {
+		return birthdate.NewParser(nil)
+	}
+	return birthdate.NewParser(append([]string{"2006-01-02"}, config.DateFormats...))
+}
+
+func NewAdapter(config Config) (Adapter, error) {
+	client, err := NewHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Type {
+	case TypeJSON, "":
+		return newJSONAdapter(config.BaseURL, DateParser(config), client), nil
+	case TypeFHIR:
+		return newFHIRAdapter(config.BaseURL, config.FHIR, client), nil
+	default:
+		return nil, fmt.Errorf("unknown adapter type: %s", config.Type)
+	}
+}
@@ -0,0 +1,122 @@
+package hospital
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/birthdate"
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+// JSONPatient is the bespoke patient payload served by hospital-a style APIs
+// at GET {base_url}/patient/search/{id}.
+type JSONPatient struct {
+	FirstNameTH  string `json:"first_name_th"`
+	MiddleNameTH string `json:"middle_name_th"`
+	LastNameTH   string `json:"last_name_th"`
+	FirstNameEN  string `json:"first_name_en"`
+	MiddleNameEN string `json:"middle_name_en"`
+	LastNameEN   string `json:"last_name_en"`
+	DateOfBirth  string `json:"date_of_birth"`
+	PatientHN    string `json:"patient_hn"`
+	NationalID   string `json:"national_id"`
+	PassportID   string `json:"passport_id"`
+	// PassportCountry is the ISO 3166-1 alpha-3 code of the passport's issuing
+	// country, used to validate PassportID.
+	PassportCountry string `json:"passport_country,omitempty"`
+	PhoneNumber     string `json:"phone_number"`
+	Email           string `json:"email"`
+	Gender          string `json:"gender"`
+}
+
+type jsonAdapter struct {
+	baseURL string
+	dates   *birthdate.Parser
+	client  *http.Client
+}
+
+func newJSONAdapter(baseURL string, dates *birthdate.Parser, client *http.Client) Adapter {
+	return &jsonAdapter{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		dates:   dates,
+		client:  client,
+	}
+}
+
+func (a *jsonAdapter) FetchPatient(ctx context.Context, id string) (*entity.Patient, error) {
+	apiURL := fmt.Sprintf("%s/patient/search/%s", a.baseURL, url.PathEscape(id))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrPatientNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var hospitalResp JSONPatient
+	if err := json.NewDecoder(resp.Body).Decode(&hospitalResp); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	patient, err := hospitalResp.ToEntity(a.dates)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return patient, nil
+}
+
+// ToEntity validates the record carries an identifier and a full date of
+// birth, parsed with dates, and maps it onto a Patient. A nil dates parses
+// birthdate.DefaultLayouts. The national ID is checked, and stored, without
+// the dashes and spaces it is often written with. Identifiers and contact
+// details failing validation are reported as an *InvalidRecordError.
+func (p *JSONPatient) ToEntity(dates *birthdate.Parser) (*entity.Patient, error) {
+	nationalID := validation.NormalizeNationalID(p.NationalID)
+	if nationalID == "" && p.PassportID == "" {
+		return nil, errors.New("record has neither national_id nor passport_id")
+	}
+
+	dob, err := dates.ParseFull(p.DateOfBirth)
+	if err != nil {
+		return nil, fmt.Errorf("date_of_birth %q %w", p.DateOfBirth, err)
+	}
+
+	patient := &entity.Patient{
+		FirstNameTH:  p.FirstNameTH,
+		MiddleNameTH: p.MiddleNameTH,
+		LastNameTH:   p.LastNameTH,
+		FirstNameEN:  p.FirstNameEN,
+		MiddleNameEN: p.MiddleNameEN,
+		LastNameEN:   p.LastNameEN,
+		DateOfBirth:  dob,
+		PatientHN:    p.PatientHN,
+		NationalID:   nationalID,
+		PassportID:   p.PassportID,
+		PhoneNumber:  p.PhoneNumber,
+		Email:        p.Email,
+		Gender:       p.Gender,
+	}
+	if err := ValidatePatient(patient, p.PassportCountry); err != nil {
+		return nil, err
+	}
+
+	return patient, nil
+}
@@ -0,0 +1,89 @@
+package hospital
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenExpiryMargin refreshes tokens slightly early so a request never
+	// leaves with a token that expires in flight. Tokens living less than
+	// twice the margin are refreshed at half their lifetime instead.
+	tokenExpiryMargin = 30 * time.Second
+	// defaultTokenTTL is assumed for tokens issued without expires_in, which
+	// RFC 6749 makes optional; short, so a token is never cached for long
+	// past an expiry the server did not state.
+	defaultTokenTTL = 5 * time.Minute
+)
+
+// tokenSource implements the OAuth2 client-credentials grant and caches the
+// access token until shortly before it expires.
+type tokenSource struct {
+	config AuthConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newTokenSource(config AuthConfig, client *http.Client) *tokenSource {
+	return &tokenSource{
+		config: config,
+		client: client,
+	}
+}
+
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: token request: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: token endpoint returned status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AccessToken == "" {
+		return "", fmt.Errorf("%w: malformed token response", ErrUnavailable)
+	}
+
+	ttl := defaultTokenTTL
+	if body.ExpiresIn > 0 {
+		ttl = time.Duration(body.ExpiresIn) * time.Second
+	}
+	s.token = body.AccessToken
+	s.expires = time.Now().Add(ttl - min(tokenExpiryMargin, ttl/2))
+	return s.token, nil
+}
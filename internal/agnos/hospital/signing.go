@@ -0,0 +1,86 @@
+package hospital
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader          = "X-Agnos-Signature"
+	SignatureTimestampHeader = "X-Agnos-Timestamp"
+	SignatureKeyIDHeader     = "X-Agnos-Key-Id"
+)
+
+// Sign returns the hex HMAC-SHA256 of the canonical request string:
+// method, request URI, unix timestamp and hex SHA-256 of the body, newline separated.
+func Sign(secret []byte, method, requestURI, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	ErrSignatureMissing = errors.New("request signature missing")
+	ErrSignatureInvalid = errors.New("request signature invalid")
+	ErrSignatureExpired = errors.New("request signature timestamp outside tolerance")
+)
+
+// Verify checks a signature produced by Sign and rejects timestamps further
+// than tolerance from now, so captured requests cannot be replayed later.
+func Verify(secret []byte, method, requestURI, timestamp string, body []byte, signature string, now time.Time, tolerance time.Duration) error {
+	if timestamp == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > tolerance || skew < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	expected := Sign(secret, method, requestURI, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+type signingTransport struct {
+	base   http.RoundTripper
+	keyID  string
+	secret []byte
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	if t.keyID != "" {
+		req.Header.Set(SignatureKeyIDHeader, t.keyID)
+	}
+	req.Header.Set(SignatureHeader, Sign(t.secret, req.Method, req.URL.RequestURI(), timestamp, body))
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,53 @@
+package hospital
+
+import (
+	"sync"
+	"time"
+)
+
+// UpstreamFailureThreshold is how many calls in a row must fail for a
+// hospital API to be reported down.
+const UpstreamFailureThreshold = 5
+
+// Upstream is what recent calls tell of a hospital API's health. Calls that
+// failed in transit or met a server error count as failures.
+type Upstream struct {
+	ConsecutiveFailures int
+	LastSuccessAt       *time.Time
+	LastFailureAt       *time.Time
+}
+
+// Down reports whether the last UpstreamFailureThreshold calls all failed.
+func (u Upstream) Down() bool {
+	return u.ConsecutiveFailures >= UpstreamFailureThreshold
+}
+
+// upstreams holds the Upstream of each hospital called by this process.
+var upstreams = struct {
+	sync.Mutex
+	byHospital map[string]Upstream
+}{byHospital: make(map[string]Upstream)}
+
+func recordUpstream(hospital string, failed bool) {
+	now := time.Now()
+	upstreams.Lock()
+	defer upstreams.Unlock()
+
+	upstream := upstreams.byHospital[hospital]
+	if failed {
+		upstream.ConsecutiveFailures++
+		upstream.LastFailureAt = &now
+	} else {
+		upstream.ConsecutiveFailures = 0
+		upstream.LastSuccessAt = &now
+	}
+	upstreams.byHospital[hospital] = upstream
+}
+
+// Upstream returns the health of hospital's API as seen by the calls made so
+// far; a hospital not called yet has a zero Upstream.
+func (r *Registry) Upstream(hospital string) Upstream {
+	upstreams.Lock()
+	defer upstreams.Unlock()
+	return upstreams.byHospital[hospital]
+}
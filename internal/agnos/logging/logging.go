@@ -0,0 +1,61 @@
+// Package logging builds the service's structured logger: JSON records,
+// scrubbed of patient identifiers and contact details, carrying the request
+// attributes kept in their context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+// Keys of the request attributes logged with records made in a request's
+// context.
+const (
+	RequestIDKey = "request_id"
+	StaffIDKey   = "staff_id"
+	HospitalKey  = "hospital"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing JSON records of level and above to w. Every
+// record is scrubbed, and carries the attributes added to its context with
+// With.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(contextHandler{scrubHandler{handler}})
+}
+
+// With returns a copy of ctx whose log records also carry attrs.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return context.WithValue(ctx, contextKey{}, append(slices.Clip(existing), attrs...))
+}
+
+// contextHandler adds the attributes of the record's context before passing
+// it on.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.next.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.next.WithGroup(name)}
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrub(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "national_id 1234567890121 exists", want: "national_id [REDACTED] exists"},
+		{value: "id 1-2345-67890-12-1", want: "id [REDACTED]"},
+		{value: "passport AA1234567 of THA", want: "passport [REDACTED] of THA"},
+		{value: "passport E12345678", want: "passport [REDACTED]"},
+		{value: "phone +66812345678", want: "phone [REDACTED]"},
+		{value: "phone +66 81 234 5678.", want: "phone [REDACTED]."},
+		{value: "phone 0812345678", want: "phone [REDACTED]"},
+		{value: "phone 02-123-4567", want: "phone [REDACTED]"},
+		{value: "email somchai.j@example.co.th bounced", want: "email [REDACTED] bounced"},
+		{value: "patient 0b1e6a53-1234-4f5a-9c0d-081234567890", want: "patient 0b1e6a53-1234-4f5a-9c0d-081234567890"},
+		{value: "POST /patient/search 200 in 12ms", want: "POST /patient/search 200 in 12ms"},
+		{value: "checked 100, updated 3", want: "checked 100, updated 3"},
+		{value: "HN12345", want: "HN12345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.want, Scrub(tt.value))
+		})
+	}
+}
+
+type testPatient struct {
+	Email string
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+	patientID := uuid.New()
+
+	ctx := With(context.Background(), slog.String(RequestIDKey, "req-1"))
+	ctx = With(ctx, slog.String(StaffIDKey, "staff-1"), slog.String(HospitalKey, "hospital-a"))
+	logger.With("component", "test").ErrorContext(ctx, "lookup of 1234567890121 failed",
+		"error", errors.New("patient with phone 0812345678 not found"),
+		"patient", patientID,
+		"record", testPatient{Email: "a@example.com"},
+		slog.Group("query", "passport_id", "AA1234567", "limit", 10),
+	)
+	logger.DebugContext(ctx, "not logged")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "lookup of [REDACTED] failed", record["msg"])
+	assert.Equal(t, "patient with phone [REDACTED] not found", record["error"])
+	assert.Equal(t, patientID.String(), record["patient"])
+	assert.Equal(t, "{[REDACTED]}", record["record"])
+	assert.Equal(t, map[string]any{"passport_id": "[REDACTED]", "limit": float64(10)}, record["query"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, "req-1", record[RequestIDKey])
+	assert.Equal(t, "staff-1", record[StaffIDKey])
+	assert.Equal(t, "hospital-a", record[HospitalKey])
+}
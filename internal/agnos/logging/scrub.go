@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+// Redacted replaces the PII Scrub finds.
+const Redacted = "[REDACTED]"
+
+// piiPatterns match the identifiers and contact details patients are looked up
+// by: Thai national IDs, with or without dashes, passport numbers, E.164 and
+// Thai phone numbers, and email addresses.
+var piiPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b[0-9]{13}\b`),
+	regexp.MustCompile(`\b[0-9]-[0-9]{4}-[0-9]{5}-[0-9]{2}-[0-9]\b`),
+	regexp.MustCompile(`\b[A-Z]{1,2}[0-9]{6,8}\b`),
+	regexp.MustCompile(`\+[0-9][0-9 -]{6,16}[0-9]\b`),
+	regexp.MustCompile(`\b0[0-9]{8,9}\b`),
+	regexp.MustCompile(`\b0[0-9]{1,2}[- ][0-9]{3}[- ][0-9]{3,4}\b`),
+}
+
+// Scrub redacts the national IDs, passport numbers, phone numbers and email
+// addresses in s.
+func Scrub(s string) string {
+	for _, pattern := range piiPatterns {
+		s = pattern.ReplaceAllLiteralString(s, Redacted)
+	}
+	return s
+}
+
+// scrubHandler scrubs the message and attributes of records before passing
+// them on. Errors, and values logged as text, are scrubbed as their text.
+type scrubHandler struct {
+	next slog.Handler
+}
+
+func (h scrubHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h scrubHandler) Handle(ctx context.Context, record slog.Record) error {
+	scrubbed := slog.NewRecord(record.Time, record.Level, Scrub(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		scrubbed.AddAttrs(scrubAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h scrubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		scrubbed[i] = scrubAttr(attr)
+	}
+	return scrubHandler{h.next.WithAttrs(scrubbed)}
+}
+
+func (h scrubHandler) WithGroup(name string) slog.Handler {
+	return scrubHandler{h.next.WithGroup(name)}
+}
+
+func scrubAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Scrub(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		scrubbed := make([]slog.Attr, len(group))
+		for i, member := range group {
+			scrubbed[i] = scrubAttr(member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindAny:
+		// Structured values are logged as text so nothing escapes scrubbing.
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, Scrub(v.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, Scrub(v.String()))
+		default:
+			return slog.String(attr.Key, Scrub(fmt.Sprint(v)))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
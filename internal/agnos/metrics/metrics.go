@@ -0,0 +1,97 @@
+// Package metrics holds the Prometheus collectors the service exports at
+// /metrics: HTTP request latency, database pool stats, hospital API calls,
+// local-vs-upstream patient lookups and staff logins.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "agnos"
+
+// Patient lookup sources, the values of PatientLookups' source label.
+const (
+	LookupLocal    = "local"
+	LookupUpstream = "upstream"
+)
+
+// Login results, the values of Logins' result label.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+// Registry holds the service's collectors, along with the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes handled requests by method, route
+	// template and status; unmatched routes are labelled "unmatched".
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HospitalRequestDuration observes calls to hospital APIs, whatever
+	// their outcome.
+	HospitalRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "hospital_request_duration_seconds",
+		Help:      "Latency of hospital API calls by hospital.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"hospital"})
+
+	// HospitalRequestErrors counts hospital API calls that failed in transit
+	// or were answered with a 5xx status.
+	HospitalRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hospital_request_errors_total",
+		Help:      "Hospital API calls that failed or returned a server error, by hospital.",
+	}, []string{"hospital"})
+
+	// PatientLookups counts patient searches answered from the local
+	// database and those that went on to the staff's hospital; the cache hit
+	// ratio is local over local plus upstream.
+	PatientLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "patient_lookups_total",
+		Help:      "Patient searches by the source that answered them, local or upstream.",
+	}, []string{"source"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "staff_logins_total",
+		Help:      "Staff login attempts by result, success or failure.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HospitalRequestDuration,
+		HospitalRequestErrors,
+		PatientLookups,
+		Logins,
+	)
+}
+
+// RegisterDB exports the connection pool stats of db, the database behind
+// the gorm connection.
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Handler serves the collectors in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
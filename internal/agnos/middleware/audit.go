@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuditPatientsKey is the context key under which handlers list the IDs
+// ([]uuid.UUID) of the patients a request accessed.
+const AuditPatientsKey = "audit_patient_ids"
+
+// Audit records every authenticated request in the audit log once handled,
+// with the patients the handler reports under AuditPatientsKey. It must run
+// after AuthMiddleware. Failures to record are logged; the response has
+// already been written by then.
+func Audit(auditService service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		patientIDs, _ := c.Value(AuditPatientsKey).([]uuid.UUID)
+		action := c.Request.Method + " " + c.FullPath()
+		err := auditService.Record(service.AuditRecord{
+			Actor:      "staff:" + c.GetString("staff_id"),
+			Hospital:   c.GetString("hospital"),
+			Action:     action,
+			Status:     c.Writer.Status(),
+			PatientIDs: patientIDs,
+		})
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "audit failed", "action", action, "error", err)
+		}
+	}
+}
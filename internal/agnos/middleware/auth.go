@@ -1,17 +1,26 @@
 package middleware
 
 import (
-	"net/http"
+	"log/slog"
 	"strings"
 
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/Markikie/agnos/internal/agnos/service"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	ErrAuthorizationRequired = service.NewError(service.ErrUnauthorized, "authorization_required", "Authorization header required")
+	ErrAuthorizationFormat   = service.NewError(service.ErrUnauthorized, "invalid_authorization_header", "Invalid authorization header format")
+	ErrInvalidToken          = service.NewError(service.ErrUnauthorized, "invalid_token", "Invalid token")
+)
+
 type Claims struct {
 	StaffID  string `json:"staff_id"`
 	Username string `json:"username"`
 	Hospital string `json:"hospital"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -19,15 +28,13 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			AbortWithProblem(c, ErrAuthorizationRequired)
 			return
 		}
 
 		// Check if the header starts with "Bearer "
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			AbortWithProblem(c, ErrAuthorizationFormat)
 			return
 		}
 
@@ -41,8 +48,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			AbortWithProblem(c, ErrInvalidToken)
 			return
 		}
 
@@ -50,7 +56,23 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		c.Set("staff_id", claims.StaffID)
 		c.Set("username", claims.Username)
 		c.Set("hospital", claims.Hospital)
+		c.Set("role", claims.Role)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(),
+			slog.String(logging.StaffIDKey, claims.StaffID),
+			slog.String(logging.HospitalKey, claims.Hospital),
+		))
 
 		c.Next()
 	}
 }
+
+// RequireRole rejects staff without role; it runs after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			AbortWithProblem(c, service.NewError(service.ErrForbidden, "role_required", "This action requires the "+role+" role"))
+			return
+		}
+		c.Next()
+	}
+}
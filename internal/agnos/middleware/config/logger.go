@@ -1,7 +1,75 @@
 package config
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
 
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+var errPanic = errors.New("panic")
+
+// quietRoutes are polled by probes and scrapers; they are logged at debug
+// level unless they fail.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Logger logs every request once handled, at warning level for client errors
+// and error level for server errors. It runs after RequestID, so the record
+// carries the request ID, and the staff ID and hospital once authenticated.
 func Logger() gin.HandlerFunc {
-	return gin.Logger()
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quietRoutes[c.FullPath()]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", max(c.Writer.Size(), 0)),
+		}
+		if c.Request.URL.RawQuery != "" {
+			attrs = append(attrs, slog.String("query", c.Request.URL.RawQuery))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panicking request into a 500 problem response, logging
+// the panic and its stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "panic",
+			"error", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		problem := middleware.NewProblem(c, errPanic)
+		middleware.WriteProblem(c, problem.Status, problem)
+	})
 }
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics observes the latency of every request by method, route template
+// and status. Requests matching no route share one label, so probing paths
+// cannot grow the series without bound.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
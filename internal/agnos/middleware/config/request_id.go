@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader carries the request ID, set by nginx in front of the
+	// service and echoed in every response.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the context key of the request ID.
+	RequestIDKey = "request_id"
+)
+
+// validRequestID bounds what is accepted from clients, so the ID is safe to
+// log and echo.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID takes the request's ID from the X-Request-ID header, or generates
+// one when it is missing or malformed, and echoes it in the response. The ID
+// is attached to the log records of the request.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String(logging.RequestIDKey, requestID)))
+		c.Next()
+	}
+}
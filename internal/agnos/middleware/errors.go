@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ProblemContentType is the media type of error responses.
+	ProblemContentType = "application/problem+json"
+	// ProblemTypePrefix prefixes the code of a problem to form its type.
+	ProblemTypePrefix = "urn:agnos:problem:"
+
+	codeInternal = "internal_error"
+)
+
+// kindStatuses maps the kinds of service errors onto HTTP statuses.
+var kindStatuses = []struct {
+	kind   error
+	status int
+}{
+	{service.ErrValidation, http.StatusBadRequest},
+	{service.ErrUnauthorized, http.StatusUnauthorized},
+	{service.ErrForbidden, http.StatusForbidden},
+	{service.ErrNotFound, http.StatusNotFound},
+	{service.ErrConflict, http.StatusConflict},
+	{service.ErrGone, http.StatusGone},
+	{service.ErrTooLarge, http.StatusRequestEntityTooLarge},
+	{service.ErrUpstream, http.StatusBadGateway},
+}
+
+// Errors answers requests whose handlers recorded an error with c.Error but
+// wrote no response, with the problem AbortWithProblem would.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			problem := NewProblem(c, c.Errors.Last().Err)
+			WriteProblem(c, problem.Status, problem)
+		}
+	}
+}
+
+// AbortWithProblem answers the request with the problem err maps to and stops
+// the chain. err is recorded with c.Error, so the request log carries it.
+func AbortWithProblem(c *gin.Context, err error) {
+	_ = c.Error(err)
+	problem := NewProblem(c, err)
+	WriteProblem(c, problem.Status, problem)
+}
+
+// WriteProblem writes problem, a response.Problem or a response embedding
+// one, with status and stops the chain.
+func WriteProblem(c *gin.Context, status int, problem any) {
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
+
+// NewProblem maps err onto the problem the request is answered with. Errors
+// service.AsError knows are reported by kind and code; any other is a 500
+// whose detail, which may come from the database or a driver, is not shown.
+func NewProblem(c *gin.Context, err error) response.Problem {
+	problem := response.Problem{
+		Status:    http.StatusInternalServerError,
+		Code:      codeInternal,
+		Detail:    "the request could not be completed; quote the request ID when reporting it",
+		Instance:  c.Request.URL.Path,
+		RequestID: c.GetString(logging.RequestIDKey),
+	}
+	if serviceErr := service.AsError(err); serviceErr != nil {
+		for _, ks := range kindStatuses {
+			if errors.Is(serviceErr, ks.kind) {
+				problem.Status = ks.status
+				problem.Code = serviceErr.Code
+				problem.Detail = serviceErr.Message
+				break
+			}
+		}
+		for _, field := range serviceErr.Fields {
+			problem.Errors = append(problem.Errors, response.ProblemField{Field: field.Field, Message: field.Message})
+		}
+	}
+	problem.Type = ProblemTypePrefix + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	return problem
+}
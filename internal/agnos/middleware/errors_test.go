@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/logging"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{name: "not found", err: service.ErrPatientNotFound, status: http.StatusNotFound, code: "patient_not_found", detail: "patient not found"},
+		{name: "gone", err: service.ErrPatientErased, status: http.StatusGone, code: "patient_erased", detail: "patient has been erased"},
+		{name: "conflict", err: service.ErrMergeConflict, status: http.StatusConflict, code: "merge_conflict"},
+		{name: "upstream", err: service.ErrHospitalUnavailable, status: http.StatusBadGateway, code: "hospital_unavailable"},
+		{name: "forbidden", err: service.NewError(service.ErrForbidden, "role_required", "admin only"), status: http.StatusForbidden, code: "role_required"},
+		{name: "unauthorized", err: ErrInvalidToken, status: http.StatusUnauthorized, code: "invalid_token"},
+		{
+			name:   "internal",
+			err:    errors.New(`ERROR: duplicate key value violates unique constraint "idx_patients_national_id"`),
+			status: http.StatusInternalServerError,
+			code:   "internal_error",
+			detail: "the request could not be completed; quote the request ID when reporting it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/patient/1", nil)
+			c.Set(logging.RequestIDKey, "req-1")
+
+			problem := NewProblem(c, tt.err)
+
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, ProblemTypePrefix+tt.code, problem.Type)
+			assert.Equal(t, http.StatusText(tt.status), problem.Title)
+			assert.Equal(t, "/patient/1", problem.Instance)
+			assert.Equal(t, "req-1", problem.RequestID)
+			if tt.detail != "" {
+				assert.Equal(t, tt.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestAbortWithProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/patient/search", nil)
+
+	AbortWithProblem(c, service.NewValidationError("invalid search request", validation.Errors{
+		{Field: "email", Message: "is not a valid email address"},
+	}))
+
+	assert.True(t, c.IsAborted())
+	assert.Len(t, c.Errors, 1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "urn:agnos:problem:validation_failed",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid search request",
+		"instance": "/patient/search",
+		"code": "validation_failed",
+		"errors": [{"field": "email", "message": "is not a valid email address"}]
+	}`, w.Body.String())
+}
+
+func TestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(Errors())
+	engine.GET("/recorded", func(c *gin.Context) {
+		_ = c.Error(service.ErrJobNotFound)
+	})
+	engine.GET("/answered", func(c *gin.Context) {
+		_ = c.Error(errors.New("already answered"))
+		c.Status(http.StatusNoContent)
+		c.Writer.WriteHeaderNow()
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/recorded", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "job_not_found", problem.Code)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/answered", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
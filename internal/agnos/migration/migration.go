@@ -0,0 +1,309 @@
+// Package migration versions the database schema. Migrations are SQL files
+// embedded from sql/, named NNNN_name.up.sql and NNNN_name.down.sql, and data
+// migrations written in Go. Applied versions are recorded in
+// schema_migrations.
+package migration
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+// lockKey is the advisory lock serializing migrations across processes; it
+// spells "agnos" in ASCII.
+const lockKey = 0x61676e6f73
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// Migration changes the schema from Version-1 to Version. Down reverts it and
+// is nil for migrations that cannot be reverted.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Status is a migration and when it was applied, nil if pending.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// goMigrations are data migrations that cannot be written in SQL.
+var goMigrations = []Migration{
+	{
+		Version: 3,
+		Name:    "backfill_normalized_contacts",
+		Up: func(tx *gorm.DB) error {
+			_, err := repository.BackfillNormalizedContacts(tx, 500)
+			return err
+		},
+		// The normalized columns are derived, so there is nothing to undo.
+		Down: func(tx *gorm.DB) error { return nil },
+	},
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the embedded SQL and Go migrations.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(sqlFiles, goMigrations)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns those applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending(m.migrations, versions) {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, latest first, and returns
+// those reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %04d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions := map[int]time.Time{}
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if versions, err = appliedVersions(db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i].Migration = migration
+		if appliedAt, ok := versions[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns ErrSchemaBehind when migrations are pending. A schema ahead
+// of this binary, migrated by a newer release, is accepted.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			missing = append(missing, fmt.Sprintf("%04d_%s", status.Version, status.Name))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: pending %s; run agnos migrate up", ErrSchemaBehind, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock,
+// so concurrent deploys apply each migration once.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+// appliedVersions maps each applied version to when it was applied.
+func appliedVersions(db *gorm.DB) (map[int]time.Time, error) {
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+	return versions, nil
+}
+
+// pending returns the migrations not in applied, in version order.
+func pending(migrations []Migration, applied map[int]time.Time) []Migration {
+	var result []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			result = append(result, migration)
+		}
+	}
+	return result
+}
+
+// load reads the SQL migrations in fsys and merges them with goMigrations,
+// checking that versions are unique and every migration has an up step.
+func load(fsys fs.FS, goMigrations []Migration) ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+
+	files, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		version, name, direction, err := parseFileName(path.Base(file))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration version %04d used by both %s and %s", version, migration.Name, name)
+		}
+
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if direction == "up" {
+			migration.Up = execSQL(string(b))
+		} else {
+			migration.Down = execSQL(string(b))
+		}
+	}
+	for _, migration := range goMigrations {
+		if existing, ok := byVersion[migration.Version]; ok {
+			return nil, fmt.Errorf("migration version %04d used by both %s and %s", migration.Version, existing.Name, migration.Name)
+		}
+		byVersion[migration.Version] = &migration
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %04d_%s has no up step", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// parseFileName splits NNNN_name.up.sql into its version, name and direction.
+func parseFileName(file string) (int, string, string, error) {
+	var base, direction string
+	if stem, ok := strings.CutSuffix(file, ".up.sql"); ok {
+		base, direction = stem, "up"
+	} else if stem, ok := strings.CutSuffix(file, ".down.sql"); ok {
+		base, direction = stem, "down"
+	} else {
+		return 0, "", "", fmt.Errorf("migration %s: want .up.sql or .down.sql", file)
+	}
+
+	digits, name, ok := strings.Cut(base, "_")
+	version, err := strconv.Atoi(digits)
+	if !ok || err != nil || version <= 0 || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s: want NNNN_name", file)
+	}
+	return version, name, direction, nil
+}
+
+// execSQL runs statements, which may be several separated by semicolons;
+// without arguments they are sent over the simple query protocol.
+func execSQL(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(statements).Error
+	}
+}
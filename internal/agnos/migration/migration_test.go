@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMigrator_EmbeddedMigrations(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	require.NoError(t, err)
+
+	for i, migration := range migrator.migrations {
+		assert.Equal(t, i+1, migration.Version, "versions must be contiguous")
+		assert.NotNil(t, migration.Up, migration.Name)
+		assert.NotNil(t, migration.Down, migration.Name)
+	}
+	assert.Equal(t, "initial_schema", migrator.migrations[0].Name)
+	assert.Equal(t, "backfill_normalized_contacts", migrator.migrations[2].Name)
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":  {Data: []byte("SELECT 2")},
+		"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+		"sql/0001_first.down.sql": {Data: []byte("SELECT 1")},
+	}
+
+	migrations, err := load(fsys, []Migration{{Version: 3, Name: "third", Up: execSQL("SELECT 3")}})
+
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, []string{"first", "second", "third"}, []string{migrations[0].Name, migrations[1].Name, migrations[2].Name})
+	assert.NotNil(t, migrations[0].Down)
+	assert.Nil(t, migrations[1].Down)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":          {"sql/first.up.sql": {}},
+		"bad direction":     {"sql/0001_first.sql": {}},
+		"duplicate version": {"sql/0001_first.up.sql": {}, "sql/0001_other.up.sql": {}},
+		"down only":         {"sql/0001_first.down.sql": {}},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(fsys, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := load(fstest.MapFS{"sql/0001_first.up.sql": {}}, []Migration{{Version: 1, Name: "go", Up: execSQL("")}})
+	assert.Error(t, err)
+}
+
+func TestPending(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+
+	got := pending(migrations, map[int]time.Time{1: time.Now(), 3: time.Now()})
+
+	assert.Equal(t, []Migration{{Version: 2}}, got)
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS tbl_quarantined_patients;
+DROP TABLE IF EXISTS tbl_duplicate_candidates;
+DROP TABLE IF EXISTS tbl_patient_merges;
+DROP TABLE IF EXISTS tbl_webhook_events;
+DROP TABLE IF EXISTS tbl_patient_hospitals;
+DROP TABLE IF EXISTS tbl_patients;
+DROP TABLE IF EXISTS tbl_staff;
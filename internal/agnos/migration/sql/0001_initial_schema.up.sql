@@ -0,0 +1,94 @@
+-- The schema as created by AutoMigrate before versioned migrations. Every
+-- statement is a no-op on a database AutoMigrate already created, so such
+-- databases are adopted as they are.
+
+CREATE TABLE IF NOT EXISTS tbl_staff (
+    id uuid PRIMARY KEY,
+    username text NOT NULL CONSTRAINT uni_tbl_staff_username UNIQUE,
+    password text NOT NULL,
+    hospital text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS tbl_patients (
+    id uuid PRIMARY KEY,
+    first_name_th text,
+    middle_name_th text,
+    last_name_th text,
+    first_name_en text,
+    middle_name_en text,
+    last_name_en text,
+    date_of_birth date,
+    patient_hn text,
+    national_id text CONSTRAINT uni_tbl_patients_national_id UNIQUE,
+    passport_id text CONSTRAINT uni_tbl_patients_passport_id UNIQUE,
+    phone_number text,
+    email text,
+    gender text,
+    phone_normalized text,
+    email_normalized text
+);
+CREATE INDEX IF NOT EXISTS idx_tbl_patients_date_of_birth ON tbl_patients (date_of_birth);
+CREATE INDEX IF NOT EXISTS idx_tbl_patients_phone_normalized ON tbl_patients (phone_normalized);
+CREATE INDEX IF NOT EXISTS idx_tbl_patients_email_normalized ON tbl_patients (email_normalized);
+
+CREATE TABLE IF NOT EXISTS tbl_patient_hospitals (
+    patient_id uuid CONSTRAINT fk_tbl_patients_hospitals REFERENCES tbl_patients (id),
+    hospital text,
+    patient_hn text,
+    source text NOT NULL,
+    last_synced_at timestamptz,
+    last_attempt_at timestamptz,
+    sync_error text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (patient_id, hospital)
+);
+CREATE INDEX IF NOT EXISTS idx_tbl_patient_hospitals_last_attempt_at ON tbl_patient_hospitals (last_attempt_at);
+
+CREATE TABLE IF NOT EXISTS tbl_webhook_events (
+    hospital text,
+    event_id text,
+    type text NOT NULL,
+    patient_id uuid,
+    received_at timestamptz NOT NULL,
+    PRIMARY KEY (hospital, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS tbl_patient_merges (
+    id uuid PRIMARY KEY,
+    survivor_id uuid NOT NULL,
+    retired_id uuid NOT NULL,
+    retired_record jsonb NOT NULL,
+    survivorship jsonb NOT NULL,
+    merged_by text NOT NULL,
+    reason text,
+    merged_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_tbl_patient_merges_survivor_id ON tbl_patient_merges (survivor_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tbl_patient_merges_retired_id ON tbl_patient_merges (retired_id);
+
+CREATE TABLE IF NOT EXISTS tbl_duplicate_candidates (
+    id uuid PRIMARY KEY,
+    patient_a_id uuid NOT NULL,
+    patient_b_id uuid NOT NULL,
+    score decimal NOT NULL,
+    reasons text,
+    status text NOT NULL,
+    detected_at timestamptz NOT NULL,
+    reviewed_by text,
+    reviewed_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_duplicate_candidates_pair ON tbl_duplicate_candidates (patient_a_id, patient_b_id);
+CREATE INDEX IF NOT EXISTS idx_tbl_duplicate_candidates_status ON tbl_duplicate_candidates (status);
+
+CREATE TABLE IF NOT EXISTS tbl_quarantined_patients (
+    hospital text,
+    source text,
+    reference text,
+    record jsonb NOT NULL,
+    errors jsonb NOT NULL,
+    received_at timestamptz NOT NULL,
+    PRIMARY KEY (hospital, source, reference)
+);
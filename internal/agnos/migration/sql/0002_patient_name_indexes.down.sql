@@ -0,0 +1,2 @@
+DROP INDEX idx_patient_name_en;
+DROP INDEX idx_patient_name_th;
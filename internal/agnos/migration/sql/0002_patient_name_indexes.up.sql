@@ -0,0 +1,3 @@
+-- Composite indexes on first and last name, which AutoMigrate could not express.
+CREATE INDEX idx_patient_name_th ON tbl_patients (first_name_th, last_name_th);
+CREATE INDEX idx_patient_name_en ON tbl_patients (first_name_en, last_name_en);
@@ -0,0 +1 @@
+ALTER TABLE tbl_staff DROP COLUMN role;
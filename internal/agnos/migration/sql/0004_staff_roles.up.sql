@@ -0,0 +1 @@
+ALTER TABLE tbl_staff ADD COLUMN role text NOT NULL DEFAULT 'staff';
@@ -0,0 +1,12 @@
+CREATE TABLE tbl_audit_log (
+    seq bigserial PRIMARY KEY,
+    occurred_at timestamptz NOT NULL,
+    actor text NOT NULL,
+    hospital text NOT NULL,
+    action text NOT NULL,
+    patient_id uuid,
+    status integer NOT NULL,
+    prev_hash text NOT NULL,
+    hash text NOT NULL
+);
+CREATE INDEX idx_tbl_audit_log_patient_id ON tbl_audit_log (patient_id);
@@ -0,0 +1,2 @@
+DROP TABLE tbl_import_job_errors;
+DROP TABLE tbl_import_jobs;
@@ -0,0 +1,23 @@
+CREATE TABLE tbl_import_jobs (
+    id uuid PRIMARY KEY,
+    hospital text NOT NULL,
+    format text NOT NULL,
+    status text NOT NULL,
+    checkpoint integer NOT NULL DEFAULT 0,
+    created integer NOT NULL DEFAULT 0,
+    updated integer NOT NULL DEFAULT 0,
+    duplicates integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    error text,
+    started_by text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE tbl_import_job_errors (
+    job_id uuid NOT NULL REFERENCES tbl_import_jobs (id) ON DELETE CASCADE,
+    line integer NOT NULL,
+    kind text NOT NULL,
+    error text NOT NULL,
+    PRIMARY KEY (job_id, line)
+);
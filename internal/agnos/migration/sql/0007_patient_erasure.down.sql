@@ -0,0 +1 @@
+ALTER TABLE tbl_patients DROP COLUMN erased_at;
@@ -0,0 +1,21 @@
+CREATE TABLE tbl_jobs (
+    id uuid PRIMARY KEY,
+    kind text NOT NULL,
+    hospital text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    max_attempts integer NOT NULL,
+    run_at timestamptz NOT NULL,
+    locked_until timestamptz,
+    cancel_requested boolean NOT NULL DEFAULT false,
+    result jsonb,
+    error text,
+    created_by text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    finished_at timestamptz
+);
+-- Workers look for queued jobs that are due and running jobs whose lease expired.
+CREATE INDEX idx_tbl_jobs_due ON tbl_jobs (run_at) WHERE status = 'queued';
+CREATE INDEX idx_tbl_jobs_lease ON tbl_jobs (locked_until) WHERE status = 'running';
@@ -0,0 +1 @@
+ALTER TABLE tbl_patients ADD COLUMN erased_hospital text;
@@ -0,0 +1,5 @@
+-- Fails once more than one patient lacks a national ID or a passport.
+DROP INDEX uni_tbl_patients_passport_id;
+DROP INDEX uni_tbl_patients_national_id;
+ALTER TABLE tbl_patients ADD CONSTRAINT uni_tbl_patients_national_id UNIQUE (national_id);
+ALTER TABLE tbl_patients ADD CONSTRAINT uni_tbl_patients_passport_id UNIQUE (passport_id);
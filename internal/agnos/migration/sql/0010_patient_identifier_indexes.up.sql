@@ -0,0 +1,7 @@
+-- Patients without a national ID or passport store '' in that column, which
+-- the unique constraints allowed only once. Uniqueness now applies to the
+-- identifiers actually set.
+ALTER TABLE tbl_patients DROP CONSTRAINT uni_tbl_patients_national_id;
+ALTER TABLE tbl_patients DROP CONSTRAINT uni_tbl_patients_passport_id;
+CREATE UNIQUE INDEX uni_tbl_patients_national_id ON tbl_patients (national_id) WHERE national_id <> '';
+CREATE UNIQUE INDEX uni_tbl_patients_passport_id ON tbl_patients (passport_id) WHERE passport_id <> '';
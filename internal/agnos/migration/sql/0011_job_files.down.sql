@@ -0,0 +1,2 @@
+ALTER TABLE tbl_import_jobs DROP COLUMN job_id;
+DROP TABLE tbl_job_files;
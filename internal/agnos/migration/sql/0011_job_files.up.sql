@@ -0,0 +1,15 @@
+-- Imports and exports run as jobs: the uploaded input and the produced output
+-- are kept here rather than in the job's payload and result.
+CREATE TABLE tbl_job_files (
+    job_id uuid NOT NULL REFERENCES tbl_jobs (id) ON DELETE CASCADE,
+    name text NOT NULL,
+    filename text,
+    content_type text,
+    data bytea NOT NULL,
+    expires_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (job_id, name)
+);
+CREATE INDEX idx_tbl_job_files_expires_at ON tbl_job_files (expires_at) WHERE expires_at IS NOT NULL;
+
+ALTER TABLE tbl_import_jobs ADD COLUMN job_id uuid;
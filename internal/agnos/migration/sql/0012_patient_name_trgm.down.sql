@@ -0,0 +1,9 @@
+-- pg_trgm is left installed; other objects may depend on it.
+DROP INDEX idx_tbl_patients_last_name_en_trgm;
+DROP INDEX idx_tbl_patients_first_name_en_trgm;
+DROP INDEX idx_tbl_patients_middle_name_en_trgm;
+DROP INDEX idx_tbl_patients_last_name_th_trgm;
+DROP INDEX idx_tbl_patients_middle_name_th_trgm;
+DROP INDEX idx_tbl_patients_first_name_th_trgm;
+CREATE INDEX idx_patient_name_th ON tbl_patients (first_name_th, last_name_th);
+CREATE INDEX idx_patient_name_en ON tbl_patients (first_name_en, last_name_en);
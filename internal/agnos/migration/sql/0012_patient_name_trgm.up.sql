@@ -0,0 +1,12 @@
+-- Name searches match substrings with ILIKE '%x%', which the B-tree indexes of
+-- 0002 cannot serve. Trigram GIN indexes can, one per name column since a
+-- search may name any of them.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+DROP INDEX idx_patient_name_th;
+DROP INDEX idx_patient_name_en;
+CREATE INDEX idx_tbl_patients_first_name_th_trgm ON tbl_patients USING gin (first_name_th gin_trgm_ops);
+CREATE INDEX idx_tbl_patients_middle_name_th_trgm ON tbl_patients USING gin (middle_name_th gin_trgm_ops);
+CREATE INDEX idx_tbl_patients_last_name_th_trgm ON tbl_patients USING gin (last_name_th gin_trgm_ops);
+CREATE INDEX idx_tbl_patients_first_name_en_trgm ON tbl_patients USING gin (first_name_en gin_trgm_ops);
+CREATE INDEX idx_tbl_patients_middle_name_en_trgm ON tbl_patients USING gin (middle_name_en gin_trgm_ops);
+CREATE INDEX idx_tbl_patients_last_name_en_trgm ON tbl_patients USING gin (last_name_en gin_trgm_ops);
@@ -0,0 +1,93 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Markikie/agnos/internal/agnos/app"
+	"github.com/Markikie/agnos/internal/agnos/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	app.NewRouter(engine, &app.Handler{}, &app.Service{})
+	return engine
+}
+
+// TestSpec_CoversRoutes fails when a route is registered without being
+// documented, or documented without being registered.
+func TestSpec_CoversRoutes(t *testing.T) {
+	paths := openapi.Spec().Paths
+
+	registered := make(map[string]bool)
+	for _, route := range newEngine().Routes() {
+		path := openapi.Path(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		assert.NotNil(t, paths[path][method], "%s %s is not in the OpenAPI document", route.Method, path)
+	}
+	for path, item := range paths {
+		for method := range item {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestSpec_Schemas(t *testing.T) {
+	doc := openapi.Spec()
+	schemas := doc.Components.Schemas
+
+	search := schemas["Search"]
+	require.NotNil(t, search)
+	assert.Equal(t, "uuid", search.Properties["id"].Format)
+	assert.Contains(t, search.Required, "id")
+
+	merge := schemas["PatientMergeRequest"]
+	require.NotNil(t, merge)
+	assert.ElementsMatch(t, []string{"survivor_id", "retired_id"}, merge.Required)
+
+	patient := doc.Paths["/patient/{id}"]["get"]
+	require.NotNil(t, patient)
+	require.Len(t, patient.Parameters, 1)
+	assert.Equal(t, "path", patient.Parameters[0].In)
+	assert.NotEmpty(t, patient.Security)
+	assert.Contains(t, patient.Responses["404"].Content, "application/problem+json")
+	assert.Contains(t, patient.Responses, "401")
+
+	login := doc.Paths["/staff/login"]["post"]
+	require.NotNil(t, login)
+	assert.Empty(t, login.Security)
+
+	ids := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			assert.False(t, ids[op.OperationID], "%s %s reuses operationId %s", method, path, op.OperationID)
+			ids[op.OperationID] = true
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	engine := newEngine()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `fetch("openapi.json")`)
+}
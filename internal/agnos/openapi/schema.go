@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	uuidType       = reflect.TypeFor[uuid.UUID]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// schemaRegistry generates the schemas of Go types as encoding/json writes
+// them. Named structs become components, referenced where they are used.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+// of returns the schema of t.
+func (r *schemaRegistry) of(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawMessageType:
+		// Any JSON value.
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return r.of(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+		return r.component(t)
+	}
+	// Interfaces hold any JSON value.
+	return &Schema{}
+}
+
+// component registers the named struct t and returns a reference to it.
+func (r *schemaRegistry) component(t reflect.Type) *Schema {
+	name := schemaName(t)
+	ref := &Schema{Ref: schemaRefPrefix + name}
+	if registered, ok := r.types[name]; ok {
+		if registered != t {
+			panic(fmt.Sprintf("openapi: %s and %s are both named %s", registered, t, name))
+		}
+		return ref
+	}
+	// Registered before its fields, which may refer back to it.
+	r.types[name] = t
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.object(t)
+	return ref
+}
+
+func (r *schemaRegistry) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(object, t)
+	return object
+}
+
+// addFields adds the fields of the struct t to object. Fields of request
+// types are required by their binding tag; response fields are required
+// unless omitempty, as they are always written.
+func (r *schemaRegistry) addFields(object *Schema, t reflect.Type) {
+	request := path.Base(t.PkgPath()) == "request"
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			r.addFields(object, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		omitempty := strings.Contains(options, "omitempty")
+
+		property := r.of(field.Type)
+		if typ, ok := property.Type.(string); ok && field.Type.Kind() == reflect.Pointer && !omitempty {
+			property.Type = []string{typ, "null"}
+		}
+		object.Properties[name] = property
+
+		required := !omitempty
+		if request {
+			required = strings.Contains(field.Tag.Get("binding"), "required")
+		}
+		if required {
+			object.Required = append(object.Required, name)
+		}
+	}
+}
+
+// schemaName names the schema of t after it. Types from outside the API
+// packages are prefixed with their package, so fhir.Patient is FHIRPatient.
+func schemaName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if pkg == "request" || pkg == "response" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg) + t.Name()
+}
@@ -0,0 +1,559 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Markikie/agnos/internal/agnos/api/request"
+	"github.com/Markikie/agnos/internal/agnos/api/response"
+	"github.com/Markikie/agnos/internal/agnos/fhir"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// Authentication an operation requires.
+type auth int
+
+const (
+	authNone auth = iota
+	// authStaff is a staff token, checked by middleware.AuthMiddleware.
+	authStaff
+	// authAdmin is the token of an admin, checked by middleware.RequireRole.
+	authAdmin
+	// authSignature is a hospital's request signature.
+	authSignature
+)
+
+const bearerAuth = "bearerAuth"
+
+// operation documents one route as router registers it; the schemas of its
+// bodies are generated from the types of api/request and api/response.
+type operation struct {
+	method, path string
+	id           string
+	tag          string
+	summary      string
+	description  string
+	auth         auth
+	// params are the query and header parameters, and descriptions of the
+	// path parameters, which are otherwise documented from the path.
+	params []Parameter
+	// request is a value of the type of the JSON body, or a *Schema of a
+	// body in requestTypes.
+	request      any
+	requestTypes []string
+	responses    []reply
+}
+
+type reply struct {
+	status      int
+	description string
+	// body is a value of the type of the JSON body, or a *Schema of a body
+	// in mediaTypes; nil for none.
+	body       any
+	mediaTypes []string
+	headers    map[string]Header
+}
+
+func problem(status int, description string) reply {
+	return reply{status: status, description: description, body: response.Problem{}, mediaTypes: []string{middleware.ProblemContentType}}
+}
+
+func fhirReply(status int, description string, body any) reply {
+	return reply{status: status, description: description, body: body, mediaTypes: []string{fhir.ContentType}}
+}
+
+func query(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func pathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+func number(typ string, minimum, maximum float64, def any) *Schema {
+	return &Schema{Type: typ, Minimum: &minimum, Maximum: &maximum, Default: def}
+}
+
+func enum(values ...string) *Schema {
+	schema := &Schema{Type: "string"}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value)
+	}
+	return schema
+}
+
+var (
+	stringSchema = &Schema{Type: "string"}
+	binarySchema = &Schema{Type: "string", Format: "binary"}
+
+	importTypes    = []string{"text/csv", "application/x-ndjson", "application/jsonl"}
+	locationHeader = map[string]Header{"Location": {Schema: stringSchema}}
+	patientID      = pathParam("id", "Patient ID; the ID of a patient retired by a merge resolves to the survivor")
+)
+
+var tags = []Tag{
+	{Name: "Staff", Description: "Staff accounts and tokens"},
+	{Name: "Patients", Description: "Patient search, merges, duplicates and PDPA requests"},
+	{Name: "Bulk", Description: "Bulk import and export of a hospital's patients; admin staff only"},
+	{Name: "Integrations", Description: "Changes pushed by partner hospitals"},
+	{Name: "FHIR", Description: "FHIR R4 Patient resources"},
+	{Name: "Jobs", Description: "Background jobs; admin staff only"},
+	{Name: "Service", Description: "Probes, metrics and this document"},
+}
+
+var operations = []operation{
+	{
+		method: http.MethodPost, path: "/staff/create", id: "createStaff", tag: "Staff",
+		summary: "Create staff member",
+		request: request.StaffRequest{},
+		responses: []reply{
+			{status: http.StatusCreated, description: "Staff created", body: response.CreateStaffResponse{}},
+			problem(http.StatusBadRequest, "A field is missing"),
+			problem(http.StatusConflict, "The username is taken in the hospital"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/staff/login", id: "login", tag: "Staff",
+		summary:     "Log in",
+		description: "Returns a staff token, valid for 24 hours.",
+		params:      []Parameter{{Name: "hospital", In: "query", Required: true, Schema: stringSchema}},
+		request:     request.LoginStaffRequest{},
+		responses: []reply{
+			{status: http.StatusOK, description: "Logged in", body: response.LoginStaffResponse{}},
+			problem(http.StatusBadRequest, "The hospital parameter is missing"),
+			problem(http.StatusUnauthorized, "Unknown username or wrong password"),
+		},
+	},
+
+	{
+		method: http.MethodPost, path: "/patient/search", id: "searchPatients", tag: "Patients",
+		summary:     "Search patients",
+		description: "Searches the patients linked to the staff member's hospital, then its hospital API when nothing matches a national ID or passport. Filters combine.",
+		auth:        authStaff,
+		request:     request.PatientSearchRequest{},
+		responses: []reply{
+			{status: http.StatusOK, description: "Matching patients, and the outcome of each source", body: response.PatientSearchResponse{}},
+			{status: http.StatusPartialContent, description: "A patient was fetched from the hospital API but could not be cached", body: response.PatientSearchResponse{}},
+			problem(http.StatusBadRequest, "Fields failing validation"),
+			{status: http.StatusBadGateway, description: "No local match, and the hospital API failed", body: response.PatientSearchResponse{}},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/patient/:id", id: "getPatient", tag: "Patients",
+		summary: "Get patient",
+		auth:    authStaff,
+		params:  []Parameter{patientID},
+		responses: []reply{
+			{status: http.StatusOK, description: "The patient", body: response.Search{}},
+			{status: http.StatusPermanentRedirect, description: "The patient was merged; the body is the survivor", body: response.Search{}, headers: locationHeader},
+			problem(http.StatusNotFound, "Unknown patient, or a patient not linked to the staff member's hospital"),
+			problem(http.StatusGone, "The patient was erased"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/patient/:id/refresh", id: "refreshPatient", tag: "Patients",
+		summary: "Refresh patient from the hospital API",
+		auth:    authStaff,
+		params:  []Parameter{patientID},
+		responses: []reply{
+			{status: http.StatusOK, description: "The refreshed patient and the columns that changed", body: response.PatientRefreshResponse{}},
+			problem(http.StatusBadRequest, "The staff member's hospital has no API"),
+			problem(http.StatusNotFound, "Unknown patient, a patient not linked to the staff member's hospital, or one the hospital API no longer has"),
+			problem(http.StatusGone, "The patient was erased"),
+			problem(http.StatusBadGateway, "The hospital API failed"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/patient/merge", id: "mergePatients", tag: "Patients",
+		summary:     "Merge patients",
+		description: "Merges the retired patient into the survivor, which takes over its hospital links. Both must be linked to the admin's hospital.",
+		auth:        authAdmin,
+		request:     request.PatientMergeRequest{},
+		responses: []reply{
+			{status: http.StatusOK, description: "The survivor and the merge record", body: response.PatientMergeResponse{}},
+			problem(http.StatusBadRequest, "Missing IDs, the same ID twice, or an unknown prefer column or side"),
+			problem(http.StatusNotFound, "Either patient does not exist or is not linked to the admin's hospital"),
+			problem(http.StatusConflict, "The patients have different national IDs"),
+			problem(http.StatusGone, "Either patient was erased"),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/patient/:id/merges", id: "listMerges", tag: "Patients",
+		summary: "List merges into a patient",
+		auth:    authStaff,
+		params:  []Parameter{patientID},
+		responses: []reply{
+			{status: http.StatusOK, description: "Merges, newest first", body: response.PatientMergeHistoryResponse{}},
+			problem(http.StatusNotFound, "Unknown patient, or a patient not linked to the staff member's hospital"),
+			problem(http.StatusGone, "The patient was erased"),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/patient/duplicates", id: "listDuplicates", tag: "Patients",
+		summary:     "List duplicate candidates",
+		description: "Pairs whose patients are both linked to the staff member's hospital.",
+		auth:        authStaff,
+		params: []Parameter{
+			query("limit", "", number("integer", 1, 500, 50)),
+			query("min_score", "", number("number", 0, 1, 0)),
+		},
+		responses: []reply{
+			{status: http.StatusOK, description: "Pending candidates, highest score first", body: response.DuplicateListResponse{}},
+			problem(http.StatusBadRequest, "Invalid limit or min_score"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/patient/duplicates/:id/dismiss", id: "dismissDuplicate", tag: "Patients",
+		summary: "Dismiss duplicate candidate",
+		auth:    authAdmin,
+		params:  []Parameter{pathParam("id", "Candidate ID")},
+		responses: []reply{
+			{status: http.StatusNoContent, description: "Dismissed"},
+			problem(http.StatusNotFound, "Unknown candidate, or one not listed to the admin"),
+			problem(http.StatusConflict, "The candidate was already merged or dismissed"),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/patient/:id/export", id: "exportPatient", tag: "Patients",
+		summary:     "Export patient",
+		description: "Everything held about the patient, as a signed bundle, for data portability requests under the PDPA. Only for patients linked to the admin's hospital.",
+		auth:        authAdmin,
+		params:      []Parameter{patientID},
+		responses: []reply{
+			{status: http.StatusOK, description: "The bundle", body: binarySchema, mediaTypes: []string{"application/zip"}},
+			problem(http.StatusNotFound, "Unknown patient, or a patient not linked to the staff member's hospital"),
+			problem(http.StatusGone, "The patient was erased"),
+		},
+	},
+	{
+		method: http.MethodDelete, path: "/patient/:id/erase", id: "erasePatient", tag: "Patients",
+		summary:     "Erase patient",
+		description: "Erases a patient linked to the admin's hospital. A patient the hospital pseudonymized can still be deleted.",
+		auth:        authAdmin,
+		params: []Parameter{
+			patientID,
+			query("mode", "", &Schema{Type: "string", Enum: []any{service.EraseModeDelete, service.EraseModePseudonymize}, Default: service.EraseModeDelete}),
+		},
+		responses: []reply{
+			{status: http.StatusNoContent, description: "Erased"},
+			problem(http.StatusBadRequest, "Unknown mode"),
+			problem(http.StatusNotFound, "Unknown patient, or a patient not linked to the admin's hospital"),
+			problem(http.StatusGone, "The patient was already pseudonymized"),
+		},
+	},
+
+	{
+		method: http.MethodPost, path: "/patient/import", id: "importPatients", tag: "Bulk",
+		summary:      "Import patients",
+		description:  "Queues an import of hospital records as CSV with a header row, or NDJSON, into the staff member's hospital. A background job runs it; the import job reports its progress.",
+		auth:         authAdmin,
+		params:       []Parameter{query("format", "Overrides the Content-Type", enum(service.ImportFormatCSV, service.ImportFormatNDJSON))},
+		request:      stringSchema,
+		requestTypes: importTypes,
+		responses:    importReplies,
+	},
+	{
+		method: http.MethodGet, path: "/patient/import/:job_id", id: "getImportJob", tag: "Bulk",
+		summary: "Get import job",
+		auth:    authAdmin,
+		params:  []Parameter{pathParam("job_id", "Import job ID")},
+		responses: []reply{
+			{status: http.StatusOK, description: "The job", body: response.ImportJobResponse{}},
+			problem(http.StatusNotFound, "Unknown job, or a job of another hospital"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/patient/import/:job_id/resume", id: "resumeImport", tag: "Bulk",
+		summary:      "Resume import",
+		description:  "Queues a stopped import again with the same body; lines up to the job's checkpoint are skipped.",
+		auth:         authAdmin,
+		params:       []Parameter{pathParam("job_id", "Import job ID")},
+		request:      stringSchema,
+		requestTypes: importTypes,
+		responses: append(importReplies,
+			problem(http.StatusNotFound, "Unknown job, or a job of another hospital"),
+			problem(http.StatusConflict, "The job completed, or is already queued or running"),
+		),
+	},
+	{
+		method: http.MethodPost, path: "/patient/export", id: "exportPatients", tag: "Bulk",
+		summary:     "Export patients",
+		description: "Queues an export of the hospital's patients as NDJSON hospital records. Once the job succeeded, the admin who queued it downloads the records from its output.",
+		auth:        authAdmin,
+		responses: []reply{
+			{status: http.StatusAccepted, description: "The export job, queued", body: response.JobResponse{}, headers: locationHeader},
+			problem(http.StatusBadRequest, "The hospital is not supported"),
+		},
+	},
+
+	{
+		method: http.MethodPost, path: "/integrations/:hospital/webhook", id: "webhook", tag: "Integrations",
+		summary:     "Receive hospital webhook",
+		description: "Applies patient changes pushed by a hospital, authenticated by an HMAC-SHA256 signature of the request.",
+		auth:        authSignature,
+		params:      []Parameter{pathParam("hospital", "Hospital name")},
+		request:     request.WebhookRequest{},
+		responses: []reply{
+			{status: http.StatusOK, description: "One result per event", body: response.WebhookResponse{}},
+			problem(http.StatusBadRequest, "Malformed body, unsupported version or too many events"),
+			problem(http.StatusNotFound, "No webhook is configured for the hospital"),
+			problem(http.StatusRequestEntityTooLarge, "The body exceeds 1 MiB"),
+		},
+	},
+
+	{
+		method: http.MethodGet, path: "/fhir/Patient/:id", id: "fhirReadPatient", tag: "FHIR",
+		summary: "Read Patient",
+		auth:    authStaff,
+		params:  []Parameter{patientID},
+		responses: []reply{
+			fhirReply(http.StatusOK, "The Patient resource", fhir.Patient{}),
+			fhirReply(http.StatusNotFound, "Unknown patient, or a patient not linked to the staff member's hospital", fhir.OperationOutcome{}),
+			fhirReply(http.StatusGone, "The patient was erased", fhir.OperationOutcome{}),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/fhir/Patient", id: "fhirSearchPatients", tag: "FHIR",
+		summary: "Search Patient",
+		auth:    authStaff,
+		params: []Parameter{
+			query("identifier", "[system|]value; without a system, a national ID, passport or HN", stringSchema),
+			query("name", "Part of any Thai or English name", stringSchema),
+			query("birthdate", "YYYY, YYYY-MM or YYYY-MM-DD, optionally prefixed with eq, ge, gt, le, lt, sa or eb", stringSchema),
+			query("telecom", "[phone|email|]value", stringSchema),
+			query("gender", "", enum("male", "female")),
+		},
+		responses: []reply{
+			fhirReply(http.StatusOK, "A searchset Bundle of Patient resources, and an OperationOutcome of warnings", fhir.Bundle{}),
+			fhirReply(http.StatusBadRequest, "Unparseable birthdate or gender", fhir.OperationOutcome{}),
+			fhirReply(http.StatusBadGateway, "No local match, and the hospital API failed", fhir.OperationOutcome{}),
+		},
+	},
+
+	{
+		method: http.MethodPost, path: "/jobs", id: "createJob", tag: "Jobs",
+		summary: "Create job",
+		auth:    authAdmin,
+		request: request.JobRequest{},
+		responses: []reply{
+			{status: http.StatusAccepted, description: "The job, queued", body: response.JobResponse{}, headers: locationHeader},
+			problem(http.StatusBadRequest, "Missing or unknown kind"),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/jobs/:id", id: "getJob", tag: "Jobs",
+		summary: "Get job",
+		auth:    authAdmin,
+		params:  []Parameter{pathParam("id", "Job ID")},
+		responses: []reply{
+			{status: http.StatusOK, description: "The job", body: response.JobResponse{}},
+			problem(http.StatusNotFound, "Unknown job, or a job of another hospital"),
+		},
+	},
+	{
+		method: http.MethodGet, path: "/jobs/:id/output", id: "getJobOutput", tag: "Jobs",
+		summary:     "Download job output",
+		description: "The file a job produced, such as an export's records, for the admin who queued the job. Outputs expire after JOB_OUTPUT_TTL.",
+		auth:        authAdmin,
+		params:      []Parameter{pathParam("id", "Job ID")},
+		responses: []reply{
+			{status: http.StatusOK, description: "The output", body: binarySchema, mediaTypes: []string{"application/x-ndjson"}},
+			problem(http.StatusNotFound, "Unknown job, a job without output yet, or one queued by someone else"),
+			problem(http.StatusGone, "The output expired"),
+		},
+	},
+	{
+		method: http.MethodPost, path: "/jobs/:id/cancel", id: "cancelJob", tag: "Jobs",
+		summary:     "Cancel job",
+		description: "Cancels a queued job; a running one is returned with cancel_requested set until its worker stops it.",
+		auth:        authAdmin,
+		params:      []Parameter{pathParam("id", "Job ID")},
+		responses: []reply{
+			{status: http.StatusOK, description: "The job", body: response.JobResponse{}},
+			problem(http.StatusNotFound, "Unknown job, or a job of another hospital"),
+			problem(http.StatusConflict, "The job already finished"),
+		},
+	},
+
+	{
+		method: http.MethodGet, path: "/", id: "root", tag: "Service",
+		summary: "Service name",
+		responses: []reply{{status: http.StatusOK, description: "The service name", body: &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{"message": stringSchema},
+		}}},
+	},
+	{
+		method: http.MethodGet, path: "/healthz", id: "liveness", tag: "Service",
+		summary: "Liveness probe",
+		responses: []reply{
+			{status: http.StatusOK, description: "The process is serving", body: response.HealthResponse{}},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/readyz", id: "readiness", tag: "Service",
+		summary: "Readiness probe",
+		responses: []reply{
+			{status: http.StatusOK, description: "Up, or degraded by a non-critical component", body: response.HealthResponse{}},
+			{status: http.StatusServiceUnavailable, description: "A critical component is down", body: response.HealthResponse{}},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/metrics", id: "metrics", tag: "Service",
+		summary:     "Prometheus metrics",
+		description: "Not exposed by nginx.",
+		responses: []reply{
+			{status: http.StatusOK, description: "The text exposition format", body: stringSchema, mediaTypes: []string{"text/plain"}},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", id: "openapi", tag: "Service",
+		summary: "This document",
+		responses: []reply{
+			{status: http.StatusOK, description: "The OpenAPI 3.1 document", body: &Schema{Type: "object"}},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/docs", id: "docs", tag: "Service",
+		summary: "API documentation",
+		responses: []reply{
+			{status: http.StatusOK, description: "A page rendering this document", body: stringSchema, mediaTypes: []string{"text/html"}},
+		},
+	},
+}
+
+var importReplies = []reply{
+	{status: http.StatusAccepted, description: "The import job, queued", body: response.ImportJobResponse{}, headers: locationHeader},
+	problem(http.StatusBadRequest, "Unsupported format, CSV header or hospital"),
+	problem(http.StatusRequestEntityTooLarge, "The body exceeds 64 MiB"),
+}
+
+// ginParam matches the parameters of gin paths, :name and *name.
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// Path turns a gin route path into an OpenAPI one, /patient/:id into
+// /patient/{id}.
+func Path(ginPath string) string {
+	return ginParam.ReplaceAllString(ginPath, "{$1}")
+}
+
+// Spec returns the document of the API, generated on first use.
+func Spec() *Document {
+	return spec()
+}
+
+var spec = sync.OnceValue(build)
+
+func build() *Document {
+	registry := newSchemaRegistry()
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:   "Agnos Hospital Middleware API",
+			Version: "1",
+			Description: "Every response carries an X-Request-ID header; quote it when reporting a problem. " +
+				"Errors are RFC 7807 problem details with a stable code, except for the FHIR API, which answers with an OperationOutcome.",
+		},
+		Tags:  tags,
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "A staff token from POST /staff/login"},
+			},
+		},
+	}
+	for _, op := range operations {
+		path := Path(op.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(op.method)] = op.document(registry)
+	}
+	doc.Components.Schemas = registry.schemas
+	return doc
+}
+
+func (op operation) document(registry *schemaRegistry) *Operation {
+	documented := &Operation{
+		Tags:        []string{op.tag},
+		Summary:     op.summary,
+		Description: op.description,
+		OperationID: op.id,
+		Responses:   make(map[string]*Response),
+	}
+
+	for _, match := range ginParam.FindAllStringSubmatch(op.path, -1) {
+		param := pathParam(match[1], "")
+		for _, described := range op.params {
+			if described.In == "path" && described.Name == param.Name {
+				param = described
+			}
+		}
+		documented.Parameters = append(documented.Parameters, param)
+	}
+	for _, param := range op.params {
+		if param.In != "path" {
+			documented.Parameters = append(documented.Parameters, param)
+		}
+	}
+
+	replies := op.responses
+	switch op.auth {
+	case authStaff, authAdmin:
+		documented.Security = []map[string][]string{{bearerAuth: {}}}
+		replies = append(replies, problem(http.StatusUnauthorized, "Missing or invalid token"))
+		if op.auth == authAdmin {
+			replies = append(replies, problem(http.StatusForbidden, "The staff member is not an admin"))
+		}
+	case authSignature:
+		documented.Parameters = append(documented.Parameters,
+			Parameter{Name: hospital.SignatureTimestampHeader, In: "header", Required: true, Description: "Unix seconds", Schema: stringSchema},
+			Parameter{
+				Name: hospital.SignatureHeader, In: "header", Required: true, Schema: stringSchema,
+				Description: `hex(HMAC-SHA256(secret, METHOD + "\n" + REQUEST_URI + "\n" + TIMESTAMP + "\n" + hex(SHA256(body))))`,
+			},
+		)
+		replies = append(replies, problem(http.StatusUnauthorized, "Missing, invalid or expired signature"))
+	}
+
+	if op.request != nil {
+		documented.RequestBody = &RequestBody{Required: true, Content: content(registry, op.request, op.requestTypes)}
+	}
+	for _, r := range replies {
+		documented.Responses[strconv.Itoa(r.status)] = &Response{
+			Description: r.description,
+			Headers:     r.headers,
+			Content:     content(registry, r.body, r.mediaTypes),
+		}
+	}
+	documented.Responses["default"] = &Response{
+		Description: "Unexpected error; its cause is only logged",
+		Content:     content(registry, response.Problem{}, []string{middleware.ProblemContentType}),
+	}
+	return documented
+}
+
+// content documents body in each of mediaTypes, application/json when none.
+func content(registry *schemaRegistry, body any, mediaTypes []string) map[string]MediaType {
+	if body == nil {
+		return nil
+	}
+	schema, ok := body.(*Schema)
+	if !ok {
+		schema = registry.of(reflect.TypeOf(body))
+	}
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+	content := make(map[string]MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = MediaType{Schema: schema}
+	}
+	return content
+}
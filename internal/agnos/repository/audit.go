@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// auditLockKey is the advisory lock serializing appends, so each entry chains
+// to the one before; it spells "audit" in ASCII.
+const auditLockKey = 0x6175646974
+
+type AuditRepository interface {
+	Append(entries []*entity.AuditEntry) error
+	EachEntry(batchSize int, fn func(entries []*entity.AuditEntry) error) error
+	// ListByPatients returns the entries about any of patientIDs in order.
+	ListByPatients(patientIDs []uuid.UUID) ([]*entity.AuditEntry, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// Append chains entries onto the log, setting their PrevHash and Hash.
+func (r *auditRepository) Append(entries []*entity.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+			return err
+		}
+
+		var last entity.AuditEntry
+		err := tx.Order("seq DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+
+		prevHash := last.Hash
+		for _, entry := range entries {
+			entry.PrevHash = prevHash
+			entry.Hash = entry.ComputeHash()
+			prevHash = entry.Hash
+		}
+		return tx.Create(entries).Error
+	})
+}
+
+// EachEntry passes the whole log to fn in seq order, batchSize entries at a time.
+func (r *auditRepository) EachEntry(batchSize int, fn func(entries []*entity.AuditEntry) error) error {
+	var entries []*entity.AuditEntry
+	return r.db.FindInBatches(&entries, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(entries)
+	}).Error
+}
+
+func (r *auditRepository) ListByPatients(patientIDs []uuid.UUID) ([]*entity.AuditEntry, error) {
+	var entries []*entity.AuditEntry
+	err := r.db.Where("patient_id IN ?", patientIDs).Order("seq").Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrImportJobMoved is returned by SaveBatch when another process advanced the
+// job's checkpoint first, i.e. the job is being resumed twice.
+var ErrImportJobMoved = errors.New("import job was advanced by another run")
+
+type ImportJobRepository interface {
+	Create(job *entity.ImportJob) error
+	GetByID(id string) (*entity.ImportJob, error)
+	// Update saves the job's status and counters.
+	Update(job *entity.ImportJob) error
+	// SetJobID records the background job that runs the job, leaving the
+	// rest of it to that job.
+	SetJobID(id string, jobID uuid.UUID) error
+	// SaveBatch calls fn inside a transaction and then saves job together with
+	// the row errors fn returned, so a batch of records and the checkpoint
+	// covering it are committed or rolled back as one. checkpoint is the job's
+	// checkpoint before the batch.
+	SaveBatch(job *entity.ImportJob, checkpoint int, fn func(batch ImportBatch) ([]*entity.ImportJobError, error)) error
+	// ListErrors returns up to limit errors of the job in line order.
+	ListErrors(jobID string, limit int) ([]*entity.ImportJobError, error)
+}
+
+// ImportBatch is the transaction of one import batch.
+type ImportBatch interface {
+	// Row runs fn in a savepoint, so that a record failing in the database
+	// is rolled back without aborting the rest of the batch.
+	Row(fn func(patients PatientRepository) error) error
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{
+		db: db,
+	}
+}
+
+func (r *importJobRepository) Create(job *entity.ImportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *importJobRepository) GetByID(id string) (*entity.ImportJob, error) {
+	var job entity.ImportJob
+	if err := r.db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *importJobRepository) Update(job *entity.ImportJob) error {
+	return r.db.Save(job).Error
+}
+
+func (r *importJobRepository) SetJobID(id string, jobID uuid.UUID) error {
+	return r.db.Model(&entity.ImportJob{}).Where("id = ?", id).Update("job_id", jobID).Error
+}
+
+func (r *importJobRepository) SaveBatch(job *entity.ImportJob, checkpoint int, fn func(batch ImportBatch) ([]*entity.ImportJobError, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		rowErrors, err := fn(&importBatch{tx: tx})
+		if err != nil {
+			return err
+		}
+		if len(rowErrors) > 0 {
+			if err := tx.Create(&rowErrors).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(job).
+			Where("checkpoint = ?", checkpoint).
+			Select("status", "checkpoint", "created", "updated", "duplicates", "failed", "error", "updated_at").
+			Updates(job)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrImportJobMoved
+		}
+		return nil
+	})
+}
+
+func (r *importJobRepository) ListErrors(jobID string, limit int) ([]*entity.ImportJobError, error) {
+	var rowErrors []*entity.ImportJobError
+	err := r.db.Where("job_id = ?", jobID).Order("line").Limit(limit).Find(&rowErrors).Error
+	return rowErrors, err
+}
+
+type importBatch struct {
+	tx *gorm.DB
+}
+
+func (b *importBatch) Row(fn func(patients PatientRepository) error) error {
+	return b.tx.Transaction(func(tx *gorm.DB) error {
+		return fn(NewPatientRepository(tx))
+	})
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJobLost is returned when a running job is no longer held by the attempt
+// acting on it: its lease expired and another worker claimed it again.
+var ErrJobLost = errors.New("job lease was lost to another worker")
+
+type JobRepository interface {
+	// Create saves a new job together with its files.
+	Create(job *entity.Job, files ...*entity.JobFile) error
+	GetByID(id string) (*entity.Job, error)
+	// Claim takes the first due job of one of kinds, or a running one whose
+	// lease expired, marks it running for another attempt and leases it until
+	// lockedUntil. It returns nil when no job is due.
+	Claim(kinds []string, lockedUntil time.Time) (*entity.Job, error)
+	// Heartbeat extends the lease of the job's current attempt to lockedUntil
+	// and reports whether cancelling the job was requested.
+	Heartbeat(job *entity.Job, lockedUntil time.Time) (bool, error)
+	// Finish saves the outcome of attempt, the job's current attempt, and
+	// deletes the job's input once it finished.
+	Finish(job *entity.Job, attempt int) error
+	// Cancel cancels a queued job, deleting its input, or asks the worker
+	// running it to stop.
+	Cancel(id string) error
+	// SaveFile creates or replaces a file of a job.
+	SaveFile(file *entity.JobFile) error
+	GetFile(jobID, name string) (*entity.JobFile, error)
+	// DeleteExpiredFiles deletes the files that expired before now and
+	// returns how many there were.
+	DeleteExpiredFiles(now time.Time) (int64, error)
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{
+		db: db,
+	}
+}
+
+func (r *jobRepository) Create(job *entity.Job, files ...*entity.JobFile) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		for _, file := range files {
+			file.JobID = job.ID
+			if err := tx.Create(file).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *jobRepository) GetByID(id string) (*entity.Job, error) {
+	var job entity.Job
+	if err := r.db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim skips rows locked by other workers' claims, so concurrent workers
+// never wait on each other or take the same job.
+func (r *jobRepository) Claim(kinds []string, lockedUntil time.Time) (*entity.Job, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	var claimed *entity.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var jobs []*entity.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("kind IN ?", kinds).
+			Where("((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))",
+				entity.JobQueued, now, entity.JobRunning, now).
+			Order("run_at").
+			Limit(1).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		job := jobs[0]
+		job.Status = entity.JobRunning
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		err = tx.Model(job).Select("status", "attempts", "locked_until", "updated_at").Updates(job).Error
+		if err != nil {
+			return err
+		}
+		claimed = job
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *jobRepository) Heartbeat(job *entity.Job, lockedUntil time.Time) (bool, error) {
+	result := r.db.Model(&entity.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, entity.JobRunning, job.Attempts).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrJobLost
+	}
+
+	var cancelRequested bool
+	err := r.db.Model(&entity.Job{}).Where("id = ?", job.ID).Select("cancel_requested").Scan(&cancelRequested).Error
+	return cancelRequested, err
+}
+
+// Finish only updates the job while the attempt still holds it, and reports
+// ErrJobLost otherwise.
+func (r *jobRepository) Finish(job *entity.Job, attempt int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(job).
+			Where("status = ? AND attempts = ?", entity.JobRunning, attempt).
+			Select("status", "attempts", "run_at", "locked_until", "result", "error", "updated_at", "finished_at").
+			Updates(job)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrJobLost
+		}
+		if !job.Finished() {
+			return nil
+		}
+		return tx.Where("job_id = ? AND name = ?", job.ID, entity.JobFileInput).Delete(&entity.JobFile{}).Error
+	})
+}
+
+func (r *jobRepository) Cancel(id string) error {
+	now := time.Now()
+	result := r.db.Model(&entity.Job{}).
+		Where("id = ? AND status = ?", id, entity.JobQueued).
+		Updates(map[string]interface{}{"status": entity.JobCancelled, "finished_at": now, "updated_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return r.db.Where("job_id = ? AND name = ?", id, entity.JobFileInput).Delete(&entity.JobFile{}).Error
+	}
+	return r.db.Model(&entity.Job{}).
+		Where("id = ? AND status = ?", id, entity.JobRunning).
+		Updates(map[string]interface{}{"cancel_requested": true, "updated_at": now}).Error
+}
+
+func (r *jobRepository) SaveFile(file *entity.JobFile) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(file).Error
+}
+
+func (r *jobRepository) GetFile(jobID, name string) (*entity.JobFile, error) {
+	var file entity.JobFile
+	if err := r.db.Where("job_id = ? AND name = ?", jobID, name).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *jobRepository) DeleteExpiredFiles(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", now).Delete(&entity.JobFile{})
+	return result.RowsAffected, result.Error
+}
@@ -1,14 +1,28 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/validation"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PatientRepository interface {
 	Create(patient *entity.Patient) error
-	Search(filters map[string]interface{}) ([]*entity.Patient, error)
+	Update(patient *entity.Patient) error
+	// Search returns the patients linked to hospital that match filters.
+	Search(ctx context.Context, hospital string, filters map[string]interface{}) ([]*entity.Patient, error)
 	GetByID(id string) (*entity.Patient, error)
+	GetByIdentifiers(nationalID, passportID string) (*entity.Patient, error)
+	Delete(id string) error
+	SaveHospitalLink(link *entity.PatientHospital) error
+	DeleteHospitalLink(patientID, hospital string) error
+	ListStale(hospital string, attemptedBefore time.Time, limit int) ([]*entity.Patient, error)
+	EachByHospital(hospital string, batchSize int, fn func(patients []*entity.Patient) error) error
 }
 
 type patientRepository struct {
@@ -25,10 +39,17 @@ func (r *patientRepository) Create(patient *entity.Patient) error {
 	return r.db.Create(patient).Error
 }
 
-func (r *patientRepository) Search(filters map[string]interface{}) ([]*entity.Patient, error) {
+// Update saves the patient's own columns; hospital links are saved separately.
+func (r *patientRepository) Update(patient *entity.Patient) error {
+	return r.db.Omit(clause.Associations).Save(patient).Error
+}
+
+func (r *patientRepository) Search(ctx context.Context, hospital string, filters map[string]interface{}) ([]*entity.Patient, error) {
 	var patients []*entity.Patient
-	query := r.db.Model(&entity.Patient{})
-	
+	// Erased patients have no PII left to match, and are not listed.
+	query := r.db.WithContext(ctx).Model(&entity.Patient{}).Preload("Hospitals").Where("erased_at IS NULL").
+		Where("EXISTS (SELECT 1 FROM tbl_patient_hospitals h WHERE h.patient_id = tbl_patients.id AND h.hospital = ?)", hospital)
+
 	for key, value := range filters {
 		if value != nil && value != "" {
 			switch key {
@@ -42,25 +63,167 @@ func (r *patientRepository) Search(filters map[string]interface{}) ([]*entity.Pa
 				query = query.Where("middle_name_th ILIKE ? OR middle_name_en ILIKE ?", "%"+value.(string)+"%", "%"+value.(string)+"%")
 			case "last_name":
 				query = query.Where("last_name_th ILIKE ? OR last_name_en ILIKE ?", "%"+value.(string)+"%", "%"+value.(string)+"%")
+			case "name":
+				pattern := "%" + value.(string) + "%"
+				query = query.Where("first_name_th ILIKE @name OR middle_name_th ILIKE @name OR last_name_th ILIKE @name OR first_name_en ILIKE @name OR middle_name_en ILIKE @name OR last_name_en ILIKE @name", sql.Named("name", pattern))
 			case "date_of_birth":
 				query = query.Where("date_of_birth = ?", value)
+			case "date_of_birth_from":
+				query = query.Where("date_of_birth >= ?", value)
+			case "date_of_birth_to":
+				query = query.Where("date_of_birth <= ?", value)
+			case "birth_year":
+				from := time.Date(value.(int), time.January, 1, 0, 0, 0, 0, time.UTC)
+				query = query.Where("date_of_birth BETWEEN ? AND ?", from, from.AddDate(1, 0, -1))
+			case "age_min":
+				// Ages are turned into date of birth bounds so the index applies.
+				query = query.Where("date_of_birth <= ?", today().AddDate(-value.(int), 0, 0))
+			case "age_max":
+				query = query.Where("date_of_birth > ?", today().AddDate(-value.(int)-1, 0, 0))
+			case "gender":
+				query = query.Where("gender = ?", value)
+			case "patient_hn":
+				// An HN qualified by hn_hospital only matches the HN that
+				// hospital issued.
+				if hospital, _ := filters["hn_hospital"].(string); hospital != "" {
+					query = query.Where("id IN (?)",
+						r.db.Model(&entity.PatientHospital{}).Select("patient_id").Where("hospital = ? AND patient_hn = ?", hospital, value))
+					break
+				}
+				query = query.Where("patient_hn = ? OR id IN (?)", value,
+					r.db.Model(&entity.PatientHospital{}).Select("patient_id").Where("patient_hn = ?", value))
+			case "identifier":
+				query = query.Where("national_id = ? OR passport_id = ? OR patient_hn = ? OR id IN (?)", value, value, value,
+					r.db.Model(&entity.PatientHospital{}).Select("patient_id").Where("patient_hn = ?", value))
+			case "telecom":
+				query = query.Where("phone_normalized = ? OR email_normalized = ?",
+					validation.CanonicalPhone(value.(string)), validation.CanonicalEmail(value.(string)))
 			case "phone_number":
-				query = query.Where("phone_number = ?", value)
+				query = query.Where("phone_normalized = ?", validation.CanonicalPhone(value.(string)))
 			case "email":
-				query = query.Where("email = ?", value)
+				query = query.Where("email_normalized = ?", validation.CanonicalEmail(value.(string)))
 			}
 		}
 	}
-	
+
 	err := query.Find(&patients).Error
 	return patients, err
 }
 
+// today is midnight UTC of the current date, the form dates of birth are
+// stored in.
+func today() time.Time {
+	year, month, day := time.Now().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
 func (r *patientRepository) GetByID(id string) (*entity.Patient, error) {
 	var patient entity.Patient
-	err := r.db.Where("id = ?", id).First(&patient).Error
+	err := r.db.Preload("Hospitals").Where("id = ?", id).First(&patient).Error
 	if err != nil {
 		return nil, err
 	}
 	return &patient, nil
 }
+
+// GetByIdentifiers finds the patient holding either the national ID or the
+// passport ID; empty identifiers are ignored.
+func (r *patientRepository) GetByIdentifiers(nationalID, passportID string) (*entity.Patient, error) {
+	if nationalID == "" && passportID == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	query := r.db.Preload("Hospitals")
+	switch {
+	case nationalID != "" && passportID != "":
+		query = query.Where("national_id = ? OR passport_id = ?", nationalID, passportID)
+	case nationalID != "":
+		query = query.Where("national_id = ?", nationalID)
+	default:
+		query = query.Where("passport_id = ?", passportID)
+	}
+
+	var patient entity.Patient
+	if err := query.First(&patient).Error; err != nil {
+		return nil, err
+	}
+	return &patient, nil
+}
+
+// Delete removes the patient together with its hospital links and any
+// unreviewed duplicate pairs it is part of.
+func (r *patientRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("patient_id = ?", id).Delete(&entity.PatientHospital{}).Error; err != nil {
+			return err
+		}
+		err := tx.Where("(patient_a_id = @id OR patient_b_id = @id) AND status IN @statuses", map[string]interface{}{
+			"id":       id,
+			"statuses": []string{entity.DuplicateStatusPending, entity.DuplicateStatusUnlikely},
+		}).Delete(&entity.DuplicateCandidate{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&entity.Patient{}).Error
+	})
+}
+
+func (r *patientRepository) SaveHospitalLink(link *entity.PatientHospital) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "patient_id"}, {Name: "hospital"}},
+		DoUpdates: clause.AssignmentColumns([]string{"patient_hn", "last_synced_at", "last_attempt_at", "sync_error", "updated_at"}),
+	}).Create(link).Error
+}
+
+// ListStale returns patients linked to hospital whose last sync attempt from
+// it is older than attemptedBefore, least recently attempted first.
+func (r *patientRepository) ListStale(hospital string, attemptedBefore time.Time, limit int) ([]*entity.Patient, error) {
+	var patients []*entity.Patient
+	err := r.db.Preload("Hospitals").
+		Joins("JOIN tbl_patient_hospitals ph ON ph.patient_id = tbl_patients.id").
+		Where("ph.hospital = ? AND (ph.last_attempt_at IS NULL OR ph.last_attempt_at < ?)", hospital, attemptedBefore).
+		Order("ph.last_attempt_at ASC NULLS FIRST").
+		Limit(limit).
+		Find(&patients).Error
+	return patients, err
+}
+
+// EachByHospital calls fn with the patients linked to hospital, batchSize at a
+// time in ID order, stopping at the first error fn returns.
+func (r *patientRepository) EachByHospital(hospital string, batchSize int, fn func(patients []*entity.Patient) error) error {
+	var patients []*entity.Patient
+	return r.db.Preload("Hospitals").
+		Where("id IN (?)", r.db.Model(&entity.PatientHospital{}).Select("patient_id").Where("hospital = ?", hospital)).
+		FindInBatches(&patients, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(patients)
+		}).Error
+}
+
+func (r *patientRepository) DeleteHospitalLink(patientID, hospital string) error {
+	return r.db.Where("patient_id = ? AND hospital = ?", patientID, hospital).Delete(&entity.PatientHospital{}).Error
+}
+
+// BackfillNormalizedContacts fills the normalized phone and email columns of
+// patients stored before the columns existed, batchSize rows at a time, and
+// returns how many patients were updated.
+func BackfillNormalizedContacts(db *gorm.DB, batchSize int) (int, error) {
+	updated := 0
+	var patients []*entity.Patient
+	err := db.Select("id", "phone_number", "email").
+		Where("(phone_number <> '' AND COALESCE(phone_normalized, '') = '') OR (email <> '' AND COALESCE(email_normalized, '') = '')").
+		FindInBatches(&patients, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, patient := range patients {
+				patient.NormalizeContacts()
+				err := db.Model(patient).UpdateColumns(map[string]interface{}{
+					"phone_normalized": patient.PhoneNormalized,
+					"email_normalized": patient.EmailNormalized,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			updated += len(patients)
+			return nil
+		}).Error
+	return updated, err
+}
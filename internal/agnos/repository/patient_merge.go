@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PatientMergeRepository interface {
+	Merge(survivor *entity.Patient, retiredID string, merge *entity.PatientMerge) error
+	GetByRetiredID(retiredID string) (*entity.PatientMerge, error)
+	ListBySurvivorID(survivorID string) ([]*entity.PatientMerge, error)
+	FindUnscoredPairs(limit int) ([]*entity.DuplicateCandidate, error)
+	SaveCandidates(candidates []*entity.DuplicateCandidate) error
+	ListCandidates(status, hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error)
+	GetCandidate(id string) (*entity.DuplicateCandidate, error)
+	ReviewCandidate(id, status, reviewedBy string) error
+}
+
+type patientMergeRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientMergeRepository(db *gorm.DB) PatientMergeRepository {
+	return &patientMergeRepository{
+		db: db,
+	}
+}
+
+// Merge retires retiredID into survivor in one transaction: hospital links
+// move to the survivor (its own link wins where both are linked to the same
+// hospital), earlier merges into the retired patient are re-pointed so
+// redirects stay a single hop, the retired row is deleted and the survivor
+// saved with its merged columns.
+func (r *patientMergeRepository) Merge(survivor *entity.Patient, retiredID string, merge *entity.PatientMerge) error {
+	survivorID := survivor.ID.String()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("patient_id = ? AND hospital IN (?)", retiredID,
+			tx.Model(&entity.PatientHospital{}).Select("hospital").Where("patient_id = ?", survivorID),
+		).Delete(&entity.PatientHospital{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.PatientHospital{}).Where("patient_id = ?", retiredID).Update("patient_id", survivorID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entity.PatientMerge{}).Where("survivor_id = ?", retiredID).Update("survivor_id", survivorID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		a, b := orderedPair(survivorID, retiredID)
+		err = tx.Model(&entity.DuplicateCandidate{}).
+			Where("patient_a_id = ? AND patient_b_id = ?", a, b).
+			Updates(map[string]interface{}{"status": entity.DuplicateStatusMerged, "reviewed_by": merge.MergedBy, "reviewed_at": now}).Error
+		if err != nil {
+			return err
+		}
+		// Other open pairs with the retired patient are rescored against the survivor.
+		err = tx.Where("(patient_a_id = @id OR patient_b_id = @id) AND status IN @statuses", map[string]interface{}{
+			"id":       retiredID,
+			"statuses": []string{entity.DuplicateStatusPending, entity.DuplicateStatusUnlikely},
+		}).Delete(&entity.DuplicateCandidate{}).Error
+		if err != nil {
+			return err
+		}
+
+		// Delete before saving so identifiers taken over from the retired
+		// patient do not collide with its unique columns.
+		if err := tx.Where("id = ?", retiredID).Delete(&entity.Patient{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(survivor).Error; err != nil {
+			return err
+		}
+		return tx.Create(merge).Error
+	})
+}
+
+func (r *patientMergeRepository) GetByRetiredID(retiredID string) (*entity.PatientMerge, error) {
+	var merge entity.PatientMerge
+	if err := r.db.Where("retired_id = ?", retiredID).First(&merge).Error; err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
+
+func (r *patientMergeRepository) ListBySurvivorID(survivorID string) ([]*entity.PatientMerge, error) {
+	var merges []*entity.PatientMerge
+	err := r.db.Where("survivor_id = ?", survivorID).Order("merged_at DESC").Find(&merges).Error
+	return merges, err
+}
+
+// FindUnscoredPairs returns up to limit pairs of patients sharing a phone
+// number, an email address, or a date of birth together with a first or last
+// name, that have not been scored yet. Each blocking key is an equality join on
+// its own indexed column, so candidates are found without comparing every pair
+// of patients. Erased patients are never paired. Patients are loaded on the
+// returned candidates.
+func (r *patientMergeRepository) FindUnscoredPairs(limit int) ([]*entity.DuplicateCandidate, error) {
+	var pairs []struct {
+		PatientAID uuid.UUID
+		PatientBID uuid.UUID
+	}
+	err := r.db.Raw(`
+		SELECT pair.patient_a_id, pair.patient_b_id
+		FROM (
+			SELECT a.id AS patient_a_id, b.id AS patient_b_id
+			FROM tbl_patients a
+			JOIN tbl_patients b ON b.phone_normalized = a.phone_normalized AND a.id < b.id
+			WHERE a.phone_normalized <> '' AND a.erased_at IS NULL AND b.erased_at IS NULL
+			UNION
+			SELECT a.id, b.id
+			FROM tbl_patients a
+			JOIN tbl_patients b ON b.email_normalized = a.email_normalized AND a.id < b.id
+			WHERE a.email_normalized <> '' AND a.erased_at IS NULL AND b.erased_at IS NULL
+			UNION
+			SELECT a.id, b.id
+			FROM tbl_patients a
+			JOIN tbl_patients b ON b.date_of_birth = a.date_of_birth AND a.id < b.id
+			WHERE a.erased_at IS NULL AND b.erased_at IS NULL AND (
+				(a.last_name_en <> '' AND lower(a.last_name_en) = lower(b.last_name_en))
+				OR (a.first_name_en <> '' AND lower(a.first_name_en) = lower(b.first_name_en))
+				OR (a.last_name_th <> '' AND a.last_name_th = b.last_name_th)
+				OR (a.first_name_th <> '' AND a.first_name_th = b.first_name_th)
+			)
+		) pair
+		WHERE NOT EXISTS (
+			SELECT 1 FROM tbl_duplicate_candidates c
+			WHERE c.patient_a_id = pair.patient_a_id AND c.patient_b_id = pair.patient_b_id
+		)
+		LIMIT ?`, limit).Scan(&pairs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*entity.DuplicateCandidate, 0, len(pairs))
+	for _, pair := range pairs {
+		candidates = append(candidates, &entity.DuplicateCandidate{PatientAID: pair.PatientAID, PatientBID: pair.PatientBID})
+	}
+	return candidates, r.loadPatients(candidates)
+}
+
+// SaveCandidates inserts scored pairs, skipping pairs already stored.
+func (r *patientMergeRepository) SaveCandidates(candidates []*entity.DuplicateCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(candidates).Error
+}
+
+// ListCandidates returns candidates in status scoring at least minScore whose
+// patients are both linked to hospital, highest first, with both patients
+// loaded.
+func (r *patientMergeRepository) ListCandidates(status, hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error) {
+	var candidates []*entity.DuplicateCandidate
+	err := r.db.Where("status = ? AND score >= ?", status, minScore).
+		Where(`EXISTS (SELECT 1 FROM tbl_patient_hospitals h WHERE h.patient_id = tbl_duplicate_candidates.patient_a_id AND h.hospital = @hospital)
+			AND EXISTS (SELECT 1 FROM tbl_patient_hospitals h WHERE h.patient_id = tbl_duplicate_candidates.patient_b_id AND h.hospital = @hospital)`,
+			map[string]interface{}{"hospital": hospital}).
+		Order("score DESC, detected_at ASC").
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, r.loadPatients(candidates)
+}
+
+func (r *patientMergeRepository) GetCandidate(id string) (*entity.DuplicateCandidate, error) {
+	var candidate entity.DuplicateCandidate
+	if err := r.db.Where("id = ?", id).First(&candidate).Error; err != nil {
+		return nil, err
+	}
+	return &candidate, r.loadPatients([]*entity.DuplicateCandidate{&candidate})
+}
+
+func (r *patientMergeRepository) ReviewCandidate(id, status, reviewedBy string) error {
+	return r.db.Model(&entity.DuplicateCandidate{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": reviewedBy, "reviewed_at": time.Now()}).Error
+}
+
+// loadPatients fills PatientA and PatientB with one query. Patients that no
+// longer exist are left nil.
+func (r *patientMergeRepository) loadPatients(candidates []*entity.DuplicateCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(candidates)*2)
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.PatientAID, candidate.PatientBID)
+	}
+
+	var patients []*entity.Patient
+	if err := r.db.Preload("Hospitals").Where("id IN ?", ids).Find(&patients).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*entity.Patient, len(patients))
+	for _, patient := range patients {
+		byID[patient.ID] = patient
+	}
+	for _, candidate := range candidates {
+		candidate.PatientA = byID[candidate.PatientAID]
+		candidate.PatientB = byID[candidate.PatientBID]
+	}
+	return nil
+}
+
+func orderedPair(a, b string) (string, string) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QuarantineRepository interface {
+	Save(record *entity.QuarantinedPatient) error
+}
+
+type quarantineRepository struct {
+	db *gorm.DB
+}
+
+func NewQuarantineRepository(db *gorm.DB) QuarantineRepository {
+	return &quarantineRepository{
+		db: db,
+	}
+}
+
+// Save stores record, replacing an earlier copy under the same reference.
+func (r *quarantineRepository) Save(record *entity.QuarantinedPatient) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hospital"}, {Name: "source"}, {Name: "reference"}},
+		DoUpdates: clause.AssignmentColumns([]string{"record", "errors", "received_at"}),
+	}).Create(record).Error
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idleLinkCondition matches the hospital links of one source not accessed by
+// staff since a cutoff: created before it, with no staff audit entry about the
+// patient after it. Its parameters are hospital, source and the cutoff.
+const idleLinkCondition = `ph.hospital = @hospital AND ph.source = @source AND ph.created_at < @idle_since
+	AND NOT EXISTS (
+		SELECT 1 FROM tbl_audit_log a
+		WHERE a.patient_id = ph.patient_id AND a.actor LIKE 'staff:%' AND a.occurred_at >= @idle_since
+	)`
+
+// IdleLink is a hospital link due for purging under a retention policy.
+type IdleLink struct {
+	PatientID uuid.UUID
+	Hospital  string
+	Source    string
+	// OtherLinks counts the patient's links to other hospitals, which keep
+	// the patient when this link is purged.
+	OtherLinks int
+}
+
+type RetentionRepository interface {
+	// ErasePatient removes the patient's PII: the patient is deleted, or kept
+	// as a pseudonymized row erased by hospital when pseudonymize is set.
+	ErasePatient(id, hospital string, pseudonymize bool) error
+	// ListIdleLinks returns up to limit links from source to hospital idle
+	// since idleSince, of patients with IDs after after, in ID order.
+	ListIdleLinks(hospital, source string, idleSince time.Time, after uuid.UUID, limit int) ([]*IdleLink, error)
+	// PurgeIdleLink deletes link if it is still idle since idleSince, and
+	// erases the patient if that left it without hospitals. It reports
+	// whether the link was deleted and whether the patient was erased.
+	PurgeIdleLink(link *IdleLink, idleSince time.Time) (bool, bool, error)
+}
+
+type retentionRepository struct {
+	db *gorm.DB
+}
+
+func NewRetentionRepository(db *gorm.DB) RetentionRepository {
+	return &retentionRepository{
+		db: db,
+	}
+}
+
+func (r *retentionRepository) ErasePatient(id, hospital string, pseudonymize bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := erasePatient(tx, id, pseudonymize); err != nil || !pseudonymize {
+			return err
+		}
+		return tx.Model(&entity.Patient{}).Where("id = ?", id).UpdateColumn("erased_hospital", hospital).Error
+	})
+}
+
+// erasePatient erases the patient within tx. Hospital links, quarantined
+// copies of its records and the records of patients merged into it go with
+// it. Reviewed duplicate pairs are kept on pseudonymizing, as they hold no
+// PII. The audit log is never touched: its entries only hold the patient ID,
+// and deleting any would break the hash chain.
+func erasePatient(tx *gorm.DB, id string, pseudonymize bool) error {
+	var patient entity.Patient
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&patient).Error; err != nil {
+		return err
+	}
+
+	var identifiers []string
+	for _, identifier := range []string{patient.NationalID, patient.PassportID} {
+		if identifier != "" {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	if len(identifiers) > 0 {
+		err := tx.Where("reference IN @ids OR record->>'national_id' IN @ids OR record->>'passport_id' IN @ids",
+			map[string]interface{}{"ids": identifiers},
+		).Delete(&entity.QuarantinedPatient{}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("patient_id = ?", id).Delete(&entity.PatientHospital{}).Error; err != nil {
+		return err
+	}
+
+	candidates := tx.Where("patient_a_id = @id OR patient_b_id = @id", map[string]interface{}{"id": id})
+	if pseudonymize {
+		candidates = candidates.Where("status IN ?", []string{entity.DuplicateStatusPending, entity.DuplicateStatusUnlikely})
+	}
+	if err := candidates.Delete(&entity.DuplicateCandidate{}).Error; err != nil {
+		return err
+	}
+
+	if !pseudonymize {
+		if err := tx.Where("survivor_id = ?", id).Delete(&entity.PatientMerge{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&entity.Patient{}).Error
+	}
+
+	// Merges stay so the IDs they retired still resolve, without the records.
+	if err := tx.Model(&entity.PatientMerge{}).Where("survivor_id = ?", id).Update("retired_record", "{}").Error; err != nil {
+		return err
+	}
+	// Only the year of birth is kept, for age-banded statistics.
+	yearOfBirth := time.Date(patient.DateOfBirth.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	return tx.Model(&entity.Patient{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"first_name_th":    "",
+		"middle_name_th":   "",
+		"last_name_th":     "",
+		"first_name_en":    "",
+		"middle_name_en":   "",
+		"last_name_en":     "",
+		"date_of_birth":    yearOfBirth,
+		"patient_hn":       "",
+		"national_id":      nil,
+		"passport_id":      nil,
+		"phone_number":     "",
+		"email":            "",
+		"phone_normalized": "",
+		"email_normalized": "",
+		"erased_at":        time.Now(),
+	}).Error
+}
+
+func (r *retentionRepository) ListIdleLinks(hospital, source string, idleSince time.Time, after uuid.UUID, limit int) ([]*IdleLink, error) {
+	var links []*IdleLink
+	err := r.db.Raw(`
+		SELECT ph.patient_id, ph.hospital, ph.source,
+			(SELECT count(*) FROM tbl_patient_hospitals o WHERE o.patient_id = ph.patient_id AND o.hospital <> ph.hospital) AS other_links
+		FROM tbl_patient_hospitals ph
+		WHERE `+idleLinkCondition+` AND ph.patient_id > @after
+		ORDER BY ph.patient_id
+		LIMIT @limit`,
+		map[string]interface{}{
+			"hospital":   hospital,
+			"source":     source,
+			"idle_since": idleSince,
+			"after":      after,
+			"limit":      limit,
+		},
+	).Scan(&links).Error
+	return links, err
+}
+
+// PurgeIdleLink checks the link is still idle as it deletes it, so a patient
+// accessed since it was listed is kept.
+func (r *retentionRepository) PurgeIdleLink(link *IdleLink, idleSince time.Time) (bool, bool, error) {
+	var removed, erased bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`DELETE FROM tbl_patient_hospitals ph WHERE ph.patient_id = @patient_id AND `+idleLinkCondition,
+			map[string]interface{}{
+				"patient_id": link.PatientID,
+				"hospital":   link.Hospital,
+				"source":     link.Source,
+				"idle_since": idleSince,
+			},
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected > 0
+		if !removed {
+			return nil
+		}
+
+		var remaining int64
+		if err := tx.Model(&entity.PatientHospital{}).Where("patient_id = ?", link.PatientID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+		erased = true
+		return erasePatient(tx, link.PatientID.String(), false)
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return removed, erased, nil
+}
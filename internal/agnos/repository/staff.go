@@ -7,6 +7,7 @@ import (
 
 type StaffRepository interface {
 	Create(staff *entity.Staff) error
+	Update(staff *entity.Staff) error
 	GetByUsernameAndHospital(username, hospital string) (*entity.Staff, error)
 	GetByID(id string) (*entity.Staff, error)
 }
@@ -24,6 +25,10 @@ func (r *staffRepository) Create(staff *entity.Staff) error {
 	return r.db.Create(staff).Error
 }
 
+func (r *staffRepository) Update(staff *entity.Staff) error {
+	return r.db.Save(staff).Error
+}
+
 func (r *staffRepository) GetByUsernameAndHospital(username, hospital string) (*entity.Staff, error) {
 	var staff entity.Staff
 	err := r.db.Where("username = ? AND hospital = ?", username, hospital).First(&staff).Error
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookEventRepository interface {
+	Exists(hospital, eventID string) (bool, error)
+	Create(event *entity.WebhookEvent) error
+}
+
+type webhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) WebhookEventRepository {
+	return &webhookEventRepository{
+		db: db,
+	}
+}
+
+func (r *webhookEventRepository) Exists(hospital, eventID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&entity.WebhookEvent{}).Where("hospital = ? AND event_id = ?", hospital, eventID).Count(&count).Error
+	return count > 0, err
+}
+
+// Create records the event, ignoring a concurrent delivery that got there first.
+func (r *webhookEventRepository) Create(event *entity.WebhookEvent) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event).Error
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/handler"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
+	"github.com/gin-gonic/gin"
+)
+
+func NewFHIRRouter(
+	ginEngine *gin.Engine,
+	handler handler.FHIRHandler,
+	jwtSecret string,
+	auditService service.AuditService,
+) {
+	fhirRouter := ginEngine.Group("/fhir")
+
+	// Apply authentication, then audit every access to patient data
+	fhirRouter.Use(middleware.AuthMiddleware(jwtSecret), middleware.Audit(auditService))
+
+	fhirRouter.GET("/Patient", handler.SearchPatients)
+	fhirRouter.GET("/Patient/:id", handler.ReadPatient)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/handler"
+	"github.com/gin-gonic/gin"
+)
+
+// NewHealthRouter serves the probes of container orchestrators and load
+// balancers; they are unauthenticated.
+func NewHealthRouter(
+	ginEngine *gin.Engine,
+	handler handler.HealthHandler,
+) {
+	ginEngine.GET("/healthz", handler.Liveness)
+	ginEngine.GET("/readyz", handler.Readiness)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func NewIntegrationRouter(
+	ginEngine *gin.Engine,
+	handler handler.IntegrationHandler,
+) {
+	integrationRouter := ginEngine.Group("/integrations")
+
+	// Authenticated per hospital by request signature rather than staff JWT
+	integrationRouter.POST("/:hospital/webhook", handler.Webhook)
+}
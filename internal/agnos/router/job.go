@@ -0,0 +1,24 @@
+package router
+
+import (
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/handler"
+	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func NewJobRouter(
+	ginEngine *gin.Engine,
+	handler handler.JobHandler,
+	jwtSecret string,
+) {
+	jobRouter := ginEngine.Group("/jobs")
+
+	// Jobs are enqueued and managed by admins
+	jobRouter.Use(middleware.AuthMiddleware(jwtSecret), middleware.RequireRole(entity.StaffRoleAdmin))
+
+	jobRouter.POST("", handler.CreateJob)
+	jobRouter.GET("/:id", handler.GetJob)
+	jobRouter.GET("/:id/output", handler.GetJobOutput)
+	jobRouter.POST("/:id/cancel", handler.CancelJob)
+}
@@ -1,19 +1,40 @@
 package router
 
 import (
+	"github.com/Markikie/agnos/internal/agnos/entity"
 	"github.com/Markikie/agnos/internal/agnos/handler"
 	"github.com/Markikie/agnos/internal/agnos/middleware"
+	"github.com/Markikie/agnos/internal/agnos/service"
 	"github.com/gin-gonic/gin"
 )
 
 func NewPatientRouter(
 	ginEngine *gin.Engine,
 	handler handler.PatientHandler,
+	jwtSecret string,
+	auditService service.AuditService,
 ) {
 	patientRouter := ginEngine.Group("/patient")
-	
-	// Apply authentication middleware
-	patientRouter.Use(middleware.AuthMiddleware("your-secret-key"))
-	
+
+	// Apply authentication, then audit every access to patient data
+	patientRouter.Use(middleware.AuthMiddleware(jwtSecret), middleware.Audit(auditService))
+
 	patientRouter.POST("/search", handler.SearchPatients)
+
+	// Bulk imports and exports, patient exports, erasure, merges and duplicate
+	// dismissal are restricted to admins
+	admin := middleware.RequireRole(entity.StaffRoleAdmin)
+	patientRouter.POST("/import", admin, handler.ImportPatients)
+	patientRouter.GET("/import/:job_id", admin, handler.GetImportJob)
+	patientRouter.POST("/import/:job_id/resume", admin, handler.ResumeImport)
+	patientRouter.POST("/export", admin, handler.ExportPatients)
+	patientRouter.GET("/:id/export", admin, handler.ExportPatient)
+	patientRouter.DELETE("/:id/erase", admin, handler.ErasePatient)
+
+	patientRouter.POST("/merge", admin, handler.MergePatients)
+	patientRouter.GET("/duplicates", handler.ListDuplicates)
+	patientRouter.POST("/duplicates/:id/dismiss", admin, handler.DismissDuplicate)
+	patientRouter.GET("/:id", handler.GetPatient)
+	patientRouter.GET("/:id/merges", handler.ListMerges)
+	patientRouter.POST("/:id/refresh", handler.RefreshPatient)
 }
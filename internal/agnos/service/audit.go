@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/google/uuid"
+)
+
+var ErrAuditChainBroken = errors.New("audit log hash chain is broken")
+
+// AuditRecord is one access to patient data, recorded as an entry per
+// patient accessed, or a single entry when there was none.
+type AuditRecord struct {
+	Actor      string
+	Hospital   string
+	Action     string
+	Status     int
+	PatientIDs []uuid.UUID
+}
+
+type AuditService interface {
+	Record(record AuditRecord) error
+	// Verify walks the hash chain and returns how many entries were checked,
+	// with ErrAuditChainBroken at the first entry that does not chain.
+	Verify() (int, error)
+}
+
+type auditService struct {
+	auditRepository repository.AuditRepository
+}
+
+func NewAuditService(
+	auditRepository repository.AuditRepository,
+) AuditService {
+	return &auditService{
+		auditRepository: auditRepository,
+	}
+}
+
+func (s *auditService) Record(record AuditRecord) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	newEntry := func(patientID *uuid.UUID) *entity.AuditEntry {
+		return &entity.AuditEntry{
+			OccurredAt: now,
+			Actor:      record.Actor,
+			Hospital:   record.Hospital,
+			Action:     record.Action,
+			PatientID:  patientID,
+			Status:     record.Status,
+		}
+	}
+
+	if len(record.PatientIDs) == 0 {
+		return s.auditRepository.Append([]*entity.AuditEntry{newEntry(nil)})
+	}
+	entries := make([]*entity.AuditEntry, len(record.PatientIDs))
+	for i := range record.PatientIDs {
+		entries[i] = newEntry(&record.PatientIDs[i])
+	}
+	return s.auditRepository.Append(entries)
+}
+
+func (s *auditService) Verify() (int, error) {
+	checked := 0
+	prevHash := ""
+	err := s.auditRepository.EachEntry(1000, func(entries []*entity.AuditEntry) error {
+		for _, entry := range entries {
+			if entry.PrevHash != prevHash || entry.ComputeHash() != entry.Hash {
+				return fmt.Errorf("%w at seq %d", ErrAuditChainBroken, entry.Seq)
+			}
+			prevHash = entry.Hash
+			checked++
+		}
+		return nil
+	})
+	return checked, err
+}
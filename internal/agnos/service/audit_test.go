@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+)
+
+// MockAuditRepository is a mock implementation of AuditRepository
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Append(entries []*entity.AuditEntry) error {
+	args := m.Called(entries)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) EachEntry(batchSize int, fn func(entries []*entity.AuditEntry) error) error {
+	args := m.Called(batchSize)
+	if entries, ok := args.Get(0).([]*entity.AuditEntry); ok && len(entries) > 0 {
+		if err := fn(entries); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockAuditRepository) ListByPatients(patientIDs []uuid.UUID) ([]*entity.AuditEntry, error) {
+	args := m.Called(patientIDs)
+	return args.Get(0).([]*entity.AuditEntry), args.Error(1)
+}
+
+// chainEntries links entries as AuditRepository.Append would.
+func chainEntries(entries ...*entity.AuditEntry) []*entity.AuditEntry {
+	prevHash := ""
+	for _, entry := range entries {
+		entry.PrevHash = prevHash
+		entry.Hash = entry.ComputeHash()
+		prevHash = entry.Hash
+	}
+	return entries
+}
+
+func TestAuditService_Record(t *testing.T) {
+	auditRepo := new(MockAuditRepository)
+	service := NewAuditService(auditRepo)
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+
+	auditRepo.On("Append", mock.MatchedBy(func(entries []*entity.AuditEntry) bool {
+		return len(entries) == 2 &&
+			*entries[0].PatientID == ids[0] && *entries[1].PatientID == ids[1] &&
+			entries[0].Actor == "staff:1" && entries[1].Action == "GET /patient/:id" &&
+			entries[0].OccurredAt.Equal(entries[1].OccurredAt)
+	})).Return(nil).Once()
+	auditRepo.On("Append", mock.MatchedBy(func(entries []*entity.AuditEntry) bool {
+		return len(entries) == 1 && entries[0].PatientID == nil && entries[0].Status == 1
+	})).Return(nil).Once()
+
+	assert.NoError(t, service.Record(AuditRecord{Actor: "staff:1", Hospital: "hospital-a", Action: "GET /patient/:id", Status: 200, PatientIDs: ids}))
+	assert.NoError(t, service.Record(AuditRecord{Actor: "cli:ops", Hospital: "hospital-a", Action: "patient export", Status: 1}))
+	auditRepo.AssertExpectations(t)
+}
+
+func TestAuditService_Verify(t *testing.T) {
+	patientID := uuid.New()
+	newChain := func() []*entity.AuditEntry {
+		return chainEntries(
+			&entity.AuditEntry{Seq: 1, Actor: "staff:1", Action: "GET /patient/search", PatientID: &patientID, Status: 200},
+			&entity.AuditEntry{Seq: 2, Actor: "staff:1", Action: "GET /patient/:id", PatientID: &patientID, Status: 200},
+			&entity.AuditEntry{Seq: 3, Actor: "cli:ops", Action: "patient export", Status: 0},
+		)
+	}
+
+	intact := new(MockAuditRepository)
+	intact.On("EachEntry", mock.Anything).Return(newChain(), nil)
+	checked, err := NewAuditService(intact).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, checked)
+
+	edited := newChain()
+	edited[1].Status = 404
+	tampered := new(MockAuditRepository)
+	tampered.On("EachEntry", mock.Anything).Return(edited, nil)
+	checked, err = NewAuditService(tampered).Verify()
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.Contains(t, err.Error(), "seq 2")
+	assert.Equal(t, 1, checked)
+
+	deleted := newChain()
+	truncated := new(MockAuditRepository)
+	truncated.On("EachEntry", mock.Anything).Return([]*entity.AuditEntry{deleted[0], deleted[2]}, nil)
+	_, err = NewAuditService(truncated).Verify()
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+}
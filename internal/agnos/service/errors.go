@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+// Kinds of the errors clients are told about. Each decides the HTTP status of
+// the response; errors.Is(err, ErrNotFound) holds for every *Error of that
+// kind, such as ErrPatientNotFound.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUpstream     = errors.New("upstream failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrGone is a resource that existed but was removed for good, such as an
+	// erased patient.
+	ErrGone     = errors.New("gone")
+	ErrTooLarge = errors.New("too large")
+)
+
+// Error is an error clients are told about. Code identifies it to programs and
+// stays the same across releases; Message is its text for people.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	// Fields are the failures of an ErrValidation error, by field.
+	Fields validation.Errors
+}
+
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewValidationError reports a request rejected for the failures of fields.
+func NewValidationError(message string, fields validation.Errors) *Error {
+	return &Error{Kind: ErrValidation, Code: "validation_failed", Message: message, Fields: fields}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports e to be its kind, so callers may check the kind or the error.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// foreignErrors are the errors of other packages that reach clients through
+// the service, as the errors they are told about.
+var foreignErrors = []struct {
+	err error
+	as  *Error
+}{
+	{hospital.ErrUnsupported, NewError(ErrValidation, "unsupported_hospital", hospital.ErrUnsupported.Error())},
+	{hospital.ErrPatientNotFound, NewError(ErrNotFound, "hospital_patient_not_found", hospital.ErrPatientNotFound.Error())},
+	{hospital.ErrUnavailable, NewError(ErrUpstream, "hospital_unavailable", hospital.ErrUnavailable.Error())},
+	{hospital.ErrInvalidResponse, NewError(ErrUpstream, "hospital_invalid_response", hospital.ErrInvalidResponse.Error())},
+	{hospital.ErrSignatureMissing, NewError(ErrUnauthorized, "signature_missing", hospital.ErrSignatureMissing.Error())},
+	{hospital.ErrSignatureInvalid, NewError(ErrUnauthorized, "signature_invalid", hospital.ErrSignatureInvalid.Error())},
+	{hospital.ErrSignatureExpired, NewError(ErrUnauthorized, "signature_expired", hospital.ErrSignatureExpired.Error())},
+	{repository.ErrImportJobMoved, NewError(ErrConflict, "import_job_moved", repository.ErrImportJobMoved.Error())},
+}
+
+// AsError returns the *Error err is or wraps, or the one a foreign error
+// stands for; validation failures become an ErrValidation error. It returns
+// nil for any other error, which clients are not told the details of.
+//
+// The service wraps its errors only with details for clients, so an *Error
+// wrapped by err takes the text of err. A foreign error keeps the *Error's
+// text, its wrapping may carry upstream URLs and bodies.
+func AsError(err error) *Error {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		if err == error(serviceErr) {
+			return serviceErr
+		}
+		wrapped := *serviceErr
+		wrapped.Message = err.Error()
+		return &wrapped
+	}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return NewValidationError("invalid request", fieldErrs)
+	}
+	for _, foreign := range foreignErrors {
+		if errors.Is(err, foreign.err) {
+			return foreign.as
+		}
+	}
+	return nil
+}
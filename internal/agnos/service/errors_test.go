@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("%w: %s", ErrPatientNotFound, "0b1e6a53")
+
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.NotErrorIs(t, ErrPatientErased, ErrPatientNotFound)
+}
+
+func TestAsError(t *testing.T) {
+	fields := validation.Errors{{Field: "email", Message: "is invalid"}}
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		kind    error
+		message string
+	}{
+		{name: "service", err: ErrJobFinished, code: "job_finished", kind: ErrConflict, message: "job already finished"},
+		{name: "wrapped", err: fmt.Errorf("%w: %s", ErrJobKind, "reindex"), code: "unknown_job_kind", kind: ErrValidation, message: "unknown job kind: reindex"},
+		{name: "hospital", err: fmt.Errorf("%w: GET https://hospital-a/patient/search/123: 503", hospital.ErrUnavailable), code: "hospital_unavailable", kind: ErrUpstream, message: "hospital API unavailable"},
+		{name: "fields", err: fields, code: "validation_failed", kind: ErrValidation, message: "invalid request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceErr := AsError(tt.err)
+			if assert.NotNil(t, serviceErr) {
+				assert.Equal(t, tt.code, serviceErr.Code)
+				assert.ErrorIs(t, serviceErr, tt.kind)
+				assert.Equal(t, tt.message, serviceErr.Message)
+			}
+		})
+	}
+
+	assert.Equal(t, fields, AsError(fields).Fields)
+	assert.Nil(t, AsError(errors.New("pq: relation \"patients\" does not exist")))
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/migration"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+type HealthStatus string
+
+const (
+	HealthUp HealthStatus = "up"
+	// HealthDegraded is a service still able to serve while a non-critical
+	// component, such as a hospital API, is down.
+	HealthDegraded HealthStatus = "degraded"
+	HealthDown     HealthStatus = "down"
+)
+
+// Names of the components Readiness checks; hospital APIs are named
+// "hospital:<name>".
+const (
+	HealthComponentDatabase = "database"
+	HealthComponentSchema   = "schema"
+	healthComponentHospital = "hospital:"
+)
+
+// ComponentHealth is the health of one dependency. A critical component down
+// takes the service down; any other only degrades it.
+type ComponentHealth struct {
+	Status   HealthStatus
+	Critical bool
+	Error    string
+	// Hospital APIs report what recent calls to them tell.
+	Upstream *hospital.Upstream
+}
+
+type HealthReport struct {
+	Status     HealthStatus
+	Components map[string]ComponentHealth
+}
+
+// SchemaChecker returns migration.ErrSchemaBehind when migrations are
+// pending, as migration.Migrator does.
+type SchemaChecker interface {
+	Check(ctx context.Context) error
+}
+
+type HealthService interface {
+	// Liveness reports the process is serving requests. It checks no
+	// dependency, so an outage of one does not get the service restarted.
+	Liveness() *HealthReport
+	// Readiness checks the database is reachable and its schema up to date,
+	// both critical, and reports the hospital APIs from the outcome of recent
+	// calls, without calling them. Checks are given the service's timeout.
+	Readiness(ctx context.Context) *HealthReport
+}
+
+type healthService struct {
+	healthRepository repository.HealthRepository
+	schema           SchemaChecker
+	hospitals        *hospital.Registry
+	timeout          time.Duration
+}
+
+func NewHealthService(
+	healthRepository repository.HealthRepository,
+	schema SchemaChecker,
+	hospitals *hospital.Registry,
+	timeout time.Duration,
+) HealthService {
+	return &healthService{
+		healthRepository: healthRepository,
+		schema:           schema,
+		hospitals:        hospitals,
+		timeout:          timeout,
+	}
+}
+
+func (s *healthService) Liveness() *HealthReport {
+	return &HealthReport{Status: HealthUp}
+}
+
+func (s *healthService) Readiness(ctx context.Context) *HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	components := map[string]ComponentHealth{
+		HealthComponentDatabase: checkComponent(ctx, HealthComponentDatabase, s.healthRepository.Ping(ctx)),
+		HealthComponentSchema:   checkComponent(ctx, HealthComponentSchema, s.schema.Check(ctx)),
+	}
+	for _, name := range s.hospitals.Names() {
+		upstream := s.hospitals.Upstream(name)
+		component := ComponentHealth{Status: HealthUp, Upstream: &upstream}
+		if upstream.Down() {
+			component.Status = HealthDown
+		}
+		components[healthComponentHospital+name] = component
+	}
+
+	report := &HealthReport{Status: HealthUp, Components: components}
+	for _, component := range components {
+		switch {
+		case component.Status == HealthUp:
+		case component.Critical:
+			report.Status = HealthDown
+		case report.Status == HealthUp:
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+// checkComponent reports a critical component from the error of its check.
+// Probes are unauthenticated, so the error is logged and only a pending
+// migration is reported as such.
+func checkComponent(ctx context.Context, name string, err error) ComponentHealth {
+	if err == nil {
+		return ComponentHealth{Status: HealthUp, Critical: true}
+	}
+	slog.WarnContext(ctx, "health check failed", "component", name, "error", err)
+	component := ComponentHealth{Status: HealthDown, Critical: true, Error: "check failed"}
+	if errors.Is(err, migration.ErrSchemaBehind) {
+		component.Error = err.Error()
+	}
+	return component
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/migration"
+)
+
+// MockHealthRepository is a mock implementation of HealthRepository
+type MockHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type stubSchemaChecker struct {
+	err error
+}
+
+func (s stubSchemaChecker) Check(ctx context.Context) error {
+	return s.err
+}
+
+func TestHealthService_Liveness(t *testing.T) {
+	service := NewHealthService(new(MockHealthRepository), stubSchemaChecker{}, newTestRegistry(t), time.Second)
+
+	assert.Equal(t, &HealthReport{Status: HealthUp}, service.Liveness())
+}
+
+func TestHealthService_Readiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		pingErr    error
+		schemaErr  error
+		wantStatus HealthStatus
+		wantErrors map[string]string
+	}{
+		{name: "up", wantStatus: HealthUp},
+		{
+			name:       "database down",
+			pingErr:    errors.New("dial tcp 10.0.0.3:5432: connection refused"),
+			schemaErr:  errors.New("dial tcp 10.0.0.3:5432: connection refused"),
+			wantStatus: HealthDown,
+			wantErrors: map[string]string{HealthComponentDatabase: "check failed", HealthComponentSchema: "check failed"},
+		},
+		{
+			name:       "migrations pending",
+			schemaErr:  fmt.Errorf("%w: pending 0009_next", migration.ErrSchemaBehind),
+			wantStatus: HealthDown,
+			wantErrors: map[string]string{HealthComponentSchema: "database schema is behind: pending 0009_next"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthRepo := new(MockHealthRepository)
+			healthRepo.On("Ping").Return(tt.pingErr)
+			service := NewHealthService(healthRepo, stubSchemaChecker{err: tt.schemaErr}, newTestRegistry(t), time.Second)
+
+			report := service.Readiness(context.Background())
+
+			assert.Equal(t, tt.wantStatus, report.Status)
+			require.Len(t, report.Components, 2)
+			for name, component := range report.Components {
+				assert.True(t, component.Critical, name)
+				assert.Equal(t, tt.wantErrors[name], component.Error, name)
+			}
+		})
+	}
+}
+
+func TestHealthService_ReadinessHospitalDown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	registry, err := hospital.NewRegistry([]hospital.Config{{Name: "hospital-health", BaseURL: server.URL}})
+	require.NoError(t, err)
+	adapter, err := registry.Adapter("hospital-health")
+	require.NoError(t, err)
+	for range hospital.UpstreamFailureThreshold {
+		_, err := adapter.FetchPatient(context.Background(), "1234567890121")
+		require.Error(t, err)
+	}
+
+	healthRepo := new(MockHealthRepository)
+	healthRepo.On("Ping").Return(nil)
+	service := NewHealthService(healthRepo, stubSchemaChecker{}, registry, time.Second)
+
+	report := service.Readiness(context.Background())
+
+	assert.Equal(t, HealthDegraded, report.Status)
+	component := report.Components["hospital:hospital-health"]
+	assert.Equal(t, HealthDown, component.Status)
+	assert.False(t, component.Critical)
+	require.NotNil(t, component.Upstream)
+	assert.Equal(t, hospital.UpstreamFailureThreshold, component.Upstream.ConsecutiveFailures)
+	assert.NotNil(t, component.Upstream.LastFailureAt)
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookSchemaVersion = "1"
+
+	WebhookEventPatientCreated = "patient.created"
+	WebhookEventPatientUpdated = "patient.updated"
+	WebhookEventPatientMerged  = "patient.merged"
+	WebhookEventPatientDeleted = "patient.deleted"
+
+	defaultWebhookTolerance = 5 * time.Minute
+)
+
+var (
+	ErrWebhookNotConfigured = NewError(ErrNotFound, "webhook_not_configured", "webhook not configured for hospital")
+	ErrHL7NotConfigured     = NewError(ErrNotFound, "hl7_not_configured", "no hospital configured for HL7 sending facility")
+	ErrHL7PeerNotAllowed    = NewError(ErrForbidden, "hl7_peer_not_allowed", "HL7 sending facility not allowed from this peer")
+)
+
+type WebhookEvent struct {
+	ID      string
+	Type    string
+	Patient hospital.JSONPatient
+	// Merged identifies the record retired by a patient.merged event; Patient is the survivor.
+	Merged *hospital.JSONPatient
+}
+
+type WebhookEventStatus string
+
+const (
+	WebhookEventApplied   WebhookEventStatus = "applied"
+	WebhookEventDuplicate WebhookEventStatus = "duplicate"
+	WebhookEventRejected  WebhookEventStatus = "rejected"
+	// WebhookEventQuarantined is a rejected event whose invalid record was
+	// kept for review.
+	WebhookEventQuarantined WebhookEventStatus = "quarantined"
+	WebhookEventFailed      WebhookEventStatus = "failed"
+)
+
+type WebhookEventResult struct {
+	ID        string
+	Status    WebhookEventStatus
+	PatientID string
+	Error     string
+}
+
+type IntegrationService interface {
+	VerifyWebhook(hospitalName, method, requestURI, timestamp, signature string, body []byte) error
+	ApplyWebhookEvents(hospitalName string, events []WebhookEvent) []WebhookEventResult
+	HL7Hospital(sendingFacility string, peer netip.Addr) (string, error)
+}
+
+type integrationService struct {
+	patientRepository      repository.PatientRepository
+	patientMergeRepository repository.PatientMergeRepository
+	webhookEventRepository repository.WebhookEventRepository
+	quarantineRepository   repository.QuarantineRepository
+	hospitals              *hospital.Registry
+}
+
+func NewIntegrationService(
+	patientRepository repository.PatientRepository,
+	patientMergeRepository repository.PatientMergeRepository,
+	webhookEventRepository repository.WebhookEventRepository,
+	quarantineRepository repository.QuarantineRepository,
+	hospitals *hospital.Registry,
+) IntegrationService {
+	return &integrationService{
+		patientRepository:      patientRepository,
+		patientMergeRepository: patientMergeRepository,
+		webhookEventRepository: webhookEventRepository,
+		quarantineRepository:   quarantineRepository,
+		hospitals:              hospitals,
+	}
+}
+
+func (s *integrationService) VerifyWebhook(hospitalName, method, requestURI, timestamp, signature string, body []byte) error {
+	config, ok := s.hospitals.Config(hospitalName)
+	if !ok || config.Webhook.Secret == "" {
+		return ErrWebhookNotConfigured
+	}
+
+	tolerance := time.Duration(config.Webhook.Tolerance)
+	if tolerance == 0 {
+		tolerance = defaultWebhookTolerance
+	}
+	return hospital.Verify([]byte(config.Webhook.Secret), method, requestURI, timestamp, body, signature, time.Now(), tolerance)
+}
+
+// HL7Hospital returns the hospital whose HL7 feed sends as sendingFacility,
+// provided the message came from one of the feed's allowed peers: MSH-4 alone
+// is plaintext anyone can claim.
+func (s *integrationService) HL7Hospital(sendingFacility string, peer netip.Addr) (string, error) {
+	if sendingFacility != "" {
+		for _, name := range s.hospitals.Names() {
+			config, _ := s.hospitals.Config(name)
+			if config.HL7.SendingFacility != sendingFacility {
+				continue
+			}
+			if !config.HL7.Allows(peer) {
+				return "", fmt.Errorf("%w: %q from %s", ErrHL7PeerNotAllowed, sendingFacility, peer)
+			}
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrHL7NotConfigured, sendingFacility)
+}
+
+// ApplyWebhookEvents applies each event independently, so one bad event does
+// not block the rest of the batch. Every operation is an upsert or a delete,
+// which makes redelivery of an event that failed to be recorded harmless.
+func (s *integrationService) ApplyWebhookEvents(hospitalName string, events []WebhookEvent) []WebhookEventResult {
+	results := make([]WebhookEventResult, 0, len(events))
+	for _, event := range events {
+		results = append(results, s.applyWebhookEvent(hospitalName, event))
+	}
+	return results
+}
+
+func (s *integrationService) applyWebhookEvent(hospitalName string, event WebhookEvent) WebhookEventResult {
+	result := WebhookEventResult{ID: event.ID}
+	if event.ID == "" {
+		result.Status = WebhookEventRejected
+		result.Error = "event id is required"
+		return result
+	}
+
+	duplicate, err := s.webhookEventRepository.Exists(hospitalName, event.ID)
+	if err != nil {
+		failWebhookEvent(&result, hospitalName, err)
+		return result
+	}
+	if duplicate {
+		result.Status = WebhookEventDuplicate
+		return result
+	}
+
+	var patient *entity.Patient
+	switch event.Type {
+	case WebhookEventPatientCreated, WebhookEventPatientUpdated:
+		patient, err = s.upsertPatient(hospitalName, event.Patient)
+	case WebhookEventPatientMerged:
+		patient, err = s.mergePatients(hospitalName, event.Patient, event.Merged)
+	case WebhookEventPatientDeleted:
+		patient, err = s.unlinkPatient(hospitalName, event.Patient)
+	default:
+		err = &rejectedEventError{fmt.Errorf("unknown event type: %s", event.Type)}
+	}
+
+	var rejected *rejectedEventError
+	switch {
+	case errors.As(err, &rejected):
+		result.Status = WebhookEventRejected
+		result.Error = rejected.Error()
+
+		quarantined, err := quarantineInvalidRecord(s.quarantineRepository, s.hospitals, hospitalName, entity.PatientSourceWebhook, event.ID, rejected)
+		if err != nil {
+			failWebhookEvent(&result, hospitalName, err)
+			return result
+		}
+		if !quarantined {
+			return result
+		}
+		// Record the event so a redelivery is not quarantined again.
+		result.Status = WebhookEventQuarantined
+	case err != nil:
+		failWebhookEvent(&result, hospitalName, err)
+		return result
+	}
+
+	record := &entity.WebhookEvent{
+		Hospital:   hospitalName,
+		EventID:    event.ID,
+		Type:       event.Type,
+		ReceivedAt: time.Now(),
+	}
+	if patient != nil {
+		record.PatientID = &patient.ID
+		result.PatientID = patient.ID.String()
+	}
+	if err := s.webhookEventRepository.Create(record); err != nil {
+		failWebhookEvent(&result, hospitalName, err)
+		return result
+	}
+
+	if result.Status == "" {
+		result.Status = WebhookEventApplied
+	}
+	return result
+}
+
+// failWebhookEvent marks result failed by err, mapped as for problems: the
+// detail of an error AsError does not know, e.g. from the database, is only
+// logged.
+func failWebhookEvent(result *WebhookEventResult, hospitalName string, err error) {
+	result.Status = WebhookEventFailed
+	if serviceErr := AsError(err); serviceErr != nil {
+		result.Error = serviceErr.Message
+		return
+	}
+	slog.Error("webhook event failed", "hospital", hospitalName, "event_id", result.ID, "error", err)
+	result.Error = "the event could not be applied; retry it later"
+}
+
+// rejectedEventError marks an event as invalid rather than failed to apply;
+// retrying a rejected event will not help.
+type rejectedEventError struct {
+	err error
+}
+
+func (e *rejectedEventError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rejectedEventError) Unwrap() error {
+	return e.err
+}
+
+func (s *integrationService) findPatient(record hospital.JSONPatient) (*entity.Patient, error) {
+	patient, err := s.patientRepository.GetByIdentifiers(validation.NormalizeNationalID(record.NationalID), record.PassportID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return patient, err
+}
+
+func (s *integrationService) upsertPatient(hospitalName string, record hospital.JSONPatient) (*entity.Patient, error) {
+	incoming, err := record.ToEntity(s.hospitals.DateParser(hospitalName))
+	if err != nil {
+		return nil, &rejectedEventError{err}
+	}
+
+	patient, err := s.findPatient(record)
+	if err != nil {
+		return nil, err
+	}
+	return s.savePatient(hospitalName, incoming, patient)
+}
+
+// savePatient saves a record pushed by the hospital, see saveHospitalRecord.
+func (s *integrationService) savePatient(hospitalName string, incoming, patient *entity.Patient) (*entity.Patient, error) {
+	return saveHospitalRecord(s.patientRepository, hospitalName, entity.PatientSourceWebhook, incoming, patient)
+}
+
+// saveHospitalRecord creates incoming when there is no existing patient,
+// otherwise applies it to patient, and records the hospital link either way.
+func saveHospitalRecord(
+	patientRepository repository.PatientRepository,
+	hospitalName, source string,
+	incoming, patient *entity.Patient,
+) (*entity.Patient, error) {
+	now := time.Now()
+	if patient == nil {
+		incoming.Hospitals = []entity.PatientHospital{{
+			Hospital:      hospitalName,
+			PatientHN:     incoming.PatientHN,
+			Source:        source,
+			LastSyncedAt:  &now,
+			LastAttemptAt: &now,
+		}}
+		return incoming, patientRepository.Create(incoming)
+	}
+
+	if changed := applyHospitalRecord(patient, incoming); len(changed) > 0 {
+		if err := patientRepository.Update(patient); err != nil {
+			return nil, err
+		}
+	}
+
+	link := hospitalLink(patient, hospitalName, source)
+	link.PatientHN = incoming.PatientHN
+	link.LastSyncedAt = &now
+	link.LastAttemptAt = &now
+	link.SyncError = ""
+	return patient, patientRepository.SaveHospitalLink(link)
+}
+
+// mergePatients folds the retired record into the survivor like a staff
+// merge, recording the merge history, then applies the hospital's
+// demographics to the survivor.
+func (s *integrationService) mergePatients(hospitalName string, survivor hospital.JSONPatient, retired *hospital.JSONPatient) (*entity.Patient, error) {
+	if retired == nil {
+		return nil, &rejectedEventError{errors.New("merged record is required for patient.merged")}
+	}
+
+	retiredPatient, err := s.findPatient(*retired)
+	if err != nil {
+		return nil, err
+	}
+	survivorPatient, err := s.findPatient(survivor)
+	if err != nil {
+		return nil, err
+	}
+
+	incoming, err := survivor.ToEntity(s.hospitals.DateParser(hospitalName))
+	if err != nil {
+		return nil, &rejectedEventError{err}
+	}
+
+	switch {
+	case retiredPatient == nil || (survivorPatient != nil && retiredPatient.ID == survivorPatient.ID):
+		return s.savePatient(hospitalName, incoming, survivorPatient)
+	case survivorPatient == nil:
+		// Only the retired record is held locally; it becomes the survivor.
+		return s.savePatient(hospitalName, incoming, retiredPatient)
+	}
+
+	_, err = mergePatientRecords(s.patientMergeRepository, survivorPatient, retiredPatient, nil, "hospital:"+hospitalName, WebhookEventPatientMerged)
+	if errors.Is(err, ErrMergeConflict) {
+		return nil, &rejectedEventError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.savePatient(hospitalName, incoming, survivorPatient)
+}
+
+// unlinkPatient removes the hospital's association with the patient, and the
+// patient itself once no hospital holds a record for them.
+func (s *integrationService) unlinkPatient(hospitalName string, record hospital.JSONPatient) (*entity.Patient, error) {
+	if record.NationalID == "" && record.PassportID == "" {
+		return nil, &rejectedEventError{errors.New("record has neither national_id nor passport_id")}
+	}
+
+	patient, err := s.findPatient(record)
+	if err != nil || patient == nil {
+		return nil, err
+	}
+
+	if err := s.patientRepository.DeleteHospitalLink(patient.ID.String(), hospitalName); err != nil {
+		return nil, err
+	}
+	for _, link := range patient.Hospitals {
+		if link.Hospital != hospitalName {
+			return patient, nil
+		}
+	}
+	return patient, s.patientRepository.Delete(patient.ID.String())
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/google/uuid"
+)
+
+// MockWebhookEventRepository is a mock implementation of WebhookEventRepository
+type MockWebhookEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookEventRepository) Exists(hospital, eventID string) (bool, error) {
+	args := m.Called(hospital, eventID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebhookEventRepository) Create(event *entity.WebhookEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func newWebhookRegistry(t *testing.T) *hospital.Registry {
+	registry, err := hospital.NewRegistry([]hospital.Config{
+		{Name: "hospital-a", BaseURL: "https://hospital-a.example", Webhook: hospital.WebhookConfig{Secret: "webhook-secret"}, HL7: hospital.HL7Config{SendingFacility: "HOSPA", AllowedPeers: []string{"10.0.0.0/24", "192.0.2.7"}}},
+		{Name: "hospital-b", BaseURL: "https://hospital-b.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestIntegrationService_VerifyWebhook(t *testing.T) {
+	service := NewIntegrationService(new(MockPatientRepository), new(MockPatientMergeRepository), new(MockWebhookEventRepository), new(MockQuarantineRepository), newWebhookRegistry(t))
+
+	body := []byte(`{"version":"1","events":[]}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := hospital.Sign([]byte("webhook-secret"), "POST", "/integrations/hospital-a/webhook", timestamp, body)
+
+	assert.NoError(t, service.VerifyWebhook("hospital-a", "POST", "/integrations/hospital-a/webhook", timestamp, signature, body))
+	assert.ErrorIs(t, service.VerifyWebhook("hospital-a", "POST", "/integrations/hospital-a/webhook", timestamp, signature, []byte(`{}`)), hospital.ErrSignatureInvalid)
+	assert.ErrorIs(t, service.VerifyWebhook("hospital-b", "POST", "/integrations/hospital-b/webhook", timestamp, signature, body), ErrWebhookNotConfigured)
+	assert.ErrorIs(t, service.VerifyWebhook("hospital-z", "POST", "/integrations/hospital-z/webhook", timestamp, signature, body), ErrWebhookNotConfigured)
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	staleSignature := hospital.Sign([]byte("webhook-secret"), "POST", "/integrations/hospital-a/webhook", stale, body)
+	assert.ErrorIs(t, service.VerifyWebhook("hospital-a", "POST", "/integrations/hospital-a/webhook", stale, staleSignature, body), hospital.ErrSignatureExpired)
+}
+
+func TestIntegrationService_HL7Hospital(t *testing.T) {
+	service := NewIntegrationService(new(MockPatientRepository), new(MockPatientMergeRepository), new(MockWebhookEventRepository), new(MockQuarantineRepository), newWebhookRegistry(t))
+
+	peer := netip.MustParseAddr("10.0.0.12")
+
+	name, err := service.HL7Hospital("HOSPA", peer)
+	assert.NoError(t, err)
+	assert.Equal(t, "hospital-a", name)
+	name, err = service.HL7Hospital("HOSPA", netip.MustParseAddr("::ffff:192.0.2.7"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hospital-a", name)
+
+	_, err = service.HL7Hospital("HOSPA", netip.MustParseAddr("10.0.1.12"))
+	assert.ErrorIs(t, err, ErrHL7PeerNotAllowed)
+	_, err = service.HL7Hospital("HOSPA", netip.Addr{})
+	assert.ErrorIs(t, err, ErrHL7PeerNotAllowed)
+	_, err = service.HL7Hospital("OTHER", peer)
+	assert.ErrorIs(t, err, ErrHL7NotConfigured)
+	_, err = service.HL7Hospital("", peer)
+	assert.ErrorIs(t, err, ErrHL7NotConfigured)
+}
+
+func TestIntegrationService_ApplyWebhookEvents(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	eventRepo := new(MockWebhookEventRepository)
+	service := NewIntegrationService(patientRepo, new(MockPatientMergeRepository), eventRepo, new(MockQuarantineRepository), newWebhookRegistry(t))
+
+	existing := &entity.Patient{
+		ID:          uuid.New(),
+		NationalID:  "1234567890121",
+		PhoneNumber: "0811111111",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	eventRepo.On("Exists", "hospital-a", "evt-dup").Return(true, nil)
+	eventRepo.On("Exists", "hospital-a", "evt-db").Return(false, errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+	eventRepo.On("Exists", "hospital-a", mock.Anything).Return(false, nil)
+	eventRepo.On("Create", mock.AnythingOfType("*entity.WebhookEvent")).Return(nil)
+
+	// evt-1 creates a new patient
+	patientRepo.On("GetByIdentifiers", "", "AA1234567").Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("Create", mock.MatchedBy(func(p *entity.Patient) bool {
+		return p.PassportID == "AA1234567" && p.Hospitals[0].Source == entity.PatientSourceWebhook
+	})).Return(nil)
+
+	// evt-2 updates the existing patient's phone number
+	patientRepo.On("GetByIdentifiers", "1234567890121", "").Return(existing, nil)
+	patientRepo.On("Update", existing).Return(nil)
+	patientRepo.On("SaveHospitalLink", mock.MatchedBy(func(link *entity.PatientHospital) bool {
+		return link.PatientID == existing.ID && link.Hospital == "hospital-a"
+	})).Return(nil)
+
+	results := service.ApplyWebhookEvents("hospital-a", []WebhookEvent{
+		{ID: "evt-1", Type: WebhookEventPatientCreated, Patient: hospital.JSONPatient{PassportID: "AA1234567", DateOfBirth: "1985-05-05"}},
+		{ID: "evt-2", Type: WebhookEventPatientUpdated, Patient: hospital.JSONPatient{NationalID: "1234567890121", PhoneNumber: "0899999999", DateOfBirth: "1990-01-01"}},
+		{ID: "evt-dup", Type: WebhookEventPatientUpdated},
+		{ID: "evt-3", Type: "patient.exploded"},
+		{ID: "evt-4", Type: WebhookEventPatientCreated, Patient: hospital.JSONPatient{NationalID: "1234567890121", DateOfBirth: "1990-13-01"}},
+		{Type: WebhookEventPatientCreated},
+		{ID: "evt-db", Type: WebhookEventPatientCreated},
+	})
+
+	assert.Len(t, results, 7)
+	assert.Equal(t, WebhookEventApplied, results[0].Status)
+	assert.Equal(t, WebhookEventApplied, results[1].Status)
+	assert.Equal(t, existing.ID.String(), results[1].PatientID)
+	assert.Equal(t, "0899999999", existing.PhoneNumber)
+	assert.Equal(t, WebhookEventDuplicate, results[2].Status)
+	assert.Equal(t, WebhookEventRejected, results[3].Status)
+	assert.Equal(t, WebhookEventRejected, results[4].Status)
+	assert.Equal(t, WebhookEventRejected, results[5].Status)
+	// The database error is logged, not sent back to the hospital.
+	assert.Equal(t, WebhookEventFailed, results[6].Status)
+	assert.NotContains(t, results[6].Error, "10.0.0.5")
+
+	eventRepo.AssertNumberOfCalls(t, "Create", 2)
+	patientRepo.AssertExpectations(t)
+}
+
+func TestIntegrationService_ApplyWebhookEvents_MergeAndDelete(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	mergeRepo := new(MockPatientMergeRepository)
+	eventRepo := new(MockWebhookEventRepository)
+	service := NewIntegrationService(patientRepo, mergeRepo, eventRepo, new(MockQuarantineRepository), newWebhookRegistry(t))
+
+	survivor := &entity.Patient{ID: uuid.New(), NationalID: "1234567890121", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)}
+	retired := &entity.Patient{ID: uuid.New(), PassportID: "AA1234567", Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}}}
+	sharedWithOtherHospital := &entity.Patient{ID: uuid.New(), PassportID: "BB7654321", Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}, {Hospital: "hospital-b"}}}
+
+	eventRepo.On("Exists", "hospital-a", mock.Anything).Return(false, nil)
+	eventRepo.On("Create", mock.AnythingOfType("*entity.WebhookEvent")).Return(nil)
+
+	patientRepo.On("GetByIdentifiers", "", "AA1234567").Return(retired, nil)
+	patientRepo.On("GetByIdentifiers", "1234567890121", "").Return(survivor, nil)
+	mergeRepo.On("Merge", survivor, retired.ID.String(), mock.MatchedBy(func(merge *entity.PatientMerge) bool {
+		return merge.RetiredID == retired.ID && merge.MergedBy == "hospital:hospital-a"
+	})).Return(nil)
+	patientRepo.On("SaveHospitalLink", mock.AnythingOfType("*entity.PatientHospital")).Return(nil)
+
+	patientRepo.On("GetByIdentifiers", "", "BB7654321").Return(sharedWithOtherHospital, nil)
+	patientRepo.On("DeleteHospitalLink", sharedWithOtherHospital.ID.String(), "hospital-a").Return(nil)
+
+	results := service.ApplyWebhookEvents("hospital-a", []WebhookEvent{
+		{
+			ID:      "evt-merge",
+			Type:    WebhookEventPatientMerged,
+			Patient: hospital.JSONPatient{NationalID: "1234567890121", DateOfBirth: "1990-01-01"},
+			Merged:  &hospital.JSONPatient{PassportID: "AA1234567"},
+		},
+		{ID: "evt-delete", Type: WebhookEventPatientDeleted, Patient: hospital.JSONPatient{PassportID: "BB7654321"}},
+	})
+
+	assert.Equal(t, WebhookEventApplied, results[0].Status)
+	assert.Equal(t, survivor.ID.String(), results[0].PatientID)
+	assert.Equal(t, WebhookEventApplied, results[1].Status)
+
+	// The patient is still held by hospital-b, so only the link is removed.
+	patientRepo.AssertNotCalled(t, "Delete", sharedWithOtherHospital.ID.String())
+	patientRepo.AssertExpectations(t)
+	mergeRepo.AssertExpectations(t)
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxJobBackoff caps the delay before a failed job is retried.
+const maxJobBackoff = time.Hour
+
+var (
+	ErrJobNotFound = NewError(ErrNotFound, "job_not_found", "job not found")
+	ErrJobFinished = NewError(ErrConflict, "job_finished", "job already finished")
+	ErrJobKind     = NewError(ErrValidation, "unknown_job_kind", "unknown job kind")
+	// ErrJobOutputNotFound is also returned to staff other than the job's
+	// creator, who alone may download its output.
+	ErrJobOutputNotFound = NewError(ErrNotFound, "job_output_not_found", "job output not found")
+	ErrJobOutputExpired  = NewError(ErrGone, "job_output_expired", "job output expired")
+	// ErrJobPermanent marks a job error that retrying cannot fix, such as an
+	// invalid payload; the job fails without further attempts.
+	ErrJobPermanent = errors.New("job cannot succeed")
+
+	errJobCancelled = errors.New("job cancelled")
+)
+
+// JobHandler runs one attempt of a job. It should return when ctx is
+// cancelled, which happens on cancellation and shutdown. Its result is stored
+// on the job as JSON.
+type JobHandler func(ctx context.Context, job *entity.Job) (any, error)
+
+// JobRequest enqueues a job of Kind with Payload, marshalled as JSON, on
+// behalf of CreatedBy, a member of Hospital's staff, "cli:<user>" or
+// "worker:<name>". Input, if any, is stored with the job for its handler to
+// read with JobService.Input.
+type JobRequest struct {
+	Kind      string
+	Hospital  string
+	CreatedBy string
+	Payload   any
+	Input     []byte
+}
+
+type JobService interface {
+	// Register makes this process run jobs of kind with handler. Kinds are
+	// registered before any job is run.
+	Register(kind string, handler JobHandler)
+	Enqueue(req JobRequest) (*entity.Job, error)
+	// GetJob returns a job of hospitalName; other hospitals' jobs are not found.
+	GetJob(id, hospitalName string) (*entity.Job, error)
+	// CancelJob cancels a queued job at once, and a running job once its
+	// worker notices, at the latest after a third of the lease.
+	CancelJob(id, hospitalName string) (*entity.Job, error)
+	// RunNext claims a due job of a registered kind and runs one attempt of it,
+	// reporting whether there was a job to run.
+	RunNext(ctx context.Context) (bool, error)
+	// Input returns the input the job was enqueued with; a job without one
+	// fails with ErrJobPermanent.
+	Input(job *entity.Job) ([]byte, error)
+	// SaveOutput stores the file the job produced, replacing one from an
+	// earlier attempt. It expires after the output TTL.
+	SaveOutput(job *entity.Job, filename, contentType string, data []byte) error
+	// GetOutput returns the output of a job of hospitalName to the staff
+	// member, createdBy, who enqueued it.
+	GetOutput(id, hospitalName, createdBy string) (*entity.JobFile, error)
+	// PurgeOutputs deletes the expired outputs and returns how many there were.
+	PurgeOutputs() (int64, error)
+}
+
+type jobService struct {
+	jobRepository repository.JobRepository
+	handlers      map[string]JobHandler
+	lease         time.Duration
+	maxAttempts   int
+	retryBackoff  time.Duration
+	outputTTL     time.Duration
+}
+
+func NewJobService(
+	jobRepository repository.JobRepository,
+	lease time.Duration,
+	maxAttempts int,
+	retryBackoff time.Duration,
+	outputTTL time.Duration,
+) JobService {
+	return &jobService{
+		jobRepository: jobRepository,
+		handlers:      make(map[string]JobHandler),
+		lease:         lease,
+		maxAttempts:   maxAttempts,
+		retryBackoff:  retryBackoff,
+		outputTTL:     outputTTL,
+	}
+}
+
+func (s *jobService) Register(kind string, handler JobHandler) {
+	s.handlers[kind] = handler
+}
+
+func (s *jobService) Enqueue(req JobRequest) (*entity.Job, error) {
+	if _, ok := s.handlers[req.Kind]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobKind, req.Kind)
+	}
+	payload := []byte("{}")
+	if req.Payload != nil {
+		var err error
+		if payload, err = json.Marshal(req.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &entity.Job{
+		Kind:        req.Kind,
+		Hospital:    req.Hospital,
+		Payload:     string(payload),
+		Status:      entity.JobQueued,
+		MaxAttempts: s.maxAttempts,
+		RunAt:       time.Now(),
+		CreatedBy:   req.CreatedBy,
+	}
+	var files []*entity.JobFile
+	if req.Input != nil {
+		files = append(files, &entity.JobFile{Name: entity.JobFileInput, Data: req.Input})
+	}
+	if err := s.jobRepository.Create(job, files...); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *jobService) GetJob(id, hospitalName string) (*entity.Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+	job, err := s.jobRepository.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && job.Hospital != hospitalName) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+func (s *jobService) CancelJob(id, hospitalName string) (*entity.Job, error) {
+	job, err := s.GetJob(id, hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrJobFinished
+	}
+	if err := s.jobRepository.Cancel(id); err != nil {
+		return nil, err
+	}
+	return s.jobRepository.GetByID(id)
+}
+
+func (s *jobService) RunNext(ctx context.Context) (bool, error) {
+	kinds := make([]string, 0, len(s.handlers))
+	for kind := range s.handlers {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+
+	job, err := s.jobRepository.Claim(kinds, time.Now().Add(s.lease))
+	if err != nil || job == nil {
+		return false, err
+	}
+	attempt := job.Attempts
+
+	switch {
+	case job.CancelRequested:
+		// Cancelled while it waited for a retry.
+		s.finish(job, nil, errJobCancelled)
+		return true, s.jobRepository.Finish(job, attempt)
+	case attempt > job.MaxAttempts:
+		// A job is claimed past its attempts only when its worker stopped
+		// without finishing the last one.
+		job.Attempts = job.MaxAttempts
+		s.finish(job, nil, errors.New("worker stopped during the last attempt"))
+		return true, s.jobRepository.Finish(job, attempt)
+	}
+
+	result, err := s.run(ctx, job)
+	switch {
+	case errors.Is(err, repository.ErrJobLost):
+		return true, err
+	case err != nil && ctx.Err() != nil:
+		// Interrupted by shutdown: the attempt does not count.
+		job.Status = entity.JobQueued
+		job.Attempts--
+		job.LockedUntil = nil
+	default:
+		s.finish(job, result, err)
+	}
+	return true, s.jobRepository.Finish(job, attempt)
+}
+
+func (s *jobService) Input(job *entity.Job) ([]byte, error) {
+	file, err := s.jobRepository.GetFile(job.ID.String(), entity.JobFileInput)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: the job has no input", ErrJobPermanent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file.Data, nil
+}
+
+func (s *jobService) SaveOutput(job *entity.Job, filename, contentType string, data []byte) error {
+	expiresAt := time.Now().Add(s.outputTTL)
+	return s.jobRepository.SaveFile(&entity.JobFile{
+		JobID:       job.ID,
+		Name:        entity.JobFileOutput,
+		Filename:    filename,
+		ContentType: contentType,
+		Data:        data,
+		ExpiresAt:   &expiresAt,
+	})
+}
+
+func (s *jobService) GetOutput(id, hospitalName, createdBy string) (*entity.JobFile, error) {
+	job, err := s.GetJob(id, hospitalName)
+	if errors.Is(err, ErrJobNotFound) || (err == nil && job.CreatedBy != createdBy) {
+		return nil, ErrJobOutputNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.jobRepository.GetFile(id, entity.JobFileOutput)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobOutputNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.ExpiresAt != nil && file.ExpiresAt.Before(time.Now()) {
+		return nil, ErrJobOutputExpired
+	}
+	return file, nil
+}
+
+func (s *jobService) PurgeOutputs() (int64, error) {
+	return s.jobRepository.DeleteExpiredFiles(time.Now())
+}
+
+// run runs the job's handler while renewing its lease, and stops it when the
+// job is cancelled or its lease is lost.
+func (s *jobService) run(ctx context.Context, job *entity.Job) (any, error) {
+	jobCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	done := make(chan struct{})
+	heartbeats := make(chan struct{})
+	go func() {
+		defer close(heartbeats)
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			cancelRequested, err := s.jobRepository.Heartbeat(job, time.Now().Add(s.lease))
+			switch {
+			case errors.Is(err, repository.ErrJobLost):
+				stop(err)
+				return
+			case err != nil:
+				// The lease is still valid; try again at the next tick.
+				slog.Warn("job heartbeat failed", "job_id", job.ID, "error", err)
+			case cancelRequested:
+				stop(errJobCancelled)
+				return
+			}
+		}
+	}()
+
+	result, err := s.handlers[job.Kind](jobCtx, job)
+	close(done)
+	<-heartbeats
+
+	// A handler stopped by cancellation or a lost lease fails for that reason.
+	if err != nil && jobCtx.Err() != nil && ctx.Err() == nil {
+		return nil, context.Cause(jobCtx)
+	}
+	return result, err
+}
+
+// finish sets the outcome of an attempt: a failed attempt is retried after an
+// exponential backoff until the job runs out of attempts.
+func (s *jobService) finish(job *entity.Job, result any, err error) {
+	now := time.Now()
+	job.LockedUntil = nil
+	job.Error = ""
+	switch {
+	case err == nil:
+		job.Status = entity.JobSucceeded
+		if result != nil {
+			encoded, marshalErr := json.Marshal(result)
+			if marshalErr != nil {
+				job.Status = entity.JobFailed
+				job.Error = marshalErr.Error()
+				break
+			}
+			resultJSON := string(encoded)
+			job.Result = &resultJSON
+		}
+	case errors.Is(err, errJobCancelled):
+		job.Status = entity.JobCancelled
+	case errors.Is(err, ErrJobPermanent) || job.Attempts >= job.MaxAttempts:
+		job.Status = entity.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = entity.JobQueued
+		job.Error = err.Error()
+		job.RunAt = now.Add(s.backoff(job.Attempts))
+		return
+	}
+	job.FinishedAt = &now
+}
+
+// backoff doubles the retry delay with each attempt made.
+func (s *jobService) backoff(attempts int) time.Duration {
+	delay := s.retryBackoff
+	for i := 1; i < attempts && delay < maxJobBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxJobBackoff)
+}
+
+// decodeJobPayload unmarshals the job's payload into v; a payload that does
+// not fit is ErrJobPermanent.
+func decodeJobPayload(job *entity.Job, v any) error {
+	decoder := json.NewDecoder(strings.NewReader(job.Payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", ErrJobPermanent, err)
+	}
+	return nil
+}
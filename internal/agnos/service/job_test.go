@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+// MockJobRepository is a mock implementation of JobRepository. Finish records
+// a copy of each job it is given in Finished, and SaveFile each file in Files.
+type MockJobRepository struct {
+	mock.Mock
+	Finished []entity.Job
+	Files    []*entity.JobFile
+}
+
+func (m *MockJobRepository) Create(job *entity.Job, files ...*entity.JobFile) error {
+	args := m.Called(job, files)
+	job.ID = uuid.New()
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) GetByID(id string) (*entity.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) Claim(kinds []string, lockedUntil time.Time) (*entity.Job, error) {
+	args := m.Called(kinds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) Heartbeat(job *entity.Job, lockedUntil time.Time) (bool, error) {
+	args := m.Called(job.ID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockJobRepository) Finish(job *entity.Job, attempt int) error {
+	args := m.Called(job.ID, attempt)
+	m.Finished = append(m.Finished, *job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Cancel(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) SaveFile(file *entity.JobFile) error {
+	args := m.Called(file.JobID, file.Name)
+	m.Files = append(m.Files, file)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) GetFile(jobID, name string) (*entity.JobFile, error) {
+	args := m.Called(jobID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.JobFile), args.Error(1)
+}
+
+func (m *MockJobRepository) DeleteExpiredFiles(now time.Time) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func newRunningJob(kind string, attempts int) *entity.Job {
+	return &entity.Job{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Hospital:    "hospital-a",
+		Payload:     "{}",
+		Status:      entity.JobRunning,
+		Attempts:    attempts,
+		MaxAttempts: 3,
+		RunAt:       time.Now(),
+	}
+}
+
+func TestJobService_Enqueue(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service.Register("echo", func(ctx context.Context, job *entity.Job) (any, error) { return nil, nil })
+
+	jobRepo.On("Create", mock.MatchedBy(func(job *entity.Job) bool {
+		return job.Kind == "echo" && job.Status == entity.JobQueued && job.Payload == `{"n":1}` && job.MaxAttempts == 3
+	}), []*entity.JobFile(nil)).Return(nil).Once()
+	jobRepo.On("Create", mock.Anything, mock.MatchedBy(func(files []*entity.JobFile) bool {
+		return len(files) == 1 && files[0].Name == entity.JobFileInput && string(files[0].Data) == "records"
+	})).Return(nil).Once()
+
+	job, err := service.Enqueue(JobRequest{Kind: "echo", Hospital: "hospital-a", CreatedBy: "staff:1", Payload: map[string]int{"n": 1}})
+	require.NoError(t, err)
+	assert.Equal(t, "hospital-a", job.Hospital)
+	_, err = service.Enqueue(JobRequest{Kind: "echo", Hospital: "hospital-a", CreatedBy: "staff:1", Input: []byte("records")})
+	require.NoError(t, err)
+
+	_, err = service.Enqueue(JobRequest{Kind: "unknown", Hospital: "hospital-a"})
+	assert.ErrorIs(t, err, ErrJobKind)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestJobService_RunNext(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service.Register("echo", func(ctx context.Context, job *entity.Job) (any, error) {
+		return map[string]string{"payload": job.Payload}, nil
+	})
+
+	job := newRunningJob("echo", 1)
+	jobRepo.On("Claim", []string{"echo"}).Return(job, nil).Once()
+	jobRepo.On("Claim", []string{"echo"}).Return(nil, nil).Once()
+	jobRepo.On("Finish", job.ID, 1).Return(nil).Once()
+
+	ran, err := service.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ran)
+	require.Len(t, jobRepo.Finished, 1)
+	assert.Equal(t, entity.JobSucceeded, jobRepo.Finished[0].Status)
+	assert.JSONEq(t, `{"payload":"{}"}`, *jobRepo.Finished[0].Result)
+	assert.NotNil(t, jobRepo.Finished[0].FinishedAt)
+
+	ran, err = service.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.False(t, ran)
+}
+
+func TestJobService_RunNext_Retries(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service.Register("flaky", func(ctx context.Context, job *entity.Job) (any, error) {
+		return nil, errors.New("hospital unavailable")
+	})
+
+	// The second attempt is retried after twice the backoff, the third fails
+	// the job for good.
+	second, third := newRunningJob("flaky", 2), newRunningJob("flaky", 3)
+	jobRepo.On("Claim", []string{"flaky"}).Return(second, nil).Once()
+	jobRepo.On("Claim", []string{"flaky"}).Return(third, nil).Once()
+	jobRepo.On("Finish", mock.Anything, mock.Anything).Return(nil)
+
+	start := time.Now()
+	_, err := service.RunNext(context.Background())
+	require.NoError(t, err)
+	_, err = service.RunNext(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, jobRepo.Finished, 2)
+	retried := jobRepo.Finished[0]
+	assert.Equal(t, entity.JobQueued, retried.Status)
+	assert.Equal(t, "hospital unavailable", retried.Error)
+	assert.WithinDuration(t, start.Add(2*time.Second), retried.RunAt, time.Second)
+	assert.Nil(t, retried.LockedUntil)
+
+	assert.Equal(t, entity.JobFailed, jobRepo.Finished[1].Status)
+	assert.NotNil(t, jobRepo.Finished[1].FinishedAt)
+}
+
+func TestJobService_RunNext_PermanentError(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	// The payload is rejected before the retention service is used.
+	service.Register(JobKindRetention, NewRetentionJob(nil, nil))
+
+	job := newRunningJob(JobKindRetention, 1)
+	job.Payload = `{"dry_run":"yes"}`
+	jobRepo.On("Claim", []string{JobKindRetention}).Return(job, nil).Once()
+	jobRepo.On("Finish", job.ID, 1).Return(nil).Once()
+
+	_, err := service.RunNext(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobRepo.Finished, 1)
+	assert.Equal(t, entity.JobFailed, jobRepo.Finished[0].Status)
+	assert.Contains(t, jobRepo.Finished[0].Error, "invalid payload")
+}
+
+func TestJobService_RunNext_Cancelled(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, 30*time.Millisecond, 3, time.Second, time.Hour)
+	service.Register("slow", func(ctx context.Context, job *entity.Job) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	job := newRunningJob("slow", 1)
+	jobRepo.On("Claim", []string{"slow"}).Return(job, nil).Once()
+	jobRepo.On("Heartbeat", job.ID).Return(false, nil).Once()
+	jobRepo.On("Heartbeat", job.ID).Return(true, nil).Once()
+	jobRepo.On("Finish", job.ID, 1).Return(nil).Once()
+
+	_, err := service.RunNext(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobRepo.Finished, 1)
+	assert.Equal(t, entity.JobCancelled, jobRepo.Finished[0].Status)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestJobService_RunNext_LeaseLost(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, 30*time.Millisecond, 3, time.Second, time.Hour)
+	service.Register("slow", func(ctx context.Context, job *entity.Job) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	job := newRunningJob("slow", 1)
+	jobRepo.On("Claim", []string{"slow"}).Return(job, nil).Once()
+	jobRepo.On("Heartbeat", job.ID).Return(false, repository.ErrJobLost).Once()
+
+	_, err := service.RunNext(context.Background())
+	assert.ErrorIs(t, err, repository.ErrJobLost)
+	// The worker that took the job over finishes it.
+	jobRepo.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+}
+
+func TestJobService_RunNext_Shutdown(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	service.Register("slow", func(ctx context.Context, job *entity.Job) (any, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	job := newRunningJob("slow", 2)
+	jobRepo.On("Claim", []string{"slow"}).Return(job, nil).Once()
+	jobRepo.On("Finish", job.ID, 2).Return(nil).Once()
+
+	_, err := service.RunNext(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobRepo.Finished, 1)
+	assert.Equal(t, entity.JobQueued, jobRepo.Finished[0].Status)
+	assert.Equal(t, 1, jobRepo.Finished[0].Attempts)
+}
+
+func TestJobService_CancelJob(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+
+	queued := &entity.Job{ID: uuid.New(), Hospital: "hospital-a", Status: entity.JobQueued}
+	cancelled := &entity.Job{ID: queued.ID, Hospital: "hospital-a", Status: entity.JobCancelled}
+	done := &entity.Job{ID: uuid.New(), Hospital: "hospital-a", Status: entity.JobSucceeded}
+	missing := uuid.New().String()
+	jobRepo.On("GetByID", queued.ID.String()).Return(queued, nil).Once()
+	jobRepo.On("GetByID", queued.ID.String()).Return(cancelled, nil).Once()
+	jobRepo.On("GetByID", done.ID.String()).Return(done, nil)
+	jobRepo.On("GetByID", missing).Return(nil, gorm.ErrRecordNotFound)
+	jobRepo.On("Cancel", queued.ID.String()).Return(nil).Once()
+
+	job, err := service.CancelJob(queued.ID.String(), "hospital-a")
+	require.NoError(t, err)
+	assert.Equal(t, entity.JobCancelled, job.Status)
+
+	_, err = service.CancelJob(done.ID.String(), "hospital-a")
+	assert.ErrorIs(t, err, ErrJobFinished)
+	_, err = service.CancelJob(done.ID.String(), "hospital-b")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = service.CancelJob(missing, "hospital-a")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestJobService_Output(t *testing.T) {
+	jobRepo := new(MockJobRepository)
+	service := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+
+	job := &entity.Job{ID: uuid.New(), Hospital: "hospital-a", Status: entity.JobSucceeded, CreatedBy: "staff:1"}
+	id := job.ID.String()
+	jobRepo.On("SaveFile", job.ID, entity.JobFileOutput).Return(nil).Once()
+	require.NoError(t, service.SaveOutput(job, "hospital-a-patients.ndjson", "application/x-ndjson", []byte("{}\n")))
+	require.Len(t, jobRepo.Files, 1)
+	output := jobRepo.Files[0]
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *output.ExpiresAt, time.Minute)
+
+	jobRepo.On("GetByID", id).Return(job, nil)
+	jobRepo.On("GetFile", id, entity.JobFileOutput).Return(output, nil).Once()
+	file, err := service.GetOutput(id, "hospital-a", "staff:1")
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", string(file.Data))
+
+	// Only the staff member who queued the job may download its output.
+	_, err = service.GetOutput(id, "hospital-a", "staff:2")
+	assert.ErrorIs(t, err, ErrJobOutputNotFound)
+	_, err = service.GetOutput(id, "hospital-b", "staff:1")
+	assert.ErrorIs(t, err, ErrJobOutputNotFound)
+
+	expired := time.Now().Add(-time.Second)
+	jobRepo.On("GetFile", id, entity.JobFileOutput).Return(&entity.JobFile{ExpiresAt: &expired}, nil).Once()
+	_, err = service.GetOutput(id, "hospital-a", "staff:1")
+	assert.ErrorIs(t, err, ErrJobOutputExpired)
+
+	jobRepo.On("GetFile", id, entity.JobFileOutput).Return(nil, gorm.ErrRecordNotFound).Once()
+	_, err = service.GetOutput(id, "hospital-a", "staff:1")
+	assert.ErrorIs(t, err, ErrJobOutputNotFound)
+	jobRepo.AssertExpectations(t)
+}
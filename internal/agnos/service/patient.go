@@ -1,125 +1,264 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
 	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-type PatientService interface {
-	SearchPatients(filters map[string]interface{}, staffHospital string) ([]*entity.Patient, error)
-	GetPatientFromHospitalAPI(id, hospital string) (*entity.Patient, error)
+var (
+	ErrPatientNotFound = NewError(ErrNotFound, "patient_not_found", "patient not found")
+	ErrPatientErased   = NewError(ErrGone, "patient_erased", "patient has been erased")
+
+	ErrUnsupportedHospital     = hospital.ErrUnsupported
+	ErrHospitalPatientNotFound = hospital.ErrPatientNotFound
+	ErrHospitalUnavailable     = hospital.ErrUnavailable
+	ErrHospitalInvalidResponse = hospital.ErrInvalidResponse
+)
+
+// SourceStatus describes the outcome of consulting one data source during a search.
+type SourceStatus string
+
+const (
+	SourceStatusFound       SourceStatus = "found"
+	SourceStatusNotFound    SourceStatus = "not_found"
+	SourceStatusUnsupported SourceStatus = "unsupported"
+	SourceStatusUnavailable SourceStatus = "unavailable"
+	SourceStatusInvalidData SourceStatus = "invalid_data"
+	SourceStatusCacheFailed SourceStatus = "cache_failed"
+)
+
+const LocalSource = "local"
+
+type SearchSource struct {
+	Name   string
+	Status SourceStatus
+	Count  int
 }
 
-type patientService struct {
-	patientRepository repository.PatientRepository
+type PatientSearchResult struct {
+	Patients []*entity.Patient
+	Sources  []SearchSource
+	Warnings []string
+}
+
+// UpstreamFailed reports whether a hospital API was consulted but could not answer.
+func (r *PatientSearchResult) UpstreamFailed() bool {
+	for _, source := range r.Sources {
+		if source.Status == SourceStatusUnavailable || source.Status == SourceStatusInvalidData {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheFailed reports whether a patient fetched from a hospital API could not be stored locally.
+func (r *PatientSearchResult) CacheFailed() bool {
+	for _, source := range r.Sources {
+		if source.Status == SourceStatusCacheFailed {
+			return true
+		}
+	}
+	return false
 }
 
-type HospitalAPatientResponse struct {
-	FirstNameTH  string `json:"first_name_th"`
-	MiddleNameTH string `json:"middle_name_th"`
-	LastNameTH   string `json:"last_name_th"`
-	FirstNameEN  string `json:"first_name_en"`
-	MiddleNameEN string `json:"middle_name_en"`
-	LastNameEN   string `json:"last_name_en"`
-	DateOfBirth  string `json:"date_of_birth"`
-	PatientHN    string `json:"patient_hn"`
-	NationalID   string `json:"national_id"`
-	PassportID   string `json:"passport_id"`
-	PhoneNumber  string `json:"phone_number"`
-	Email        string `json:"email"`
-	Gender       string `json:"gender"`
+type PatientService interface {
+	SearchPatients(ctx context.Context, filters map[string]interface{}, staffHospital string) (*PatientSearchResult, error)
+	GetPatientFromHospitalAPI(ctx context.Context, id, hospital string) (*entity.Patient, error)
+	GetPatient(id string) (*entity.Patient, error)
+	// ResolvePatient returns the patient with id as GetPatient does, erased
+	// or not.
+	ResolvePatient(id string) (*entity.Patient, error)
+	GetHospitalPatient(id, hospital string) (*entity.Patient, error)
+	RefreshPatient(ctx context.Context, id, staffHospital string) (*SyncResult, error)
+	ListStalePatients(hospital string, limit int) ([]*entity.Patient, error)
+	SyncPatient(ctx context.Context, patient *entity.Patient, hospital string) (*SyncResult, error)
+}
+
+type patientService struct {
+	patientRepository      repository.PatientRepository
+	patientMergeRepository repository.PatientMergeRepository
+	quarantineRepository   repository.QuarantineRepository
+	hospitals              *hospital.Registry
+	staleAfter             time.Duration
 }
 
 func NewPatientService(
 	patientRepository repository.PatientRepository,
+	patientMergeRepository repository.PatientMergeRepository,
+	quarantineRepository repository.QuarantineRepository,
+	hospitals *hospital.Registry,
+	staleAfter time.Duration,
 ) PatientService {
 	return &patientService{
-		patientRepository: patientRepository,
+		patientRepository:      patientRepository,
+		patientMergeRepository: patientMergeRepository,
+		quarantineRepository:   quarantineRepository,
+		hospitals:              hospitals,
+		staleAfter:             staleAfter,
 	}
 }
 
-func (s *patientService) SearchPatients(filters map[string]interface{}, staffHospital string) ([]*entity.Patient, error) {
-	// First search in local database
-	patients, err := s.patientRepository.Search(filters)
+func (s *patientService) SearchPatients(ctx context.Context, filters map[string]interface{}, staffHospital string) (*PatientSearchResult, error) {
+	// First search the patients already linked to the staff member's hospital
+	patients, err := s.patientRepository.Search(ctx, staffHospital, filters)
 	if err != nil {
 		return nil, err
 	}
 
+	s.markStale(patients...)
+	result := &PatientSearchResult{
+		Patients: patients,
+		Sources:  []SearchSource{{Name: LocalSource, Status: SourceStatusFound, Count: len(patients)}},
+	}
+	if len(patients) > 0 {
+		metrics.PatientLookups.WithLabelValues(metrics.LookupLocal).Inc()
+		return result, nil
+	}
+	result.Sources[0].Status = SourceStatusNotFound
+
 	// If searching by national_id or passport_id and no local results, try Hospital API
-	if len(patients) == 0 {
-		if nationalID, ok := filters["national_id"].(string); ok && nationalID != "" {
-			if apiPatient, err := s.GetPatientFromHospitalAPI(nationalID, staffHospital); err == nil {
-				// Save to local database for future searches
-				s.patientRepository.Create(apiPatient)
-				patients = append(patients, apiPatient)
-			}
-		} else if passportID, ok := filters["passport_id"].(string); ok && passportID != "" {
-			if apiPatient, err := s.GetPatientFromHospitalAPI(passportID, staffHospital); err == nil {
-				// Save to local database for future searches
-				s.patientRepository.Create(apiPatient)
-				patients = append(patients, apiPatient)
-			}
+	id, _ := filters["national_id"].(string)
+	if id == "" {
+		id, _ = filters["passport_id"].(string)
+	}
+	if id == "" {
+		return result, nil
+	}
+
+	metrics.PatientLookups.WithLabelValues(metrics.LookupUpstream).Inc()
+	source := SearchSource{Name: staffHospital}
+	apiPatient, err := s.GetPatientFromHospitalAPI(ctx, id, staffHospital)
+	switch {
+	case err == nil:
+		source.Status = SourceStatusFound
+		source.Count = 1
+
+		// Save to local database for future searches. A patient known from
+		// another hospital is linked to this one, as its API vouches for it.
+		patient, err := s.cacheHospitalPatient(staffHospital, apiPatient)
+		if err != nil {
+			source.Status = SourceStatusCacheFailed
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: patient could not be cached locally", staffHospital))
 		}
+		result.Patients = append(result.Patients, patient)
+	case errors.Is(err, ErrHospitalPatientNotFound):
+		source.Status = SourceStatusNotFound
+	case errors.Is(err, ErrUnsupportedHospital):
+		source.Status = SourceStatusUnsupported
+	case errors.Is(err, ErrHospitalInvalidResponse):
+		source.Status = SourceStatusInvalidData
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", staffHospital, ErrHospitalInvalidResponse))
+
+		quarantined, err := quarantineInvalidRecord(s.quarantineRepository, s.hospitals, staffHospital, entity.PatientSourceAPI, id, err)
+		switch {
+		case err != nil:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: invalid record could not be quarantined", staffHospital))
+		case quarantined:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: invalid record quarantined for review", staffHospital))
+		}
+	default:
+		source.Status = SourceStatusUnavailable
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", staffHospital, ErrHospitalUnavailable))
 	}
+	result.Sources = append(result.Sources, source)
 
-	return patients, nil
+	return result, nil
 }
 
-func (s *patientService) GetPatientFromHospitalAPI(id, hospital string) (*entity.Patient, error) {
-	var apiURL string
-	
-	switch hospital {
-	case "hospital-a":
-		apiURL = fmt.Sprintf("https://hospital-a.api.co.th/patient/search/%s", id)
-	default:
-		return nil, fmt.Errorf("unsupported hospital: %s", hospital)
+// cacheHospitalPatient saves a patient fetched from hospitalName's API, see
+// saveHospitalRecord. The fetched patient is returned if it cannot be saved.
+func (s *patientService) cacheHospitalPatient(hospitalName string, apiPatient *entity.Patient) (*entity.Patient, error) {
+	existing, err := s.patientRepository.GetByIdentifiers(apiPatient.NationalID, apiPatient.PassportID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		existing = nil
+	case err != nil:
+		return apiPatient, err
 	}
+	patient, err := saveHospitalRecord(s.patientRepository, hospitalName, entity.PatientSourceAPI, apiPatient, existing)
+	if err != nil {
+		return apiPatient, err
+	}
+	return patient, nil
+}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// GetPatient returns the patient with id. The ID of a patient retired by a
+// merge resolves to its survivor, so callers should compare the returned ID.
+// A pseudonymized patient is reported as ErrPatientErased.
+func (s *patientService) GetPatient(id string) (*entity.Patient, error) {
+	patient, err := s.ResolvePatient(id)
+	if err != nil {
+		return nil, err
+	}
+	if patient.ErasedAt != nil {
+		return nil, ErrPatientErased
 	}
 
-	resp, err := client.Get(apiURL)
+	s.markStale(patient)
+	return patient, nil
+}
+
+// GetHospitalPatient returns the patient with id as GetPatient does, provided
+// it is linked to hospital. A patient of other hospitals only is reported as
+// ErrPatientNotFound, so staff cannot learn that it exists.
+func (s *patientService) GetHospitalPatient(id, hospital string) (*entity.Patient, error) {
+	patient, err := s.GetPatient(id)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if !linkedTo(patient, hospital) {
+		return nil, ErrPatientNotFound
+	}
+	return patient, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("hospital API returned status: %d", resp.StatusCode)
+func (s *patientService) ResolvePatient(id string) (*entity.Patient, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrPatientNotFound
 	}
 
-	var hospitalResp HospitalAPatientResponse
-	if err := json.NewDecoder(resp.Body).Decode(&hospitalResp); err != nil {
-		return nil, err
+	patient, err := s.patientRepository.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.getSurvivor(id)
 	}
+	return patient, err
+}
+
+func linkedTo(patient *entity.Patient, hospital string) bool {
+	return findHospitalLink(patient, hospital) != nil
+}
 
-	// Parse date of birth
-	dob, err := time.Parse("2006-01-02", hospitalResp.DateOfBirth)
+// getSurvivor follows a merge of retiredID. Merges are re-pointed when a
+// survivor is itself merged, so one hop always reaches the current record.
+func (s *patientService) getSurvivor(retiredID string) (*entity.Patient, error) {
+	merge, err := s.patientMergeRepository.GetByRetiredID(retiredID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPatientNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	patient := &entity.Patient{
-		FirstNameTH:  hospitalResp.FirstNameTH,
-		MiddleNameTH: hospitalResp.MiddleNameTH,
-		LastNameTH:   hospitalResp.LastNameTH,
-		FirstNameEN:  hospitalResp.FirstNameEN,
-		MiddleNameEN: hospitalResp.MiddleNameEN,
-		LastNameEN:   hospitalResp.LastNameEN,
-		DateOfBirth:  dob,
-		PatientHN:    hospitalResp.PatientHN,
-		NationalID:   hospitalResp.NationalID,
-		PassportID:   hospitalResp.PassportID,
-		PhoneNumber:  hospitalResp.PhoneNumber,
-		Email:        hospitalResp.Email,
-		Gender:       hospitalResp.Gender,
+	patient, err := s.patientRepository.GetByID(merge.SurvivorID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPatientNotFound
 	}
+	return patient, err
+}
 
-	return patient, nil
+func (s *patientService) GetPatientFromHospitalAPI(ctx context.Context, id, hospitalName string) (*entity.Patient, error) {
+	adapter, err := s.hospitals.Adapter(hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.FetchPatient(ctx, id)
 }
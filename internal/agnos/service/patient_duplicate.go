@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/validation"
+)
+
+// Weights of the signals combined by ScoreDuplicate. A matching name and date
+// of birth alone reach the default review threshold of 0.75.
+const (
+	nameWeight         = 0.45
+	dateOfBirthWeight  = 0.35
+	phoneWeight        = 0.1
+	emailWeight        = 0.1
+	genderMismatchCost = 0.2
+)
+
+// ScoreDuplicate estimates how likely a and b are the same person, between 0
+// and 1, and lists the signals that contributed. Different national IDs rule
+// a match out, since each person has exactly one.
+func ScoreDuplicate(a, b *entity.Patient) (float64, []string) {
+	if a.NationalID != "" && b.NationalID != "" && a.NationalID != b.NationalID {
+		return 0, []string{"national_id_mismatch"}
+	}
+
+	var score float64
+	var reasons []string
+
+	nameScore := max(
+		nameSimilarity(fullName(a.FirstNameEN, a.MiddleNameEN, a.LastNameEN), fullName(b.FirstNameEN, b.MiddleNameEN, b.LastNameEN)),
+		nameSimilarity(fullName(a.FirstNameTH, a.MiddleNameTH, a.LastNameTH), fullName(b.FirstNameTH, b.MiddleNameTH, b.LastNameTH)),
+	)
+	if nameScore > 0 {
+		score += nameWeight * nameScore
+		reasons = append(reasons, fmt.Sprintf("name:%.2f", nameScore))
+	}
+
+	if !a.DateOfBirth.IsZero() && !b.DateOfBirth.IsZero() {
+		switch {
+		case a.DateOfBirth.Equal(b.DateOfBirth):
+			score += dateOfBirthWeight
+			reasons = append(reasons, "date_of_birth")
+		case a.DateOfBirth.Year() == b.DateOfBirth.Year() &&
+			int(a.DateOfBirth.Month()) == b.DateOfBirth.Day() && a.DateOfBirth.Day() == int(b.DateOfBirth.Month()):
+			// Day and month swapped at data entry.
+			score += dateOfBirthWeight / 2
+			reasons = append(reasons, "date_of_birth_transposed")
+		}
+	}
+
+	// Contacts compare in the canonical forms stored as PhoneNormalized and
+	// EmailNormalized, which candidate pairs were found by.
+	if phone := validation.CanonicalPhone(a.PhoneNumber); phone != "" && phone == validation.CanonicalPhone(b.PhoneNumber) {
+		score += phoneWeight
+		reasons = append(reasons, "phone_number")
+	}
+	if email := validation.CanonicalEmail(a.Email); email != "" && email == validation.CanonicalEmail(b.Email) {
+		score += emailWeight
+		reasons = append(reasons, "email")
+	}
+
+	if a.Gender != "" && b.Gender != "" && !strings.EqualFold(a.Gender, b.Gender) {
+		score -= genderMismatchCost
+		reasons = append(reasons, "gender_mismatch")
+	}
+
+	return min(max(score, 0), 1), reasons
+}
+
+func fullName(parts ...string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Join(parts, " ")), " "))
+}
+
+// nameSimilarity is the Jaro-Winkler similarity of two names, or 0 when
+// either is missing.
+func nameSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	return jaroWinkler([]rune(a), []rune(b))
+}
+
+func jaroWinkler(a, b []rune) float64 {
+	if string(a) == string(b) {
+		return 1
+	}
+
+	window := max(len(a), len(b))/2 - 1
+	window = max(window, 0)
+	aMatched := make([]bool, len(a))
+	bMatched := make([]bool, len(b))
+
+	matches := 0
+	for i := range a {
+		for j := max(0, i-window); j < min(len(b), i+window+1); j++ {
+			if !bMatched[j] && a[i] == b[j] {
+				aMatched[i], bMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range a {
+		if !aMatched[i] {
+			continue
+		}
+		for !bMatched[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+const (
+	// exportBatchSize is how many patients are loaded at a time while exporting.
+	exportBatchSize = 500
+
+	bundleManifest  = "manifest.json"
+	bundleSignature = "manifest.sig"
+
+	// JobKindExport exports a hospital's patients as a background job, see
+	// NewExportJob.
+	JobKindExport = "export"
+)
+
+var (
+	ErrBundleSignature = errors.New("export bundle signature is invalid")
+	// ErrExportSigningKeyUnset is reported instead of signing or verifying a
+	// bundle with an empty key, which anyone could forge.
+	ErrExportSigningKeyUnset = errors.New("EXPORT_SIGNING_KEY is not set")
+)
+
+// ExportRequest asks for the patients linked to Hospital. Audit is recorded
+// for each batch of patients, with their IDs, before the batch is written.
+type ExportRequest struct {
+	Hospital string
+	Audit    AuditRecord
+}
+
+type ExportService interface {
+	// ExportPatients writes the patients linked to a hospital to w as NDJSON
+	// hospital records, the format ImportPatients reads, and returns how many
+	// were written.
+	ExportPatients(ctx context.Context, req ExportRequest, w io.Writer) (int, error)
+	// QueueExport queues a JobKindExport job exporting the patients linked to
+	// hospitalName on behalf of createdBy, a member of its staff.
+	QueueExport(hospitalName, createdBy string) (*entity.Job, error)
+	// ExportPatient builds the signed data portability bundle of patient: a
+	// zip of its record, hospital links, merged records and the audit entries
+	// about it as JSON and CSV, with a manifest of their SHA-256 digests
+	// signed by HMAC-SHA256.
+	ExportPatient(patient *entity.Patient, generatedBy string) ([]byte, error)
+}
+
+// ExportJobResult is the result of JobKindExport jobs; the records are the
+// job's output.
+type ExportJobResult struct {
+	Exported int `json:"exported"`
+}
+
+// NewExportJob runs JobKindExport jobs: the job hospital's patients are
+// exported as NDJSON to the job's output, audited as exported by the job's
+// creator.
+func NewExportJob(exportService ExportService, jobService JobService) JobHandler {
+	return func(ctx context.Context, job *entity.Job) (any, error) {
+		if err := decodeJobPayload(job, &struct{}{}); err != nil {
+			return nil, err
+		}
+
+		var out bytes.Buffer
+		exported, err := exportService.ExportPatients(ctx, ExportRequest{
+			Hospital: job.Hospital,
+			Audit:    AuditRecord{Actor: job.CreatedBy, Hospital: job.Hospital, Action: "patient export job " + job.ID.String()},
+		}, &out)
+		if errors.Is(err, ErrUnsupportedHospital) {
+			return nil, fmt.Errorf("%w: %w", ErrJobPermanent, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := jobService.SaveOutput(job, job.Hospital+"-patients.ndjson", "application/x-ndjson", out.Bytes()); err != nil {
+			return nil, err
+		}
+		return &ExportJobResult{Exported: exported}, nil
+	}
+}
+
+type exportService struct {
+	patientRepository      repository.PatientRepository
+	patientMergeRepository repository.PatientMergeRepository
+	auditRepository        repository.AuditRepository
+	auditService           AuditService
+	jobService             JobService
+	hospitals              *hospital.Registry
+	signingKey             []byte
+}
+
+func NewExportService(
+	patientRepository repository.PatientRepository,
+	patientMergeRepository repository.PatientMergeRepository,
+	auditRepository repository.AuditRepository,
+	auditService AuditService,
+	jobService JobService,
+	hospitals *hospital.Registry,
+	signingKey string,
+) ExportService {
+	return &exportService{
+		patientRepository:      patientRepository,
+		patientMergeRepository: patientMergeRepository,
+		auditRepository:        auditRepository,
+		auditService:           auditService,
+		jobService:             jobService,
+		hospitals:              hospitals,
+		signingKey:             []byte(signingKey),
+	}
+}
+
+func (s *exportService) QueueExport(hospitalName, createdBy string) (*entity.Job, error) {
+	if _, ok := s.hospitals.Config(hospitalName); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHospital, hospitalName)
+	}
+	return s.jobService.Enqueue(JobRequest{Kind: JobKindExport, Hospital: hospitalName, CreatedBy: createdBy})
+}
+
+func (s *exportService) ExportPatients(ctx context.Context, req ExportRequest, w io.Writer) (int, error) {
+	if _, ok := s.hospitals.Config(req.Hospital); !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedHospital, req.Hospital)
+	}
+
+	encoder := json.NewEncoder(w)
+	exported := 0
+	err := s.patientRepository.EachByHospital(req.Hospital, exportBatchSize, func(patients []*entity.Patient) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record := req.Audit
+		record.PatientIDs = make([]uuid.UUID, len(patients))
+		for i, patient := range patients {
+			record.PatientIDs[i] = patient.ID
+		}
+		if err := s.auditService.Record(record); err != nil {
+			return fmt.Errorf("audit export: %w", err)
+		}
+
+		for _, patient := range patients {
+			if err := encoder.Encode(hospitalRecord(patient, req.Hospital)); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	return exported, err
+}
+
+// hospitalRecord is patient as hospitalName would send it, carrying the HN
+// the hospital knows the patient by.
+func hospitalRecord(patient *entity.Patient, hospitalName string) hospital.JSONPatient {
+	record := hospital.JSONPatient{
+		FirstNameTH:  patient.FirstNameTH,
+		MiddleNameTH: patient.MiddleNameTH,
+		LastNameTH:   patient.LastNameTH,
+		FirstNameEN:  patient.FirstNameEN,
+		MiddleNameEN: patient.MiddleNameEN,
+		LastNameEN:   patient.LastNameEN,
+		DateOfBirth:  patient.DateOfBirth.Format("2006-01-02"),
+		PatientHN:    patient.PatientHN,
+		NationalID:   patient.NationalID,
+		PassportID:   patient.PassportID,
+		PhoneNumber:  patient.PhoneNumber,
+		Email:        patient.Email,
+		Gender:       patient.Gender,
+	}
+	for _, link := range patient.Hospitals {
+		if link.Hospital == hospitalName {
+			record.PatientHN = link.PatientHN
+		}
+	}
+	return record
+}
+
+type bundleHospital struct {
+	Hospital     string     `json:"hospital"`
+	PatientHN    string     `json:"patient_hn"`
+	Source       string     `json:"source"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	LinkedAt     time.Time  `json:"linked_at"`
+}
+
+type bundleMerge struct {
+	RetiredID     uuid.UUID       `json:"retired_id"`
+	RetiredRecord json.RawMessage `json:"retired_record"`
+	MergedAt      time.Time       `json:"merged_at"`
+}
+
+type bundleAccess struct {
+	OccurredAt time.Time `json:"occurred_at"`
+	Actor      string    `json:"actor"`
+	Hospital   string    `json:"hospital"`
+	Action     string    `json:"action"`
+	Status     int       `json:"status"`
+}
+
+type bundleFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+type bundleManifestData struct {
+	PatientID   uuid.UUID    `json:"patient_id"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	GeneratedBy string       `json:"generated_by"`
+	Files       []bundleFile `json:"files"`
+}
+
+func (s *exportService) ExportPatient(patient *entity.Patient, generatedBy string) ([]byte, error) {
+	if len(s.signingKey) == 0 {
+		return nil, ErrExportSigningKeyUnset
+	}
+	merges, err := s.patientMergeRepository.ListBySurvivorID(patient.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	// Accesses to records since merged into the patient are theirs too.
+	ids := []uuid.UUID{patient.ID}
+	for _, merge := range merges {
+		ids = append(ids, merge.RetiredID)
+	}
+	entries, err := s.auditRepository.ListByPatients(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	record := patientRecord(patient)
+	columns := append([]string{"id"}, slices.Sorted(maps.Keys(mergeColumns))...)
+
+	hospitals := make([]bundleHospital, len(patient.Hospitals))
+	hospitalRows := [][]string{{"hospital", "patient_hn", "source", "last_synced_at", "linked_at"}}
+	for i, link := range patient.Hospitals {
+		hospitals[i] = bundleHospital{
+			Hospital:     link.Hospital,
+			PatientHN:    link.PatientHN,
+			Source:       link.Source,
+			LastSyncedAt: link.LastSyncedAt,
+			LinkedAt:     link.CreatedAt,
+		}
+		hospitalRows = append(hospitalRows, []string{link.Hospital, link.PatientHN, link.Source, formatTime(link.LastSyncedAt), formatTime(&link.CreatedAt)})
+	}
+	retired := make([]bundleMerge, len(merges))
+	for i, merge := range merges {
+		retired[i] = bundleMerge{RetiredID: merge.RetiredID, RetiredRecord: json.RawMessage(merge.RetiredRecord), MergedAt: merge.MergedAt}
+	}
+	accesses := make([]bundleAccess, len(entries))
+	accessRows := [][]string{{"occurred_at", "actor", "hospital", "action", "status"}}
+	for i, entry := range entries {
+		accesses[i] = bundleAccess{OccurredAt: entry.OccurredAt, Actor: entry.Actor, Hospital: entry.Hospital, Action: entry.Action, Status: entry.Status}
+		accessRows = append(accessRows, []string{formatTime(&entry.OccurredAt), entry.Actor, entry.Hospital, entry.Action, strconv.Itoa(entry.Status)})
+	}
+
+	patientJSON, err := json.MarshalIndent(map[string]interface{}{
+		"patient":   record,
+		"hospitals": hospitals,
+		"merged":    retired,
+		"accesses":  accesses,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	patientRow := make([]string, len(columns))
+	for i, column := range columns {
+		patientRow[i] = record[column]
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"patient.json", patientJSON},
+		{"patient.csv", csvBytes([][]string{columns, patientRow})},
+		{"hospitals.csv", csvBytes(hospitalRows)},
+		{"accesses.csv", csvBytes(accessRows)},
+	}
+
+	manifest := bundleManifestData{
+		PatientID:   patient.ID,
+		GeneratedAt: time.Now().UTC(),
+		GeneratedBy: generatedBy,
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		digest := sha256.Sum256(file.data)
+		manifest.Files = append(manifest.Files, bundleFile{Name: file.name, SHA256: hex.EncodeToString(digest[:])})
+		if err := writeZipFile(archive, file.name, file.data); err != nil {
+			return nil, err
+		}
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(archive, bundleManifest, manifestJSON); err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(archive, bundleSignature, []byte(signManifest(s.signingKey, manifestJSON)+"\n")); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyPatientBundle checks that bundle was signed with signingKey and that
+// its files are exactly those the manifest lists, unaltered.
+func VerifyPatientBundle(bundle []byte, signingKey string) error {
+	if signingKey == "" {
+		return ErrExportSigningKeyUnset
+	}
+	archive, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		return err
+	}
+	contents := make(map[string][]byte, len(archive.File))
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		contents[file.Name] = data
+	}
+
+	manifestJSON := contents[bundleManifest]
+	signature := bytes.TrimSpace(contents[bundleSignature])
+	if !hmac.Equal(signature, []byte(signManifest([]byte(signingKey), manifestJSON))) {
+		return ErrBundleSignature
+	}
+	var manifest bundleManifestData
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return err
+	}
+	if len(contents) != len(manifest.Files)+2 {
+		return fmt.Errorf("%w: unexpected files", ErrBundleSignature)
+	}
+	for _, file := range manifest.Files {
+		data, ok := contents[file.Name]
+		digest := sha256.Sum256(data)
+		if !ok || hex.EncodeToString(digest[:]) != file.SHA256 {
+			return fmt.Errorf("%w: %s was altered", ErrBundleSignature, file.Name)
+		}
+	}
+	return nil
+}
+
+func signManifest(key, manifest []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(manifest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func csvBytes(rows [][]string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.WriteAll(rows)
+	return buf.Bytes()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
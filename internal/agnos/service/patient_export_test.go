@@ -0,0 +1,174 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+)
+
+func TestExportService_ExportPatients(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	auditRepo := new(MockAuditRepository)
+	service := NewExportService(patientRepo, new(MockPatientMergeRepository), auditRepo, NewAuditService(auditRepo), nil, newImportRegistry(t), "key")
+
+	patients := []*entity.Patient{{
+		ID:          uuid.New(),
+		NationalID:  "1234567890121",
+		FirstNameEN: "Somchai",
+		DateOfBirth: time.Date(1990, 3, 12, 0, 0, 0, 0, time.UTC),
+		PatientHN:   "HN-B",
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-b", PatientHN: "HN-B"}, {Hospital: "hospital-a", PatientHN: "HN-A"}},
+	}}
+	patientRepo.On("EachByHospital", "hospital-a", exportBatchSize).Return(patients, nil)
+	auditRepo.On("Append", mock.MatchedBy(func(entries []*entity.AuditEntry) bool {
+		return len(entries) == 1 && *entries[0].PatientID == patients[0].ID && entries[0].Action == "patient export"
+	})).Return(nil)
+
+	var out bytes.Buffer
+	exported, err := service.ExportPatients(context.Background(), ExportRequest{
+		Hospital: "hospital-a",
+		Audit:    AuditRecord{Actor: "cli:ops", Hospital: "hospital-a", Action: "patient export"},
+	}, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exported)
+	assert.JSONEq(t, `{"first_name_th":"","middle_name_th":"","last_name_th":"","first_name_en":"Somchai","middle_name_en":"","last_name_en":"",
+		"date_of_birth":"1990-03-12","patient_hn":"HN-A","national_id":"1234567890121","passport_id":"","phone_number":"","email":"","gender":""}`, out.String())
+	auditRepo.AssertExpectations(t)
+
+	_, err = service.ExportPatients(context.Background(), ExportRequest{Hospital: "hospital-z"}, &out)
+	assert.ErrorIs(t, err, ErrUnsupportedHospital)
+}
+
+func TestExportService_ExportPatients_AuditFails(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	auditRepo := new(MockAuditRepository)
+	service := NewExportService(patientRepo, new(MockPatientMergeRepository), auditRepo, NewAuditService(auditRepo), nil, newImportRegistry(t), "key")
+
+	patientRepo.On("EachByHospital", "hospital-a", exportBatchSize).Return([]*entity.Patient{{ID: uuid.New()}}, nil)
+	auditRepo.On("Append", mock.Anything).Return(errors.New("connection reset"))
+
+	var out bytes.Buffer
+	exported, err := service.ExportPatients(context.Background(), ExportRequest{Hospital: "hospital-a"}, &out)
+
+	// Nothing is written that was not audited.
+	assert.ErrorContains(t, err, "audit export")
+	assert.Zero(t, exported)
+	assert.Zero(t, out.Len())
+}
+
+func TestExportService_ExportPatient(t *testing.T) {
+	mergeRepo := new(MockPatientMergeRepository)
+	auditRepo := new(MockAuditRepository)
+	service := NewExportService(new(MockPatientRepository), mergeRepo, auditRepo, NewAuditService(auditRepo), nil, newImportRegistry(t), "key")
+
+	synced := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	patient := &entity.Patient{
+		ID:          uuid.New(),
+		NationalID:  "1234567890121",
+		FirstNameEN: "Somchai",
+		DateOfBirth: time.Date(1990, 3, 12, 0, 0, 0, 0, time.UTC),
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-a", PatientHN: "HN-A", Source: entity.PatientSourceAPI, LastSyncedAt: &synced}},
+	}
+	retiredID := uuid.New()
+	mergeRepo.On("ListBySurvivorID", patient.ID.String()).Return([]*entity.PatientMerge{{RetiredID: retiredID, RetiredRecord: `{"first_name_en":"Somchay"}`}}, nil)
+	auditRepo.On("ListByPatients", []uuid.UUID{patient.ID, retiredID}).Return([]*entity.AuditEntry{
+		{Seq: 1, OccurredAt: synced, Actor: "staff:1", Hospital: "hospital-a", Action: "GET /patient/:id", PatientID: &retiredID, Status: 200},
+	}, nil)
+
+	bundle, err := service.ExportPatient(patient, "staff:1")
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyPatientBundle(bundle, "key"))
+	assert.ErrorIs(t, VerifyPatientBundle(bundle, "other-key"), ErrBundleSignature)
+	assert.ErrorIs(t, VerifyPatientBundle(bundle, ""), ErrExportSigningKeyUnset)
+
+	unkeyed := NewExportService(new(MockPatientRepository), mergeRepo, auditRepo, NewAuditService(auditRepo), nil, newImportRegistry(t), "")
+	_, err = unkeyed.ExportPatient(patient, "staff:1")
+	assert.ErrorIs(t, err, ErrExportSigningKeyUnset)
+
+	files := readBundle(t, bundle)
+	assert.Equal(t, "id,date_of_birth,email,first_name_en,first_name_th,gender,last_name_en,last_name_th,middle_name_en,middle_name_th,national_id,passport_id,patient_hn,phone_number\n"+
+		patient.ID.String()+",1990-03-12,,Somchai,,,,,,,1234567890121,,,\n", string(files["patient.csv"]))
+	assert.Equal(t, "occurred_at,actor,hospital,action,status\n2025-01-01T08:00:00Z,staff:1,hospital-a,GET /patient/:id,200\n", string(files["accesses.csv"]))
+
+	var data map[string]interface{}
+	assert.NoError(t, json.Unmarshal(files["patient.json"], &data))
+	assert.Equal(t, "Somchai", data["patient"].(map[string]interface{})["first_name_en"])
+	assert.Equal(t, "HN-A", data["hospitals"].([]interface{})[0].(map[string]interface{})["patient_hn"])
+	assert.Equal(t, "Somchay", data["merged"].([]interface{})[0].(map[string]interface{})["retired_record"].(map[string]interface{})["first_name_en"])
+
+	// Altering any file breaks the manifest.
+	files["patient.csv"] = []byte("id\n")
+	assert.ErrorIs(t, VerifyPatientBundle(writeBundle(t, files), "key"), ErrBundleSignature)
+}
+
+func readBundle(t *testing.T, bundle []byte) map[string][]byte {
+	archive, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	return files
+}
+
+func writeBundle(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, data := range files {
+		if err := writeZipFile(archive, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewExportJob(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	auditRepo := new(MockAuditRepository)
+	jobRepo := new(MockJobRepository)
+	jobService := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service := NewExportService(patientRepo, new(MockPatientMergeRepository), auditRepo, NewAuditService(auditRepo), jobService, newImportRegistry(t), "key")
+
+	job := newRunningJob(JobKindExport, 1)
+	job.CreatedBy = "staff:1"
+	patient := &entity.Patient{ID: uuid.New(), NationalID: "1234567890121", Hospitals: []entity.PatientHospital{{Hospital: "hospital-a", PatientHN: "HN-A"}}}
+	patientRepo.On("EachByHospital", "hospital-a", exportBatchSize).Return([]*entity.Patient{patient}, nil)
+	auditRepo.On("Append", mock.MatchedBy(func(entries []*entity.AuditEntry) bool {
+		return len(entries) == 1 && entries[0].Actor == "staff:1" && *entries[0].PatientID == patient.ID
+	})).Return(nil)
+	jobRepo.On("SaveFile", job.ID, entity.JobFileOutput).Return(nil)
+
+	result, err := NewExportJob(service, jobService)(context.Background(), job)
+
+	require.NoError(t, err)
+	assert.Equal(t, &ExportJobResult{Exported: 1}, result)
+	require.Len(t, jobRepo.Files, 1)
+	output := jobRepo.Files[0]
+	assert.Equal(t, "hospital-a-patients.ndjson", output.Filename)
+	assert.Equal(t, "application/x-ndjson", output.ContentType)
+	assert.Contains(t, string(output.Data), `"patient_hn":"HN-A"`)
+	auditRepo.AssertExpectations(t)
+}
@@ -0,0 +1,542 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	// maxImportLine bounds one NDJSON record.
+	maxImportLine = 1 << 20
+	// maxReportErrors bounds the row errors returned with an import report.
+	maxReportErrors = 100
+
+	// JobKindImport runs a queued import as a background job, see
+	// NewImportJob.
+	JobKindImport = "import"
+)
+
+var (
+	ErrImportFormat       = NewError(ErrValidation, "unsupported_import_format", "unsupported import format")
+	ErrImportJobNotFound  = NewError(ErrNotFound, "import_job_not_found", "import job not found")
+	ErrImportJobCompleted = NewError(ErrConflict, "import_job_completed", "import job already completed")
+	ErrImportJobQueued    = NewError(ErrConflict, "import_job_queued", "import job is already queued or running")
+)
+
+type ImportRequest struct {
+	Hospital string
+	// Format is ImportFormatCSV or ImportFormatNDJSON.
+	Format    string
+	StartedBy string
+}
+
+// ImportReport is the state of an import job with its first row errors.
+type ImportReport struct {
+	Job    *entity.ImportJob
+	Errors []*entity.ImportJobError
+}
+
+type ImportService interface {
+	// ImportPatients starts an import job and reads hospital records from r
+	// one at a time, committing them in batches. It returns the job's report
+	// even when the job stops early, in which case it can be resumed.
+	ImportPatients(ctx context.Context, req ImportRequest, r io.Reader) (*ImportReport, error)
+	// QueueImport checks the format and header of input and queues an import
+	// job of it, run by a JobKindImport background job.
+	QueueImport(req ImportRequest, input []byte) (*ImportReport, error)
+	// ResumeImport runs a queued job of hospitalName, or continues one that
+	// stopped early, reading the same input again from r and skipping the
+	// lines already committed.
+	ResumeImport(ctx context.Context, jobID, hospitalName string, r io.Reader) (*ImportReport, error)
+	// QueueResume queues a JobKindImport background job resuming a job of
+	// hospitalName with the same input again, on behalf of startedBy.
+	QueueResume(jobID, hospitalName, startedBy string, input []byte) (*ImportReport, error)
+	GetImportJob(jobID, hospitalName string) (*ImportReport, error)
+}
+
+// ImportJobPayload is the payload of JobKindImport jobs, whose input is the
+// import's input.
+type ImportJobPayload struct {
+	ImportJobID string `json:"import_job_id"`
+}
+
+// ImportJobResult is the result of JobKindImport jobs; the import job has
+// the row errors.
+type ImportJobResult struct {
+	ImportJobID uuid.UUID `json:"import_job_id"`
+	Created     int       `json:"created"`
+	Updated     int       `json:"updated"`
+	Duplicates  int       `json:"duplicates"`
+	Failed      int       `json:"failed"`
+}
+
+// NewImportJob runs JobKindImport jobs. An attempt stopped early is retried
+// from the import's checkpoint; an import that cannot run, such as one of
+// another hospital, fails the job at once.
+func NewImportJob(importService ImportService, jobService JobService) JobHandler {
+	return func(ctx context.Context, job *entity.Job) (any, error) {
+		var payload ImportJobPayload
+		if err := decodeJobPayload(job, &payload); err != nil {
+			return nil, err
+		}
+		input, err := jobService.Input(job)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := importService.ResumeImport(ctx, payload.ImportJobID, job.Hospital, bytes.NewReader(input))
+		if errors.Is(err, ErrImportJobCompleted) {
+			// An earlier attempt completed it but could not finish the job.
+			report, err = importService.GetImportJob(payload.ImportJobID, job.Hospital)
+		}
+		if err != nil {
+			if AsError(err) != nil {
+				return nil, fmt.Errorf("%w: %w", ErrJobPermanent, err)
+			}
+			return nil, err
+		}
+		return &ImportJobResult{
+			ImportJobID: report.Job.ID,
+			Created:     report.Job.Created,
+			Updated:     report.Job.Updated,
+			Duplicates:  report.Job.Duplicates,
+			Failed:      report.Job.Failed,
+		}, nil
+	}
+}
+
+type importService struct {
+	importJobRepository repository.ImportJobRepository
+	jobService          JobService
+	hospitals           *hospital.Registry
+	batchSize           int
+}
+
+func NewImportService(
+	importJobRepository repository.ImportJobRepository,
+	jobService JobService,
+	hospitals *hospital.Registry,
+	batchSize int,
+) ImportService {
+	return &importService{
+		importJobRepository: importJobRepository,
+		jobService:          jobService,
+		hospitals:           hospitals,
+		batchSize:           batchSize,
+	}
+}
+
+func (s *importService) ImportPatients(ctx context.Context, req ImportRequest, r io.Reader) (*ImportReport, error) {
+	if _, ok := s.hospitals.Config(req.Hospital); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHospital, req.Hospital)
+	}
+	records, err := newRecordReader(req.Format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &entity.ImportJob{
+		Hospital:  req.Hospital,
+		Format:    req.Format,
+		Status:    entity.ImportJobRunning,
+		StartedBy: req.StartedBy,
+	}
+	if err := s.importJobRepository.Create(job); err != nil {
+		return nil, err
+	}
+	return s.run(ctx, job, records)
+}
+
+func (s *importService) QueueImport(req ImportRequest, input []byte) (*ImportReport, error) {
+	if _, ok := s.hospitals.Config(req.Hospital); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHospital, req.Hospital)
+	}
+	if _, err := newRecordReader(req.Format, bytes.NewReader(input)); err != nil {
+		return nil, err
+	}
+
+	job := &entity.ImportJob{
+		Hospital:  req.Hospital,
+		Format:    req.Format,
+		Status:    entity.ImportJobQueued,
+		StartedBy: req.StartedBy,
+	}
+	if err := s.importJobRepository.Create(job); err != nil {
+		return nil, err
+	}
+	return s.queue(job, req.StartedBy, input)
+}
+
+func (s *importService) QueueResume(jobID, hospitalName, startedBy string, input []byte) (*ImportReport, error) {
+	job, err := s.getJob(jobID, hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == entity.ImportJobCompleted {
+		return nil, ErrImportJobCompleted
+	}
+	if job.JobID != nil {
+		// The job may still be waiting for a retry of a failed attempt.
+		queued, err := s.jobService.GetJob(job.JobID.String(), hospitalName)
+		if err != nil && !errors.Is(err, ErrJobNotFound) {
+			return nil, err
+		}
+		if err == nil && !queued.Finished() {
+			return nil, ErrImportJobQueued
+		}
+	}
+	if _, err := newRecordReader(job.Format, bytes.NewReader(input)); err != nil {
+		return nil, err
+	}
+
+	job.Status = entity.ImportJobQueued
+	job.Error = ""
+	if err := s.importJobRepository.Update(job); err != nil {
+		return nil, err
+	}
+	return s.queue(job, startedBy, input)
+}
+
+// queue enqueues the background job running job with input, and records it
+// on job. job is not saved whole once the background job may be running it.
+func (s *importService) queue(job *entity.ImportJob, createdBy string, input []byte) (*ImportReport, error) {
+	queued, err := s.jobService.Enqueue(JobRequest{
+		Kind:      JobKindImport,
+		Hospital:  job.Hospital,
+		CreatedBy: createdBy,
+		Payload:   ImportJobPayload{ImportJobID: job.ID.String()},
+		Input:     input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	job.JobID = &queued.ID
+	if err := s.importJobRepository.SetJobID(job.ID.String(), queued.ID); err != nil {
+		return nil, err
+	}
+	return s.report(job)
+}
+
+func (s *importService) ResumeImport(ctx context.Context, jobID, hospitalName string, r io.Reader) (*ImportReport, error) {
+	job, err := s.getJob(jobID, hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == entity.ImportJobCompleted {
+		return nil, ErrImportJobCompleted
+	}
+	records, err := newRecordReader(job.Format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = entity.ImportJobRunning
+	job.Error = ""
+	if err := s.importJobRepository.Update(job); err != nil {
+		return nil, err
+	}
+	return s.run(ctx, job, records)
+}
+
+func (s *importService) GetImportJob(jobID, hospitalName string) (*ImportReport, error) {
+	job, err := s.getJob(jobID, hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	return s.report(job)
+}
+
+// getJob finds a job of hospitalName; other hospitals' jobs are not found.
+func (s *importService) getJob(jobID, hospitalName string) (*entity.ImportJob, error) {
+	if _, err := uuid.Parse(jobID); err != nil {
+		return nil, ErrImportJobNotFound
+	}
+	job, err := s.importJobRepository.GetByID(jobID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && job.Hospital != hospitalName) {
+		return nil, ErrImportJobNotFound
+	}
+	return job, err
+}
+
+func (s *importService) report(job *entity.ImportJob) (*ImportReport, error) {
+	rowErrors, err := s.importJobRepository.ListErrors(job.ID.String(), maxReportErrors)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportReport{Job: job, Errors: rowErrors}, nil
+}
+
+// importRow is a record prepared for a batch: either the patient to save or
+// the reason it is skipped.
+type importRow struct {
+	line     int
+	patient  *entity.Patient
+	rowError *entity.ImportJobError
+}
+
+// run imports records into job, from the line after its checkpoint. Lines up
+// to the checkpoint are read again only to recognise later duplicates.
+func (s *importService) run(ctx context.Context, job *entity.ImportJob, records recordReader) (*ImportReport, error) {
+	seen := make(map[[2]string]int)
+	rows := make([]importRow, 0, s.batchSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return s.fail(job, err)
+		}
+		record, line, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		var fatal *fatalImportError
+		if errors.As(err, &fatal) {
+			return s.fail(job, fatal.err)
+		}
+
+		row := s.prepare(job.Hospital, record, line, err, seen)
+		if line <= job.Checkpoint {
+			continue
+		}
+		rows = append(rows, row)
+		if len(rows) == s.batchSize {
+			if err := s.saveBatch(job, rows, entity.ImportJobRunning); err != nil {
+				return s.fail(job, err)
+			}
+			rows = rows[:0]
+		}
+	}
+
+	if err := s.saveBatch(job, rows, entity.ImportJobCompleted); err != nil {
+		return s.fail(job, err)
+	}
+	return s.report(job)
+}
+
+// prepare validates a record like any hospital record and checks its
+// identifiers against the earlier records in seen.
+func (s *importService) prepare(hospitalName string, record hospital.JSONPatient, line int, err error, seen map[[2]string]int) importRow {
+	row := importRow{line: line}
+	if err == nil {
+		row.patient, err = record.ToEntity(s.hospitals.DateParser(hospitalName))
+	}
+	if err != nil {
+		row.rowError = &entity.ImportJobError{Line: line, Kind: entity.ImportErrorInvalid, Error: err.Error()}
+		return row
+	}
+
+	identifiers := [][2]string{{"national_id", row.patient.NationalID}, {"passport_id", row.patient.PassportID}}
+	for _, identifier := range identifiers {
+		if first, ok := seen[identifier]; ok && identifier[1] != "" {
+			row.rowError = &entity.ImportJobError{
+				Line:  line,
+				Kind:  entity.ImportErrorDuplicate,
+				Error: fmt.Sprintf("%s repeats line %d", identifier[0], first),
+			}
+			return row
+		}
+	}
+	for _, identifier := range identifiers {
+		if identifier[1] != "" {
+			seen[identifier] = line
+		}
+	}
+	return row
+}
+
+// saveBatch commits rows and advances job past them, leaving it with status.
+// job is only changed once the batch is committed.
+func (s *importService) saveBatch(job *entity.ImportJob, rows []importRow, status string) error {
+	next := *job
+	next.Status = status
+	err := s.importJobRepository.SaveBatch(&next, job.Checkpoint, func(batch repository.ImportBatch) ([]*entity.ImportJobError, error) {
+		var rowErrors []*entity.ImportJobError
+		for _, row := range rows {
+			next.Checkpoint = row.line
+			rowError := row.rowError
+			created := false
+			if rowError == nil {
+				err := batch.Row(func(patients repository.PatientRepository) error {
+					var err error
+					created, err = importPatient(patients, job.Hospital, row.patient)
+					return err
+				})
+				if err != nil {
+					rowError = &entity.ImportJobError{Line: row.line, Kind: entity.ImportErrorInvalid, Error: err.Error()}
+				}
+			}
+
+			switch {
+			case rowError == nil && created:
+				next.Created++
+			case rowError == nil:
+				next.Updated++
+			case rowError.Kind == entity.ImportErrorDuplicate:
+				next.Duplicates++
+			default:
+				next.Failed++
+			}
+			if rowError != nil {
+				rowError.JobID = job.ID
+				rowErrors = append(rowErrors, rowError)
+			}
+		}
+		return rowErrors, nil
+	})
+	if err != nil {
+		return err
+	}
+	*job = next
+	return nil
+}
+
+// importPatient saves incoming as held by hospitalName, reporting whether the
+// patient was new.
+func importPatient(patients repository.PatientRepository, hospitalName string, incoming *entity.Patient) (bool, error) {
+	patient, err := patients.GetByIdentifiers(incoming.NationalID, incoming.PassportID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	_, err = saveHospitalRecord(patients, hospitalName, entity.PatientSourceImport, incoming, patient)
+	return patient == nil, err
+}
+
+// fail records why job stopped, so it can be resumed, and returns its report
+// with cause. A job advanced by another run is left to that run.
+func (s *importService) fail(job *entity.ImportJob, cause error) (*ImportReport, error) {
+	if errors.Is(cause, repository.ErrImportJobMoved) {
+		return nil, cause
+	}
+	job.Status = entity.ImportJobFailed
+	job.Error = cause.Error()
+	if err := s.importJobRepository.Update(job); err != nil {
+		return nil, errors.Join(cause, err)
+	}
+	report, err := s.report(job)
+	if err != nil {
+		return nil, errors.Join(cause, err)
+	}
+	return report, cause
+}
+
+// fatalImportError is a failure to read the input rather than a malformed
+// record; it stops the import.
+type fatalImportError struct {
+	err error
+}
+
+func (e *fatalImportError) Error() string {
+	return e.err.Error()
+}
+
+// recordReader yields hospital records with their line; malformed records
+// are returned as errors so reading can go on, the end as io.EOF.
+type recordReader interface {
+	Next() (hospital.JSONPatient, int, error)
+}
+
+func newRecordReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case ImportFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+		return &ndjsonReader{scanner: scanner}, nil
+	case ImportFormatCSV:
+		return newCSVReader(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrImportFormat, format)
+	}
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Next() (hospital.JSONPatient, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		b := bytes.TrimSpace(r.scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var record hospital.JSONPatient
+		return record, r.line, decodeRecord(b, &record)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return hospital.JSONPatient{}, r.line + 1, &fatalImportError{err}
+	}
+	return hospital.JSONPatient{}, r.line, io.EOF
+}
+
+// csvReader reads records whose header names the JSON fields of
+// hospital.JSONPatient.
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: CSV header: %v", ErrImportFormat, err)
+	}
+
+	columns := make(map[string]string, len(header))
+	for _, column := range header {
+		columns[column] = ""
+	}
+	b, _ := json.Marshal(columns)
+	if err := decodeRecord(b, &hospital.JSONPatient{}); err != nil {
+		return nil, fmt.Errorf("%w: CSV header: %v", ErrImportFormat, err)
+	}
+	return &csvReader{reader: reader, header: header}, nil
+}
+
+func (r *csvReader) Next() (hospital.JSONPatient, int, error) {
+	var record hospital.JSONPatient
+	row, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		switch {
+		case err == io.EOF:
+			return record, 0, io.EOF
+		case errors.As(err, &parseErr):
+			return record, parseErr.StartLine, err
+		default:
+			return record, 0, &fatalImportError{err}
+		}
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	fields := make(map[string]string, len(row))
+	for i, value := range row {
+		fields[r.header[i]] = value
+	}
+	b, _ := json.Marshal(fields)
+	return record, line, decodeRecord(b, &record)
+}
+
+// decodeRecord decodes one JSON record, rejecting fields JSONPatient does not
+// have so misspelt columns are not silently dropped.
+func decodeRecord(b []byte, record *hospital.JSONPatient) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(record)
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+func newImportRegistry(t *testing.T) *hospital.Registry {
+	registry, err := hospital.NewRegistry([]hospital.Config{{Name: "hospital-a", BaseURL: "https://hospital-a.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+// MockImportJobRepository is a mock implementation of ImportJobRepository.
+// Batches run against Patients, and the row errors they return are kept in
+// Errors for ListErrors.
+type MockImportJobRepository struct {
+	mock.Mock
+	Patients repository.PatientRepository
+	Errors   []*entity.ImportJobError
+}
+
+func (m *MockImportJobRepository) Create(job *entity.ImportJob) error {
+	args := m.Called(job)
+	job.ID = uuid.New()
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepository) GetByID(id string) (*entity.ImportJob, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ImportJob), args.Error(1)
+}
+
+func (m *MockImportJobRepository) Update(job *entity.ImportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepository) SetJobID(id string, jobID uuid.UUID) error {
+	args := m.Called(id, jobID)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepository) SaveBatch(job *entity.ImportJob, checkpoint int, fn func(batch repository.ImportBatch) ([]*entity.ImportJobError, error)) error {
+	args := m.Called(job, checkpoint)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	rowErrors, err := fn(mockImportBatch{patients: m.Patients})
+	m.Errors = append(m.Errors, rowErrors...)
+	return err
+}
+
+func (m *MockImportJobRepository) ListErrors(jobID string, limit int) ([]*entity.ImportJobError, error) {
+	args := m.Called(jobID, limit)
+	return m.Errors, args.Error(0)
+}
+
+type mockImportBatch struct {
+	patients repository.PatientRepository
+}
+
+func (b mockImportBatch) Row(fn func(patients repository.PatientRepository) error) error {
+	return fn(b.patients)
+}
+
+// importInput has records on lines 1 and 3 to 7: a new patient, an existing
+// one, two invalid records, a duplicate of line 1 and a malformed line.
+var importInput = strings.Join([]string{
+	`{"national_id":"1234567890121","first_name_en":"Somchai","date_of_birth":"2533-03-12","patient_hn":"HN1"}`,
+	``,
+	`{"national_id":"3100701443816","first_name_en":"Malee","last_name_en":"Srisuk","date_of_birth":"1985-06-01","patient_hn":"HN2"}`,
+	`{"national_id":"1234567890123","date_of_birth":"1990-01-01"}`,
+	`{"national_id":"1234567890121","nickname":"Chai"}`,
+	`{"national_id":"1234567890121","first_name_en":"Somchai","date_of_birth":"1990-03-12"}`,
+	`not json`,
+}, "\n")
+
+func TestImportService_ImportPatients_NDJSON(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	jobRepo := &MockImportJobRepository{Patients: patientRepo}
+	service := NewImportService(jobRepo, nil, newImportRegistry(t), 2)
+
+	existing := &entity.Patient{ID: uuid.New(), NationalID: "3100701443816", FirstNameEN: "Malee"}
+	patientRepo.On("GetByIdentifiers", "1234567890121", "").Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("GetByIdentifiers", "3100701443816", "").Return(existing, nil)
+	patientRepo.On("Create", mock.MatchedBy(func(patient *entity.Patient) bool {
+		return patient.FirstNameEN == "Somchai" && patient.Hospitals[0].Source == entity.PatientSourceImport
+	})).Return(nil).Once()
+	patientRepo.On("Update", existing).Return(nil)
+	patientRepo.On("SaveHospitalLink", mock.MatchedBy(func(link *entity.PatientHospital) bool {
+		return link.Hospital == "hospital-a" && link.PatientHN == "HN2" && link.Source == entity.PatientSourceImport
+	})).Return(nil)
+	jobRepo.On("Create", mock.MatchedBy(func(job *entity.ImportJob) bool {
+		return job.Hospital == "hospital-a" && job.Status == entity.ImportJobRunning && job.StartedBy == "staff:1"
+	})).Return(nil)
+	for _, checkpoint := range []int{0, 3, 5, 7} {
+		jobRepo.On("SaveBatch", mock.Anything, checkpoint).Return(nil).Once()
+	}
+	jobRepo.On("ListErrors", mock.Anything, maxReportErrors).Return(nil)
+
+	report, err := service.ImportPatients(context.Background(), ImportRequest{
+		Hospital:  "hospital-a",
+		Format:    ImportFormatNDJSON,
+		StartedBy: "staff:1",
+	}, strings.NewReader(importInput))
+
+	assert.NoError(t, err)
+	job := report.Job
+	assert.Equal(t, entity.ImportJobCompleted, job.Status)
+	assert.Equal(t, 7, job.Checkpoint)
+	assert.Equal(t, []int{1, 1, 1, 3}, []int{job.Created, job.Updated, job.Duplicates, job.Failed})
+	assert.Equal(t, "Srisuk", existing.LastNameEN)
+
+	assert.Len(t, report.Errors, 4)
+	assert.Equal(t, 4, report.Errors[0].Line)
+	assert.Contains(t, report.Errors[0].Error, "national_id has an invalid check digit")
+	assert.Contains(t, report.Errors[1].Error, `unknown field "nickname"`)
+	assert.Equal(t, &entity.ImportJobError{JobID: job.ID, Line: 6, Kind: entity.ImportErrorDuplicate, Error: "national_id repeats line 1"}, report.Errors[2])
+	assert.Equal(t, entity.ImportErrorInvalid, report.Errors[3].Kind)
+	patientRepo.AssertExpectations(t)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestImportService_ImportPatients_CSV(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	jobRepo := &MockImportJobRepository{Patients: patientRepo}
+	service := NewImportService(jobRepo, nil, newImportRegistry(t), 500)
+
+	patientRepo.On("GetByIdentifiers", "1234567890121", "").Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("GetByIdentifiers", "", "AA1234567").Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("Create", mock.Anything).Return(nil)
+	jobRepo.On("Create", mock.Anything).Return(nil)
+	jobRepo.On("SaveBatch", mock.Anything, 0).Return(nil)
+	jobRepo.On("ListErrors", mock.Anything, maxReportErrors).Return(nil)
+
+	input := "national_id,passport_id,first_name_en,date_of_birth,phone_number\n" +
+		"1234567890121,,Somchai,12/03/1990,081-234-5678\n" +
+		",AA1234567,John,1990-01-01,\n" +
+		"3100701443816,,Somchai,1990-13-01,\n"
+
+	report, err := service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-a", Format: ImportFormatCSV}, strings.NewReader(input))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Job.Created)
+	assert.Equal(t, 4, report.Job.Checkpoint)
+	assert.Equal(t, `date_of_birth "1990-13-01" is not a date in a supported format`, report.Errors[0].Error)
+	assert.Equal(t, 4, report.Errors[0].Line)
+	patientRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+func TestImportService_ImportPatients_MissingIdentifiers(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	jobRepo := &MockImportJobRepository{Patients: patientRepo}
+	service := NewImportService(jobRepo, nil, newImportRegistry(t), 500)
+
+	var created []*entity.Patient
+	patientRepo.On("GetByIdentifiers", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		created = append(created, args.Get(0).(*entity.Patient))
+	}).Return(nil)
+	jobRepo.On("Create", mock.Anything).Return(nil)
+	jobRepo.On("SaveBatch", mock.Anything, 0).Return(nil)
+	jobRepo.On("ListErrors", mock.Anything, maxReportErrors).Return(nil)
+
+	input := "national_id,passport_id,first_name_en,date_of_birth\n" +
+		"1234567890121,,Somchai,1990-03-12\n" +
+		"3100701443816,,Malee,1985-06-01\n" +
+		",AA1234567,John,1990-01-01\n" +
+		",BB7654321,Jane,1991-01-01\n"
+
+	report, err := service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-a", Format: ImportFormatCSV}, strings.NewReader(input))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Job.Created)
+	assert.Zero(t, report.Job.Failed)
+	assert.Empty(t, report.Errors)
+	// Missing identifiers are stored empty, which the partial unique indexes
+	// of tbl_patients leave unconstrained.
+	require.Len(t, created, 4)
+	assert.Equal(t, []string{"", ""}, []string{created[0].PassportID, created[1].PassportID})
+	assert.Equal(t, []string{"", ""}, []string{created[2].NationalID, created[3].NationalID})
+}
+
+func TestImportService_ImportPatients_BatchFails(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	jobRepo := &MockImportJobRepository{Patients: patientRepo}
+	service := NewImportService(jobRepo, nil, newImportRegistry(t), 2)
+
+	patientRepo.On("GetByIdentifiers", mock.Anything, "").Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("Create", mock.Anything).Return(nil)
+	jobRepo.On("Create", mock.Anything).Return(nil)
+	jobRepo.On("SaveBatch", mock.Anything, 0).Return(nil)
+	jobRepo.On("SaveBatch", mock.Anything, 3).Return(errors.New("connection reset"))
+	jobRepo.On("Update", mock.MatchedBy(func(job *entity.ImportJob) bool {
+		return job.Status == entity.ImportJobFailed && job.Error == "connection reset" && job.Checkpoint == 3
+	})).Return(nil)
+	jobRepo.On("ListErrors", mock.Anything, maxReportErrors).Return(nil)
+
+	report, err := service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-a", Format: ImportFormatNDJSON}, strings.NewReader(importInput))
+
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 2, report.Job.Created)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestImportService_ResumeImport(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	jobRepo := &MockImportJobRepository{Patients: patientRepo}
+	service := NewImportService(jobRepo, nil, newImportRegistry(t), 500)
+
+	job := &entity.ImportJob{ID: uuid.New(), Hospital: "hospital-a", Format: ImportFormatNDJSON, Status: entity.ImportJobFailed, Checkpoint: 3, Created: 2, Error: "connection reset"}
+	completed := &entity.ImportJob{ID: uuid.New(), Hospital: "hospital-a", Status: entity.ImportJobCompleted}
+	jobRepo.On("GetByID", job.ID.String()).Return(job, nil)
+	jobRepo.On("GetByID", completed.ID.String()).Return(completed, nil)
+	jobRepo.On("Update", mock.MatchedBy(func(job *entity.ImportJob) bool {
+		return job.Status == entity.ImportJobRunning && job.Error == ""
+	})).Return(nil)
+	jobRepo.On("SaveBatch", mock.Anything, 3).Return(nil)
+	jobRepo.On("ListErrors", job.ID.String(), maxReportErrors).Return(nil)
+
+	report, err := service.ResumeImport(context.Background(), job.ID.String(), "hospital-a", strings.NewReader(importInput))
+
+	// Lines 1 to 3 were committed before; line 6 still repeats line 1.
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ImportJobCompleted, report.Job.Status)
+	assert.Equal(t, []int{2, 1, 3}, []int{report.Job.Created, report.Job.Duplicates, report.Job.Failed})
+	patientRepo.AssertNotCalled(t, "GetByIdentifiers", mock.Anything, mock.Anything)
+
+	_, err = service.ResumeImport(context.Background(), job.ID.String(), "hospital-b", strings.NewReader(importInput))
+	assert.ErrorIs(t, err, ErrImportJobNotFound)
+	_, err = service.ResumeImport(context.Background(), completed.ID.String(), "hospital-a", strings.NewReader(importInput))
+	assert.ErrorIs(t, err, ErrImportJobCompleted)
+	_, err = service.GetImportJob("not-a-uuid", "hospital-a")
+	assert.ErrorIs(t, err, ErrImportJobNotFound)
+}
+
+func TestImportService_ImportPatients_Rejected(t *testing.T) {
+	service := NewImportService(new(MockImportJobRepository), nil, newImportRegistry(t), 500)
+
+	_, err := service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-z", Format: ImportFormatCSV}, strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnsupportedHospital)
+
+	_, err = service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-a", Format: "xml"}, strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrImportFormat)
+
+	_, err = service.ImportPatients(context.Background(), ImportRequest{Hospital: "hospital-a", Format: ImportFormatCSV}, strings.NewReader("national_id,nickname\n"))
+	assert.ErrorIs(t, err, ErrImportFormat)
+	assert.ErrorContains(t, err, `unknown field "nickname"`)
+}
+
+func TestImportService_QueueImport(t *testing.T) {
+	importRepo := new(MockImportJobRepository)
+	jobRepo := new(MockJobRepository)
+	jobService := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service := NewImportService(importRepo, jobService, newImportRegistry(t), 500)
+	jobService.Register(JobKindImport, NewImportJob(service, jobService))
+
+	input := "national_id,first_name_en,date_of_birth\n1234567890121,Somchai,1990-03-12\n"
+	importRepo.On("Create", mock.MatchedBy(func(job *entity.ImportJob) bool {
+		return job.Status == entity.ImportJobQueued && job.Format == ImportFormatCSV && job.StartedBy == "staff:1"
+	})).Return(nil).Once()
+	jobRepo.On("Create", mock.MatchedBy(func(job *entity.Job) bool {
+		return job.Kind == JobKindImport && job.Hospital == "hospital-a" && job.CreatedBy == "staff:1" && strings.Contains(job.Payload, "import_job_id")
+	}), mock.MatchedBy(func(files []*entity.JobFile) bool {
+		return len(files) == 1 && string(files[0].Data) == input
+	})).Return(nil).Once()
+	importRepo.On("SetJobID", mock.Anything, mock.Anything).Return(nil).Once()
+	importRepo.On("ListErrors", mock.Anything, maxReportErrors).Return(nil)
+
+	report, err := service.QueueImport(ImportRequest{Hospital: "hospital-a", Format: ImportFormatCSV, StartedBy: "staff:1"}, []byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, entity.ImportJobQueued, report.Job.Status)
+	require.NotNil(t, report.Job.JobID)
+	importRepo.AssertCalled(t, "SetJobID", report.Job.ID.String(), *report.Job.JobID)
+
+	// The header is checked before anything is queued.
+	_, err = service.QueueImport(ImportRequest{Hospital: "hospital-a", Format: ImportFormatCSV, StartedBy: "staff:1"}, []byte("national_id,nickname\n"))
+	assert.ErrorIs(t, err, ErrImportFormat)
+	importRepo.AssertExpectations(t)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestImportService_QueueResume(t *testing.T) {
+	importRepo := new(MockImportJobRepository)
+	jobRepo := new(MockJobRepository)
+	jobService := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service := NewImportService(importRepo, jobService, newImportRegistry(t), 500)
+
+	// The job of a failed attempt may still be waiting to retry it.
+	retrying := &entity.Job{ID: uuid.New(), Hospital: "hospital-a", Status: entity.JobQueued}
+	job := &entity.ImportJob{ID: uuid.New(), Hospital: "hospital-a", Format: ImportFormatNDJSON, Status: entity.ImportJobFailed, JobID: &retrying.ID}
+	importRepo.On("GetByID", job.ID.String()).Return(job, nil)
+	jobRepo.On("GetByID", retrying.ID.String()).Return(retrying, nil)
+
+	_, err := service.QueueResume(job.ID.String(), "hospital-a", "staff:1", []byte(importInput))
+	assert.ErrorIs(t, err, ErrImportJobQueued)
+	importRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestNewImportJob(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	importRepo := &MockImportJobRepository{Patients: patientRepo}
+	jobRepo := new(MockJobRepository)
+	jobService := NewJobService(jobRepo, time.Minute, 3, time.Second, time.Hour)
+	service := NewImportService(importRepo, jobService, newImportRegistry(t), 500)
+	run := NewImportJob(service, jobService)
+
+	// The first attempt committed lines 1 to 3 before it failed.
+	imported := &entity.ImportJob{ID: uuid.New(), Hospital: "hospital-a", Format: ImportFormatNDJSON, Status: entity.ImportJobFailed, Checkpoint: 3, Created: 2}
+	job := newRunningJob(JobKindImport, 2)
+	job.Payload = `{"import_job_id":"` + imported.ID.String() + `"}`
+	jobRepo.On("GetFile", job.ID.String(), entity.JobFileInput).Return(&entity.JobFile{Data: []byte(importInput)}, nil)
+	importRepo.On("GetByID", imported.ID.String()).Return(imported, nil)
+	importRepo.On("Update", mock.Anything).Return(nil)
+	importRepo.On("SaveBatch", mock.Anything, 3).Return(nil)
+	importRepo.On("ListErrors", imported.ID.String(), maxReportErrors).Return(nil)
+
+	result, err := run(context.Background(), job)
+	require.NoError(t, err)
+	assert.Equal(t, &ImportJobResult{ImportJobID: imported.ID, Created: 2, Duplicates: 1, Failed: 3}, result)
+
+	// An import the job cannot run fails it without retries.
+	job.Hospital = "hospital-b"
+	_, err = run(context.Background(), job)
+	assert.ErrorIs(t, err, ErrJobPermanent)
+	assert.ErrorIs(t, err, ErrImportJobNotFound)
+}
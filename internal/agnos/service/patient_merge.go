@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMergeSamePatient       = NewError(ErrValidation, "merge_same_patient", "survivor and retired patient are the same")
+	ErrMergeConflict          = NewError(ErrConflict, "merge_conflict", "patients have different national IDs and cannot be merged")
+	ErrInvalidMergeField      = NewError(ErrValidation, "invalid_merge_field", "invalid survivorship field")
+	ErrDuplicateNotFound      = NewError(ErrNotFound, "duplicate_not_found", "duplicate candidate not found")
+	ErrDuplicateAlreadyClosed = NewError(ErrConflict, "duplicate_already_reviewed", "duplicate candidate already reviewed")
+)
+
+// MergeSide names which patient a merged column was taken from.
+type MergeSide string
+
+const (
+	MergeSurvivor MergeSide = "survivor"
+	MergeRetired  MergeSide = "retired"
+)
+
+type MergeRequest struct {
+	SurvivorID string
+	RetiredID  string
+	// Prefer overrides the default survivorship for individual columns.
+	Prefer   map[string]MergeSide
+	Reason   string
+	MergedBy string
+	// Hospital is the merging staff member's hospital; both patients must be
+	// linked to it.
+	Hospital string
+}
+
+type MergeResult struct {
+	Patient *entity.Patient
+	Merge   *entity.PatientMerge
+}
+
+type MergeService interface {
+	MergePatients(req MergeRequest) (*MergeResult, error)
+	ListMerges(survivorID string) ([]*entity.PatientMerge, error)
+	DetectDuplicates(ctx context.Context, batchSize int) (scored, flagged int, err error)
+	ListDuplicates(hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error)
+	DismissDuplicate(id, hospital, reviewedBy string) error
+}
+
+type mergeService struct {
+	patientRepository      repository.PatientRepository
+	patientMergeRepository repository.PatientMergeRepository
+	threshold              float64
+}
+
+func NewMergeService(
+	patientRepository repository.PatientRepository,
+	patientMergeRepository repository.PatientMergeRepository,
+	threshold float64,
+) MergeService {
+	return &mergeService{
+		patientRepository:      patientRepository,
+		patientMergeRepository: patientMergeRepository,
+		threshold:              threshold,
+	}
+}
+
+func (s *mergeService) MergePatients(req MergeRequest) (*MergeResult, error) {
+	if req.SurvivorID == req.RetiredID {
+		return nil, ErrMergeSamePatient
+	}
+	for column, side := range req.Prefer {
+		if _, ok := mergeColumns[column]; !ok || (side != MergeSurvivor && side != MergeRetired) {
+			return nil, fmt.Errorf("%w: %s=%s", ErrInvalidMergeField, column, side)
+		}
+	}
+
+	survivor, err := s.getPatient(req.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+	retired, err := s.getPatient(req.RetiredID)
+	if err != nil {
+		return nil, err
+	}
+	// Patients of other hospitals are reported as unknown, as GetHospitalPatient does.
+	for _, patient := range []*entity.Patient{survivor, retired} {
+		if !linkedTo(patient, req.Hospital) {
+			return nil, fmt.Errorf("%w: %s", ErrPatientNotFound, patient.ID)
+		}
+	}
+
+	merge, err := mergePatientRecords(s.patientMergeRepository, survivor, retired, req.Prefer, req.MergedBy, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload so the survivor carries the hospital links it took over.
+	patient, err := s.patientRepository.GetByID(survivor.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	return &MergeResult{Patient: patient, Merge: merge}, nil
+}
+
+func (s *mergeService) getPatient(id string) (*entity.Patient, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPatientNotFound, id)
+	}
+	patient, err := s.patientRepository.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrPatientNotFound, id)
+	}
+	if err == nil && patient.ErasedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPatientErased, id)
+	}
+	return patient, err
+}
+
+func (s *mergeService) ListMerges(survivorID string) ([]*entity.PatientMerge, error) {
+	return s.patientMergeRepository.ListBySurvivorID(survivorID)
+}
+
+// DetectDuplicates scores one batch of unscored candidate pairs. Pairs at or
+// above the threshold are queued for review; the rest are kept as unlikely so
+// they are not scored again.
+func (s *mergeService) DetectDuplicates(ctx context.Context, batchSize int) (int, int, error) {
+	candidates, err := s.patientMergeRepository.FindUnscoredPairs(batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	flagged := 0
+	scored := make([]*entity.DuplicateCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		if candidate.PatientA == nil || candidate.PatientB == nil {
+			continue
+		}
+
+		score, reasons := ScoreDuplicate(candidate.PatientA, candidate.PatientB)
+		candidate.Score = score
+		candidate.Reasons = strings.Join(reasons, ",")
+		candidate.DetectedAt = now
+		candidate.Status = entity.DuplicateStatusUnlikely
+		if score >= s.threshold {
+			candidate.Status = entity.DuplicateStatusPending
+			flagged++
+		}
+		scored = append(scored, candidate)
+	}
+
+	if err := s.patientMergeRepository.SaveCandidates(scored); err != nil {
+		return 0, 0, err
+	}
+	return len(scored), flagged, nil
+}
+
+// ListDuplicates returns hospital's review queue: pending pairs of patients
+// both linked to hospital scoring at least minScore, highest first. A zero
+// minScore uses the detection threshold.
+func (s *mergeService) ListDuplicates(hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error) {
+	if minScore <= 0 {
+		minScore = s.threshold
+	}
+	candidates, err := s.patientMergeRepository.ListCandidates(entity.DuplicateStatusPending, hospital, minScore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip pairs whose patients were deleted since they were scored.
+	queue := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.PatientA != nil && candidate.PatientB != nil {
+			queue = append(queue, candidate)
+		}
+	}
+	return queue, nil
+}
+
+// DismissDuplicate closes a pair of hospital's review queue, see ListDuplicates.
+func (s *mergeService) DismissDuplicate(id, hospital, reviewedBy string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrDuplicateNotFound
+	}
+	candidate, err := s.patientMergeRepository.GetCandidate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDuplicateNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if candidate.PatientA == nil || candidate.PatientB == nil ||
+		!linkedTo(candidate.PatientA, hospital) || !linkedTo(candidate.PatientB, hospital) {
+		return ErrDuplicateNotFound
+	}
+	if candidate.Status != entity.DuplicateStatusPending && candidate.Status != entity.DuplicateStatusUnlikely {
+		return ErrDuplicateAlreadyClosed
+	}
+	return s.patientMergeRepository.ReviewCandidate(id, entity.DuplicateStatusDismissed, reviewedBy)
+}
+
+// mergeColumns are the patient columns subject to survivorship.
+var mergeColumns = map[string]func(*entity.Patient) *string{
+	"first_name_th":  func(p *entity.Patient) *string { return &p.FirstNameTH },
+	"middle_name_th": func(p *entity.Patient) *string { return &p.MiddleNameTH },
+	"last_name_th":   func(p *entity.Patient) *string { return &p.LastNameTH },
+	"first_name_en":  func(p *entity.Patient) *string { return &p.FirstNameEN },
+	"middle_name_en": func(p *entity.Patient) *string { return &p.MiddleNameEN },
+	"last_name_en":   func(p *entity.Patient) *string { return &p.LastNameEN },
+	"patient_hn":     func(p *entity.Patient) *string { return &p.PatientHN },
+	"national_id":    func(p *entity.Patient) *string { return &p.NationalID },
+	"passport_id":    func(p *entity.Patient) *string { return &p.PassportID },
+	"phone_number":   func(p *entity.Patient) *string { return &p.PhoneNumber },
+	"email":          func(p *entity.Patient) *string { return &p.Email },
+	"gender":         func(p *entity.Patient) *string { return &p.Gender },
+	"date_of_birth":  nil,
+}
+
+// applySurvivorship merges retired into survivor in place. By default the
+// survivor's values are kept and only its empty columns are filled from the
+// retired patient; prefer overrides this per column. It returns where each
+// column's value came from.
+func applySurvivorship(survivor, retired *entity.Patient, prefer map[string]MergeSide) (map[string]MergeSide, error) {
+	if survivor.NationalID != "" && retired.NationalID != "" && survivor.NationalID != retired.NationalID {
+		return nil, ErrMergeConflict
+	}
+
+	survivorship := make(map[string]MergeSide, len(mergeColumns))
+	for column, field := range mergeColumns {
+		side := MergeSurvivor
+		var survivorEmpty, retiredEmpty bool
+		if field == nil {
+			survivorEmpty, retiredEmpty = survivor.DateOfBirth.IsZero(), retired.DateOfBirth.IsZero()
+		} else {
+			survivorEmpty, retiredEmpty = *field(survivor) == "", *field(retired) == ""
+		}
+		if (survivorEmpty || prefer[column] == MergeRetired) && !retiredEmpty {
+			side = MergeRetired
+		}
+
+		if side == MergeRetired {
+			if field == nil {
+				survivor.DateOfBirth = retired.DateOfBirth
+			} else {
+				*field(survivor) = *field(retired)
+			}
+		}
+		survivorship[column] = side
+	}
+	return survivorship, nil
+}
+
+// mergePatientRecords applies survivorship and retires retired into survivor,
+// recording the merge. It is shared by staff merges and hospital-pushed merges.
+func mergePatientRecords(
+	mergeRepository repository.PatientMergeRepository,
+	survivor, retired *entity.Patient,
+	prefer map[string]MergeSide,
+	mergedBy, reason string,
+) (*entity.PatientMerge, error) {
+	retiredRecord, err := json.Marshal(patientRecord(retired))
+	if err != nil {
+		return nil, err
+	}
+
+	survivorship, err := applySurvivorship(survivor, retired, prefer)
+	if err != nil {
+		return nil, err
+	}
+	survivorshipJSON, err := json.Marshal(survivorship)
+	if err != nil {
+		return nil, err
+	}
+
+	merge := &entity.PatientMerge{
+		SurvivorID:    survivor.ID,
+		RetiredID:     retired.ID,
+		RetiredRecord: string(retiredRecord),
+		Survivorship:  string(survivorshipJSON),
+		MergedBy:      mergedBy,
+		Reason:        reason,
+		MergedAt:      time.Now(),
+	}
+	if err := mergeRepository.Merge(survivor, retired.ID.String(), merge); err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// patientRecord snapshots the merge columns of patient keyed by column name.
+func patientRecord(patient *entity.Patient) map[string]string {
+	record := map[string]string{"id": patient.ID.String()}
+	for column, field := range mergeColumns {
+		if field == nil {
+			if !patient.DateOfBirth.IsZero() {
+				record[column] = patient.DateOfBirth.Format("2006-01-02")
+			}
+			continue
+		}
+		if value := *field(patient); value != "" {
+			record[column] = value
+		}
+	}
+	return record
+}
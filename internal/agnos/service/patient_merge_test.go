@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/google/uuid"
+)
+
+// MockPatientMergeRepository is a mock implementation of PatientMergeRepository
+type MockPatientMergeRepository struct {
+	mock.Mock
+}
+
+func (m *MockPatientMergeRepository) Merge(survivor *entity.Patient, retiredID string, merge *entity.PatientMerge) error {
+	args := m.Called(survivor, retiredID, merge)
+	return args.Error(0)
+}
+
+func (m *MockPatientMergeRepository) GetByRetiredID(retiredID string) (*entity.PatientMerge, error) {
+	args := m.Called(retiredID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.PatientMerge), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) ListBySurvivorID(survivorID string) ([]*entity.PatientMerge, error) {
+	args := m.Called(survivorID)
+	return args.Get(0).([]*entity.PatientMerge), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) FindUnscoredPairs(limit int) ([]*entity.DuplicateCandidate, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]*entity.DuplicateCandidate), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) SaveCandidates(candidates []*entity.DuplicateCandidate) error {
+	args := m.Called(candidates)
+	return args.Error(0)
+}
+
+func (m *MockPatientMergeRepository) ListCandidates(status, hospital string, minScore float64, limit int) ([]*entity.DuplicateCandidate, error) {
+	args := m.Called(status, hospital, minScore, limit)
+	return args.Get(0).([]*entity.DuplicateCandidate), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) GetCandidate(id string) (*entity.DuplicateCandidate, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.DuplicateCandidate), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) ReviewCandidate(id, status, reviewedBy string) error {
+	args := m.Called(id, status, reviewedBy)
+	return args.Error(0)
+}
+
+func TestScoreDuplicate(t *testing.T) {
+	dob := time.Date(1990, 3, 12, 0, 0, 0, 0, time.UTC)
+	base := entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob, PhoneNumber: "081-234-5678", Gender: "M"}
+
+	tests := []struct {
+		name     string
+		other    entity.Patient
+		minScore float64
+		maxScore float64
+		reason   string
+	}{
+		{
+			name:     "same person with typo and formatted phone",
+			other:    entity.Patient{FirstNameEN: "Somchay", LastNameEN: "Jaidee", DateOfBirth: dob, PhoneNumber: "+66812345678", Gender: "M"},
+			minScore: 0.85,
+			maxScore: 1,
+			reason:   "phone_number",
+		},
+		{
+			name:     "transposed date of birth",
+			other:    entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: time.Date(1990, 12, 3, 0, 0, 0, 0, time.UTC)},
+			minScore: 0.6,
+			maxScore: 0.65,
+			reason:   "date_of_birth_transposed",
+		},
+		{
+			name:     "different national IDs",
+			other:    entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob, NationalID: "1234567890121"},
+			minScore: 0,
+			maxScore: 0,
+			reason:   "national_id_mismatch",
+		},
+		{
+			name:     "gender mismatch",
+			other:    entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob, Gender: "F"},
+			minScore: 0.6,
+			maxScore: 0.6,
+			reason:   "gender_mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := base
+			if tt.other.NationalID != "" {
+				a.NationalID = "3100701443816"
+			}
+			score, reasons := ScoreDuplicate(&a, &tt.other)
+			assert.GreaterOrEqual(t, score, tt.minScore)
+			assert.LessOrEqual(t, score, tt.maxScore+1e-9)
+			assert.Contains(t, reasons, tt.reason)
+		})
+	}
+}
+
+func TestApplySurvivorship(t *testing.T) {
+	survivor := &entity.Patient{FirstNameEN: "Somchai", PhoneNumber: "0811111111", NationalID: "1234567890121"}
+	retired := &entity.Patient{FirstNameEN: "Somchay", PhoneNumber: "0822222222", Email: "somchai@example.com", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	survivorship, err := applySurvivorship(survivor, retired, map[string]MergeSide{"phone_number": MergeRetired})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Somchai", survivor.FirstNameEN)
+	assert.Equal(t, "0822222222", survivor.PhoneNumber)
+	assert.Equal(t, "somchai@example.com", survivor.Email)
+	assert.Equal(t, retired.DateOfBirth, survivor.DateOfBirth)
+	assert.Equal(t, MergeSurvivor, survivorship["first_name_en"])
+	assert.Equal(t, MergeRetired, survivorship["phone_number"])
+	assert.Equal(t, MergeRetired, survivorship["email"])
+	assert.Equal(t, MergeRetired, survivorship["date_of_birth"])
+}
+
+func TestApplySurvivorship_NationalIDConflict(t *testing.T) {
+	survivor := &entity.Patient{NationalID: "1234567890121"}
+	retired := &entity.Patient{NationalID: "3100701443816"}
+
+	_, err := applySurvivorship(survivor, retired, nil)
+
+	assert.ErrorIs(t, err, ErrMergeConflict)
+}
+
+func TestMergeService_MergePatients(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	mergeRepo := new(MockPatientMergeRepository)
+	service := NewMergeService(patientRepo, mergeRepo, 0.75)
+
+	linked := []entity.PatientHospital{{Hospital: "hospital-a"}}
+	survivor := &entity.Patient{ID: uuid.New(), FirstNameEN: "Somchai", Hospitals: linked}
+	retired := &entity.Patient{ID: uuid.New(), FirstNameEN: "Somchay", Email: "somchai@example.com", Hospitals: linked}
+	patientRepo.On("GetByID", survivor.ID.String()).Return(survivor, nil)
+	patientRepo.On("GetByID", retired.ID.String()).Return(retired, nil)
+	mergeRepo.On("Merge", survivor, retired.ID.String(), mock.AnythingOfType("*entity.PatientMerge")).Return(nil)
+
+	result, err := service.MergePatients(MergeRequest{
+		SurvivorID: survivor.ID.String(),
+		RetiredID:  retired.ID.String(),
+		Reason:     "registered twice",
+		MergedBy:   "staff:1",
+		Hospital:   "hospital-a",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "somchai@example.com", result.Patient.Email)
+	assert.Equal(t, retired.ID, result.Merge.RetiredID)
+	assert.Equal(t, "staff:1", result.Merge.MergedBy)
+
+	var record map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(result.Merge.RetiredRecord), &record))
+	assert.Equal(t, "Somchay", record["first_name_en"])
+	mergeRepo.AssertExpectations(t)
+}
+
+func TestMergeService_MergePatients_OtherHospital(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	mergeRepo := new(MockPatientMergeRepository)
+	service := NewMergeService(patientRepo, mergeRepo, 0.75)
+
+	survivor := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}}}
+	retired := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-b"}}}
+	patientRepo.On("GetByID", survivor.ID.String()).Return(survivor, nil)
+	patientRepo.On("GetByID", retired.ID.String()).Return(retired, nil)
+
+	_, err := service.MergePatients(MergeRequest{SurvivorID: survivor.ID.String(), RetiredID: retired.ID.String(), Hospital: "hospital-a"})
+
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+	mergeRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMergeService_MergePatients_InvalidRequest(t *testing.T) {
+	service := NewMergeService(new(MockPatientRepository), new(MockPatientMergeRepository), 0.75)
+	id := uuid.New().String()
+
+	_, err := service.MergePatients(MergeRequest{SurvivorID: id, RetiredID: id})
+	assert.ErrorIs(t, err, ErrMergeSamePatient)
+
+	_, err = service.MergePatients(MergeRequest{SurvivorID: id, RetiredID: uuid.New().String(), Prefer: map[string]MergeSide{"id": MergeRetired}})
+	assert.ErrorIs(t, err, ErrInvalidMergeField)
+}
+
+func TestMergeService_DetectDuplicates(t *testing.T) {
+	mergeRepo := new(MockPatientMergeRepository)
+	service := NewMergeService(new(MockPatientRepository), mergeRepo, 0.75)
+
+	dob := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	likely := &entity.DuplicateCandidate{
+		PatientA: &entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob},
+		PatientB: &entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob},
+	}
+	unlikely := &entity.DuplicateCandidate{
+		PatientA: &entity.Patient{FirstNameEN: "Somchai", LastNameEN: "Jaidee", DateOfBirth: dob},
+		PatientB: &entity.Patient{FirstNameEN: "Malee", LastNameEN: "Srisuk", DateOfBirth: dob},
+	}
+	mergeRepo.On("FindUnscoredPairs", 100).Return([]*entity.DuplicateCandidate{likely, unlikely}, nil)
+	mergeRepo.On("SaveCandidates", mock.Anything).Return(nil)
+
+	scored, flagged, err := service.DetectDuplicates(context.Background(), 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, scored)
+	assert.Equal(t, 1, flagged)
+	assert.Equal(t, entity.DuplicateStatusPending, likely.Status)
+	assert.Equal(t, entity.DuplicateStatusUnlikely, unlikely.Status)
+	mergeRepo.AssertExpectations(t)
+}
+
+func TestMergeService_DismissDuplicate(t *testing.T) {
+	mergeRepo := new(MockPatientMergeRepository)
+	service := NewMergeService(new(MockPatientRepository), mergeRepo, 0.75)
+
+	linked := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}}}
+	other := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-b"}}}
+	pending := uuid.New().String()
+	merged := uuid.New().String()
+	missing := uuid.New().String()
+	foreign := uuid.New().String()
+	mergeRepo.On("GetCandidate", pending).Return(&entity.DuplicateCandidate{Status: entity.DuplicateStatusPending, PatientA: linked, PatientB: linked}, nil)
+	mergeRepo.On("GetCandidate", merged).Return(&entity.DuplicateCandidate{Status: entity.DuplicateStatusMerged, PatientA: linked, PatientB: linked}, nil)
+	mergeRepo.On("GetCandidate", missing).Return(nil, gorm.ErrRecordNotFound)
+	mergeRepo.On("GetCandidate", foreign).Return(&entity.DuplicateCandidate{Status: entity.DuplicateStatusPending, PatientA: linked, PatientB: other}, nil)
+	mergeRepo.On("ReviewCandidate", pending, entity.DuplicateStatusDismissed, "staff:1").Return(nil)
+
+	assert.NoError(t, service.DismissDuplicate(pending, "hospital-a", "staff:1"))
+	assert.ErrorIs(t, service.DismissDuplicate(merged, "hospital-a", "staff:1"), ErrDuplicateAlreadyClosed)
+	assert.ErrorIs(t, service.DismissDuplicate(missing, "hospital-a", "staff:1"), ErrDuplicateNotFound)
+	assert.ErrorIs(t, service.DismissDuplicate(foreign, "hospital-a", "staff:1"), ErrDuplicateNotFound)
+	mergeRepo.AssertExpectations(t)
+}
+
+func TestPatientService_GetPatient_FollowsMerge(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	mergeRepo := new(MockPatientMergeRepository)
+	service := NewPatientService(patientRepo, mergeRepo, new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	survivor := &entity.Patient{ID: uuid.New()}
+	retiredID := uuid.New().String()
+	unknownID := uuid.New().String()
+	patientRepo.On("GetByID", retiredID).Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("GetByID", unknownID).Return(nil, gorm.ErrRecordNotFound)
+	patientRepo.On("GetByID", survivor.ID.String()).Return(survivor, nil)
+	mergeRepo.On("GetByRetiredID", retiredID).Return(&entity.PatientMerge{SurvivorID: survivor.ID}, nil)
+	mergeRepo.On("GetByRetiredID", unknownID).Return(nil, gorm.ErrRecordNotFound)
+
+	patient, err := service.GetPatient(retiredID)
+	assert.NoError(t, err)
+	assert.Equal(t, survivor.ID, patient.ID)
+
+	_, err = service.GetPatient(unknownID)
+	assert.True(t, errors.Is(err, ErrPatientNotFound))
+}
+
+func TestPatientService_GetHospitalPatient(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	service := NewPatientService(patientRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	patient := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}}}
+	patientRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+
+	found, err := service.GetHospitalPatient(patient.ID.String(), "hospital-a")
+	assert.NoError(t, err)
+	assert.Equal(t, patient.ID, found.ID)
+
+	_, err = service.GetHospitalPatient(patient.ID.String(), "hospital-b")
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+)
+
+// JobKindSync re-fetches a hospital's stale patients from its API as a
+// background job, see NewSyncJob. The sync worker enqueues one per hospital
+// every SYNC_INTERVAL.
+const JobKindSync = "sync"
+
+type SyncResult struct {
+	Patient *entity.Patient
+	// Changed lists the patient columns updated from the hospital record.
+	Changed []string
+}
+
+// SyncReport counts what a sync job did; it is the job's result, hence the
+// JSON tags.
+type SyncReport struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+// syncJobPayload is the payload of JobKindSync jobs.
+type syncJobPayload struct {
+	Limit int `json:"limit"`
+}
+
+// NewSyncJob runs JobKindSync jobs: up to the payload's limit, batchSize by
+// default, of the job hospital's stale patients are synced within the
+// hospital's rate limit, with a SyncReport as their result. Patients that
+// fail to sync are counted rather than failing the job.
+func NewSyncJob(patientService PatientService, hospitals *hospital.Registry, batchSize int) JobHandler {
+	return func(ctx context.Context, job *entity.Job) (any, error) {
+		payload := syncJobPayload{Limit: batchSize}
+		if err := decodeJobPayload(job, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Limit <= 0 {
+			return nil, fmt.Errorf("%w: invalid payload: limit must be positive", ErrJobPermanent)
+		}
+		config, ok := hospitals.Config(job.Hospital)
+		if !ok {
+			return nil, fmt.Errorf("%w: %w: %s", ErrJobPermanent, ErrUnsupportedHospital, job.Hospital)
+		}
+		limit := rate.Limit(config.RateLimit)
+		if config.RateLimit <= 0 {
+			limit = 1
+		}
+		limiter := rate.NewLimiter(limit, 1)
+
+		patients, err := patientService.ListStalePatients(job.Hospital, payload.Limit)
+		if err != nil {
+			return nil, err
+		}
+		report := &SyncReport{}
+		for _, patient := range patients {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			result, err := patientService.SyncPatient(ctx, patient, job.Hospital)
+			report.Checked++
+			switch {
+			case err != nil:
+				report.Failed++
+				slog.WarnContext(ctx, "sync patient failed", "job_id", job.ID, "patient_id", patient.ID, "error", err)
+			case len(result.Changed) > 0:
+				report.Updated++
+			}
+		}
+		if report.Checked > 0 {
+			slog.InfoContext(ctx, "sync", "job_id", job.ID, "hospital", job.Hospital,
+				"checked", report.Checked, "updated", report.Updated, "failed", report.Failed)
+		}
+		return report, nil
+	}
+}
+
+// RefreshPatient syncs a patient linked to staffHospital from its API. Other
+// patients are reported as ErrPatientNotFound.
+func (s *patientService) RefreshPatient(ctx context.Context, id, staffHospital string) (*SyncResult, error) {
+	patient, err := s.GetHospitalPatient(id, staffHospital)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.SyncPatient(ctx, patient, staffHospital)
+}
+
+func (s *patientService) ListStalePatients(hospital string, limit int) ([]*entity.Patient, error) {
+	return s.patientRepository.ListStale(hospital, time.Now().Add(-s.staleAfter), limit)
+}
+
+// SyncPatient re-fetches patient from the hospital's API, applies any changed
+// demographics locally and records the attempt on the patient's hospital link.
+// A patient not linked to the hospital is reported as ErrPatientNotFound: a
+// link is only ever added from a record the hospital sent.
+func (s *patientService) SyncPatient(ctx context.Context, patient *entity.Patient, hospitalName string) (*SyncResult, error) {
+	id := patient.NationalID
+	if id == "" {
+		id = patient.PassportID
+	}
+	if id == "" {
+		return nil, fmt.Errorf("patient %s has no national_id or passport_id to sync by", patient.ID)
+	}
+
+	adapter, err := s.hospitals.Adapter(hospitalName)
+	if err != nil {
+		return nil, err
+	}
+
+	link := findHospitalLink(patient, hospitalName)
+	if link == nil {
+		return nil, ErrPatientNotFound
+	}
+	now := time.Now()
+	link.LastAttemptAt = &now
+
+	remote, err := adapter.FetchPatient(ctx, id)
+	if err != nil {
+		link.SyncError = err.Error()
+		_, quarantineErr := quarantineInvalidRecord(s.quarantineRepository, s.hospitals, hospitalName, entity.PatientSourceAPI, id, err)
+		saveErr := s.patientRepository.SaveHospitalLink(link)
+		return nil, errors.Join(err, quarantineErr, saveErr)
+	}
+
+	changed := applyHospitalRecord(patient, remote)
+	if len(changed) > 0 {
+		if err := s.patientRepository.Update(patient); err != nil {
+			return nil, err
+		}
+	}
+
+	link.PatientHN = remote.PatientHN
+	link.LastSyncedAt = &now
+	link.SyncError = ""
+	if err := s.patientRepository.SaveHospitalLink(link); err != nil {
+		return nil, err
+	}
+
+	s.markStale(patient)
+	return &SyncResult{Patient: patient, Changed: changed}, nil
+}
+
+// hospitalLink returns the patient's link to hospital, adding one from source
+// if the patient has not been associated with it yet.
+func hospitalLink(patient *entity.Patient, hospital, source string) *entity.PatientHospital {
+	if link := findHospitalLink(patient, hospital); link != nil {
+		return link
+	}
+	patient.Hospitals = append(patient.Hospitals, entity.PatientHospital{
+		PatientID: patient.ID,
+		Hospital:  hospital,
+		Source:    source,
+	})
+	return &patient.Hospitals[len(patient.Hospitals)-1]
+}
+
+// findHospitalLink returns the patient's link to hospital, or nil.
+func findHospitalLink(patient *entity.Patient, hospital string) *entity.PatientHospital {
+	for i := range patient.Hospitals {
+		if patient.Hospitals[i].Hospital == hospital {
+			return &patient.Hospitals[i]
+		}
+	}
+	return nil
+}
+
+// applyHospitalRecord copies demographics from remote onto patient and returns
+// the names of the columns that changed. Identifiers are only filled in, never
+// cleared, since hospitals frequently omit the one they did not search by.
+func applyHospitalRecord(patient, remote *entity.Patient) []string {
+	var changed []string
+	set := func(column string, dst *string, src string) {
+		if *dst != src {
+			*dst = src
+			changed = append(changed, column)
+		}
+	}
+
+	set("first_name_th", &patient.FirstNameTH, remote.FirstNameTH)
+	set("middle_name_th", &patient.MiddleNameTH, remote.MiddleNameTH)
+	set("last_name_th", &patient.LastNameTH, remote.LastNameTH)
+	set("first_name_en", &patient.FirstNameEN, remote.FirstNameEN)
+	set("middle_name_en", &patient.MiddleNameEN, remote.MiddleNameEN)
+	set("last_name_en", &patient.LastNameEN, remote.LastNameEN)
+	set("patient_hn", &patient.PatientHN, remote.PatientHN)
+	set("phone_number", &patient.PhoneNumber, remote.PhoneNumber)
+	set("email", &patient.Email, remote.Email)
+	set("gender", &patient.Gender, remote.Gender)
+	if remote.NationalID != "" {
+		set("national_id", &patient.NationalID, remote.NationalID)
+	}
+	if remote.PassportID != "" {
+		set("passport_id", &patient.PassportID, remote.PassportID)
+	}
+	if !patient.DateOfBirth.Equal(remote.DateOfBirth) {
+		patient.DateOfBirth = remote.DateOfBirth
+		changed = append(changed, "date_of_birth")
+	}
+
+	return changed
+}
+
+// markStale flags hospital links whose last successful sync is older than staleAfter.
+func (s *patientService) markStale(patients ...*entity.Patient) {
+	cutoff := time.Now().Add(-s.staleAfter)
+	for _, patient := range patients {
+		for i := range patient.Hospitals {
+			link := &patient.Hospitals[i]
+			link.Stale = link.LastSyncedAt == nil || link.LastSyncedAt.Before(cutoff)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// MockPatientRepository is a mock implementation of PatientRepository
+type MockPatientRepository struct {
+	mock.Mock
+}
+
+func (m *MockPatientRepository) Create(patient *entity.Patient) error {
+	args := m.Called(patient)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) Update(patient *entity.Patient) error {
+	args := m.Called(patient)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) Search(ctx context.Context, hospital string, filters map[string]interface{}) ([]*entity.Patient, error) {
+	args := m.Called(hospital, filters)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) GetByID(id string) (*entity.Patient, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) GetByIdentifiers(nationalID, passportID string) (*entity.Patient, error) {
+	args := m.Called(nationalID, passportID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) DeleteHospitalLink(patientID, hospital string) error {
+	args := m.Called(patientID, hospital)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) SaveHospitalLink(link *entity.PatientHospital) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) ListStale(hospital string, attemptedBefore time.Time, limit int) ([]*entity.Patient, error) {
+	args := m.Called(hospital, attemptedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) EachByHospital(hospital string, batchSize int, fn func(patients []*entity.Patient) error) error {
+	args := m.Called(hospital, batchSize)
+	if patients, ok := args.Get(0).([]*entity.Patient); ok && len(patients) > 0 {
+		if err := fn(patients); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func newTestRegistry(t *testing.T) *hospital.Registry {
+	registry, err := hospital.NewRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestPatientService_SearchPatients_LocalHit(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	filters := map[string]interface{}{"national_id": "1234567890121"}
+	mockRepo.On("Search", "hospital-a", filters).Return([]*entity.Patient{{ID: uuid.New(), NationalID: "1234567890121"}}, nil)
+	lookups := testutil.ToFloat64(metrics.PatientLookups.WithLabelValues(metrics.LookupLocal))
+
+	result, err := service.SearchPatients(context.Background(), filters, "hospital-a")
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Patients, 1)
+	assert.Equal(t, []SearchSource{{Name: LocalSource, Status: SourceStatusFound, Count: 1}}, result.Sources)
+	assert.Empty(t, result.Warnings)
+	assert.Equal(t, lookups+1, testutil.ToFloat64(metrics.PatientLookups.WithLabelValues(metrics.LookupLocal)))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatientService_SearchPatients_UnsupportedHospital(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	filters := map[string]interface{}{"passport_id": "AA1234567"}
+	mockRepo.On("Search", "hospital-z", filters).Return([]*entity.Patient{}, nil)
+
+	result, err := service.SearchPatients(context.Background(), filters, "hospital-z")
+
+	assert.NoError(t, err)
+	assert.Empty(t, result.Patients)
+	assert.Equal(t, SourceStatusNotFound, result.Sources[0].Status)
+	assert.Equal(t, SearchSource{Name: "hospital-z", Status: SourceStatusUnsupported}, result.Sources[1])
+	assert.False(t, result.UpstreamFailed())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatientService_SearchPatients_RepositoryError(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	filters := map[string]interface{}{"first_name": "John"}
+	mockRepo.On("Search", "hospital-a", filters).Return(nil, errors.New("connection refused"))
+
+	result, err := service.SearchPatients(context.Background(), filters, "hospital-a")
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func newHospitalServer(t *testing.T, handler http.HandlerFunc) *hospital.Registry {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	registry, err := hospital.NewRegistry([]hospital.Config{{Name: "hospital-a", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestPatientService_SearchPatients_HospitalAPIFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		createErr    error
+		wantStatus   SourceStatus
+		wantPatients int
+		wantWarnings int
+	}{
+		{
+			name: "found and cached",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"national_id":"1234567890121","first_name_en":"Somchai","date_of_birth":"1990-01-01"}`))
+			},
+			wantStatus:   SourceStatusFound,
+			wantPatients: 1,
+		},
+		{
+			name: "found but cache failed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"national_id":"1234567890121","first_name_en":"Somchai","date_of_birth":"1990-01-01"}`))
+			},
+			createErr:    errors.New("duplicate key"),
+			wantStatus:   SourceStatusCacheFailed,
+			wantPatients: 1,
+			wantWarnings: 1,
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantStatus: SourceStatusNotFound,
+		},
+		{
+			name: "unavailable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantStatus:   SourceStatusUnavailable,
+			wantWarnings: 1,
+		},
+		{
+			name: "invalid data",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"national_id":"1234567890121","date_of_birth":"1990-13-01"}`))
+			},
+			wantStatus:   SourceStatusInvalidData,
+			wantWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockPatientRepository)
+			service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newHospitalServer(t, tt.handler), 24*time.Hour)
+
+			filters := map[string]interface{}{"national_id": "1234567890121"}
+			mockRepo.On("Search", "hospital-a", filters).Return([]*entity.Patient{}, nil)
+			if tt.wantPatients > 0 {
+				mockRepo.On("GetByIdentifiers", "1234567890121", "").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("Create", mock.AnythingOfType("*entity.Patient")).Return(tt.createErr)
+			}
+
+			result, err := service.SearchPatients(context.Background(), filters, "hospital-a")
+
+			assert.NoError(t, err)
+			assert.Len(t, result.Patients, tt.wantPatients)
+			assert.Equal(t, tt.wantStatus, result.Sources[1].Status)
+			assert.Len(t, result.Warnings, tt.wantWarnings)
+			if tt.wantPatients > 0 {
+				assert.Equal(t, "hospital-a", result.Patients[0].Hospitals[0].Hospital)
+				assert.NotNil(t, result.Patients[0].Hospitals[0].LastSyncedAt)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatientService_SearchPatients_OtherHospitalPatient(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	registry := newHospitalServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"national_id":"1234567890121","first_name_en":"Somchai","date_of_birth":"1990-01-01","patient_hn":"HN-A"}`))
+	})
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), registry, 24*time.Hour)
+
+	// Known locally from hospital-b only: hidden from hospital-a's search, so
+	// hospital-a's API is asked and the patient is linked to it.
+	existing := &entity.Patient{
+		ID:          uuid.New(),
+		NationalID:  "1234567890121",
+		FirstNameEN: "Somchai",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Hospitals:   []entity.PatientHospital{{Hospital: "hospital-b", PatientHN: "HN-B"}},
+	}
+	filters := map[string]interface{}{"national_id": "1234567890121"}
+	mockRepo.On("Search", "hospital-a", filters).Return([]*entity.Patient{}, nil)
+	mockRepo.On("GetByIdentifiers", "1234567890121", "").Return(existing, nil)
+	mockRepo.On("Update", existing).Return(nil)
+	mockRepo.On("SaveHospitalLink", mock.MatchedBy(func(link *entity.PatientHospital) bool {
+		return link.Hospital == "hospital-a" && link.PatientHN == "HN-A" && link.Source == entity.PatientSourceAPI
+	})).Return(nil)
+
+	result, err := service.SearchPatients(context.Background(), filters, "hospital-a")
+
+	assert.NoError(t, err)
+	require.Len(t, result.Patients, 1)
+	assert.Equal(t, existing.ID, result.Patients[0].ID)
+	assert.Equal(t, SourceStatusFound, result.Sources[1].Status)
+	assert.Len(t, existing.Hospitals, 2)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestPatientService_RefreshPatient_AppliesChanges(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	registry := newHospitalServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"national_id":"1234567890121","first_name_en":"Somchai","phone_number":"0899999999","date_of_birth":"1990-01-01","patient_hn":"HN1"}`))
+	})
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), registry, 24*time.Hour)
+
+	lastSynced := time.Now().Add(-48 * time.Hour)
+	patient := &entity.Patient{
+		ID:          uuid.New(),
+		NationalID:  "1234567890121",
+		FirstNameEN: "Somchai",
+		PhoneNumber: "0811111111",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		PatientHN:   "HN1",
+		Hospitals: []entity.PatientHospital{
+			{Hospital: "hospital-a", PatientHN: "HN1", Source: entity.PatientSourceAPI, LastSyncedAt: &lastSynced},
+		},
+	}
+	patient.Hospitals[0].PatientID = patient.ID
+
+	mockRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+	mockRepo.On("Update", patient).Return(nil)
+	mockRepo.On("SaveHospitalLink", mock.MatchedBy(func(link *entity.PatientHospital) bool {
+		return link.Hospital == "hospital-a" && link.SyncError == "" && link.LastSyncedAt.After(lastSynced)
+	})).Return(nil)
+
+	result, err := service.RefreshPatient(context.Background(), patient.ID.String(), "hospital-a")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"phone_number"}, result.Changed)
+	assert.Equal(t, "0899999999", result.Patient.PhoneNumber)
+	assert.False(t, result.Patient.Hospitals[0].Stale)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatientService_RefreshPatient_RecordsUpstreamFailure(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	registry := newHospitalServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), registry, 24*time.Hour)
+
+	lastSynced := time.Now().Add(-48 * time.Hour)
+	patient := &entity.Patient{
+		ID:         uuid.New(),
+		PassportID: "AA1234567",
+		Hospitals:  []entity.PatientHospital{{Hospital: "hospital-a", Source: entity.PatientSourceAPI, LastSyncedAt: &lastSynced}},
+	}
+
+	mockRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+	mockRepo.On("SaveHospitalLink", mock.MatchedBy(func(link *entity.PatientHospital) bool {
+		return link.LastSyncedAt.Equal(lastSynced) && link.LastAttemptAt != nil && link.SyncError != ""
+	})).Return(nil)
+
+	result, err := service.RefreshPatient(context.Background(), patient.ID.String(), "hospital-a")
+
+	assert.ErrorIs(t, err, ErrHospitalUnavailable)
+	assert.Nil(t, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatientService_RefreshPatient_NotFound(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), newTestRegistry(t), 24*time.Hour)
+
+	_, err := service.RefreshPatient(context.Background(), "not-a-uuid", "hospital-a")
+
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+}
+
+func TestSyncJob(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/patient/search/AA1234567" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"national_id":"1234567890121","phone_number":"0899999999","date_of_birth":"1990-01-01"}`))
+	}))
+	t.Cleanup(server.Close)
+	registry, err := hospital.NewRegistry([]hospital.Config{{Name: "hospital-a", BaseURL: server.URL, RateLimit: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), registry, 24*time.Hour)
+
+	link := entity.PatientHospital{Hospital: "hospital-a", Source: entity.PatientSourceAPI}
+	updated := &entity.Patient{ID: uuid.New(), NationalID: "1234567890121", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Hospitals: []entity.PatientHospital{link}}
+	failed := &entity.Patient{ID: uuid.New(), PassportID: "AA1234567", Hospitals: []entity.PatientHospital{link}}
+	mockRepo.On("ListStale", "hospital-a", mock.Anything, 2).Return([]*entity.Patient{updated, failed}, nil)
+	mockRepo.On("Update", updated).Return(nil)
+	mockRepo.On("SaveHospitalLink", mock.Anything).Return(nil)
+
+	job := &entity.Job{ID: uuid.New(), Kind: JobKindSync, Hospital: "hospital-a", Payload: `{"limit":2}`}
+	result, err := NewSyncJob(service, registry, 100)(context.Background(), job)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &SyncReport{Checked: 2, Updated: 1, Failed: 1}, result)
+
+	job.Payload = `{"limit":0}`
+	_, err = NewSyncJob(service, registry, 100)(context.Background(), job)
+	assert.ErrorIs(t, err, ErrJobPermanent)
+}
+
+func TestPatientService_RefreshPatient_NotLinked(t *testing.T) {
+	mockRepo := new(MockPatientRepository)
+	registry := newHospitalServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("hospital API called for a patient not linked to it")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), new(MockQuarantineRepository), registry, 24*time.Hour)
+
+	patient := &entity.Patient{
+		ID:         uuid.New(),
+		NationalID: "1234567890121",
+		Hospitals:  []entity.PatientHospital{{Hospital: "hospital-b", Source: entity.PatientSourceAPI}},
+	}
+	mockRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+
+	_, err := service.RefreshPatient(context.Background(), patient.ID.String(), "hospital-a")
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+
+	// Syncing through a hospital the patient is not linked to adds no link.
+	_, err = service.SyncPatient(context.Background(), patient, "hospital-a")
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+	assert.Len(t, patient.Hospitals, 1)
+	mockRepo.AssertNotCalled(t, "SaveHospitalLink", mock.Anything)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+// quarantineInvalidRecord keeps the record behind err for review when err
+// reports a record failing validation and the hospital quarantines invalid
+// records. It reports whether the record was quarantined.
+func quarantineInvalidRecord(
+	quarantineRepository repository.QuarantineRepository,
+	hospitals *hospital.Registry,
+	hospitalName, source, reference string,
+	err error,
+) (bool, error) {
+	var invalid *hospital.InvalidRecordError
+	if !errors.As(err, &invalid) {
+		return false, nil
+	}
+	config, _ := hospitals.Config(hospitalName)
+	if config.InvalidRecords != hospital.InvalidRecordsQuarantine {
+		return false, nil
+	}
+
+	// The record was never stored, so it has no ID of its own.
+	snapshot := patientRecord(invalid.Patient)
+	delete(snapshot, "id")
+	record, err := json.Marshal(snapshot)
+	if err != nil {
+		return false, err
+	}
+	fieldErrors, err := json.Marshal(invalid.Errors)
+	if err != nil {
+		return false, err
+	}
+
+	err = quarantineRepository.Save(&entity.QuarantinedPatient{
+		Hospital:   hospitalName,
+		Source:     source,
+		Reference:  reference,
+		Record:     string(record),
+		Errors:     string(fieldErrors),
+		ReceivedAt: time.Now(),
+	})
+	return err == nil, err
+}
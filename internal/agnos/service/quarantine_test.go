@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+)
+
+// MockQuarantineRepository is a mock implementation of QuarantineRepository
+type MockQuarantineRepository struct {
+	mock.Mock
+}
+
+func (m *MockQuarantineRepository) Save(record *entity.QuarantinedPatient) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func TestIntegrationService_ApplyWebhookEvents_InvalidRecords(t *testing.T) {
+	registry, err := hospital.NewRegistry([]hospital.Config{
+		{Name: "hospital-a", BaseURL: "https://hospital-a.example"},
+		{Name: "hospital-q", BaseURL: "https://hospital-q.example", InvalidRecords: hospital.InvalidRecordsQuarantine},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventRepo := new(MockWebhookEventRepository)
+	quarantineRepo := new(MockQuarantineRepository)
+	service := NewIntegrationService(new(MockPatientRepository), new(MockPatientMergeRepository), eventRepo, quarantineRepo, registry)
+
+	eventRepo.On("Exists", mock.Anything, mock.Anything).Return(false, nil)
+	eventRepo.On("Create", mock.MatchedBy(func(event *entity.WebhookEvent) bool {
+		return event.Hospital == "hospital-q" && event.PatientID == nil
+	})).Return(nil)
+	quarantineRepo.On("Save", mock.MatchedBy(func(record *entity.QuarantinedPatient) bool {
+		return record.Hospital == "hospital-q" &&
+			record.Source == entity.PatientSourceWebhook &&
+			record.Reference == "evt-1" &&
+			record.Errors == `[{"field":"national_id","message":"has an invalid check digit"}]`
+	})).Return(nil)
+
+	// 1234567890123 fails the national ID check digit.
+	events := []WebhookEvent{{
+		ID:      "evt-1",
+		Type:    WebhookEventPatientCreated,
+		Patient: hospital.JSONPatient{NationalID: "1234567890123", DateOfBirth: "1990-01-01"},
+	}}
+
+	rejected := service.ApplyWebhookEvents("hospital-a", events)
+	assert.Equal(t, WebhookEventRejected, rejected[0].Status)
+	assert.Contains(t, rejected[0].Error, "national_id has an invalid check digit")
+
+	quarantined := service.ApplyWebhookEvents("hospital-q", events)
+	assert.Equal(t, WebhookEventQuarantined, quarantined[0].Status)
+
+	eventRepo.AssertNumberOfCalls(t, "Create", 1)
+	quarantineRepo.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestPatientService_SearchPatients_QuarantinesInvalidRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"passport_id":"AA1234567","email":"not-an-email","date_of_birth":"1990-01-01"}`))
+	}))
+	t.Cleanup(server.Close)
+	registry, err := hospital.NewRegistry([]hospital.Config{{Name: "hospital-a", BaseURL: server.URL, InvalidRecords: hospital.InvalidRecordsQuarantine}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := new(MockPatientRepository)
+	quarantineRepo := new(MockQuarantineRepository)
+	service := NewPatientService(mockRepo, new(MockPatientMergeRepository), quarantineRepo, registry, 24*time.Hour)
+
+	filters := map[string]interface{}{"passport_id": "AA1234567"}
+	mockRepo.On("Search", "hospital-a", filters).Return([]*entity.Patient{}, nil)
+	quarantineRepo.On("Save", mock.MatchedBy(func(record *entity.QuarantinedPatient) bool {
+		return record.Source == entity.PatientSourceAPI && record.Reference == "AA1234567" &&
+			record.Record == `{"date_of_birth":"1990-01-01","email":"not-an-email","passport_id":"AA1234567"}`
+	})).Return(nil)
+
+	result, err := service.SearchPatients(context.Background(), filters, "hospital-a")
+
+	assert.NoError(t, err)
+	assert.Empty(t, result.Patients)
+	assert.Equal(t, SourceStatusInvalidData, result.Sources[1].Status)
+	assert.Contains(t, result.Warnings, "hospital-a: invalid record quarantined for review")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	quarantineRepo.AssertExpectations(t)
+}
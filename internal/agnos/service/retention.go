@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// EraseModeDelete removes the patient row altogether.
+	EraseModeDelete = "delete"
+	// EraseModePseudonymize blanks the patient's PII but keeps its ID, so
+	// merges into it and references from other systems still resolve.
+	EraseModePseudonymize = "pseudonymize"
+
+	// retentionBatchSize is how many idle links are listed at a time.
+	retentionBatchSize = 500
+
+	// JobKindRetention applies the retention policies as a background job,
+	// see NewRetentionJob. The retention worker enqueues one every
+	// RETENTION_INTERVAL.
+	JobKindRetention = "retention"
+)
+
+var ErrEraseMode = NewError(ErrValidation, "unsupported_erase_mode", "unsupported erase mode")
+
+// RetentionPolicyReport counts what one hospital's retention policy purged,
+// or would purge on a dry run. Reports are the result of retention jobs, hence
+// the JSON tags.
+type RetentionPolicyReport struct {
+	Hospital string        `json:"hospital"`
+	Source   string        `json:"source"`
+	MaxIdle  time.Duration `json:"-"`
+	// LinksRemoved counts purged links of patients kept for other hospitals.
+	LinksRemoved int `json:"links_removed"`
+	// PatientsErased counts patients deleted as their last link was purged.
+	PatientsErased int `json:"patients_erased"`
+}
+
+type RetentionReport struct {
+	DryRun   bool                    `json:"dry_run"`
+	Policies []RetentionPolicyReport `json:"policies"`
+}
+
+// RetentionJobPayload is the payload of JobKindRetention jobs.
+type RetentionJobPayload struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// NewRetentionJob runs JobKindRetention jobs, whose payload may set dry_run,
+// with the RetentionReport as their result. Unless running dry, the jobs
+// also delete the expired job outputs, which hold exported PII.
+func NewRetentionJob(retentionService RetentionService, jobService JobService) JobHandler {
+	return func(ctx context.Context, job *entity.Job) (any, error) {
+		var payload RetentionJobPayload
+		if err := decodeJobPayload(job, &payload); err != nil {
+			return nil, err
+		}
+		report, err := retentionService.ApplyRetention(ctx, payload.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range report.Policies {
+			if policy.LinksRemoved > 0 || policy.PatientsErased > 0 {
+				slog.InfoContext(ctx, "retention",
+					"job_id", job.ID,
+					"dry_run", report.DryRun,
+					"hospital", policy.Hospital,
+					"source", policy.Source,
+					"max_idle", policy.MaxIdle,
+					"links_removed", policy.LinksRemoved,
+					"patients_erased", policy.PatientsErased,
+				)
+			}
+		}
+		if payload.DryRun {
+			return report, nil
+		}
+
+		purged, err := jobService.PurgeOutputs()
+		if err != nil {
+			return nil, fmt.Errorf("purge job outputs: %w", err)
+		}
+		if purged > 0 {
+			slog.InfoContext(ctx, "retention", "job_id", job.ID, "job_outputs_deleted", purged)
+		}
+		return report, nil
+	}
+}
+
+type RetentionService interface {
+	// ErasePatient erases the PII of a patient linked to hospital in mode,
+	// EraseModeDelete or EraseModePseudonymize, and returns the ID of the
+	// patient erased: the ID of a patient retired by a merge resolves to its
+	// survivor. A patient hospital pseudonymized can still be deleted. Audit
+	// entries about the patient are kept.
+	ErasePatient(id, hospital, mode string) (uuid.UUID, error)
+	// ApplyRetention purges the hospital links idle for longer than their
+	// hospital's retention policy allows, erasing patients left without
+	// links, and reports what was purged. A dry run only reports.
+	ApplyRetention(ctx context.Context, dryRun bool) (*RetentionReport, error)
+}
+
+type retentionService struct {
+	retentionRepository repository.RetentionRepository
+	patientService      PatientService
+	auditService        AuditService
+	hospitals           *hospital.Registry
+}
+
+func NewRetentionService(
+	retentionRepository repository.RetentionRepository,
+	patientService PatientService,
+	auditService AuditService,
+	hospitals *hospital.Registry,
+) RetentionService {
+	return &retentionService{
+		retentionRepository: retentionRepository,
+		patientService:      patientService,
+		auditService:        auditService,
+		hospitals:           hospitals,
+	}
+}
+
+func (s *retentionService) ErasePatient(id, hospital, mode string) (uuid.UUID, error) {
+	if mode != EraseModeDelete && mode != EraseModePseudonymize {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrEraseMode, mode)
+	}
+
+	patient, err := s.patientService.ResolvePatient(id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	switch {
+	case patient.ErasedAt != nil && patient.ErasedHospital != hospital:
+		return uuid.Nil, ErrPatientNotFound
+	case patient.ErasedAt != nil && mode == EraseModePseudonymize:
+		return uuid.Nil, ErrPatientErased
+	case patient.ErasedAt == nil && !linkedTo(patient, hospital):
+		return uuid.Nil, ErrPatientNotFound
+	}
+	if err := s.retentionRepository.ErasePatient(patient.ID.String(), hospital, mode == EraseModePseudonymize); err != nil {
+		return uuid.Nil, err
+	}
+	return patient.ID, nil
+}
+
+func (s *retentionService) ApplyRetention(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{DryRun: dryRun}
+	now := time.Now()
+	for _, hospitalName := range s.hospitals.Names() {
+		config, _ := s.hospitals.Config(hospitalName)
+		for _, policy := range config.Retention {
+			policyReport := RetentionPolicyReport{
+				Hospital: hospitalName,
+				Source:   policy.Source,
+				MaxIdle:  time.Duration(policy.MaxIdle),
+			}
+			err := s.applyPolicy(ctx, &policyReport, now.Add(-policyReport.MaxIdle), dryRun)
+			report.Policies = append(report.Policies, policyReport)
+			if err != nil {
+				return report, fmt.Errorf("retention of %s records from %s: %w", policy.Source, hospitalName, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// applyPolicy purges the links idle since idleSince batch by batch. Each
+// batch of purged patients is audited as AuditActorRetention.
+func (s *retentionService) applyPolicy(ctx context.Context, report *RetentionPolicyReport, idleSince time.Time, dryRun bool) error {
+	after := uuid.Nil
+	for ctx.Err() == nil {
+		links, err := s.retentionRepository.ListIdleLinks(report.Hospital, report.Source, idleSince, after, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var purged []uuid.UUID
+		for _, link := range links {
+			after = link.PatientID
+			if dryRun {
+				if link.OtherLinks > 0 {
+					report.LinksRemoved++
+				} else {
+					report.PatientsErased++
+				}
+				continue
+			}
+
+			removed, erased, err := s.retentionRepository.PurgeIdleLink(link, idleSince)
+			if err != nil {
+				return err
+			}
+			switch {
+			case erased:
+				report.PatientsErased++
+			case removed:
+				report.LinksRemoved++
+			}
+			if removed {
+				purged = append(purged, link.PatientID)
+			}
+		}
+
+		if len(purged) > 0 {
+			err := s.auditService.Record(AuditRecord{
+				Actor:      entity.AuditActorRetention,
+				Hospital:   report.Hospital,
+				Action:     "retention purge " + report.Source,
+				PatientIDs: purged,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(links) < retentionBatchSize {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/repository"
+)
+
+// MockRetentionRepository is a mock implementation of RetentionRepository
+type MockRetentionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRetentionRepository) ErasePatient(id, hospital string, pseudonymize bool) error {
+	args := m.Called(id, hospital, pseudonymize)
+	return args.Error(0)
+}
+
+func (m *MockRetentionRepository) ListIdleLinks(hospital, source string, idleSince time.Time, after uuid.UUID, limit int) ([]*repository.IdleLink, error) {
+	args := m.Called(hospital, source, idleSince, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*repository.IdleLink), args.Error(1)
+}
+
+func (m *MockRetentionRepository) PurgeIdleLink(link *repository.IdleLink, idleSince time.Time) (bool, bool, error) {
+	args := m.Called(link, idleSince)
+	return args.Bool(0), args.Bool(1), args.Error(2)
+}
+
+func newRetentionRegistry(t *testing.T) *hospital.Registry {
+	registry, err := hospital.NewRegistry([]hospital.Config{{
+		Name:      "hospital-a",
+		BaseURL:   "https://hospital-a.example",
+		Retention: []hospital.RetentionPolicy{{Source: entity.PatientSourceAPI, MaxIdle: hospital.Duration(90 * 24 * time.Hour)}},
+	}})
+	require.NoError(t, err)
+	return registry
+}
+
+func TestRetentionService_ErasePatient(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	mergeRepo := new(MockPatientMergeRepository)
+	retentionRepo := new(MockRetentionRepository)
+	patientService := NewPatientService(patientRepo, mergeRepo, nil, newTestRegistry(t), time.Hour)
+	service := NewRetentionService(retentionRepo, patientService, nil, newTestRegistry(t))
+
+	patient := &entity.Patient{ID: uuid.New(), Hospitals: []entity.PatientHospital{{Hospital: "hospital-a"}}}
+	retiredID := uuid.New().String()
+	patientRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+	patientRepo.On("GetByID", retiredID).Return(nil, gorm.ErrRecordNotFound)
+	mergeRepo.On("GetByRetiredID", retiredID).Return(&entity.PatientMerge{SurvivorID: patient.ID}, nil)
+	retentionRepo.On("ErasePatient", patient.ID.String(), "hospital-a", true).Return(nil).Once()
+	retentionRepo.On("ErasePatient", patient.ID.String(), "hospital-a", false).Return(nil).Once()
+
+	id, err := service.ErasePatient(patient.ID.String(), "hospital-a", EraseModePseudonymize)
+	require.NoError(t, err)
+	assert.Equal(t, patient.ID, id)
+
+	// The ID of a retired patient erases the survivor it was merged into.
+	id, err = service.ErasePatient(retiredID, "hospital-a", EraseModeDelete)
+	require.NoError(t, err)
+	assert.Equal(t, patient.ID, id)
+
+	_, err = service.ErasePatient(patient.ID.String(), "hospital-a", "shred")
+	assert.ErrorIs(t, err, ErrEraseMode)
+
+	// Another hospital cannot learn the patient exists.
+	_, err = service.ErasePatient(patient.ID.String(), "hospital-b", EraseModeDelete)
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+
+	retentionRepo.AssertExpectations(t)
+}
+
+func TestRetentionService_ErasePatient_AlreadyErased(t *testing.T) {
+	patientRepo := new(MockPatientRepository)
+	retentionRepo := new(MockRetentionRepository)
+	patientService := NewPatientService(patientRepo, nil, nil, newTestRegistry(t), time.Hour)
+	service := NewRetentionService(retentionRepo, patientService, nil, newTestRegistry(t))
+
+	erasedAt := time.Now()
+	patient := &entity.Patient{ID: uuid.New(), ErasedAt: &erasedAt, ErasedHospital: "hospital-a"}
+	patientRepo.On("GetByID", patient.ID.String()).Return(patient, nil)
+	retentionRepo.On("ErasePatient", patient.ID.String(), "hospital-a", false).Return(nil).Once()
+
+	_, err := service.ErasePatient(patient.ID.String(), "hospital-a", EraseModePseudonymize)
+	assert.ErrorIs(t, err, ErrPatientErased)
+	_, err = service.ErasePatient(patient.ID.String(), "hospital-b", EraseModeDelete)
+	assert.ErrorIs(t, err, ErrPatientNotFound)
+
+	// The hospital that pseudonymized the patient can still delete it.
+	id, err := service.ErasePatient(patient.ID.String(), "hospital-a", EraseModeDelete)
+	require.NoError(t, err)
+	assert.Equal(t, patient.ID, id)
+	retentionRepo.AssertExpectations(t)
+}
+
+func TestRetentionService_ApplyRetention(t *testing.T) {
+	retentionRepo := new(MockRetentionRepository)
+	auditRepo := new(MockAuditRepository)
+	service := NewRetentionService(retentionRepo, nil, NewAuditService(auditRepo), newRetentionRegistry(t))
+
+	unlinked := &repository.IdleLink{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI, OtherLinks: 1}
+	erased := &repository.IdleLink{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI}
+	accessed := &repository.IdleLink{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI}
+	idleSince := mock.MatchedBy(func(idleSince time.Time) bool {
+		return time.Since(idleSince) > 89*24*time.Hour && time.Since(idleSince) < 91*24*time.Hour
+	})
+	retentionRepo.On("ListIdleLinks", "hospital-a", entity.PatientSourceAPI, idleSince, uuid.Nil, retentionBatchSize).
+		Return([]*repository.IdleLink{unlinked, erased, accessed}, nil)
+	retentionRepo.On("PurgeIdleLink", unlinked, idleSince).Return(true, false, nil).Once()
+	retentionRepo.On("PurgeIdleLink", erased, idleSince).Return(true, true, nil).Once()
+	// Accessed since it was listed, so it is kept.
+	retentionRepo.On("PurgeIdleLink", accessed, idleSince).Return(false, false, nil).Once()
+	auditRepo.On("Append", mock.MatchedBy(func(entries []*entity.AuditEntry) bool {
+		return len(entries) == 2 &&
+			*entries[0].PatientID == unlinked.PatientID && *entries[1].PatientID == erased.PatientID &&
+			entries[0].Actor == entity.AuditActorRetention && entries[0].Hospital == "hospital-a"
+	})).Return(nil).Once()
+
+	report, err := service.ApplyRetention(context.Background(), false)
+	require.NoError(t, err)
+	assert.False(t, report.DryRun)
+	require.Len(t, report.Policies, 1)
+	assert.Equal(t, RetentionPolicyReport{
+		Hospital:       "hospital-a",
+		Source:         entity.PatientSourceAPI,
+		MaxIdle:        90 * 24 * time.Hour,
+		LinksRemoved:   1,
+		PatientsErased: 1,
+	}, report.Policies[0])
+
+	retentionRepo.AssertExpectations(t)
+	auditRepo.AssertExpectations(t)
+}
+
+func TestRetentionService_ApplyRetention_DryRun(t *testing.T) {
+	retentionRepo := new(MockRetentionRepository)
+	auditRepo := new(MockAuditRepository)
+	service := NewRetentionService(retentionRepo, nil, NewAuditService(auditRepo), newRetentionRegistry(t))
+
+	retentionRepo.On("ListIdleLinks", "hospital-a", entity.PatientSourceAPI, mock.Anything, uuid.Nil, retentionBatchSize).
+		Return([]*repository.IdleLink{
+			{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI, OtherLinks: 2},
+			{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI},
+			{PatientID: uuid.New(), Hospital: "hospital-a", Source: entity.PatientSourceAPI},
+		}, nil)
+
+	report, err := service.ApplyRetention(context.Background(), true)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Policies, 1)
+	assert.Equal(t, 1, report.Policies[0].LinksRemoved)
+	assert.Equal(t, 2, report.Policies[0].PatientsErased)
+
+	retentionRepo.AssertNotCalled(t, "PurgeIdleLink", mock.Anything, mock.Anything)
+	auditRepo.AssertNotCalled(t, "Append", mock.Anything)
+}
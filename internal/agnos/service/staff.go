@@ -5,12 +5,26 @@ import (
 	"time"
 
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
 	"github.com/Markikie/agnos/internal/agnos/repository"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrStaffFieldsRequired = NewError(ErrValidation, "staff_fields_required", "username, password, and hospital are required")
+	ErrStaffNotFound       = NewError(ErrNotFound, "staff_not_found", "staff not found")
+	ErrStaffExists         = NewError(ErrConflict, "staff_exists", "staff with this username already exists in this hospital")
+	// ErrInvalidCredentials does not tell an unknown username from a wrong
+	// password.
+	ErrInvalidCredentials = NewError(ErrUnauthorized, "invalid_credentials", "invalid credentials")
 )
 
 type StaffService interface {
 	CreateStaff(username, password, hospital string) (*entity.Staff, error)
+	// CreateAdmin creates a staff member with the admin role.
+	CreateAdmin(username, password, hospital string) (*entity.Staff, error)
+	ResetPassword(username, hospital, password string) error
 	Login(username, password, hospital string) (*entity.Staff, error)
 	GetStaffByID(id string) (*entity.Staff, error)
 }
@@ -28,10 +42,25 @@ func NewStaffService(
 }
 
 func (s *staffService) CreateStaff(username, password, hospital string) (*entity.Staff, error) {
+	return s.createStaff(username, password, hospital, entity.StaffRoleStaff)
+}
+
+func (s *staffService) CreateAdmin(username, password, hospital string) (*entity.Staff, error) {
+	return s.createStaff(username, password, hospital, entity.StaffRoleAdmin)
+}
+
+func (s *staffService) createStaff(username, password, hospital, role string) (*entity.Staff, error) {
+	if username == "" || password == "" || hospital == "" {
+		return nil, ErrStaffFieldsRequired
+	}
+
 	// Check if staff already exists
-	existingStaff, _ := s.staffRepository.GetByUsernameAndHospital(username, hospital)
-	if existingStaff != nil {
-		return nil, errors.New("staff with this username already exists in this hospital")
+	_, err := s.staffRepository.GetByUsernameAndHospital(username, hospital)
+	switch {
+	case err == nil:
+		return nil, ErrStaffExists
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
 	}
 
 	// Hash password
@@ -44,6 +73,7 @@ func (s *staffService) CreateStaff(username, password, hospital string) (*entity
 		Username:  username,
 		Password:  string(hashedPassword),
 		Hospital:  hospital,
+		Role:      role,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -58,18 +88,46 @@ func (s *staffService) CreateStaff(username, password, hospital string) (*entity
 
 func (s *staffService) Login(username, password, hospital string) (*entity.Staff, error) {
 	staff, err := s.staffRepository.GetByUsernameAndHospital(username, hospital)
-	if err != nil {
-		return nil, errors.New("invalid credentials")
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return nil, ErrInvalidCredentials
+	case err != nil:
+		return nil, err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(staff.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return nil, ErrInvalidCredentials
 	}
 
+	metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
 	return staff, nil
 }
 
+func (s *staffService) ResetPassword(username, hospital, password string) error {
+	if username == "" || password == "" || hospital == "" {
+		return ErrStaffFieldsRequired
+	}
+
+	staff, err := s.staffRepository.GetByUsernameAndHospital(username, hospital)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrStaffNotFound
+	case err != nil:
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	staff.Password = string(hashedPassword)
+	staff.UpdatedAt = time.Now()
+	return s.staffRepository.Update(staff)
+}
+
 func (s *staffService) GetStaffByID(id string) (*entity.Staff, error) {
 	return s.staffRepository.GetByID(id)
 }
@@ -7,9 +7,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/metrics"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // MockStaffRepository is a mock implementation of StaffRepository
@@ -22,6 +25,11 @@ func (m *MockStaffRepository) Create(staff *entity.Staff) error {
 	return args.Error(0)
 }
 
+func (m *MockStaffRepository) Update(staff *entity.Staff) error {
+	args := m.Called(staff)
+	return args.Error(0)
+}
+
 func (m *MockStaffRepository) GetByUsernameAndHospital(username, hospital string) (*entity.Staff, error) {
 	args := m.Called(username, hospital)
 	if args.Get(0) == nil {
@@ -43,7 +51,7 @@ func TestStaffService_CreateStaff_Success(t *testing.T) {
 	service := NewStaffService(mockRepo)
 
 	// Mock that staff doesn't exist
-	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, errors.New("not found"))
+	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, gorm.ErrRecordNotFound)
 	mockRepo.On("Create", mock.AnythingOfType("*entity.Staff")).Return(nil)
 
 	staff, err := service.CreateStaff("testuser", "password123", "hospital-a")
@@ -78,10 +86,26 @@ func TestStaffService_CreateStaff_UserExists(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, staff)
 	assert.Contains(t, err.Error(), "already exists")
+	assert.ErrorIs(t, err, ErrConflict)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestStaffService_CreateStaff_RepositoryError(t *testing.T) {
+	mockRepo := new(MockStaffRepository)
+	service := NewStaffService(mockRepo)
+
+	dbErr := errors.New("connection refused")
+	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, dbErr)
+
+	staff, err := service.CreateStaff("testuser", "password123", "hospital-a")
+
+	assert.ErrorIs(t, err, dbErr)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.Nil(t, staff)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
 func TestStaffService_Login_Success(t *testing.T) {
 	mockRepo := new(MockStaffRepository)
 	service := NewStaffService(mockRepo)
@@ -95,6 +119,7 @@ func TestStaffService_Login_Success(t *testing.T) {
 	}
 
 	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(existingStaff, nil)
+	logins := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginSuccess))
 
 	staff, err := service.Login("testuser", "password123", "hospital-a")
 
@@ -102,6 +127,7 @@ func TestStaffService_Login_Success(t *testing.T) {
 	assert.NotNil(t, staff)
 	assert.Equal(t, existingStaff.ID, staff.ID)
 	assert.Equal(t, "testuser", staff.Username)
+	assert.Equal(t, logins+1, testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginSuccess)))
 
 	mockRepo.AssertExpectations(t)
 }
@@ -110,17 +136,32 @@ func TestStaffService_Login_InvalidCredentials(t *testing.T) {
 	mockRepo := new(MockStaffRepository)
 	service := NewStaffService(mockRepo)
 
-	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, errors.New("not found"))
+	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, gorm.ErrRecordNotFound)
 
 	staff, err := service.Login("testuser", "wrongpassword", "hospital-a")
 
 	assert.Error(t, err)
 	assert.Nil(t, staff)
 	assert.Contains(t, err.Error(), "invalid credentials")
+	assert.ErrorIs(t, err, ErrUnauthorized)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestStaffService_Login_RepositoryError(t *testing.T) {
+	mockRepo := new(MockStaffRepository)
+	service := NewStaffService(mockRepo)
+
+	dbErr := errors.New("connection refused")
+	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(nil, dbErr)
+
+	staff, err := service.Login("testuser", "password123", "hospital-a")
+
+	assert.ErrorIs(t, err, dbErr)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+	assert.Nil(t, staff)
+}
+
 func TestStaffService_Login_WrongPassword(t *testing.T) {
 	mockRepo := new(MockStaffRepository)
 	service := NewStaffService(mockRepo)
@@ -134,12 +175,14 @@ func TestStaffService_Login_WrongPassword(t *testing.T) {
 	}
 
 	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(existingStaff, nil)
+	logins := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginFailure))
 
 	staff, err := service.Login("testuser", "wrongpassword", "hospital-a")
 
 	assert.Error(t, err)
 	assert.Nil(t, staff)
 	assert.Contains(t, err.Error(), "invalid credentials")
+	assert.Equal(t, logins+1, testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginFailure)))
 
 	mockRepo.AssertExpectations(t)
 }
@@ -165,3 +208,33 @@ func TestStaffService_GetStaffByID_Success(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestStaffService_CreateAdmin(t *testing.T) {
+	mockRepo := new(MockStaffRepository)
+	service := NewStaffService(mockRepo)
+
+	mockRepo.On("GetByUsernameAndHospital", "admin", "hospital-a").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Create", mock.AnythingOfType("*entity.Staff")).Return(nil)
+
+	staff, err := service.CreateAdmin("admin", "password123", "hospital-a")
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StaffRoleAdmin, staff.Role)
+
+	_, err = service.CreateAdmin("admin", "", "hospital-a")
+	assert.ErrorIs(t, err, ErrStaffFieldsRequired)
+}
+
+func TestStaffService_ResetPassword(t *testing.T) {
+	mockRepo := new(MockStaffRepository)
+	service := NewStaffService(mockRepo)
+
+	existingStaff := &entity.Staff{Username: "testuser", Hospital: "hospital-a", Password: "old-hash"}
+	mockRepo.On("GetByUsernameAndHospital", "testuser", "hospital-a").Return(existingStaff, nil)
+	mockRepo.On("GetByUsernameAndHospital", "nobody", "hospital-a").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Update", existingStaff).Return(nil)
+
+	assert.NoError(t, service.ResetPassword("testuser", "hospital-a", "new-password"))
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(existingStaff.Password), []byte("new-password")))
+	assert.ErrorIs(t, service.ResetPassword("nobody", "hospital-a", "new-password"), ErrStaffNotFound)
+}
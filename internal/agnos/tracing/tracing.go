@@ -0,0 +1,93 @@
+// Package tracing sets up OpenTelemetry tracing: spans of HTTP requests,
+// database queries and hospital API calls, with W3C trace context propagated
+// to and from other services.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters Setup accepts.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans over OTLP/HTTP, configured by the standard
+	// OTEL_EXPORTER_OTLP_* environment variables.
+	ExporterOTLP = "otlp"
+)
+
+const (
+	serviceName         = "agnos"
+	instrumentationName = "github.com/Markikie/agnos"
+)
+
+var ErrExporter = errors.New("unknown trace exporter")
+
+// Tracer returns the service's tracer, from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the W3C trace context propagator and a global tracer
+// provider batching spans to exporter; the stdout exporter writes to w. The
+// returned function flushes pending spans and stops the provider. With
+// ExporterNone spans are not recorded, but trace context is still propagated.
+func Setup(ctx context.Context, exporter string, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrExporter, exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := newProvider(sdktrace.WithBatcher(spanExporter))
+	return provider.Shutdown, nil
+}
+
+// SetupInMemory installs a global tracer provider recording spans, as they
+// end, in the returned exporter, so tests can assert them.
+func SetupInMemory() *tracetest.InMemoryExporter {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter := tracetest.NewInMemoryExporter()
+	newProvider(sdktrace.WithSyncer(exporter))
+	return exporter
+}
+
+func newProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		res = resource.Default()
+	}
+	provider := sdktrace.NewTracerProvider(append(opts, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(provider)
+	return provider
+}
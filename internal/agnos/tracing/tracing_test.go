@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDryRunDB returns a gorm.DB building statements without a database.
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GORMPlugin{}))
+	return db
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestGORMPlugin(t *testing.T) {
+	exporter := SetupInMemory()
+	db := newDryRunDB(t)
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	var patients []entity.Patient
+	db.WithContext(ctx).Where("national_id = ?", "1234567890121").Find(&patients)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	query := spans[0]
+	assert.Equal(t, "db query", query.Name)
+	assert.Equal(t, trace.SpanKindClient, query.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+	assert.Equal(t, parent.SpanContext().TraceID(), query.SpanContext.TraceID())
+
+	attrs := spanAttributes(query)
+	assert.Equal(t, "tbl_patients", attrs["db.collection.name"].AsString())
+	assert.Contains(t, attrs["db.query.text"].AsString(), "national_id = $1")
+	assert.NotContains(t, attrs["db.query.text"].AsString(), "1234567890121")
+}
+
+func TestGORMPlugin_NoContext(t *testing.T) {
+	exporter := SetupInMemory()
+	db := newDryRunDB(t)
+
+	db.Create(&entity.Job{Kind: "retention"})
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "db create", spans[0].Name)
+	assert.False(t, spans[0].Parent.IsValid())
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "zipkin", nil)
+	assert.ErrorIs(t, err, ErrExporter)
+}
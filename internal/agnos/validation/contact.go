@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrPhoneFormat = errors.New("is not a valid Thai or E.164 phone number")
+	ErrEmailFormat = errors.New("is not a valid email address")
+)
+
+const thaiCountryCode = "66"
+
+var (
+	// Thai national significant numbers: 9-digit mobile numbers starting 6, 8
+	// or 9 and 8-digit landlines starting 2 to 7.
+	thaiMobile   = regexp.MustCompile(`^[689][0-9]{8}$`)
+	thaiLandline = regexp.MustCompile(`^[2-7][0-9]{7}$`)
+	e164         = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// NormalizePhone returns phone in E.164 form. Thai numbers may be written
+// nationally (081-234-5678, 02 123 4567) or internationally (+66 81 234 5678,
+// 66812345678); numbers of other countries must carry a + and country code.
+func NormalizePhone(phone string) (string, error) {
+	digits := stripPhoneFormatting(phone)
+
+	var national string
+	switch {
+	case strings.HasPrefix(digits, "+"+thaiCountryCode):
+		national = strings.TrimPrefix(digits, "+"+thaiCountryCode)
+	case strings.HasPrefix(digits, "+"):
+		if !e164.MatchString(digits) {
+			return "", ErrPhoneFormat
+		}
+		return digits, nil
+	case strings.HasPrefix(digits, "0"):
+		national = digits[1:]
+	case strings.HasPrefix(digits, thaiCountryCode):
+		national = strings.TrimPrefix(digits, thaiCountryCode)
+	default:
+		return "", ErrPhoneFormat
+	}
+
+	// A trunk 0 is sometimes kept after the country code: +66 (0)81 234 5678.
+	national = strings.TrimPrefix(national, "0")
+	if !thaiMobile.MatchString(national) && !thaiLandline.MatchString(national) {
+		return "", ErrPhoneFormat
+	}
+	return "+" + thaiCountryCode + national, nil
+}
+
+// CanonicalPhone is the form phone is matched by: its E.164 form, or for
+// numbers that do not validate, the number without formatting, so that
+// differently written copies of a number still compare equal.
+func CanonicalPhone(phone string) string {
+	if normalized, err := NormalizePhone(phone); err == nil {
+		return normalized
+	}
+	return stripPhoneFormatting(phone)
+}
+
+func stripPhoneFormatting(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+}
+
+// CanonicalEmail is the form email is matched by. Local parts are treated as
+// case-insensitive, as virtually all mail providers do.
+func CanonicalEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Email checks that email is a bare RFC 5322 addr-spec, without a display
+// name or angle brackets.
+func Email(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return ErrEmailFormat
+	}
+	return nil
+}
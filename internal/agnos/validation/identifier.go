@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNationalIDFormat   = errors.New("must be 13 digits")
+	ErrNationalIDChecksum = errors.New("has an invalid check digit")
+	ErrPassportFormat     = errors.New("is not a valid passport number")
+	ErrPassportCountry    = errors.New("issuing country must be an ISO 3166-1 alpha-3 code")
+)
+
+// NormalizeNationalID strips the dashes and spaces a Thai national ID is
+// commonly written with, e.g. 1-2345-67890-12-1.
+func NormalizeNationalID(id string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(id))
+}
+
+// NationalID checks a Thai 13-digit national ID, whose last digit is a mod 11
+// check digit over the first twelve weighted 13 down to 2.
+func NationalID(id string) error {
+	if len(id) != 13 {
+		return ErrNationalIDFormat
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return ErrNationalIDFormat
+		}
+		if i < 12 {
+			sum += int(id[i]-'0') * (13 - i)
+		}
+	}
+	if int(id[12]-'0') != (11-sum%11)%10 {
+		return ErrNationalIDChecksum
+	}
+	return nil
+}
+
+// passportFormats are the passport number formats of issuing countries
+// patients commonly present, keyed by ISO 3166-1 alpha-3 code as printed in
+// the passport's machine readable zone.
+var passportFormats = map[string]*regexp.Regexp{
+	"THA": regexp.MustCompile(`^[A-Z]{1,2}[0-9]{6,7}$`),
+	"CHN": regexp.MustCompile(`^(E[0-9]{8}|E[A-HJ-NP-Z][0-9]{7}|G[0-9]{8})$`),
+	"GBR": regexp.MustCompile(`^[0-9]{9}$`),
+	"IND": regexp.MustCompile(`^[A-Z][0-9]{7}$`),
+	"JPN": regexp.MustCompile(`^[A-Z]{2}[0-9]{7}$`),
+	"KOR": regexp.MustCompile(`^[MSRODG]([0-9]{8}|[0-9]{3}[A-Z][0-9]{4})$`),
+	"USA": regexp.MustCompile(`^[A-Z0-9][0-9]{8}$`),
+}
+
+// icaoDocumentNumber is the document number field of an ICAO 9303 machine
+// readable passport, used when the issuing country is unknown or not listed.
+var icaoDocumentNumber = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+
+var countryCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Passport checks number against the format used by the issuing country,
+// given as an ISO 3166-1 alpha-3 code. Countries without a known format, or
+// an empty country, are checked against the ICAO document number format.
+func Passport(number, country string) error {
+	format := icaoDocumentNumber
+	if country != "" {
+		country = strings.ToUpper(country)
+		if !countryCode.MatchString(country) {
+			return ErrPassportCountry
+		}
+		if countryFormat, ok := passportFormats[country]; ok {
+			format = countryFormat
+		}
+	}
+	if !format.MatchString(number) {
+		return ErrPassportFormat
+	}
+	return nil
+}
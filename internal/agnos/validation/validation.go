@@ -0,0 +1,60 @@
+// Package validation checks patient identifiers and contact details against
+// the formats they are issued in.
+package validation
+
+import (
+	"strings"
+)
+
+// FieldError is a validation failure of one field, named as in the API.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects the failures of a record; a nil Errors means it is valid.
+type Errors []FieldError
+
+// Add records err against field when err is not nil.
+func (e *Errors) Add(field string, err error) {
+	if err != nil {
+		*e = append(*e, FieldError{Field: field, Message: err.Error()})
+	}
+}
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+" "+fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Err returns e as an error, or nil when there are no failures.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Patient validates the identifiers and contact details of a patient record.
+// Empty fields are not checked; whether a field is required is up to the
+// caller.
+func Patient(nationalID, passportID, passportCountry, phone, email string) Errors {
+	var errs Errors
+	if nationalID != "" {
+		errs.Add("national_id", NationalID(nationalID))
+	}
+	if passportID != "" {
+		errs.Add("passport_id", Passport(passportID, passportCountry))
+	}
+	if phone != "" {
+		_, err := NormalizePhone(phone)
+		errs.Add("phone_number", err)
+	}
+	if email != "" {
+		errs.Add("email", Email(email))
+	}
+	return errs
+}
@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNationalID(t *testing.T) {
+	tests := []struct {
+		id  string
+		err error
+	}{
+		{id: "1234567890121"},
+		{id: "3100701443816"},
+		{id: "1234567890123", err: ErrNationalIDChecksum},
+		{id: "123456789012", err: ErrNationalIDFormat},
+		{id: "12345678901a1", err: ErrNationalIDFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.err, NationalID(tt.id))
+		})
+	}
+}
+
+func TestNormalizeNationalID(t *testing.T) {
+	assert.Equal(t, "1234567890121", NormalizeNationalID(" 1-2345-67890-12-1 "))
+}
+
+func TestPassport(t *testing.T) {
+	tests := []struct {
+		number  string
+		country string
+		err     error
+	}{
+		{number: "AA1234567", country: "THA"},
+		{number: "AA1234567", country: "tha"},
+		{number: "A123456", country: "THA"},
+		{number: "123456789", country: "THA", err: ErrPassportFormat},
+		{number: "TR1234567", country: "JPN"},
+		{number: "E12345678", country: "CHN"},
+		{number: "AB1234567", country: "USA", err: ErrPassportFormat},
+		{number: "AB1234567", country: "TH", err: ErrPassportCountry},
+		{number: "X1234567", country: "FRA"},
+		{number: "AA1234567"},
+		{number: "aa1234567", err: ErrPassportFormat},
+		{number: "AA12", err: ErrPassportFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.country+"/"+tt.number, func(t *testing.T) {
+			assert.Equal(t, tt.err, Passport(tt.number, tt.country))
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+		err   error
+	}{
+		{phone: "0812345678", want: "+66812345678"},
+		{phone: "081-234-5678", want: "+66812345678"},
+		{phone: "+66 81 234 5678", want: "+66812345678"},
+		{phone: "+66 (0)81 234 5678", want: "+66812345678"},
+		{phone: "66812345678", want: "+66812345678"},
+		{phone: "02 123 4567", want: "+6621234567"},
+		{phone: "+1 415 555 2671", want: "+14155552671"},
+		{phone: "081234567", err: ErrPhoneFormat},
+		{phone: "0112345678", err: ErrPhoneFormat},
+		{phone: "4155552671", err: ErrPhoneFormat},
+		{phone: "+0812345678", err: ErrPhoneFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			got, err := NormalizePhone(tt.phone)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCanonicalPhone(t *testing.T) {
+	for _, phone := range []string{"081-234-5678", "0812345678", "+66812345678", "+66 (0)81 234 5678"} {
+		assert.Equal(t, "+66812345678", CanonicalPhone(phone), phone)
+	}
+	assert.Equal(t, "12345", CanonicalPhone(" 12-345 "))
+	assert.Equal(t, "", CanonicalPhone(""))
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	assert.Equal(t, "somchai@example.com", CanonicalEmail(" Somchai@Example.COM"))
+}
+
+func TestEmail(t *testing.T) {
+	assert.NoError(t, Email("somchai@example.com"))
+	assert.NoError(t, Email("somchai.jaidee+clinic@mail.example.co.th"))
+	assert.Equal(t, ErrEmailFormat, Email("somchai"))
+	assert.Equal(t, ErrEmailFormat, Email("somchai@"))
+	assert.Equal(t, ErrEmailFormat, Email("Somchai <somchai@example.com>"))
+	assert.Equal(t, ErrEmailFormat, Email("somchai@example.com "))
+}
+
+func TestPatient(t *testing.T) {
+	errs := Patient("1234567890123", "AA1234567", "THA", "12345", "somchai@example.com")
+
+	assert.Equal(t, Errors{
+		{Field: "national_id", Message: ErrNationalIDChecksum.Error()},
+		{Field: "phone_number", Message: ErrPhoneFormat.Error()},
+	}, errs)
+	assert.Equal(t, "national_id has an invalid check digit; phone_number is not a valid Thai or E.164 phone number", errs.Error())
+	assert.NoError(t, Patient("1234567890121", "", "", "", "").Err())
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// DuplicateWorker periodically scores new candidate pairs of possibly
+// duplicated patients and queues likely ones for review.
+type DuplicateWorker struct {
+	mergeService service.MergeService
+	interval     time.Duration
+	batchSize    int
+}
+
+func NewDuplicateWorker(
+	mergeService service.MergeService,
+	interval time.Duration,
+	batchSize int,
+) *DuplicateWorker {
+	return &DuplicateWorker{
+		mergeService: mergeService,
+		interval:     interval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run scores pairs every interval until ctx is cancelled.
+func (w *DuplicateWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.detect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// detect drains the unscored pairs batch by batch.
+func (w *DuplicateWorker) detect(ctx context.Context) {
+	var scored, flagged int
+	for ctx.Err() == nil {
+		n, f, err := w.mergeService.DetectDuplicates(ctx, w.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("duplicate detection failed", "error", err)
+			}
+			break
+		}
+		scored += n
+		flagged += f
+		if n < w.batchSize {
+			break
+		}
+	}
+
+	if scored > 0 {
+		slog.Info("duplicate detection", "scored", scored, "flagged", flagged)
+	}
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// JobWorker runs queued background jobs on a pool of goroutines. Each claims
+// one job at a time, so any number of JobWorkers across processes share the
+// queue.
+type JobWorker struct {
+	jobService   service.JobService
+	workers      int
+	pollInterval time.Duration
+}
+
+func NewJobWorker(
+	jobService service.JobService,
+	workers int,
+	pollInterval time.Duration,
+) *JobWorker {
+	return &JobWorker{
+		jobService:   jobService,
+		workers:      workers,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run runs jobs until ctx is cancelled, and returns once the jobs running
+// then have stopped.
+func (w *JobWorker) Run(ctx context.Context) {
+	var pool sync.WaitGroup
+	for range w.workers {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			w.poll(ctx)
+		}()
+	}
+	pool.Wait()
+}
+
+// poll runs due jobs back to back, and waits pollInterval when there are none.
+func (w *JobWorker) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := w.jobService.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("run job failed", "error", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// RetentionWorker periodically enqueues a retention job, which applies the
+// hospitals' retention policies, or only reports what they would purge when
+// running dry. See service.NewRetentionJob.
+type RetentionWorker struct {
+	jobService service.JobService
+	interval   time.Duration
+	dryRun     bool
+}
+
+func NewRetentionWorker(
+	jobService service.JobService,
+	interval time.Duration,
+	dryRun bool,
+) *RetentionWorker {
+	return &RetentionWorker{
+		jobService: jobService,
+		interval:   interval,
+		dryRun:     dryRun,
+	}
+}
+
+// Run enqueues a job every interval until ctx is cancelled. The jobs span
+// all hospitals, so they belong to none.
+func (w *RetentionWorker) Run(ctx context.Context) {
+	schedule(ctx, w.jobService, w.interval, []service.JobRequest{{
+		Kind:      service.JobKindRetention,
+		CreatedBy: "worker:retention",
+		Payload:   service.RetentionJobPayload{DryRun: w.dryRun},
+	}})
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/entity"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// schedule enqueues a job of each of reqs every interval until ctx is
+// cancelled; the JobWorkers run them. A request whose previous job has not
+// finished is skipped, so jobs slower than the interval do not pile up.
+func schedule(ctx context.Context, jobService service.JobService, interval time.Duration, reqs []service.JobRequest) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := make([]*entity.Job, len(reqs))
+	for {
+		for i, req := range reqs {
+			if previous[i] != nil {
+				job, err := jobService.GetJob(previous[i].ID.String(), req.Hospital)
+				if err != nil && !errors.Is(err, service.ErrJobNotFound) {
+					slog.Error("get scheduled job failed", "kind", req.Kind, "hospital", req.Hospital, "error", err)
+					continue
+				}
+				if err == nil && !job.Finished() {
+					continue
+				}
+			}
+
+			job, err := jobService.Enqueue(req)
+			if err != nil {
+				slog.Error("schedule job failed", "kind", req.Kind, "hospital", req.Hospital, "error", err)
+				continue
+			}
+			previous[i] = job
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
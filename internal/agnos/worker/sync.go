@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Markikie/agnos/internal/agnos/hospital"
+	"github.com/Markikie/agnos/internal/agnos/service"
+)
+
+// SyncWorker periodically enqueues a sync job for each configured hospital,
+// which re-fetches its stale patients so local copies pick up changes made at
+// the source. See service.NewSyncJob.
+type SyncWorker struct {
+	jobService service.JobService
+	hospitals  *hospital.Registry
+	interval   time.Duration
+}
+
+func NewSyncWorker(
+	jobService service.JobService,
+	hospitals *hospital.Registry,
+	interval time.Duration,
+) *SyncWorker {
+	return &SyncWorker{
+		jobService: jobService,
+		hospitals:  hospitals,
+		interval:   interval,
+	}
+}
+
+// Run enqueues the jobs every interval until ctx is cancelled.
+func (w *SyncWorker) Run(ctx context.Context) {
+	var reqs []service.JobRequest
+	for _, name := range w.hospitals.Names() {
+		reqs = append(reqs, service.JobRequest{Kind: service.JobKindSync, Hospital: name, CreatedBy: "worker:sync"})
+	}
+	schedule(ctx, w.jobService, w.interval, reqs)
+}
@@ -9,6 +9,13 @@ mkdir -p ssl logs/nginx
 echo "Generating self-signed SSL certificate..."
 bash scripts/generate-ssl.sh
 
+# Generate the key signing patient export bundles, kept in .env so bundles
+# stay verifiable across restarts
+if ! grep -qs '^EXPORT_SIGNING_KEY=' .env; then
+    echo "Generating EXPORT_SIGNING_KEY in .env..."
+    echo "EXPORT_SIGNING_KEY=$(openssl rand -hex 32)" >> .env
+fi
+
 # Build and start services
 echo "Building and starting Docker services..."
 docker-compose down